@@ -8,20 +8,131 @@
 #define PATH_MAX_LEN 256
 #define COMMAND_LINE_LEN 512
 #define NAME_MAX 128
-#define MAX_ARGC_FOR_CMD 16
-#define MAX_ARGS_TO_READ 4
-#define ARGV0_READ_LEN 256
-#define CMD_LINE_SAFETY_MARGIN 64
+#define ARGS_CHUNK_LEN 1024
+#define MAX_ARG_READS 256
+#define MAX_ARGS_CHUNKS 32
+#define MAX_DIR_DEPTH 16
+#define MAX_PATH_DEPTH 32
+#define PATH_WALK_MASK (PATH_MAX_LEN - 1)
 #define EVENT_TYPE_EXEC 1
 #define EVENT_TYPE_FILE_OPEN 2
 #define EVENT_TYPE_CONNECT 3
+#define EVENT_TYPE_EXIT 4
+#define EVENT_TYPE_PRIVILEGE 5
+#define EVENT_TYPE_KERNEL_LOAD 6
+#define EVENT_TYPE_PTRACE 7
+#define EVENT_TYPE_DNS 8
+#define EVENT_TYPE_LISTEN 9
+#define EVENT_TYPE_NAMESPACE 10
+#define EVENT_TYPE_SIGNAL 11
+#define EVENT_TYPE_EXEC_ARGS 12
+#define EVENT_TYPE_SLOTS 16
+
+#define ARGS_FLAG_ENV 0x01
+#define ARGS_FLAG_TRUNCATED 0x02
+#define ARGS_FLAG_LAST 0x04
+
+#define LOAD_KIND_MODULE 1
+#define LOAD_KIND_BPF 2
+
+#ifndef BPF_OBJ_NAME_LEN
+#define BPF_OBJ_NAME_LEN 16
+#endif
+
+#define PRIV_OP_SETUID 1
+#define PRIV_OP_SETGID 2
+#define PRIV_OP_CAPSET 3
+
+#define PTRACE_MODE_ATTACH 0x02
+
+#define EXEC_FLAG_FILELESS 0x01
+#define EXEC_FLAG_DELETED 0x02
+
+#define DNS_PORT 53
+#define DNS_PAYLOAD_LEN 512
+#define DNS_TRANSPORT_UDP 1
+#define DNS_TRANSPORT_TCP 2
+#define SOCK_STREAM 1
+#define SOCK_DGRAM 2
+
+#define LISTEN_OP_BIND 1
+#define LISTEN_OP_LISTEN 2
+#define LISTEN_OP_CLOSE 3
+#define LISTEN_RULE_ALLOW 0
+#define PTRACE_RULE_ALLOW 0
+#define EXEC_RULE_ALLOW 0
+#define IPPROTO_TCP 6
+#define IPPROTO_UDP 17
+
+#define NS_OP_SETNS 1
+#define NS_OP_UNSHARE 2
+#define NS_OP_MOUNT 3
+#define NS_OP_MOVE_MOUNT 4
+#define FSTYPE_LEN 16
+#define CLONE_NEWNS 0x00020000
+#define CLONE_NEWUTS 0x04000000
+#define CLONE_NEWUSER 0x10000000
+#define CLONE_NEWPID 0x20000000
+#define CLONE_NEWNET 0x40000000
+
+// File map values are u16 bitmasks: FILE_MONITOR reports every operation on
+// the path and FILE_BLOCKS(op) denies and reports that operation. Opens are
+// further split by the FILE_ACCESS_* bits derived from their flags.
+#define FILE_OP_READ 1
+#define FILE_OP_WRITE 2
+#define FILE_OP_DELETE 3
+#define FILE_OP_RENAME 4
+#define FILE_OP_CHMOD 5
+#define FILE_ACCESS_APPEND 6
+#define FILE_ACCESS_CREATE 7
+#define FILE_ACCESS_TRUNCATE 8
+#define FILE_MONITOR 0x01
+#define FILE_BLOCKS(op) (1 << (op))
+// Set alongside deny bits by kill and kill_tree rules. They apply to every
+// operation the path's mask denies.
+#define FILE_KILL 0x200
+#define FILE_KILL_TREE 0x400
+
+#define FMODE_READ 0x1
+#define FMODE_WRITE 0x2
+#define O_WRONLY 01
+#define O_CREAT 0100
+#define O_TRUNC 01000
+#define O_APPEND 02000
+#define ATTR_MODE (1 << 0)
+#define ATTR_UID (1 << 1)
+#define ATTR_GID (1 << 2)
+#define ATTR_SIZE (1 << 3)
 
 #define EPERM 1
+#define AF_UNIX 1
 #define AF_INET 2
 #define AF_INET6 10
+#define UNIX_PATH_LEN 108
+
+#define SIGKILL 9
+#define SIGSTOP 19
 
+// Actions are ordered by strength so map merges keep the largest. Every action
+// from ACTION_BLOCK up denies the operation.
 #define ACTION_MONITOR 1
 #define ACTION_BLOCK 2
+#define ACTION_KILL 3
+#define ACTION_KILL_TREE 4
+
+#ifndef container_of
+#define container_of(ptr, type, member) \
+    ((type*)((void*)(ptr) - bpf_core_field_offset(type, member)))
+#endif
+
+// Namespace inode numbers, as in /proc/<pid>/ns.
+struct ns_ids {
+    u32 pid;
+    u32 mnt;
+    u32 net;
+    u32 user;
+    u32 uts;
+};
 
 struct aegis_event_header {
     u64 timestamp_ns;
@@ -32,26 +143,48 @@ struct aegis_event_header {
     u32 gid;
     u8  type;
     u8  blocked;
-    u8  _pad[6];
+    u8  response;     // the ACTION_* applied when blocked
+    u8  self_protect; // blocked to protect Aegis itself
+    u8  path_cut;     // the event's path is only the tail of the real one
+    u8  _pad[3];
     char comm[TASK_COMM_LEN];
+    struct ns_ids ns;
+    u8  _ns_pad[4];
 };
 
 struct exec_event {
     struct aegis_event_header hdr;
     u32 ppid;
-    u8  _pad[4];
+    u8  exec_flags;
+    u8  _pad[3];
+    u64 args_seq; // matches the exec_args records sent ahead of this event
     char pcomm[TASK_COMM_LEN];
     char filename[PATH_MAX_LEN];
     char command_line[COMMAND_LINE_LEN];
 };
 
+// One piece of an exec's argv (or envp) as NUL-separated strings. The
+// records of one exec share seq and are numbered by chunk; their data
+// concatenated in order is the whole vector. Only len bytes of data are sent.
+struct exec_args_event {
+    struct aegis_event_header hdr;
+    u64 seq;
+    u16 chunk;
+    u8  flags;
+    u8  _pad;
+    u32 len;
+    char data[ARGS_CHUNK_LEN * 2];
+};
+
 struct file_event {
     struct aegis_event_header hdr;
     u64 ino;
     u64 dev;
     u32 flags;
-    u8  _pad[4];
+    u32 op;
     char filename[PATH_MAX_LEN];
+    u64 dir_ino;
+    u64 dir_dev;
 };
 
 struct connect_event {
@@ -60,6 +193,113 @@ struct connect_event {
     u16 family;
     u16 port;
     u8  addr_v6[16];
+    char unix_path[UNIX_PATH_LEN];
+    u8  _pad[4];
+};
+
+struct exit_event {
+    struct aegis_event_header hdr;
+    u32 ppid;
+    u32 exit_code;
+    u32 signal;
+    u8  core_dumped;
+    u8  _pad[3];
+    u64 runtime_ns;
+};
+
+// Credential transition. uid/gid fields are always filled from both creds so
+// one layout covers setuid, setgid and capset.
+struct privilege_event {
+    struct aegis_event_header hdr;
+    u32 op;
+    u32 old_uid;
+    u32 old_euid;
+    u32 old_gid;
+    u32 old_egid;
+    u32 new_uid;
+    u32 new_euid;
+    u32 new_gid;
+    u32 new_egid;
+    u8  _pad[4];
+    u64 old_caps;
+    u64 new_caps;
+};
+
+// Kernel module or BPF program load. name is the module path (empty for
+// init_module from memory) or the BPF program name.
+struct kernel_load_event {
+    struct aegis_event_header hdr;
+    u32 kind;
+    u32 bpf_cmd;
+    u32 prog_type;
+    u8  _pad[4];
+    char name[PATH_MAX_LEN];
+};
+
+// A task asking for attach-level access to another process: ptrace(2),
+// process_vm_readv/writev and /proc/<pid>/mem all pass through
+// ptrace_access_check. The header describes the tracer.
+struct ptrace_event {
+    struct aegis_event_header hdr;
+    u32 target_pid;
+    u32 mode;
+    char target_comm[TASK_COMM_LEN];
+};
+
+// A signal sent to another process, as checked by task_kill.
+struct signal_event {
+    struct aegis_event_header hdr;
+    u32 target_pid;
+    u32 sig;
+    char target_comm[TASK_COMM_LEN];
+};
+
+// A DNS message sent to or received from port 53. Queries carry the sending
+// process; replies are delivered in softirq context and carry the process
+// that sent the query on that socket. addr is the resolver.
+struct dns_event {
+    struct aegis_event_header hdr;
+    u16 family;
+    u16 port;
+    u8  transport;
+    u8  response;
+    u8  _pad[2];
+    u32 addr_v4;
+    u8  addr_v6[16];
+    u32 len;
+    u8  payload[DNS_PAYLOAD_LEN];
+};
+
+// A UDP socket bound to a fixed port, or a TCP socket entering the listening
+// state. When such a socket is freed the same record is sent again with op
+// LISTEN_OP_CLOSE. cookie is the kernel socket cookie.
+struct listen_event {
+    struct aegis_event_header hdr;
+    u64 cookie;
+    u16 family;
+    u16 port;
+    u8  protocol;
+    u8  op;
+    u8  _pad[2];
+    u32 addr_v4;
+    u8  addr_v6[16];
+    u32 backlog;
+};
+
+// setns, unshare and mounts. For setns/unshare the header holds the
+// namespaces before the call and new_ns those after it; the pid entry of
+// new_ns is pid_ns_for_children, the only one the syscalls move. For mounts
+// flags are the MS_* flags and new_ns is unused.
+struct ns_event {
+    struct aegis_event_header hdr;
+    u8  op;
+    u8  _pad[3];
+    u32 flags;
+    struct ns_ids new_ns;
+    u8  _pad2[4];
+    char fstype[FSTYPE_LEN];
+    char source[PATH_MAX_LEN];
+    char target[PATH_MAX_LEN];
 };
 
 struct {
@@ -67,13 +307,67 @@ struct {
     __uint(max_entries, 2 * 1024 * 1024);
 } events SEC(".maps");
 
+// Records the ring buffer had no room for, per CPU and event type. Blocked
+// counts the drops whose hook denied the operation: the denial still
+// happened, but userspace never heard of it.
+struct ringbuf_drop {
+    u64 events;
+    u64 blocked;
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, EVENT_TYPE_SLOTS);
+    __type(key, u32);
+    __type(value, struct ringbuf_drop);
+} ringbuf_drops SEC(".maps");
+
 struct {
     __uint(type, BPF_MAP_TYPE_HASH);
     __uint(max_entries, 1024);
     __type(key, char[PATH_MAX_LEN]);
-    __type(value, u8);
+    __type(value, u16);
 } monitored_files SEC(".maps");
 
+// Per-workload variants of monitored_files and blocked_ports, used for rules
+// with a cgroup_id condition.
+struct scoped_path_key {
+    u64 cgroup_id;
+    char path[PATH_MAX_LEN];
+};
+
+struct scoped_port_key {
+    u64 cgroup_id;
+    u16 port;
+    u8  _pad[6];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 1024);
+    __type(key, struct scoped_path_key);
+    __type(value, u16);
+} scoped_files SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 1024);
+    __type(key, struct scoped_port_key);
+    __type(value, u8);
+} scoped_ports SEC(".maps");
+
+struct dir_key {
+    u64 ino;
+    u64 dev;
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 1024);
+    __type(key, struct dir_key);
+    __type(value, u16);
+} monitored_dirs SEC(".maps");
+
 struct {
     __uint(type, BPF_MAP_TYPE_HASH);
     __uint(max_entries, 1024);
@@ -81,6 +375,249 @@ struct {
     __type(value, u8);
 } blocked_ports SEC(".maps");
 
+// Exec rules keyed by the new image name and the parent's comm. An empty
+// string on either side is a wildcard; an EXEC_RULE_ALLOW entry overrides any
+// block, as an allow rule does in userspace.
+struct exec_rule_key {
+    char comm[TASK_COMM_LEN];
+    char pcomm[TASK_COMM_LEN];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 1024);
+    __type(key, struct exec_rule_key);
+    __type(value, u8);
+} exec_rules SEC(".maps");
+
+// Ptrace rules keyed by the target's comm and the tracer's comm. An empty
+// string on either side is a wildcard; a PTRACE_RULE_ALLOW entry overrides
+// any block, as an allow rule does in userspace.
+struct ptrace_rule_key {
+    char target[TASK_COMM_LEN];
+    char tracer[TASK_COMM_LEN];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 1024);
+    __type(key, struct ptrace_rule_key);
+    __type(value, u8);
+} ptrace_rules SEC(".maps");
+
+// Listen rules keyed by the listener's comm and local port. An empty comm or
+// port 0 is a wildcard; a LISTEN_RULE_ALLOW entry overrides any block.
+struct listen_rule_key {
+    char comm[TASK_COMM_LEN];
+    u16 port;
+    u8  _pad[6];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 1024);
+    __type(key, struct listen_rule_key);
+    __type(value, u8);
+} listen_rules SEC(".maps");
+
+// Reported listeners by socket cookie, so sk_free_security can report them
+// closed with the owner they were opened by.
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 8192);
+    __type(key, u64);
+    __type(value, struct listen_event);
+} listen_socks SEC(".maps");
+
+// Destination rules. The port (network order, 0 = any port) is part of the
+// trie key so "1.2.3.4:443" and "10.0.0.0/8" share one map; its 16 bits are
+// always covered by the prefix length.
+struct ipv4_lpm_key {
+    u32 prefixlen;
+    u8  port[2];
+    u8  addr[4];
+    u8  _pad[2];
+};
+
+struct ipv6_lpm_key {
+    u32 prefixlen;
+    u8  port[2];
+    u8  addr[16];
+    u8  _pad[2];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct ipv4_lpm_key);
+    __type(value, u8);
+} blocked_v4 SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct ipv6_lpm_key);
+    __type(value, u8);
+} blocked_v6 SEC(".maps");
+
+// Addresses resolved for dest_domain rules, filled from DNS replies by
+// userspace. port is in network order; 0 matches any port.
+struct domain_v4_key {
+    u8  addr[4];
+    u16 port;
+    u8  _pad[2];
+};
+
+struct domain_v6_key {
+    u8  addr[16];
+    u16 port;
+    u8  _pad[2];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 8192);
+    __type(key, struct domain_v4_key);
+    __type(value, u8);
+} domain_v4 SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 8192);
+    __type(key, struct domain_v6_key);
+    __type(value, u8);
+} domain_v6 SEC(".maps");
+
+// dest_socket rules keyed by AF_UNIX path. Exact rules include the trailing
+// NUL in the prefix, prefix rules do not. Abstract names keep their leading
+// NUL byte.
+struct unix_lpm_key {
+    u32  prefixlen;
+    char path[UNIX_PATH_LEN];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct unix_lpm_key);
+    __type(value, u8);
+} unix_sockets SEC(".maps");
+
+// UDP sockets that sent a DNS query, so replies can be attributed to the
+// querying process.
+struct dns_owner {
+    u64 cgroup_id;
+    u32 pid;
+    u32 tid;
+    u32 uid;
+    u32 gid;
+    char comm[TASK_COMM_LEN];
+    struct ns_ids ns;
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 4096);
+    __type(key, u64);
+    __type(value, struct dns_owner);
+} dns_socks SEC(".maps");
+
+// Load policy per LOAD_KIND_*. Loads are always reported; ACTION_BLOCK and
+// stronger deny them unless the module path or the loader's comm is
+// allowlisted. Allowlist values are bitmasks of (1 << LOAD_KIND_*).
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 3);
+    __type(key, u32);
+    __type(value, u8);
+} load_policy SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 256);
+    __type(key, char[PATH_MAX_LEN]);
+    __type(value, u8);
+} load_allow_paths SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 256);
+    __type(key, char[TASK_COMM_LEN]);
+    __type(value, u8);
+} load_allow_comms SEC(".maps");
+
+// Written by the loader on every start rather than set as constants, so
+// programs left pinned across a daemon restart follow the new daemon and its
+// settings. tgid is Aegis's own, whose bpf() calls are never reported or
+// blocked; it is cleared when the daemon stops. With self_protection on,
+// SIGKILL, SIGSTOP, ptrace attach, link detaches, write access to Aegis's
+// maps and removing pins aimed at Aegis are denied to everyone but the admin
+// uids and executables below, whether or not the daemon is running.
+// capture_env streams envp as well as argv.
+struct aegis_config {
+    u32 tgid;
+    u8  self_protection;
+    u8  capture_env;
+    u8  _pad[2];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, u32);
+    __type(value, struct aegis_config);
+} aegis_config SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 64);
+    __type(key, u32);
+    __type(value, u8);
+} protect_admin_uids SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 64);
+    __type(key, struct dir_key);
+    __type(value, u8);
+} protect_admin_exes SEC(".maps");
+
+// IDs of the links Aegis attached, filled in by the loader.
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 128);
+    __type(key, u32);
+    __type(value, u8);
+} aegis_links SEC(".maps");
+
+// IDs of Aegis's maps, filled in by the loader.
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 64);
+    __type(key, u32);
+    __type(value, u8);
+} aegis_maps SEC(".maps");
+
+// The bpffs directories holding Aegis's pins, filled in by the loader when
+// pinning. Dropping the last pin of a link while the daemon is down detaches
+// it.
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 8);
+    __type(key, struct dir_key);
+    __type(value, u8);
+} protect_pins SEC(".maps");
+
+// Set by the loader when inode_setattr takes a leading mnt_idmap (6.8+).
+const volatile u8 setattr_has_idmap = 0;
+
+// The parent each live process was forked from, recorded at fork and exec
+// and dropped at exit. Unlike the process's real_parent it survives the
+// parent's death, so userspace can finish a kill_tree over children that
+// never exec'd and were reparented by the time it reads the event.
 struct {
     __uint(type, BPF_MAP_TYPE_LRU_HASH);
     __uint(max_entries, 32768);
@@ -88,10 +625,55 @@ struct {
     __type(value, u32);
 } pid_to_ppid SEC(".maps");
 
+// argv and envp of a thread inside execve/execveat, saved at syscall entry
+// while they still point into the caller's memory.
+struct exec_argv {
+    u64 argv;
+    u64 envp;
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 4096);
+    __type(key, u32);
+    __type(value, struct exec_argv);
+} exec_argv SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, u32);
+    __type(value, struct exec_args_event);
+} args_scratch SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, u32);
+    __type(value, u64);
+} exec_seq SEC(".maps");
+
+// Namespaces of a thread inside setns/unshare, saved at syscall entry so the
+// exit handler can tell which ones the call replaced.
+struct ns_pending {
+    struct ns_ids ns;
+    u32 flags;
+    u8  op;
+    u8  _pad[3];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 4096);
+    __type(key, u32);
+    __type(value, struct ns_pending);
+} ns_pending SEC(".maps");
+
 struct path_scratch {
     char path_buf[PATH_MAX_LEN];
-    char filename[NAME_MAX];
-    char parent[NAME_MAX];
+    char walk_buf[PATH_MAX_LEN * 2];
+    struct scoped_path_key scoped;
+    bool path_cut; // set by walk_dentries when it stopped short of the root
 };
 
 struct {
@@ -108,6 +690,16 @@ struct {
     __type(value, struct exec_event);
 } event_scratch SEC(".maps");
 
+static __always_inline void count_drop(u32 type, bool blocked)
+{
+    struct ringbuf_drop* drop = bpf_map_lookup_elem(&ringbuf_drops, &type);
+    if (!drop)
+        return;
+    drop->events++;
+    if (blocked)
+        drop->blocked++;
+}
+
 static __always_inline void fill_event_header(
     struct aegis_event_header *hdr,
     u8 type,
@@ -116,6 +708,9 @@ static __always_inline void fill_event_header(
     hdr->timestamp_ns = bpf_ktime_get_ns();
     hdr->type = type;
     hdr->blocked = 0;
+    hdr->response = 0;
+    hdr->self_protect = 0;
+    hdr->path_cut = 0;
     
     u64 pid_tgid = bpf_get_current_pid_tgid();
     hdr->pid = pid_tgid >> 32;
@@ -127,6 +722,16 @@ static __always_inline void fill_event_header(
     
     hdr->cgroup_id = bpf_get_current_cgroup_id();
     bpf_get_current_comm(&hdr->comm, sizeof(hdr->comm));
+
+    // Reads through a NULL nsproxy (an exiting task) leave zeroes. The pid
+    // namespace is the task's own, not pid_ns_for_children.
+    struct pid* pid = BPF_CORE_READ(task, thread_pid);
+    unsigned int level = BPF_CORE_READ(pid, level);
+    hdr->ns.pid = BPF_CORE_READ(pid, numbers[level].ns, ns.inum);
+    hdr->ns.mnt = BPF_CORE_READ(task, nsproxy, mnt_ns, ns.inum);
+    hdr->ns.net = BPF_CORE_READ(task, nsproxy, net_ns, ns.inum);
+    hdr->ns.uts = BPF_CORE_READ(task, nsproxy, uts_ns, ns.inum);
+    hdr->ns.user = BPF_CORE_READ(task, real_cred, user_ns, ns.inum);
 }
 
 static __always_inline u32 get_parent_pid(struct task_struct* task)
@@ -136,70 +741,415 @@ static __always_inline u32 get_parent_pid(struct task_struct* task)
     return BPF_CORE_READ(task, real_parent, tgid);
 }
 
-static __always_inline u8 check_file_action(struct dentry* dentry, char* out_path)
+// Apply a rule action to the current task. Enforcing actions deny the
+// operation, and ACTION_KILL and ACTION_KILL_TREE also SIGKILL the caller;
+// userspace kills the rest of the tree, as only it knows the descendants.
+// Returns the response for the event header, or 0 to let the operation go.
+static __always_inline u8 enforce_action(u8 action)
 {
-    if (!dentry)
+    if (action < ACTION_BLOCK)
         return 0;
+    if (action >= ACTION_KILL)
+        bpf_send_signal(SIGKILL);
+    return action;
+}
 
-    u32 key = 0;
-    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &key);
-    if (!s)
-        return 0;
-    __builtin_memset(s, 0, sizeof(*s));
+// Admins are matched by real uid or by the inode of the running executable.
+static __always_inline bool is_protect_admin(struct task_struct* task)
+{
+    u32 uid = (u32)bpf_get_current_uid_gid();
+    if (bpf_map_lookup_elem(&protect_admin_uids, &uid))
+        return true;
 
-    struct qstr d_name = BPF_CORE_READ(dentry, d_name);
-    if (!d_name.name || d_name.len == 0 || d_name.len >= NAME_MAX)
-        return 0;
-    bpf_probe_read_kernel_str(s->filename, NAME_MAX, d_name.name);
+    struct inode* inode = BPF_CORE_READ(task, mm, exe_file, f_inode);
+    if (!inode)
+        return false;
+    struct dir_key key = {
+        .ino = BPF_CORE_READ(inode, i_ino),
+        .dev = BPF_CORE_READ(inode, i_sb, s_dev),
+    };
+    return bpf_map_lookup_elem(&protect_admin_exes, &key) != NULL;
+}
 
-    struct dentry* parent_dentry = BPF_CORE_READ(dentry, d_parent);
-    if (parent_dentry && parent_dentry != dentry) {
-        struct qstr pd_name = BPF_CORE_READ(parent_dentry, d_name);
-        if (pd_name.name && pd_name.len > 0 && pd_name.len < NAME_MAX) {
-            bpf_probe_read_kernel_str(s->parent, NAME_MAX, pd_name.name);
-        }
-    }
+static __always_inline struct aegis_config* get_aegis_config(void)
+{
+    u32 key = 0;
+    return bpf_map_lookup_elem(&aegis_config, &key);
+}
 
-    int pos = 0;
-    if (s->parent[0]) {
-        for (int i = 0; i < NAME_MAX - 1 && s->parent[i] && pos < PATH_MAX_LEN - 2; i++) {
-            s->path_buf[pos++] = s->parent[i];
-        }
-        if (s->filename[0] && pos < PATH_MAX_LEN - 1) {
-            s->path_buf[pos++] = '/';
-        }
-    }
-    for (int i = 0; i < NAME_MAX - 1 && s->filename[i] && pos < PATH_MAX_LEN - 1; i++) {
-        s->path_buf[pos++] = s->filename[i];
-    }
+static __always_inline bool self_protection_on(void)
+{
+    struct aegis_config* cfg = get_aegis_config();
+    return cfg && cfg->self_protection;
+}
 
-    __builtin_memcpy(out_path, s->path_buf, PATH_MAX_LEN);
-    u8* action = bpf_map_lookup_elem(&monitored_files, s->path_buf);
-    if (action)
-        return *action;
+// Whether self-protection denies the current task access to target_tgid.
+static __always_inline bool guards_aegis(u32 target_tgid, struct task_struct* task)
+{
+    struct aegis_config* cfg = get_aegis_config();
+    if (!cfg || !cfg->self_protection || target_tgid != cfg->tgid)
+        return false;
+    return !is_protect_admin(task);
+}
 
-    if (s->parent[0]) {
-        __builtin_memset(s->path_buf, 0, PATH_MAX_LEN);
-        pos = 0;
-        for (int i = 0; i < NAME_MAX - 1 && s->parent[i] && pos < PATH_MAX_LEN - 2; i++) {
-            s->path_buf[pos++] = s->parent[i];
+// The action behind a denied file operation, from the path's kill bits.
+static __always_inline u8 file_deny_action(u16 mask)
+{
+    if (mask & FILE_KILL_TREE)
+        return ACTION_KILL_TREE;
+    if (mask & FILE_KILL)
+        return ACTION_KILL;
+    return ACTION_BLOCK;
+}
+
+// Fallback path resolver for kernels (or hooks) without bpf_d_path. Names are
+// written right-to-left into walk_buf, crossing mount points until the
+// namespace root or MAX_PATH_DEPTH components. Inode hooks have no vfsmount;
+// with a NULL vfsmnt the walk stops at the filesystem root, which gives the
+// absolute path for files on the root filesystem. A walk that runs out of
+// components, buffer or name length leaves only the tail of the path, which
+// still starts with '/'; s->path_cut marks it so it is not taken for an
+// absolute path.
+static __always_inline void walk_dentries(struct path_scratch* s, struct dentry* dentry, struct vfsmount* vfsmnt, char* out)
+{
+    struct mount* mnt = vfsmnt ? container_of(vfsmnt, struct mount, mnt) : NULL;
+    struct dentry* mnt_root = vfsmnt ? BPF_CORE_READ(vfsmnt, mnt_root) : NULL;
+    u32 off = PATH_MAX_LEN - 1;
+    bool at_root = false;
+
+    s->walk_buf[off] = '\0';
+    for (int i = 0; i < MAX_PATH_DEPTH && dentry; i++) {
+        struct dentry* parent = BPF_CORE_READ(dentry, d_parent);
+        if (dentry == mnt_root || dentry == parent) {
+            at_root = true;
+            if (!mnt)
+                break;
+            struct mount* mnt_parent = BPF_CORE_READ(mnt, mnt_parent);
+            if (dentry != mnt_root || mnt_parent == mnt)
+                break;
+            at_root = false;
+            dentry = BPF_CORE_READ(mnt, mnt_mountpoint);
+            mnt = mnt_parent;
+            mnt_root = BPF_CORE_READ(mnt, mnt.mnt_root);
+            continue;
         }
-        action = bpf_map_lookup_elem(&monitored_files, s->path_buf);
-        if (action)
-            return *action;
+
+        struct qstr d_name = BPF_CORE_READ(dentry, d_name);
+        u32 len = d_name.len;
+        if (len == 0 || len >= NAME_MAX || len + 1 > off)
+            break;
+        off -= len;
+        bpf_probe_read_kernel(&s->walk_buf[off & PATH_WALK_MASK], len & (NAME_MAX - 1), d_name.name);
+        off -= 1;
+        s->walk_buf[off & PATH_WALK_MASK] = '/';
+        dentry = parent;
     }
+    s->path_cut = dentry && !at_root;
 
-    __builtin_memset(s->path_buf, 0, PATH_MAX_LEN);
-    __builtin_memcpy(s->path_buf, s->filename, NAME_MAX);
-    action = bpf_map_lookup_elem(&monitored_files, s->path_buf);
+    if (off == PATH_MAX_LEN - 1) {
+        off -= 1;
+        s->walk_buf[off] = '/';
+    }
+    bpf_probe_read_kernel_str(out, PATH_MAX_LEN, &s->walk_buf[off & PATH_WALK_MASK]);
+}
+
+// Resolve the absolute path of a file into out (zero padded, so it can be
+// used as a monitored_files key). bpf_d_path is only allowed from a few
+// hooks such as security_file_open, so callers opt in with allow_d_path.
+static __always_inline void resolve_file_path(struct path_scratch* s, struct path* path, char* out, bool allow_d_path)
+{
+    __builtin_memset(out, 0, PATH_MAX_LEN);
+    s->path_cut = false;
+    if (!path)
+        return;
+
+    if (allow_d_path && bpf_core_enum_value_exists(enum bpf_func_id, BPF_FUNC_d_path)) {
+        long n = bpf_d_path(path, s->walk_buf, PATH_MAX_LEN);
+        if (n > 0) {
+            bpf_probe_read_kernel_str(out, PATH_MAX_LEN, s->walk_buf);
+            return;
+        }
+    }
+
+    walk_dentries(s, BPF_CORE_READ(path, dentry), BPF_CORE_READ(path, mnt), out);
+}
+
+// Look up a path key globally and for the current cgroup.
+static __always_inline u16 lookup_path_action(struct path_scratch* s, char* path, u64 cgroup_id)
+{
+    u16 result = 0;
+    u16* action = bpf_map_lookup_elem(&monitored_files, path);
     if (action)
-        return *action;
+        result = *action;
 
-    return 0;
+    s->scoped.cgroup_id = cgroup_id;
+    __builtin_memcpy(s->scoped.path, path, PATH_MAX_LEN);
+    action = bpf_map_lookup_elem(&scoped_files, &s->scoped);
+    if (action)
+        result |= *action;
+
+    return result;
 }
 
-SEC("lsm/bprm_check_security")
-int BPF_PROG(lsm_bprm_check, struct linux_binprm* bprm)
+// Look up the path already resolved into s->path_buf in the file maps, plus
+// the basename for bare-name rules such as "Makefile". The path is left in
+// s->path_buf for the caller's event.
+static __always_inline u16 check_resolved_path_action(struct path_scratch* s, struct dentry* dentry)
+{
+    if (!s->path_buf[0])
+        return 0;
+
+    // A cut path could match a rule for a shorter path; only its basename
+    // is reliable.
+    u64 cgroup_id = bpf_get_current_cgroup_id();
+    u16 result = 0;
+    if (!s->path_cut)
+        result = lookup_path_action(s, s->path_buf, cgroup_id);
+
+    struct qstr d_name = BPF_CORE_READ(dentry, d_name);
+    if (!d_name.name || d_name.len == 0 || d_name.len >= NAME_MAX)
+        return result;
+    __builtin_memset(s->walk_buf, 0, PATH_MAX_LEN);
+    bpf_probe_read_kernel_str(s->walk_buf, NAME_MAX, d_name.name);
+
+    return result | lookup_path_action(s, s->walk_buf, cgroup_id);
+}
+
+static __always_inline u16 check_path_action(struct path_scratch* s, struct path* path, bool allow_d_path)
+{
+    resolve_file_path(s, path, s->path_buf, allow_d_path);
+    return check_resolved_path_action(s, BPF_CORE_READ(path, dentry));
+}
+
+// Walk from the dentry towards the filesystem root and merge the masks of
+// every ancestor directory in monitored_dirs. out_dir is the directory that
+// contributed the most block bits, or the nearest one. d_parent ends at the
+// root of the dentry's own mount and the walk at MAX_DIR_DEPTH, so a watched
+// directory above a nested mount or further up is not found.
+static __always_inline u16 check_dir_action(struct dentry* dentry, struct dir_key* out_dir)
+{
+    struct dir_key key = {};
+    u16 result = 0;
+
+    for (int i = 0; i < MAX_DIR_DEPTH && dentry; i++) {
+        struct inode* inode = BPF_CORE_READ(dentry, d_inode);
+        if (inode) {
+            key.ino = BPF_CORE_READ(inode, i_ino);
+            key.dev = BPF_CORE_READ(inode, i_sb, s_dev);
+            u16* action = bpf_map_lookup_elem(&monitored_dirs, &key);
+            if (action && (!result || (*action & ~result))) {
+                result |= *action;
+                out_dir->ino = key.ino;
+                out_dir->dev = key.dev;
+            }
+        }
+
+        struct dentry* parent = BPF_CORE_READ(dentry, d_parent);
+        if (parent == dentry)
+            break;
+        dentry = parent;
+    }
+
+    return result;
+}
+
+static __always_inline u16 check_file_action(struct path_scratch* s, struct path* path, struct dir_key* out_dir, bool allow_d_path)
+{
+    return check_path_action(s, path, allow_d_path) | check_dir_action(BPF_CORE_READ(path, dentry), out_dir);
+}
+
+// Like check_file_action for hooks that only have a dentry (and at best the
+// parent directory's mount).
+static __always_inline u16 check_dentry_action(struct path_scratch* s, struct dentry* dentry, struct vfsmount* mnt, struct dir_key* out_dir)
+{
+    __builtin_memset(s->path_buf, 0, PATH_MAX_LEN);
+    walk_dentries(s, dentry, mnt, s->path_buf);
+    return check_resolved_path_action(s, dentry) | check_dir_action(dentry, out_dir);
+}
+
+// The comm the task will carry after exec: the basename of bprm->filename,
+// truncated like __set_task_comm does.
+static __always_inline void read_exec_comm(struct path_scratch* s, struct linux_binprm* bprm, char* comm)
+{
+    const char* filename = BPF_CORE_READ(bprm, filename);
+    if (bpf_probe_read_kernel_str(s->walk_buf, PATH_MAX_LEN, filename) <= 0)
+        return;
+
+    u32 start = 0;
+    for (u32 i = 0; i < PATH_MAX_LEN - 1; i++) {
+        char c = s->walk_buf[i];
+        if (!c)
+            break;
+        if (c == '/')
+            start = i + 1;
+    }
+    for (u32 i = 0; i < TASK_COMM_LEN - 1; i++) {
+        char c = s->walk_buf[(start + i) & (PATH_MAX_LEN * 2 - 1)];
+        if (!c)
+            break;
+        comm[i] = c;
+    }
+}
+
+static __always_inline u8 check_exec_action(struct exec_rule_key* key)
+{
+    u8 result = 0;
+    u8* action = bpf_map_lookup_elem(&exec_rules, key);
+    if (action) {
+        if (*action == EXEC_RULE_ALLOW)
+            return 0;
+        result = *action;
+    }
+
+    char pcomm[TASK_COMM_LEN];
+    __builtin_memcpy(pcomm, key->pcomm, TASK_COMM_LEN);
+    __builtin_memset(key->pcomm, 0, TASK_COMM_LEN);
+    action = bpf_map_lookup_elem(&exec_rules, key);
+    if (action) {
+        if (*action == EXEC_RULE_ALLOW)
+            return 0;
+        if (*action > result)
+            result = *action;
+    }
+
+    __builtin_memcpy(key->pcomm, pcomm, TASK_COMM_LEN);
+    __builtin_memset(key->comm, 0, TASK_COMM_LEN);
+    action = bpf_map_lookup_elem(&exec_rules, key);
+    if (action) {
+        if (*action == EXEC_RULE_ALLOW)
+            return 0;
+        if (*action > result)
+            result = *action;
+    }
+
+    return result;
+}
+
+// memfd_create files and unlinked binaries both have no links left; memfd
+// dentries are named "memfd:<name>". execveat on the fd and exec through
+// /proc/self/fd/N both land here with bprm->file pointing at the memfd.
+static __always_inline u8 exec_file_flags(struct file* file)
+{
+    struct inode* inode = BPF_CORE_READ(file, f_inode);
+    if (!inode || BPF_CORE_READ(inode, i_nlink) != 0)
+        return 0;
+
+    char prefix[6] = {};
+    const unsigned char* name = BPF_CORE_READ(file, f_path.dentry, d_name.name);
+    if (name && !bpf_probe_read_kernel(prefix, sizeof(prefix), name) &&
+        prefix[0] == 'm' && prefix[1] == 'e' && prefix[2] == 'm' &&
+        prefix[3] == 'f' && prefix[4] == 'd' && prefix[5] == ':')
+        return EXEC_FLAG_FILELESS;
+    return EXEC_FLAG_DELETED;
+}
+
+static __always_inline void flush_exec_args(struct exec_args_event* e, u32 len, u8 flags, u16 chunk)
+{
+    len &= ARGS_CHUNK_LEN * 2 - 1;
+    e->chunk = chunk;
+    e->flags = flags;
+    e->len = len;
+    if (bpf_ringbuf_output(&events, e, sizeof(*e) - sizeof(e->data) + len, 0))
+        count_drop(EVENT_TYPE_EXEC_ARGS, false);
+}
+
+// Streams a user argv/envp vector into exec_args records. Each read appends
+// one string, or ARGS_CHUNK_LEN - 1 bytes of a longer one, so a record is
+// sent once it holds ARGS_CHUNK_LEN bytes or more. The last record of the
+// vector is flagged, and marked truncated when the read budget ran out or
+// user memory could not be read. Returns the next chunk number.
+static __always_inline u16 emit_exec_args(struct exec_args_event* e, u64 vec, u8 flags, u16 chunk)
+{
+    u32 pos = 0;
+    u32 arg = 0;
+    u64 off = 0;
+    u64 str = 0;
+    u8 done = 0;
+
+    for (u32 i = 0; i < MAX_ARG_READS && chunk < MAX_ARGS_CHUNKS; i++) {
+        if (!off) {
+            if (bpf_probe_read_user(&str, sizeof(str), (void*)(vec + arg * sizeof(u64))))
+                break;
+            if (!str) {
+                done = 1;
+                break;
+            }
+        }
+        long n = bpf_probe_read_user_str(&e->data[pos & (ARGS_CHUNK_LEN - 1)], ARGS_CHUNK_LEN, (void*)(str + off));
+        if (n <= 0)
+            break;
+        if (n == ARGS_CHUNK_LEN) {
+            // No NUL yet: the next read overwrites the terminator the
+            // helper added and carries on with the same string.
+            pos += ARGS_CHUNK_LEN - 1;
+            off += ARGS_CHUNK_LEN - 1;
+        } else {
+            pos += n;
+            off = 0;
+            arg++;
+        }
+        if (pos >= ARGS_CHUNK_LEN) {
+            flush_exec_args(e, pos, flags, chunk++);
+            pos = 0;
+        }
+    }
+
+    flags |= ARGS_FLAG_LAST;
+    if (!done)
+        flags |= ARGS_FLAG_TRUNCATED;
+    flush_exec_args(e, pos, flags, chunk++);
+    return chunk;
+}
+
+// A per-CPU counter tagged with the CPU number keeps seq unique without
+// atomics. Zero means the exec has no argument records.
+static __always_inline u64 next_exec_seq(void)
+{
+    u32 key = 0;
+    u64* seq = bpf_map_lookup_elem(&exec_seq, &key);
+    if (!seq)
+        return 0;
+    *seq += 1;
+    return ((u64)bpf_get_smp_processor_id() << 48) | (*seq & 0xffffffffffffULL);
+}
+
+// Streams the NUL-separated strings between start and end of the current
+// mm, where execve copied them once the new image is set up. The last record
+// is flagged, and marked truncated when the chunks ran out or user memory
+// could not be read. Returns the next chunk number.
+static __always_inline u16 emit_exec_args_area(struct exec_args_event* e, u64 start, u64 end, u8 flags, u16 chunk)
+{
+    u64 len = 0;
+
+    for (u32 i = 0; i < MAX_ARGS_CHUNKS && chunk < MAX_ARGS_CHUNKS; i++) {
+        if (start >= end)
+            break;
+        len = end - start;
+        if (len > ARGS_CHUNK_LEN)
+            len = ARGS_CHUNK_LEN;
+        if (bpf_probe_read_user(e->data, len, (void*)start)) {
+            len = 0;
+            break;
+        }
+        start += len;
+        if (start >= end)
+            break;
+        flush_exec_args(e, len, flags, chunk++);
+        len = 0;
+    }
+
+    flags |= ARGS_FLAG_LAST;
+    if (start < end)
+        flags |= ARGS_FLAG_TRUNCATED;
+    flush_exec_args(e, len, flags, chunk++);
+    return chunk;
+}
+
+// Report an exec. With enforce set (bprm_check_security) rule matches deny
+// it; otherwise (sched_process_exec, when BPF LSM is unavailable) the exec
+// already happened and is only reported, with the arguments read from the
+// new mm.
+static __always_inline int report_exec(struct linux_binprm* bprm, bool enforce)
 {
     struct exec_event* event;
     struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
@@ -207,7 +1157,8 @@ int BPF_PROG(lsm_bprm_check, struct linux_binprm* bprm)
     u64 pid_tgid = bpf_get_current_pid_tgid();
     u32 pid = pid_tgid >> 32;
     int ret = 0;
-    u8 blocked = 0;
+    u8 response = 0;
+    u8 exec_flags = 0;
 
     u32 scratch_key = 0;
     struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
@@ -218,27 +1169,80 @@ int BPF_PROG(lsm_bprm_check, struct linux_binprm* bprm)
 
     struct file* file = BPF_CORE_READ(bprm, file);
     if (file) {
-        struct dentry* dentry = BPF_CORE_READ(file, f_path.dentry);
-        u8 action = check_file_action(dentry, s->path_buf);
-        if (action == ACTION_BLOCK) {
-            ret = -EPERM;
-            blocked = 1;
-        }
+        exec_flags = exec_file_flags(file);
+
+        // security_bprm_check is not on the bpf_d_path allowlist. Executing
+        // a file counts as reading it.
+        struct dir_key matched_dir = {};
+        u16 action = check_file_action(s, &file->f_path, &matched_dir, false);
+        if (action & FILE_BLOCKS(FILE_OP_READ))
+            response = file_deny_action(action);
     }
 
+    struct exec_rule_key exec_key = {};
+    parent = BPF_CORE_READ(task, real_parent);
+    read_exec_comm(s, bprm, exec_key.comm);
+    if (parent)
+        BPF_CORE_READ_STR_INTO(&exec_key.pcomm, parent, comm);
+    char comm[TASK_COMM_LEN];
+    __builtin_memcpy(comm, exec_key.comm, TASK_COMM_LEN);
+    u8 exec_action = check_exec_action(&exec_key);
+    if (exec_action > response)
+        response = exec_action;
+    response = enforce ? enforce_action(response) : 0;
+    if (response)
+        ret = -EPERM;
+
     struct exec_event* scratch_event = bpf_map_lookup_elem(&event_scratch, &scratch_key);
     if (!scratch_event)
         return ret;
 
+    // The arguments go out first so userspace has them when the exec event
+    // arrives.
+    u64 args_seq = 0;
+    u32 tid = (u32)pid_tgid;
+    struct exec_argv* saved = enforce ? bpf_map_lookup_elem(&exec_argv, &tid) : NULL;
+    struct exec_args_event* args = bpf_map_lookup_elem(&args_scratch, &scratch_key);
+    if ((saved || !enforce) && args) {
+        args_seq = next_exec_seq();
+        fill_event_header(&args->hdr, EVENT_TYPE_EXEC_ARGS, task);
+        if (comm[0])
+            __builtin_memcpy(args->hdr.comm, comm, TASK_COMM_LEN);
+        args->seq = args_seq;
+        args->_pad = 0;
+        struct aegis_config* cfg = get_aegis_config();
+        bool env = cfg && cfg->capture_env;
+        if (saved) {
+            u16 chunk = emit_exec_args(args, saved->argv, 0, 0);
+            if (env && saved->envp)
+                emit_exec_args(args, saved->envp, ARGS_FLAG_ENV, chunk);
+        } else {
+            struct mm_struct* mm = BPF_CORE_READ(task, mm);
+            u16 chunk = emit_exec_args_area(args, BPF_CORE_READ(mm, arg_start), BPF_CORE_READ(mm, arg_end), 0, 0);
+            if (env)
+                emit_exec_args_area(args, BPF_CORE_READ(mm, env_start), BPF_CORE_READ(mm, env_end), ARGS_FLAG_ENV, chunk);
+        }
+    }
+    // Used once: an interpreter pass of the same exec would only repeat the
+    // script's argv, and a non-leader thread's exec ends under another tid.
+    if (saved)
+        bpf_map_delete_elem(&exec_argv, &tid);
+
     event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
-    if (!event)
+    if (!event) {
+        count_drop(EVENT_TYPE_EXEC, response != 0);
         return ret;
+    }
 
     fill_event_header(&event->hdr, EVENT_TYPE_EXEC, task);
-    event->hdr.blocked = blocked;
+    event->hdr.blocked = response != 0;
+    event->hdr.response = response;
+    // Report the name the process is exec'ing into, not the pre-exec comm.
+    if (comm[0])
+        __builtin_memcpy(event->hdr.comm, comm, TASK_COMM_LEN);
 
-    parent = BPF_CORE_READ(task, real_parent);
     event->ppid = get_parent_pid(task);
+    event->exec_flags = exec_flags;
     bpf_map_update_elem(&pid_to_ppid, &pid, &event->ppid, BPF_ANY);
     
     if (parent) {
@@ -248,217 +1252,1503 @@ int BPF_PROG(lsm_bprm_check, struct linux_binprm* bprm)
     }
 
     __builtin_memcpy(event->filename, s->path_buf, PATH_MAX_LEN);
+    // The full argv is in the exec_args records; command_line falls back to
+    // the path when they could not be sent.
     __builtin_memcpy(event->command_line, s->path_buf, PATH_MAX_LEN);
-    
-    u32 argc = 0;
-    if (bpf_probe_read_kernel(&argc, sizeof(argc), (void*)BPF_CORE_READ(bprm, p))) {
-        goto submit;
-    }
-    
-    if (argc == 0 || argc > MAX_ARGC_FOR_CMD) {
-        goto submit;
-    }
-    
-    u64 argv_start = (u64)BPF_CORE_READ(bprm, p) + sizeof(u32);
-    u64 argv0_ptr = 0;
-    if (bpf_probe_read_kernel(&argv0_ptr, sizeof(argv0_ptr), (void*)argv_start)) {
-        goto submit;
-    }
-    if (argv0_ptr == 0) {
-        goto submit;
-    }
-    
-    u32 argv0_read_size = ARGV0_READ_LEN;
-    if (argv0_read_size > COMMAND_LINE_LEN) {
-        argv0_read_size = COMMAND_LINE_LEN;
-    }
-    long n = bpf_probe_read_user_str(event->command_line, argv0_read_size, (void*)argv0_ptr);
-    if (n > 0 && n < argv0_read_size) {
-        u32 pos = (u32)(n - 1); // n includes null terminator, n > 0 so n-1 >= 0
-        if (pos >= COMMAND_LINE_LEN) {
-            goto submit;
-        }
-        u32 max_args = argc < MAX_ARGS_TO_READ ? argc : MAX_ARGS_TO_READ;
-        
-        for (u32 i = 1; i < max_args && pos < (COMMAND_LINE_LEN - CMD_LINE_SAFETY_MARGIN); i++) {
-            u64 argv_i_addr = argv_start + (i * sizeof(u64));
-            u64 argv_i_ptr = 0;
-            if (bpf_probe_read_kernel(&argv_i_ptr, sizeof(argv_i_ptr), (void*)argv_i_addr)) {
-                break;
-            }
-            if (argv_i_ptr == 0) {
-                break;
-            }
-            
-            if (pos >= COMMAND_LINE_LEN - 1) {
-                break;
-            }
-            event->command_line[pos++] = ' ';
-            
-            if (pos >= COMMAND_LINE_LEN) {
-                break;
-            }
-            u32 remaining;
-            if (pos < COMMAND_LINE_LEN) {
-                remaining = COMMAND_LINE_LEN - pos;
-            } else {
-                break;
-            }
-            if (remaining == 0) {
-                break;
-            }
-            u32 read_size;
-            if (remaining > CMD_LINE_SAFETY_MARGIN) {
-                read_size = CMD_LINE_SAFETY_MARGIN;
-            } else {
-                read_size = remaining;
-            }
-            if (read_size == 0) {
-                break;
-            }
-            if (read_size > remaining) {
-                break;
-            }
-            if (pos + read_size > COMMAND_LINE_LEN) {
-                break;
-            }
-            long arg_len = bpf_probe_read_user_str(&event->command_line[pos], read_size, (void*)argv_i_ptr);
-            if (arg_len <= 0) {
-                break;
-            }
-            if ((u32)arg_len > remaining) {
-                break;
-            }
-            pos += (u32)(arg_len - 1);
-            if (pos >= COMMAND_LINE_LEN - 1) {
-                break;
-            }
-        }
-        if (pos < COMMAND_LINE_LEN) {
-            event->command_line[pos] = '\0';
-        }
-    }
+    event->args_seq = args_seq;
 
-submit:
     bpf_ringbuf_submit(event, 0);
     return ret;
 }
 
-SEC("lsm/file_open")
-int BPF_PROG(lsm_file_open, struct file* file)
+SEC("lsm/bprm_check_security")
+int BPF_PROG(lsm_bprm_check, struct linux_binprm* bprm)
 {
-    struct file_event* event;
-    u64 pid_tgid = bpf_get_current_pid_tgid();
-    u32 pid = pid_tgid >> 32;
-    int ret = 0;
-    u8 blocked = 0;
+    return report_exec(bprm, true);
+}
 
-    u32 scratch_key = 0;
-    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
-    if (!s)
-        return 0;
+// Monitor-only stand-in for bprm_check_security. It runs once the new image
+// is in place, so current's comm and mm already belong to the new program.
+SEC("tp_btf/sched_process_exec")
+int BPF_PROG(handle_sched_process_exec, struct task_struct* p, pid_t old_pid, struct linux_binprm* bprm)
+{
+    report_exec(bprm, false);
+    return 0;
+}
 
-    __builtin_memset(s->path_buf, 0, PATH_MAX_LEN);
+static __always_inline int save_exec_argv(u64 argv, u64 envp)
+{
+    u32 tid = (u32)bpf_get_current_pid_tgid();
+    struct exec_argv saved = {
+        .argv = argv,
+        .envp = envp,
+    };
 
-    struct dentry* dentry = BPF_CORE_READ(file, f_path.dentry);
-    u8 action = check_file_action(dentry, s->path_buf);
-    if (!action)
+    bpf_map_update_elem(&exec_argv, &tid, &saved, BPF_ANY);
+    return 0;
+}
+
+// bprm_check_security runs after the arguments were copied into the new
+// mm, where they cannot be read, so the syscall's own pointers are kept.
+SEC("tracepoint/syscalls/sys_enter_execve")
+int handle_execve_enter(struct trace_event_raw_sys_enter* ctx)
+{
+    return save_exec_argv(ctx->args[1], ctx->args[2]);
+}
+
+SEC("tracepoint/syscalls/sys_enter_execveat")
+int handle_execveat_enter(struct trace_event_raw_sys_enter* ctx)
+{
+    return save_exec_argv(ctx->args[2], ctx->args[3]);
+}
+
+static __always_inline int drop_exec_argv(void)
+{
+    u32 tid = (u32)bpf_get_current_pid_tgid();
+    bpf_map_delete_elem(&exec_argv, &tid);
+    return 0;
+}
+
+// Execs that fail before bprm_check_security never use what the entries
+// saved, so the exits drop it.
+SEC("tracepoint/syscalls/sys_exit_execve")
+int handle_execve_exit(struct trace_event_raw_sys_exit* ctx)
+{
+    return drop_exec_argv();
+}
+
+SEC("tracepoint/syscalls/sys_exit_execveat")
+int handle_execveat_exit(struct trace_event_raw_sys_exit* ctx)
+{
+    return drop_exec_argv();
+}
+
+// The FILE_BLOCKS bits an open with these flags and mode falls under.
+static __always_inline u16 open_access_bits(u32 flags, u32 mode)
+{
+    u16 bits = 0;
+    if (mode & FMODE_READ)
+        bits |= FILE_BLOCKS(FILE_OP_READ);
+    if (mode & FMODE_WRITE)
+        bits |= FILE_BLOCKS(FILE_OP_WRITE);
+    if (flags & O_APPEND)
+        bits |= FILE_BLOCKS(FILE_ACCESS_APPEND);
+    if (flags & O_CREAT)
+        bits |= FILE_BLOCKS(FILE_ACCESS_CREATE);
+    if (flags & O_TRUNC)
+        bits |= FILE_BLOCKS(FILE_ACCESS_TRUNCATE);
+    return bits;
+}
+
+// Report a file operation on a monitored path (already in s->path_buf) and
+// deny it when the path's mask blocks any of the operation's access bits.
+// Operations the mask neither monitors nor blocks, such as reads of a path
+// only its deletes are blocked on, are left alone.
+static __always_inline int report_file_op(struct path_scratch* s, u32 op, u16 access, struct inode* inode, u32 flags, struct dir_key* dir, u16 action)
+{
+    struct file_event* event;
+    int ret = 0;
+    u8 response = 0;
+
+    if (!(action & (FILE_MONITOR | access)))
         return 0;
 
-    if (action == ACTION_BLOCK) {
+    if (action & access & ~FILE_MONITOR) {
+        response = enforce_action(file_deny_action(action));
         ret = -EPERM;
-        blocked = 1;
     }
 
     event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
-    if (!event)
+    if (!event) {
+        count_drop(EVENT_TYPE_FILE_OPEN, response != 0);
         return ret;
+    }
 
     struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
     fill_event_header(&event->hdr, EVENT_TYPE_FILE_OPEN, task);
-    event->hdr.blocked = blocked;
+    event->hdr.blocked = response != 0;
+    event->hdr.response = response;
+    event->hdr.path_cut = s->path_cut;
 
-    event->flags = BPF_CORE_READ(file, f_flags);
+    event->flags = flags;
+    event->op = op;
     event->ino = 0;
     event->dev = 0;
-    if (file) {
-        struct inode* inode = BPF_CORE_READ(file, f_inode);
-        if (inode) {
-            event->ino = BPF_CORE_READ(inode, i_ino);
-            struct super_block* sb = BPF_CORE_READ(inode, i_sb);
-            if (sb) {
-                event->dev = BPF_CORE_READ(sb, s_dev);
-            }
+    if (inode) {
+        event->ino = BPF_CORE_READ(inode, i_ino);
+        struct super_block* sb = BPF_CORE_READ(inode, i_sb);
+        if (sb) {
+            event->dev = BPF_CORE_READ(sb, s_dev);
         }
     }
     __builtin_memcpy(event->filename, s->path_buf, PATH_MAX_LEN);
+    event->dir_ino = dir->ino;
+    event->dir_dev = dir->dev;
     bpf_ringbuf_submit(event, 0);
 
     return ret;
 }
 
-SEC("lsm/socket_connect")
-int BPF_PROG(lsm_socket_connect, struct socket* sock, struct sockaddr* address, int addrlen)
+SEC("lsm/file_open")
+int BPF_PROG(lsm_file_open, struct file* file)
 {
-    struct connect_event* event;
-    u64 pid_tgid = bpf_get_current_pid_tgid();
-    u32 pid = pid_tgid >> 32;
-    int ret = 0;
-    u8 blocked = 0;
-    u16 port = 0;
-    u16 family = 0;
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
+        return 0;
 
-    if (!address)
+    __builtin_memset(s->path_buf, 0, PATH_MAX_LEN);
+
+    struct dir_key matched_dir = {};
+    u16 action = check_file_action(s, &file->f_path, &matched_dir, true);
+    if (!action)
         return 0;
 
-    bpf_probe_read_kernel(&family, sizeof(family), &address->sa_family);
+    // O_CREAT and O_TRUNC are still set here; do_dentry_open clears them
+    // only after the LSM check.
+    u32 flags = BPF_CORE_READ(file, f_flags);
+    u32 mode = BPF_CORE_READ(file, f_mode);
+    u32 op = (mode & FMODE_WRITE) ? FILE_OP_WRITE : FILE_OP_READ;
+    return report_file_op(s, op, open_access_bits(flags, mode), BPF_CORE_READ(file, f_inode), flags, &matched_dir, action);
+}
 
-    if (family == AF_INET) {
-        struct sockaddr_in* addr_in = (struct sockaddr_in*)address;
-        u16 port_net = 0;
-        bpf_probe_read_kernel(&port_net, sizeof(port_net), &addr_in->sin_port);
-        port = __bpf_ntohs(port_net);
-    } else if (family == AF_INET6) {
-        struct sockaddr_in6* addr_in6 = (struct sockaddr_in6*)address;
-        u16 port_net = 0;
-        bpf_probe_read_kernel(&port_net, sizeof(port_net), &addr_in6->sin6_port);
-        port = __bpf_ntohs(port_net);
-    } else {
+// Monitor-only stand-in for lsm/file_open. fentry cannot change the return
+// value, so only the monitor bit of the path's mask is passed on and nothing
+// is denied or killed.
+SEC("fentry/security_file_open")
+int BPF_PROG(fentry_file_open, struct file* file)
+{
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
         return 0;
-    }
 
-    u8* port_action = bpf_map_lookup_elem(&blocked_ports, &port);
-    if (!port_action)
+    __builtin_memset(s->path_buf, 0, PATH_MAX_LEN);
+
+    struct dir_key matched_dir = {};
+    u16 action = check_file_action(s, &file->f_path, &matched_dir, true);
+    if (!action)
         return 0;
 
-    if (*port_action == ACTION_BLOCK) {
-        ret = -EPERM;
-        blocked = 1;
-    }
+    u32 flags = BPF_CORE_READ(file, f_flags);
+    u32 mode = BPF_CORE_READ(file, f_mode);
+    u32 op = (mode & FMODE_WRITE) ? FILE_OP_WRITE : FILE_OP_READ;
+    report_file_op(s, op, open_access_bits(flags, mode), BPF_CORE_READ(file, f_inode), flags, &matched_dir, FILE_MONITOR);
+    return 0;
+}
 
-    event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
-    if (!event)
-        return ret;
+// Self-protection for Aegis's pins: unlinking or renaming an entry of a
+// protect_pins directory. Denials are reported as file events.
+static __always_inline int guard_pin(struct path_scratch* s, u32 op, struct inode* dir, struct dentry* dentry)
+{
+    if (!dir || !self_protection_on())
+        return 0;
 
     struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
-    fill_event_header(&event->hdr, EVENT_TYPE_CONNECT, task);
-    event->hdr.blocked = blocked;
+    struct dir_key key = {
+        .ino = BPF_CORE_READ(dir, i_ino),
+        .dev = BPF_CORE_READ(dir, i_sb, s_dev),
+    };
+    if (!bpf_map_lookup_elem(&protect_pins, &key) || is_protect_admin(task))
+        return 0;
 
-    event->family = family;
-    event->port = port;
-    event->addr_v4 = 0;
-    __builtin_memset(event->addr_v6, 0, 16);
+    __builtin_memset(s->path_buf, 0, PATH_MAX_LEN);
+    walk_dentries(s, dentry, NULL, s->path_buf);
 
-    if (family == AF_INET) {
-        struct sockaddr_in* addr_in = (struct sockaddr_in*)address;
-        bpf_probe_read_kernel(&event->addr_v4, sizeof(event->addr_v4), &addr_in->sin_addr.s_addr);
-    } else if (family == AF_INET6) {
-        struct sockaddr_in6* addr_in6 = (struct sockaddr_in6*)address;
-        bpf_probe_read_kernel(event->addr_v6, 16, &addr_in6->sin6_addr);
+    struct file_event* event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_FILE_OPEN, true);
+        return -EPERM;
     }
 
+    fill_event_header(&event->hdr, EVENT_TYPE_FILE_OPEN, task);
+    event->hdr.blocked = 1;
+    event->hdr.response = ACTION_BLOCK;
+    event->hdr.self_protect = 1;
+    event->hdr.path_cut = s->path_cut;
+    event->flags = 0;
+    event->op = op;
+    struct inode* inode = BPF_CORE_READ(dentry, d_inode);
+    event->ino = BPF_CORE_READ(inode, i_ino);
+    event->dev = BPF_CORE_READ(inode, i_sb, s_dev);
+    __builtin_memcpy(event->filename, s->path_buf, PATH_MAX_LEN);
+    event->dir_ino = key.ino;
+    event->dir_dev = key.dev;
+    bpf_ringbuf_submit(event, 0);
+    return -EPERM;
+}
+
+SEC("lsm/inode_unlink")
+int BPF_PROG(lsm_inode_unlink, struct inode* dir, struct dentry* dentry)
+{
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
+        return 0;
+
+    int ret = guard_pin(s, FILE_OP_DELETE, dir, dentry);
+    if (ret)
+        return ret;
+
+    struct dir_key matched_dir = {};
+    u16 action = check_dentry_action(s, dentry, NULL, &matched_dir);
+    if (!action)
+        return 0;
+
+    return report_file_op(s, FILE_OP_DELETE, FILE_BLOCKS(FILE_OP_DELETE), BPF_CORE_READ(dentry, d_inode), 0, &matched_dir, action);
+}
+
+// Both ends of a rename are checked, so moving a file over a protected path
+// is caught as well as moving the protected file away.
+SEC("lsm/path_rename")
+int BPF_PROG(lsm_path_rename, const struct path* old_dir, struct dentry* old_dentry,
+    const struct path* new_dir, struct dentry* new_dentry)
+{
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
+        return 0;
+
+    int ret = guard_pin(s, FILE_OP_RENAME, BPF_CORE_READ(old_dir, dentry, d_inode), old_dentry);
+    if (ret)
+        return ret;
+
+    struct dir_key matched_dir = {};
+    u16 action = check_dentry_action(s, old_dentry, BPF_CORE_READ(old_dir, mnt), &matched_dir);
+    if (action) {
+        ret = report_file_op(s, FILE_OP_RENAME, FILE_BLOCKS(FILE_OP_RENAME), BPF_CORE_READ(old_dentry, d_inode), 0, &matched_dir, action);
+        if (ret)
+            return ret;
+    }
+
+    struct dir_key new_matched_dir = {};
+    action = check_dentry_action(s, new_dentry, BPF_CORE_READ(new_dir, mnt), &new_matched_dir);
+    if (!action)
+        return 0;
+
+    return report_file_op(s, FILE_OP_RENAME, FILE_BLOCKS(FILE_OP_RENAME), BPF_CORE_READ(new_dentry, d_inode), 0, &new_matched_dir, action);
+}
+
+// Linux 6.8 added a leading mnt_idmap argument to inode_setattr; the loader
+// sets setattr_has_idmap from kernel BTF. Mode and owner changes report as
+// FILE_OP_CHMOD; size changes report as a write with O_WRONLY|O_TRUNC, like
+// the open(2) equivalent of truncate(2).
+SEC("lsm/inode_setattr")
+int BPF_PROG(lsm_inode_setattr, void* arg0, void* arg1, void* arg2)
+{
+    struct dentry* dentry = setattr_has_idmap ? arg1 : arg0;
+    struct iattr* attr = setattr_has_idmap ? arg2 : arg1;
+    u32 op, flags = 0;
+    u16 access;
+
+    unsigned int valid = BPF_CORE_READ(attr, ia_valid);
+    if (valid & (ATTR_MODE | ATTR_UID | ATTR_GID)) {
+        op = FILE_OP_CHMOD;
+        access = FILE_BLOCKS(FILE_OP_CHMOD);
+    } else if (valid & ATTR_SIZE) {
+        op = FILE_OP_WRITE;
+        flags = O_WRONLY | O_TRUNC;
+        access = open_access_bits(flags, FMODE_WRITE);
+    } else {
+        return 0;
+    }
+
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
+        return 0;
+
+    struct dir_key matched_dir = {};
+    u16 action = check_dentry_action(s, dentry, NULL, &matched_dir);
+    if (!action)
+        return 0;
+
+    return report_file_op(s, op, access, BPF_CORE_READ(dentry, d_inode), flags, &matched_dir, action);
+}
+
+// Look up a destination in one of the address tries, first with the exact
+// port and then with the any-port wildcard, returning the stronger action.
+static __always_inline u8 check_addr_action(void* trie, void* key, u8* key_port, u16 port_net)
+{
+    u8 result = 0;
+
+    __builtin_memcpy(key_port, &port_net, sizeof(port_net));
+    u8* action = bpf_map_lookup_elem(trie, key);
+    if (action)
+        result = *action;
+
+    __builtin_memset(key_port, 0, sizeof(port_net));
+    action = bpf_map_lookup_elem(trie, key);
+    if (action && *action > result)
+        result = *action;
+
+    return result;
+}
+
+// Report a connect to a watched address, port or unix socket. addr points
+// at the kernel's copy of the 4- or 16-byte destination address, unused for
+// AF_UNIX, where unix_key holds the path. With enforce unset (tcp_connect,
+// when BPF LSM is unavailable) the connect is only reported.
+static __always_inline int report_connect(u16 family, u16 port_net, const void* addr, struct unix_lpm_key* unix_key, bool enforce)
+{
+    struct connect_event* event;
+    int ret = 0;
+    u8 response = 0;
+    u16 port = 0;
+    u8 action = 0;
+
+    if (family == AF_INET) {
+        struct ipv4_lpm_key key = {};
+        bpf_probe_read_kernel(key.addr, sizeof(key.addr), addr);
+        key.prefixlen = 16 + 32;
+        action = check_addr_action(&blocked_v4, &key, key.port, port_net);
+
+        struct domain_v4_key domain_key = {};
+        __builtin_memcpy(domain_key.addr, key.addr, sizeof(domain_key.addr));
+        u8 domain_action = check_addr_action(&domain_v4, &domain_key, (u8*)&domain_key.port, port_net);
+        if (domain_action > action)
+            action = domain_action;
+    } else if (family == AF_INET6) {
+        struct ipv6_lpm_key key = {};
+        bpf_probe_read_kernel(key.addr, sizeof(key.addr), addr);
+        key.prefixlen = 16 + 128;
+        action = check_addr_action(&blocked_v6, &key, key.port, port_net);
+
+        struct domain_v6_key domain_key = {};
+        __builtin_memcpy(domain_key.addr, key.addr, sizeof(domain_key.addr));
+        u8 domain_action = check_addr_action(&domain_v6, &domain_key, (u8*)&domain_key.port, port_net);
+        if (domain_action > action)
+            action = domain_action;
+    } else if (family == AF_UNIX) {
+        u8* unix_action = bpf_map_lookup_elem(&unix_sockets, unix_key);
+        if (unix_action)
+            action = *unix_action;
+    } else {
+        return 0;
+    }
+    port = __bpf_ntohs(port_net);
+
+    if (family != AF_UNIX) {
+        u8* port_action = bpf_map_lookup_elem(&blocked_ports, &port);
+        if (port_action && *port_action > action)
+            action = *port_action;
+        struct scoped_port_key scoped_key = {
+            .cgroup_id = bpf_get_current_cgroup_id(),
+            .port = port,
+        };
+        port_action = bpf_map_lookup_elem(&scoped_ports, &scoped_key);
+        if (port_action && *port_action > action)
+            action = *port_action;
+    }
+    if (!action)
+        return 0;
+
+    response = enforce ? enforce_action(action) : 0;
+    if (response)
+        ret = -EPERM;
+
+    event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_CONNECT, response != 0);
+        return ret;
+    }
+
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    fill_event_header(&event->hdr, EVENT_TYPE_CONNECT, task);
+    event->hdr.blocked = response != 0;
+    event->hdr.response = response;
+
+    event->family = family;
+    event->port = port;
+    event->addr_v4 = 0;
+    __builtin_memset(event->addr_v6, 0, 16);
+    __builtin_memcpy(event->unix_path, unix_key->path, UNIX_PATH_LEN);
+
+    if (family == AF_INET)
+        bpf_probe_read_kernel(&event->addr_v4, sizeof(event->addr_v4), addr);
+    else if (family == AF_INET6)
+        bpf_probe_read_kernel(event->addr_v6, 16, addr);
+
+    bpf_ringbuf_submit(event, 0);
+    return ret;
+}
+
+SEC("lsm/socket_connect")
+int BPF_PROG(lsm_socket_connect, struct socket* sock, struct sockaddr* address, int addrlen)
+{
+    u16 family = 0;
+    u16 port_net = 0;
+    const void* addr = NULL;
+    struct unix_lpm_key unix_key = {};
+
+    if (!address)
+        return 0;
+
+    bpf_probe_read_kernel(&family, sizeof(family), &address->sa_family);
+
+    if (family == AF_INET) {
+        struct sockaddr_in* addr_in = (struct sockaddr_in*)address;
+        bpf_probe_read_kernel(&port_net, sizeof(port_net), &addr_in->sin_port);
+        addr = &addr_in->sin_addr.s_addr;
+    } else if (family == AF_INET6) {
+        struct sockaddr_in6* addr_in6 = (struct sockaddr_in6*)address;
+        bpf_probe_read_kernel(&port_net, sizeof(port_net), &addr_in6->sin6_port);
+        addr = &addr_in6->sin6_addr;
+    } else if (family == AF_UNIX) {
+        struct sockaddr_un* addr_un = (struct sockaddr_un*)address;
+        // Only the bytes the caller passed are meaningful; abstract names
+        // are not NUL-terminated.
+        int len = addrlen - (int)sizeof(addr_un->sun_family);
+        if (len <= 0)
+            return 0;
+        if (len > UNIX_PATH_LEN)
+            len = UNIX_PATH_LEN;
+        bpf_probe_read_kernel(unix_key.path, len & 0x7f, addr_un->sun_path);
+        unix_key.prefixlen = UNIX_PATH_LEN * 8;
+    } else {
+        return 0;
+    }
+
+    return report_connect(family, port_net, addr, &unix_key, true);
+}
+
+// Monitor-only stand-in for lsm/socket_connect, covering TCP over IPv4 and
+// IPv6. The socket already holds the destination when tcp_connect runs.
+SEC("fentry/tcp_connect")
+int BPF_PROG(fentry_tcp_connect, struct sock* sk)
+{
+    struct unix_lpm_key unix_key = {};
+    u16 family = BPF_CORE_READ(sk, __sk_common.skc_family);
+    u16 port_net = BPF_CORE_READ(sk, __sk_common.skc_dport);
+
+    if (family == AF_INET)
+        report_connect(family, port_net, &sk->__sk_common.skc_daddr, &unix_key, false);
+    else if (family == AF_INET6)
+        report_connect(family, port_net, &sk->__sk_common.skc_v6_daddr, &unix_key, false);
+    return 0;
+}
+
+static __always_inline bool load_allowed(u32 kind, char* path)
+{
+    char comm[TASK_COMM_LEN] = {};
+    u8 mask = 1 << kind;
+
+    bpf_get_current_comm(comm, sizeof(comm));
+    u8* kinds = bpf_map_lookup_elem(&load_allow_comms, comm);
+    if (kinds && (*kinds & mask))
+        return true;
+    if (path && path[0]) {
+        kinds = bpf_map_lookup_elem(&load_allow_paths, path);
+        if (kinds && (*kinds & mask))
+            return true;
+    }
+    return false;
+}
+
+// Report a load whose name is already in s->path_buf and apply load_policy.
+static __always_inline int handle_kernel_load(struct path_scratch* s, u32 kind, u32 bpf_cmd, u32 prog_type)
+{
+    struct kernel_load_event* event;
+    int ret = 0;
+    u8 response = 0;
+
+    u8* action = bpf_map_lookup_elem(&load_policy, &kind);
+    if (action && *action >= ACTION_BLOCK &&
+        !load_allowed(kind, kind == LOAD_KIND_MODULE && !s->path_cut ? s->path_buf : NULL)) {
+        response = enforce_action(*action);
+        ret = -EPERM;
+    }
+
+    event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_KERNEL_LOAD, response != 0);
+        return ret;
+    }
+
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    fill_event_header(&event->hdr, EVENT_TYPE_KERNEL_LOAD, task);
+    event->hdr.blocked = response != 0;
+    event->hdr.response = response;
+    event->hdr.path_cut = kind == LOAD_KIND_MODULE && s->path_cut;
+    event->kind = kind;
+    event->bpf_cmd = bpf_cmd;
+    event->prog_type = prog_type;
+    __builtin_memset(event->_pad, 0, sizeof(event->_pad));
+    __builtin_memcpy(event->name, s->path_buf, PATH_MAX_LEN);
+
     bpf_ringbuf_submit(event, 0);
     return ret;
 }
 
+// struct iov_iter changed shape across kernels: 5.14 added iter_type, 6.0
+// added ITER_UBUF for single-buffer I/O and 6.4 renamed iov to __iov. These
+// flavours let CO-RE pick whichever the running kernel has.
+struct iov_iter___new {
+    u8 iter_type;
+    void* ubuf;
+    const struct iovec* __iov;
+} __attribute__((preserve_access_index));
+
+struct iov_iter___old {
+    const struct iovec* iov;
+} __attribute__((preserve_access_index));
+
+enum iter_type___aegis {
+    ITER_IOVEC___aegis,
+    ITER_UBUF___aegis,
+};
+
+// The user buffer a sendmsg starts at. Resolvers send a DNS query as one
+// buffer, so only the first iovec segment is read.
+static __always_inline const void* msg_user_data(struct msghdr* msg)
+{
+    struct iov_iter___new* iter = (void*)&msg->msg_iter;
+    if (!bpf_core_field_exists(iter->iter_type))
+        return NULL;
+
+    u8 type = BPF_CORE_READ(iter, iter_type);
+    if (bpf_core_enum_value_exists(enum iter_type___aegis, ITER_UBUF___aegis) &&
+        type == bpf_core_enum_value(enum iter_type___aegis, ITER_UBUF___aegis))
+        return BPF_CORE_READ(iter, ubuf);
+    if (type != bpf_core_enum_value(enum iter_type___aegis, ITER_IOVEC___aegis))
+        return NULL;
+
+    const struct iovec* iov;
+    if (bpf_core_field_exists(iter->__iov))
+        iov = BPF_CORE_READ(iter, __iov);
+    else
+        iov = BPF_CORE_READ((struct iov_iter___old*)iter, iov);
+    if (!iov)
+        return NULL;
+    return BPF_CORE_READ(iov, iov_base);
+}
+
+// DNS queries are parsed in userspace; this only copies the message. UDP
+// sockets are remembered so socket_sock_rcv_skb can report the reply.
+SEC("lsm/socket_sendmsg")
+int BPF_PROG(lsm_socket_sendmsg, struct socket* sock, struct msghdr* msg, int size)
+{
+    struct dns_event* event;
+    u16 family = 0;
+    u16 port_net = 0;
+
+    if (size <= 0)
+        return 0;
+    struct sock* sk = BPF_CORE_READ(sock, sk);
+    if (!sk)
+        return 0;
+    short type = BPF_CORE_READ(sock, type);
+    if (type != SOCK_DGRAM && type != SOCK_STREAM)
+        return 0;
+
+    // Unconnected UDP names the resolver in msg_name; connected sockets
+    // already hold it.
+    struct sockaddr* name = BPF_CORE_READ(msg, msg_name);
+    if (name) {
+        bpf_probe_read_kernel(&family, sizeof(family), &name->sa_family);
+        if (family == AF_INET)
+            bpf_probe_read_kernel(&port_net, sizeof(port_net), &((struct sockaddr_in*)name)->sin_port);
+        else if (family == AF_INET6)
+            bpf_probe_read_kernel(&port_net, sizeof(port_net), &((struct sockaddr_in6*)name)->sin6_port);
+    } else {
+        family = BPF_CORE_READ(sk, __sk_common.skc_family);
+        port_net = BPF_CORE_READ(sk, __sk_common.skc_dport);
+    }
+    if ((family != AF_INET && family != AF_INET6) || __bpf_ntohs(port_net) != DNS_PORT)
+        return 0;
+
+    const void* data = msg_user_data(msg);
+    if (!data)
+        return 0;
+
+    event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_DNS, false);
+        return 0;
+    }
+
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    fill_event_header(&event->hdr, EVENT_TYPE_DNS, task);
+    event->family = family;
+    event->port = DNS_PORT;
+    event->transport = type == SOCK_DGRAM ? DNS_TRANSPORT_UDP : DNS_TRANSPORT_TCP;
+    event->response = 0;
+    __builtin_memset(event->_pad, 0, sizeof(event->_pad));
+    event->addr_v4 = 0;
+    __builtin_memset(event->addr_v6, 0, sizeof(event->addr_v6));
+    if (name && family == AF_INET)
+        bpf_probe_read_kernel(&event->addr_v4, sizeof(event->addr_v4), &((struct sockaddr_in*)name)->sin_addr.s_addr);
+    else if (name)
+        bpf_probe_read_kernel(event->addr_v6, sizeof(event->addr_v6), &((struct sockaddr_in6*)name)->sin6_addr);
+    else if (family == AF_INET)
+        event->addr_v4 = BPF_CORE_READ(sk, __sk_common.skc_daddr);
+    else
+        BPF_CORE_READ_INTO(&event->addr_v6, sk, __sk_common.skc_v6_daddr);
+
+    u32 len = size;
+    if (len > DNS_PAYLOAD_LEN - 1)
+        len = DNS_PAYLOAD_LEN - 1;
+    event->len = len;
+    if (bpf_probe_read_user(event->payload, len & (DNS_PAYLOAD_LEN - 1), data)) {
+        bpf_ringbuf_discard(event, 0);
+        return 0;
+    }
+
+    if (type == SOCK_DGRAM) {
+        u64 key = (u64)sk;
+        struct dns_owner owner = {
+            .cgroup_id = event->hdr.cgroup_id,
+            .pid = event->hdr.pid,
+            .tid = event->hdr.tid,
+            .uid = event->hdr.uid,
+            .gid = event->hdr.gid,
+        };
+        __builtin_memcpy(owner.comm, event->hdr.comm, TASK_COMM_LEN);
+        owner.ns = event->hdr.ns;
+        bpf_map_update_elem(&dns_socks, &key, &owner, BPF_ANY);
+    }
+
+    bpf_ringbuf_submit(event, 0);
+    return 0;
+}
+
+// Runs before UDP strips its header, so skb->data is the UDP header. The
+// resolver address comes from the IP header.
+SEC("lsm/socket_sock_rcv_skb")
+int BPF_PROG(lsm_socket_sock_rcv_skb, struct sock* sk, struct sk_buff* skb)
+{
+    struct dns_event* event;
+    struct udphdr udp;
+
+    u64 key = (u64)sk;
+    struct dns_owner* owner = bpf_map_lookup_elem(&dns_socks, &key);
+    if (!owner)
+        return 0;
+
+    unsigned char* data = BPF_CORE_READ(skb, data);
+    u32 skb_len = BPF_CORE_READ(skb, len);
+    u32 linear_len = skb_len - BPF_CORE_READ(skb, data_len);
+    if (!data || linear_len <= sizeof(udp) || bpf_probe_read_kernel(&udp, sizeof(udp), data))
+        return 0;
+    if (__bpf_ntohs(udp.source) != DNS_PORT)
+        return 0;
+
+    event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_DNS, false);
+        return 0;
+    }
+
+    // The reply arrives in softirq context, so the header describes the
+    // task that sent the query rather than the current one.
+    __builtin_memset(&event->hdr, 0, sizeof(event->hdr));
+    event->hdr.timestamp_ns = bpf_ktime_get_ns();
+    event->hdr.cgroup_id = owner->cgroup_id;
+    event->hdr.pid = owner->pid;
+    event->hdr.tid = owner->tid;
+    event->hdr.uid = owner->uid;
+    event->hdr.gid = owner->gid;
+    event->hdr.type = EVENT_TYPE_DNS;
+    __builtin_memcpy(event->hdr.comm, owner->comm, TASK_COMM_LEN);
+    event->hdr.ns = owner->ns;
+
+    u16 family = BPF_CORE_READ(sk, __sk_common.skc_family);
+    unsigned char* head = BPF_CORE_READ(skb, head);
+    u16 network_header = BPF_CORE_READ(skb, network_header);
+    event->family = family;
+    event->port = DNS_PORT;
+    event->transport = DNS_TRANSPORT_UDP;
+    event->response = 1;
+    __builtin_memset(event->_pad, 0, sizeof(event->_pad));
+    event->addr_v4 = 0;
+    __builtin_memset(event->addr_v6, 0, sizeof(event->addr_v6));
+    if (family == AF_INET) {
+        struct iphdr* ip = (struct iphdr*)(head + network_header);
+        bpf_probe_read_kernel(&event->addr_v4, sizeof(event->addr_v4), &ip->saddr);
+    } else {
+        struct ipv6hdr* ip6 = (struct ipv6hdr*)(head + network_header);
+        bpf_probe_read_kernel(event->addr_v6, sizeof(event->addr_v6), &ip6->saddr);
+    }
+
+    u32 len = linear_len - sizeof(udp);
+    if (len > DNS_PAYLOAD_LEN - 1)
+        len = DNS_PAYLOAD_LEN - 1;
+    event->len = len;
+    if (bpf_probe_read_kernel(event->payload, len & (DNS_PAYLOAD_LEN - 1), data + sizeof(udp))) {
+        bpf_ringbuf_discard(event, 0);
+        return 0;
+    }
+
+    bpf_ringbuf_submit(event, 0);
+    return 0;
+}
+
+static __always_inline u8 check_listen_action(struct listen_rule_key* key)
+{
+    u8 result = 0;
+    u16 port = key->port;
+    char comm[TASK_COMM_LEN];
+    __builtin_memcpy(comm, key->comm, TASK_COMM_LEN);
+
+#pragma unroll
+    for (int i = 0; i < 4; i++) {
+        key->port = (i & 1) ? 0 : port;
+        if (i & 2)
+            __builtin_memset(key->comm, 0, TASK_COMM_LEN);
+        else
+            __builtin_memcpy(key->comm, comm, TASK_COMM_LEN);
+
+        u8* action = bpf_map_lookup_elem(&listen_rules, key);
+        if (!action)
+            continue;
+        if (*action == LISTEN_RULE_ALLOW)
+            return 0;
+        if (*action > result)
+            result = *action;
+    }
+    return result;
+}
+
+static __always_inline struct listen_event* reserve_listen_event(
+    struct sock* sk, u8 op, u8 protocol, u16 family, u16 port, u8 response)
+{
+    struct listen_event* event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_LISTEN, response != 0);
+        return NULL;
+    }
+
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    fill_event_header(&event->hdr, EVENT_TYPE_LISTEN, task);
+    event->hdr.blocked = response != 0;
+    event->hdr.response = response;
+    event->cookie = bpf_get_socket_cookie(sk);
+    event->family = family;
+    event->port = port;
+    event->protocol = protocol;
+    event->op = op;
+    __builtin_memset(event->_pad, 0, sizeof(event->_pad));
+    event->addr_v4 = 0;
+    __builtin_memset(event->addr_v6, 0, sizeof(event->addr_v6));
+    event->backlog = 0;
+    return event;
+}
+
+static __always_inline void submit_listen_event(struct listen_event* event)
+{
+    if (!event->hdr.blocked)
+        bpf_map_update_elem(&listen_socks, &event->cookie, event, BPF_ANY);
+    bpf_ringbuf_submit(event, 0);
+}
+
+// TCP sockets are checked and reported when they start listening; a bind on
+// its own only matters for UDP, where it is how a server listens. Binds to
+// port 0 take an ephemeral port and are not reported.
+SEC("lsm/socket_bind")
+int BPF_PROG(lsm_socket_bind, struct socket* sock, struct sockaddr* address, int addrlen)
+{
+    struct listen_event* event;
+    u16 family = 0;
+    u16 port_net = 0;
+    int ret = 0;
+    u8 response = 0;
+
+    // Read sk directly so it keeps its BTF type for bpf_get_socket_cookie.
+    struct sock* sk = sock->sk;
+    if (!address || !sk || sock->type != SOCK_DGRAM)
+        return 0;
+
+    bpf_probe_read_kernel(&family, sizeof(family), &address->sa_family);
+    if (family == AF_INET)
+        bpf_probe_read_kernel(&port_net, sizeof(port_net), &((struct sockaddr_in*)address)->sin_port);
+    else if (family == AF_INET6)
+        bpf_probe_read_kernel(&port_net, sizeof(port_net), &((struct sockaddr_in6*)address)->sin6_port);
+    else
+        return 0;
+    u16 port = __bpf_ntohs(port_net);
+    if (!port)
+        return 0;
+
+    struct listen_rule_key key = { .port = port };
+    bpf_get_current_comm(&key.comm, sizeof(key.comm));
+    response = enforce_action(check_listen_action(&key));
+    if (response)
+        ret = -EPERM;
+
+    event = reserve_listen_event(sk, LISTEN_OP_BIND, IPPROTO_UDP, family, port, response);
+    if (!event)
+        return ret;
+    if (family == AF_INET)
+        bpf_probe_read_kernel(&event->addr_v4, sizeof(event->addr_v4), &((struct sockaddr_in*)address)->sin_addr.s_addr);
+    else
+        bpf_probe_read_kernel(event->addr_v6, sizeof(event->addr_v6), &((struct sockaddr_in6*)address)->sin6_addr);
+
+    submit_listen_event(event);
+    return ret;
+}
+
+// Runs before inet_listen(), so the port is the bound one, or 0 when the
+// socket was never bound and the kernel is about to pick one.
+SEC("lsm/socket_listen")
+int BPF_PROG(lsm_socket_listen, struct socket* sock, int backlog)
+{
+    struct listen_event* event;
+    int ret = 0;
+    u8 response = 0;
+
+    struct sock* sk = sock->sk;
+    if (!sk || sock->type != SOCK_STREAM)
+        return 0;
+    u16 family = BPF_CORE_READ(sk, __sk_common.skc_family);
+    if (family != AF_INET && family != AF_INET6)
+        return 0;
+    u16 port = BPF_CORE_READ(sk, __sk_common.skc_num);
+
+    struct listen_rule_key key = { .port = port };
+    bpf_get_current_comm(&key.comm, sizeof(key.comm));
+    response = enforce_action(check_listen_action(&key));
+    if (response)
+        ret = -EPERM;
+
+    event = reserve_listen_event(sk, LISTEN_OP_LISTEN, IPPROTO_TCP, family, port, response);
+    if (!event)
+        return ret;
+    event->backlog = backlog;
+    if (family == AF_INET)
+        event->addr_v4 = BPF_CORE_READ(sk, __sk_common.skc_rcv_saddr);
+    else
+        BPF_CORE_READ_INTO(&event->addr_v6, sk, __sk_common.skc_v6_rcv_saddr);
+
+    submit_listen_event(event);
+    return ret;
+}
+
+// May run from softirq or RCU context, so the close is attributed from the
+// stored listen record rather than the current task. Sockets that never got
+// a cookie were never reported.
+SEC("lsm/sk_free_security")
+int BPF_PROG(lsm_sk_free_security, struct sock* sk)
+{
+    u64 key = (u64)sk;
+    bpf_map_delete_elem(&dns_socks, &key);
+
+    u64 cookie = BPF_CORE_READ(sk, __sk_common.skc_cookie.counter);
+    if (!cookie)
+        return 0;
+    struct listen_event* listener = bpf_map_lookup_elem(&listen_socks, &cookie);
+    if (!listener)
+        return 0;
+
+    struct listen_event* event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (event) {
+        __builtin_memcpy(event, listener, sizeof(*event));
+        event->hdr.timestamp_ns = bpf_ktime_get_ns();
+        event->op = LISTEN_OP_CLOSE;
+        bpf_ringbuf_submit(event, 0);
+    } else {
+        count_drop(EVENT_TYPE_LISTEN, false);
+    }
+    bpf_map_delete_elem(&listen_socks, &cookie);
+    return 0;
+}
+
+// finit_module(): the module is read from a file we can name.
+SEC("lsm/kernel_read_file")
+int BPF_PROG(lsm_kernel_read_file, struct file* file, enum kernel_read_file_id id, bool contents)
+{
+    if (id != bpf_core_enum_value(enum kernel_read_file_id, READING_MODULE) || !file)
+        return 0;
+
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
+        return 0;
+
+    resolve_file_path(s, &file->f_path, s->path_buf, false);
+    return handle_kernel_load(s, LOAD_KIND_MODULE, 0, 0);
+}
+
+// init_module(): the image comes straight from user memory, so there is no
+// path and only the comm allowlist applies.
+SEC("lsm/kernel_load_data")
+int BPF_PROG(lsm_kernel_load_data, enum kernel_load_data_id id, bool contents)
+{
+    if (id != bpf_core_enum_value(enum kernel_load_data_id, LOADING_MODULE))
+        return 0;
+
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
+        return 0;
+
+    __builtin_memset(s->path_buf, 0, PATH_MAX_LEN);
+    s->path_cut = false;
+    return handle_kernel_load(s, LOAD_KIND_MODULE, 0, 0);
+}
+
+// The object behind fd in the task's fd table, or NULL. Only read for bpf()
+// link and map commands, where fds of any other kind fail anyway.
+static __always_inline void* bpf_fd_object(struct task_struct* task, u32 fd)
+{
+    struct fdtable* fdt = BPF_CORE_READ(task, files, fdt);
+    if (!fdt || fd >= BPF_CORE_READ(fdt, max_fds))
+        return NULL;
+
+    struct file** fds = BPF_CORE_READ(fdt, fd);
+    struct file* file = NULL;
+    bpf_probe_read_kernel(&file, sizeof(file), &fds[fd]);
+    if (!file)
+        return NULL;
+    return BPF_CORE_READ(file, private_data);
+}
+
+// Reports a bpf() command self-protection denied as a BPF kernel_load event
+// carrying the command.
+static __always_inline int deny_bpf_cmd(struct task_struct* task, int cmd)
+{
+    struct kernel_load_event* event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_KERNEL_LOAD, true);
+        return -EPERM;
+    }
+
+    fill_event_header(&event->hdr, EVENT_TYPE_KERNEL_LOAD, task);
+    event->hdr.blocked = 1;
+    event->hdr.response = ACTION_BLOCK;
+    event->hdr.self_protect = 1;
+    event->kind = LOAD_KIND_BPF;
+    event->bpf_cmd = cmd;
+    event->prog_type = 0;
+    __builtin_memset(event->_pad, 0, sizeof(event->_pad));
+    __builtin_memset(event->name, 0, PATH_MAX_LEN);
+    bpf_ringbuf_submit(event, 0);
+    return -EPERM;
+}
+
+// Self-protection for BPF_LINK_DETACH and BPF_LINK_UPDATE on one of Aegis's
+// links.
+static __always_inline int guard_link(int cmd, union bpf_attr* attr)
+{
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    if (!self_protection_on())
+        return 0;
+
+    u32 fd = cmd == BPF_LINK_DETACH ? BPF_CORE_READ(attr, link_detach.link_fd)
+                                    : BPF_CORE_READ(attr, link_update.link_fd);
+    struct bpf_link* link = bpf_fd_object(task, fd);
+    if (!link)
+        return 0;
+    u32 id = BPF_CORE_READ(link, id);
+    if (!id || !bpf_map_lookup_elem(&aegis_links, &id) || is_protect_admin(task))
+        return 0;
+    return deny_bpf_cmd(task, cmd);
+}
+
+// Whether self-protection denies the current task write access to the map
+// with the given ID.
+static __always_inline bool guards_map(u32 id, struct task_struct* task)
+{
+    if (!id || !self_protection_on() || !bpf_map_lookup_elem(&aegis_maps, &id))
+        return false;
+    return !is_protect_admin(task);
+}
+
+// Self-protection for the bpf() commands that change one of Aegis's maps
+// through an fd, and for BPF_MAP_GET_FD_BY_ID asking for a writable fd.
+static __always_inline int guard_map(int cmd, union bpf_attr* attr)
+{
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    u32 id = 0;
+
+    switch (cmd) {
+    case BPF_MAP_GET_FD_BY_ID:
+        if (BPF_CORE_READ(attr, open_flags) & BPF_F_RDONLY)
+            return 0;
+        id = BPF_CORE_READ(attr, map_id);
+        break;
+    case BPF_MAP_UPDATE_ELEM:
+    case BPF_MAP_DELETE_ELEM:
+    case BPF_MAP_LOOKUP_AND_DELETE_ELEM:
+    case BPF_MAP_FREEZE: {
+        struct bpf_map* map = bpf_fd_object(task, BPF_CORE_READ(attr, map_fd));
+        if (map)
+            id = BPF_CORE_READ(map, id);
+        break;
+    }
+    case BPF_MAP_UPDATE_BATCH:
+    case BPF_MAP_DELETE_BATCH:
+    case BPF_MAP_LOOKUP_AND_DELETE_BATCH: {
+        struct bpf_map* map = bpf_fd_object(task, BPF_CORE_READ(attr, batch.map_fd));
+        if (map)
+            id = BPF_CORE_READ(map, id);
+        break;
+    }
+    default:
+        return 0;
+    }
+
+    if (!guards_map(id, task))
+        return 0;
+    return deny_bpf_cmd(task, cmd);
+}
+
+SEC("lsm/bpf")
+int BPF_PROG(lsm_bpf, int cmd, union bpf_attr* attr, unsigned int size)
+{
+    struct aegis_config* cfg = get_aegis_config();
+    if (!attr || !cfg || (bpf_get_current_pid_tgid() >> 32) == cfg->tgid)
+        return 0;
+    if (cmd == BPF_LINK_DETACH || cmd == BPF_LINK_UPDATE)
+        return guard_link(cmd, attr);
+    if (cmd != BPF_PROG_LOAD)
+        return guard_map(cmd, attr);
+
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
+        return 0;
+
+    __builtin_memset(s->path_buf, 0, PATH_MAX_LEN);
+    bpf_probe_read_kernel_str(s->path_buf, BPF_OBJ_NAME_LEN, &attr->prog_name);
+    return handle_kernel_load(s, LOAD_KIND_BPF, cmd, BPF_CORE_READ(attr, prog_type));
+}
+
+// Runs whenever a map fd is handed out. BPF_MAP_GET_FD_BY_ID is already
+// checked by lsm_bpf, so a writable fd on one of Aegis's maps denied here
+// comes from BPF_OBJ_GET on its pin, under whatever path the bpffs is
+// reached by.
+SEC("lsm/bpf_map")
+int BPF_PROG(lsm_bpf_map, struct bpf_map* map, fmode_t fmode)
+{
+    struct aegis_config* cfg = get_aegis_config();
+    if (!cfg || (bpf_get_current_pid_tgid() >> 32) == cfg->tgid || !(fmode & FMODE_WRITE))
+        return 0;
+
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    if (!guards_map(BPF_CORE_READ(map, id), task))
+        return 0;
+    return deny_bpf_cmd(task, BPF_OBJ_GET);
+}
+
+static __always_inline u8 check_ptrace_action(struct ptrace_rule_key* key)
+{
+    u8 result = 0;
+    u8* action = bpf_map_lookup_elem(&ptrace_rules, key);
+    if (action) {
+        if (*action == PTRACE_RULE_ALLOW)
+            return 0;
+        result = *action;
+    }
+
+    char tracer[TASK_COMM_LEN];
+    __builtin_memcpy(tracer, key->tracer, TASK_COMM_LEN);
+    __builtin_memset(key->tracer, 0, TASK_COMM_LEN);
+    action = bpf_map_lookup_elem(&ptrace_rules, key);
+    if (action) {
+        if (*action == PTRACE_RULE_ALLOW)
+            return 0;
+        if (*action > result)
+            result = *action;
+    }
+
+    __builtin_memcpy(key->tracer, tracer, TASK_COMM_LEN);
+    __builtin_memset(key->target, 0, TASK_COMM_LEN);
+    action = bpf_map_lookup_elem(&ptrace_rules, key);
+    if (action) {
+        if (*action == PTRACE_RULE_ALLOW)
+            return 0;
+        if (*action > result)
+            result = *action;
+    }
+
+    return result;
+}
+
+// Read-mode checks fire for every ps(1) walking /proc, so only attach-mode
+// access is reported.
+SEC("lsm/ptrace_access_check")
+int BPF_PROG(lsm_ptrace_access_check, struct task_struct* child, unsigned int mode)
+{
+    struct ptrace_event* event;
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    u32 tgid = bpf_get_current_pid_tgid() >> 32;
+    u32 target_tgid;
+    int ret = 0;
+    u8 response = 0;
+
+    if (!(mode & PTRACE_MODE_ATTACH) || !child)
+        return 0;
+    target_tgid = BPF_CORE_READ(child, tgid);
+    if (target_tgid == tgid)
+        return 0;
+
+    struct ptrace_rule_key key = {};
+    BPF_CORE_READ_STR_INTO(&key.target, child, group_leader, comm);
+    bpf_get_current_comm(&key.tracer, sizeof(key.tracer));
+    char target_comm[TASK_COMM_LEN];
+    __builtin_memcpy(target_comm, key.target, TASK_COMM_LEN);
+    u8 guarded = guards_aegis(target_tgid, task);
+    u8 action = check_ptrace_action(&key);
+    if (guarded && action < ACTION_BLOCK)
+        action = ACTION_BLOCK;
+    response = enforce_action(action);
+    if (response)
+        ret = -EPERM;
+
+    event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_PTRACE, response != 0);
+        return ret;
+    }
+
+    fill_event_header(&event->hdr, EVENT_TYPE_PTRACE, task);
+    event->hdr.blocked = response != 0;
+    event->hdr.response = response;
+    event->hdr.self_protect = guarded;
+    event->target_pid = target_tgid;
+    event->mode = mode;
+    __builtin_memcpy(event->target_comm, target_comm, TASK_COMM_LEN);
+
+    bpf_ringbuf_submit(event, 0);
+    return ret;
+}
+
+// Signals a process sends to another. task_kill only runs for signals from
+// userspace; existence checks (signal 0) are not reported. With
+// self-protection on, SIGKILL and SIGSTOP to Aegis are denied.
+SEC("lsm/task_kill")
+int BPF_PROG(lsm_task_kill, struct task_struct* p, struct kernel_siginfo* info, int sig, const struct cred* cred)
+{
+    struct signal_event* event;
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    u32 tgid = bpf_get_current_pid_tgid() >> 32;
+    int ret = 0;
+    u8 guarded = 0;
+
+    if (!sig || !p)
+        return 0;
+    u32 target_tgid = BPF_CORE_READ(p, tgid);
+    if (target_tgid == tgid)
+        return 0;
+
+    if ((sig == SIGKILL || sig == SIGSTOP) && guards_aegis(target_tgid, task)) {
+        guarded = 1;
+        ret = -EPERM;
+    }
+
+    event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_SIGNAL, guarded);
+        return ret;
+    }
+
+    fill_event_header(&event->hdr, EVENT_TYPE_SIGNAL, task);
+    event->hdr.blocked = guarded;
+    event->hdr.response = guarded ? ACTION_BLOCK : 0;
+    event->hdr.self_protect = guarded;
+    event->target_pid = target_tgid;
+    event->sig = sig;
+    BPF_CORE_READ_STR_INTO(&event->target_comm, p, group_leader, comm);
+
+    bpf_ringbuf_submit(event, 0);
+    return ret;
+}
+
+// kernel_cap_t is a u64 since 6.3 and a u32[2] before; both are 8 bytes in
+// the same little-endian order.
+static __always_inline u64 read_caps(const kernel_cap_t* caps)
+{
+    u64 val = 0;
+    bpf_probe_read_kernel(&val, sizeof(val), caps);
+    return val;
+}
+
+static __always_inline int emit_privilege_event(u32 op, const struct cred* new, const struct cred* old, const kernel_cap_t* new_effective)
+{
+    struct privilege_event* event;
+    u32 old_uid = BPF_CORE_READ(old, uid.val);
+    u32 old_euid = BPF_CORE_READ(old, euid.val);
+    u32 old_gid = BPF_CORE_READ(old, gid.val);
+    u32 old_egid = BPF_CORE_READ(old, egid.val);
+    u32 new_uid = BPF_CORE_READ(new, uid.val);
+    u32 new_euid = BPF_CORE_READ(new, euid.val);
+    u32 new_gid = BPF_CORE_READ(new, gid.val);
+    u32 new_egid = BPF_CORE_READ(new, egid.val);
+    u64 old_caps = read_caps(&old->cap_effective);
+    u64 new_caps = new_effective ? read_caps(new_effective) : read_caps(&new->cap_effective);
+
+    // Dropping to the same identity (e.g. setuid(getuid())) is not interesting.
+    if (old_uid == new_uid && old_euid == new_euid && old_gid == new_gid &&
+        old_egid == new_egid && old_caps == new_caps)
+        return 0;
+
+    event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_PRIVILEGE, false);
+        return 0;
+    }
+
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    fill_event_header(&event->hdr, EVENT_TYPE_PRIVILEGE, task);
+    event->op = op;
+    event->old_uid = old_uid;
+    event->old_euid = old_euid;
+    event->old_gid = old_gid;
+    event->old_egid = old_egid;
+    event->new_uid = new_uid;
+    event->new_euid = new_euid;
+    event->new_gid = new_gid;
+    event->new_egid = new_egid;
+    __builtin_memset(event->_pad, 0, sizeof(event->_pad));
+    event->old_caps = old_caps;
+    event->new_caps = new_caps;
+
+    bpf_ringbuf_submit(event, 0);
+    return 0;
+}
+
+SEC("lsm/task_fix_setuid")
+int BPF_PROG(lsm_task_fix_setuid, struct cred* new, const struct cred* old, int flags)
+{
+    return emit_privilege_event(PRIV_OP_SETUID, new, old, NULL);
+}
+
+SEC("lsm/task_fix_setgid")
+int BPF_PROG(lsm_task_fix_setgid, struct cred* new, const struct cred* old, int flags)
+{
+    return emit_privilege_event(PRIV_OP_SETGID, new, old, NULL);
+}
+
+SEC("lsm/capset")
+int BPF_PROG(lsm_capset, struct cred* new, const struct cred* old,
+    const kernel_cap_t* effective, const kernel_cap_t* inheritable, const kernel_cap_t* permitted)
+{
+    return emit_privilege_event(PRIV_OP_CAPSET, new, old, effective);
+}
+
+// Unlike the event header, the pid entry is pid_ns_for_children: that is what
+// setns(CLONE_NEWPID) and unshare(CLONE_NEWPID) replace.
+static __always_inline void read_ns_ids(struct task_struct* task, struct ns_ids* ids)
+{
+    ids->pid = BPF_CORE_READ(task, nsproxy, pid_ns_for_children, ns.inum);
+    ids->mnt = BPF_CORE_READ(task, nsproxy, mnt_ns, ns.inum);
+    ids->net = BPF_CORE_READ(task, nsproxy, net_ns, ns.inum);
+    ids->uts = BPF_CORE_READ(task, nsproxy, uts_ns, ns.inum);
+    ids->user = BPF_CORE_READ(task, real_cred, user_ns, ns.inum);
+}
+
+static __always_inline int ns_syscall_enter(u8 op, u32 flags)
+{
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    u32 tid = (u32)bpf_get_current_pid_tgid();
+    struct ns_pending pending = {};
+
+    pending.op = op;
+    pending.flags = flags;
+    read_ns_ids(task, &pending.ns);
+    bpf_map_update_elem(&ns_pending, &tid, &pending, BPF_ANY);
+    return 0;
+}
+
+// Reports the call only when it succeeded and moved the thread into at least
+// one other namespace. The header keeps the namespaces from before the call,
+// and flags gain a CLONE_NEW* bit for every namespace that changed (setns with
+// nstype 0 names none).
+static __always_inline int ns_syscall_exit(long ret)
+{
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    u32 tid = (u32)bpf_get_current_pid_tgid();
+    struct ns_pending* pending = bpf_map_lookup_elem(&ns_pending, &tid);
+    if (!pending)
+        return 0;
+
+    struct ns_pending saved = *pending;
+    bpf_map_delete_elem(&ns_pending, &tid);
+    if (ret != 0)
+        return 0;
+
+    struct ns_ids now = {};
+    read_ns_ids(task, &now);
+    u32 changed = 0;
+    if (now.pid != saved.ns.pid)
+        changed |= CLONE_NEWPID;
+    if (now.mnt != saved.ns.mnt)
+        changed |= CLONE_NEWNS;
+    if (now.net != saved.ns.net)
+        changed |= CLONE_NEWNET;
+    if (now.uts != saved.ns.uts)
+        changed |= CLONE_NEWUTS;
+    if (now.user != saved.ns.user)
+        changed |= CLONE_NEWUSER;
+    if (!changed)
+        return 0;
+
+    struct ns_event* event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_NAMESPACE, false);
+        return 0;
+    }
+
+    fill_event_header(&event->hdr, EVENT_TYPE_NAMESPACE, task);
+    event->hdr.ns.mnt = saved.ns.mnt;
+    event->hdr.ns.net = saved.ns.net;
+    event->hdr.ns.uts = saved.ns.uts;
+    event->hdr.ns.user = saved.ns.user;
+    event->op = saved.op;
+    __builtin_memset(event->_pad, 0, sizeof(event->_pad));
+    event->flags = saved.flags | changed;
+    event->new_ns = now;
+    __builtin_memset(event->_pad2, 0, sizeof(event->_pad2));
+    __builtin_memset(event->fstype, 0, sizeof(event->fstype));
+    __builtin_memset(event->source, 0, sizeof(event->source));
+    __builtin_memset(event->target, 0, sizeof(event->target));
+
+    bpf_ringbuf_submit(event, 0);
+    return 0;
+}
+
+SEC("tracepoint/syscalls/sys_enter_setns")
+int handle_setns_enter(struct trace_event_raw_sys_enter* ctx)
+{
+    return ns_syscall_enter(NS_OP_SETNS, (u32)ctx->args[1]);
+}
+
+SEC("tracepoint/syscalls/sys_exit_setns")
+int handle_setns_exit(struct trace_event_raw_sys_exit* ctx)
+{
+    return ns_syscall_exit(ctx->ret);
+}
+
+SEC("tracepoint/syscalls/sys_enter_unshare")
+int handle_unshare_enter(struct trace_event_raw_sys_enter* ctx)
+{
+    return ns_syscall_enter(NS_OP_UNSHARE, (u32)ctx->args[0]);
+}
+
+SEC("tracepoint/syscalls/sys_exit_unshare")
+int handle_unshare_exit(struct trace_event_raw_sys_exit* ctx)
+{
+    return ns_syscall_exit(ctx->ret);
+}
+
+static __always_inline struct ns_event* reserve_mount_event(u8 op, u32 flags)
+{
+    struct ns_event* event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_NAMESPACE, false);
+        return NULL;
+    }
+
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    fill_event_header(&event->hdr, EVENT_TYPE_NAMESPACE, task);
+    event->op = op;
+    __builtin_memset(event->_pad, 0, sizeof(event->_pad));
+    event->flags = flags;
+    __builtin_memset(&event->new_ns, 0, sizeof(event->new_ns));
+    __builtin_memset(event->_pad2, 0, sizeof(event->_pad2));
+    __builtin_memset(event->fstype, 0, sizeof(event->fstype));
+    __builtin_memset(event->source, 0, sizeof(event->source));
+    return event;
+}
+
+// dev_name and type are kernel copies of the mount(2) strings. Mounts are only
+// observed; the escape rules that look at them alert.
+SEC("lsm/sb_mount")
+int BPF_PROG(lsm_sb_mount, const char* dev_name, const struct path* path,
+    const char* type, unsigned long flags, void* data)
+{
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
+        return 0;
+
+    struct ns_event* event = reserve_mount_event(NS_OP_MOUNT, (u32)flags);
+    if (!event)
+        return 0;
+
+    if (type)
+        bpf_probe_read_kernel_str(event->fstype, sizeof(event->fstype), type);
+    if (dev_name)
+        bpf_probe_read_kernel_str(event->source, sizeof(event->source), dev_name);
+    resolve_file_path(s, (struct path*)path, event->target, false);
+    event->hdr.path_cut = s->path_cut;
+
+    bpf_ringbuf_submit(event, 0);
+    return 0;
+}
+
+// move_mount(2) attaches a detached tree from open_tree/fsmount, which is how
+// a bind of the host root can bypass sb_mount. The source is the device name
+// recorded on the mount being moved.
+SEC("lsm/move_mount")
+int BPF_PROG(lsm_move_mount, const struct path* from_path, const struct path* to_path)
+{
+    u32 scratch_key = 0;
+    struct path_scratch* s = bpf_map_lookup_elem(&scratch, &scratch_key);
+    if (!s)
+        return 0;
+
+    struct ns_event* event = reserve_mount_event(NS_OP_MOVE_MOUNT, 0);
+    if (!event)
+        return 0;
+
+    struct vfsmount* vfsmnt = BPF_CORE_READ(from_path, mnt);
+    struct mount* mnt = container_of(vfsmnt, struct mount, mnt);
+    bpf_probe_read_kernel_str(event->source, sizeof(event->source), BPF_CORE_READ(mnt, mnt_devname));
+    bpf_probe_read_kernel_str(event->fstype, sizeof(event->fstype), BPF_CORE_READ(vfsmnt, mnt_sb, s_type, name));
+    resolve_file_path(s, (struct path*)to_path, event->target, false);
+    event->hdr.path_cut = s->path_cut;
+
+    bpf_ringbuf_submit(event, 0);
+    return 0;
+}
+
+// New threads share their process's entry; only new processes are recorded.
+SEC("tp_btf/sched_process_fork")
+int BPF_PROG(handle_sched_process_fork, struct task_struct* parent, struct task_struct* child)
+{
+    u32 pid = BPF_CORE_READ(child, pid);
+    if (pid != BPF_CORE_READ(child, tgid))
+        return 0;
+
+    u32 ppid = get_parent_pid(child);
+    bpf_map_update_elem(&pid_to_ppid, &pid, &ppid, BPF_ANY);
+    return 0;
+}
+
+// Fires once per thread in do_exit(); only the last thread of a group reports,
+// so userspace sees one exit per process.
+SEC("tp_btf/sched_process_exit")
+int BPF_PROG(handle_sched_process_exit, struct task_struct* p)
+{
+    struct task_struct* task = (struct task_struct*)bpf_get_current_task_btf();
+    struct exit_event* event;
+    u32 pid = bpf_get_current_pid_tgid() >> 32;
+
+    if (BPF_CORE_READ(task, signal, live.counter) != 0)
+        return 0;
+
+    bpf_map_delete_elem(&pid_to_ppid, &pid);
+
+    event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        count_drop(EVENT_TYPE_EXIT, false);
+        return 0;
+    }
+
+    fill_event_header(&event->hdr, EVENT_TYPE_EXIT, task);
+
+    // exit_code holds the wait(2) status: code in bits 8-15, signal in 0-6.
+    u32 status = BPF_CORE_READ(task, exit_code);
+    event->ppid = get_parent_pid(task);
+    event->exit_code = (status >> 8) & 0xff;
+    event->signal = status & 0x7f;
+    event->core_dumped = (status & 0x80) ? 1 : 0;
+    __builtin_memset(event->_pad, 0, sizeof(event->_pad));
+    event->runtime_ns = event->hdr.timestamp_ns - BPF_CORE_READ(task, group_leader, start_time);
+
+    bpf_ringbuf_submit(event, 0);
+    return 0;
+}
+
 char LICENSE[] SEC("license") = "GPL";
@@ -17,6 +17,7 @@ import (
 	"aegis/internal/app"
 	"aegis/internal/platform/ai/runtime"
 	internalconfig "aegis/internal/platform/config"
+	internalebpf "aegis/internal/platform/ebpf"
 	httpapi "aegis/internal/platform/http"
 )
 
@@ -28,6 +29,14 @@ func main() {
 	if os.Geteuid() != 0 {
 		log.Fatalf("must run as root (current euid=%d)", os.Geteuid())
 	}
+	if cfg.Kernel.Unpin {
+		removed, err := internalebpf.Unpin(cfg.Kernel)
+		if err != nil {
+			log.Fatalf("unpin: %v", err)
+		}
+		log.Printf("Removed %d pinned BPF objects from %s", removed, cfg.Kernel.PinPath)
+		return
+	}
 
 	prewarmAIRuntime(cfg)
 
@@ -30,6 +30,14 @@ export interface Settings {
   kernel: {
     bpf_path: string
     ring_buffer_size: number
+    self_protection: {
+      enabled: boolean
+      admin_paths: string[] | null
+      admin_uids: number[] | null
+    }
+    capture_env: boolean
+    pin: boolean
+    pin_path: string
   }
   telemetry: {
     process_tree_max_age: string
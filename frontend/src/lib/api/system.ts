@@ -9,6 +9,16 @@ export interface SystemStats {
   alertCount: number
   probeStatus: string
   probeError?: string
+  probeWarning?: string
+  ringbuf?: RingbufStats
+}
+
+export interface RingbufStats {
+  dropped: number
+  droppedBlocked: number
+  droppedByType?: Record<string, number>
+  pendingBytes: number
+  bufferSize: number
 }
 
 export interface Alert {
@@ -24,6 +34,42 @@ export interface Alert {
   blocked: boolean
 }
 
+export interface Listener {
+  pid: number
+  cgroupId: string
+  processName: string
+  protocol: 'tcp' | 'udp'
+  family: number
+  addr: string
+  port: number
+  since: number
+}
+
+export interface HookCapability {
+  name: string
+  supported: boolean
+  attached: boolean
+  error?: string
+}
+
+export interface RejectedProgram {
+  name: string
+  error: string
+}
+
+export interface Capabilities {
+  kernelVersion: string
+  btf: boolean
+  ringBuffer: boolean
+  bpfLsm: boolean
+  activeLsms: string[] | null
+  dPath: boolean
+  sendSignal: boolean
+  cgroupMode: 'unified' | 'hybrid' | 'legacy'
+  lsmHooks: HookCapability[]
+  rejectedPrograms?: RejectedProgram[]
+}
+
 type EventCallback<T> = (data: T) => void
 type UnsubscribeFn = () => void
 
@@ -38,6 +84,14 @@ export async function getAlerts(): Promise<Alert[]> {
   return requestJSON<Alert[]>(`${API_BASE}/alerts`)
 }
 
+export async function getListeners(): Promise<Listener[]> {
+  return requestJSON<Listener[]>(`${API_BASE}/listeners`)
+}
+
+export async function getCapabilities(): Promise<Capabilities> {
+  return requestJSON<Capabilities>(`${API_BASE}/capabilities`)
+}
+
 export function subscribeToAlerts(callback: EventCallback<Alert[]>): UnsubscribeFn {
   alertListeners.add(callback)
 
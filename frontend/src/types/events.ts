@@ -1,6 +1,16 @@
 // Event Types - Phase 4
 
-export type EventType = 'exec' | 'file' | 'connect'
+export type EventType = 'exec' | 'file' | 'connect' | 'exit' | 'privilege' | 'kernel_load' | 'ptrace' | 'dns' | 'listen' | 'namespace' | 'signal'
+
+// Namespace inode numbers of the process; host is true outside containers
+export interface Namespaces {
+  pid: number
+  mnt: number
+  net: number
+  user: number
+  uts: number
+  host: boolean
+}
 
 export interface ExecEvent {
   id: string
@@ -9,24 +19,34 @@ export interface ExecEvent {
   pid: number
   ppid?: number
   cgroupId: string
+  namespaces?: Namespaces
   processName: string
   parentComm: string
   filename: string
   commandLine: string
+  args?: string[] // full argv; commandLine is args joined by spaces
+  env?: string[]
+  argsTruncated?: boolean
+  fileless?: boolean
+  deletedBinary?: boolean
   blocked: boolean
 }
 
+export type FileOperation = 'read' | 'write' | 'delete' | 'rename' | 'chmod'
+
 export interface FileEvent {
   id: string
   type: 'file'
   timestamp: number
   pid: number
   cgroupId: string
+  namespaces?: Namespaces
   processName: string
   filename: string
   flags: number
   ino?: number
   dev?: number
+  operation?: FileOperation
   blocked: boolean
 }
 
@@ -36,14 +56,241 @@ export interface ConnectEvent {
   timestamp: number
   pid: number
   cgroupId: string
+  namespaces?: Namespaces
+  processName: string
+  family: number
+  port: number
+  addr: string
+  domain?: string
+  blocked: boolean
+}
+
+export interface DnsEvent {
+  id: string
+  type: 'dns'
+  timestamp: number
+  pid: number
+  cgroupId: string
+  namespaces?: Namespaces
+  processName: string
+  family: number
+  port: number
+  addr: string
+  domain?: string
+  operation: 'query' | 'response'
+  queryType?: string
+  transport?: 'udp' | 'tcp'
+  answers?: string[]
+  blocked: boolean
+}
+
+export interface ListenEvent {
+  id: string
+  type: 'listen'
+  timestamp: number
+  pid: number
+  cgroupId: string
+  namespaces?: Namespaces
   processName: string
   family: number
   port: number
   addr: string
+  operation: 'bind' | 'listen' | 'close'
+  protocol: 'tcp' | 'udp'
   blocked: boolean
 }
 
-export type SecurityEvent = ExecEvent | FileEvent | ConnectEvent
+// setns/unshare/mount. namespaces are the ones before the call and
+// newNamespaces the ones after it; filename is a mount's target.
+export interface NamespaceEvent {
+  id: string
+  type: 'namespace'
+  timestamp: number
+  pid: number
+  ppid?: number
+  cgroupId: string
+  namespaces?: Namespaces
+  processName: string
+  parentComm?: string
+  operation: 'setns' | 'unshare' | 'mount' | 'move_mount'
+  flags?: number
+  changedNamespaces?: string[]
+  newNamespaces?: Namespaces
+  intoHost?: boolean
+  fsType?: string
+  source?: string
+  filename?: string
+  blocked: boolean
+}
+
+export interface ExitEvent {
+  id: string
+  type: 'exit'
+  timestamp: number
+  pid: number
+  ppid?: number
+  cgroupId: string
+  namespaces?: Namespaces
+  processName: string
+  exitCode?: number
+  signal?: number
+  runtimeMs?: number
+  blocked: boolean
+}
+
+export interface CredentialChange {
+  operation: 'setuid' | 'setgid' | 'capset'
+  oldUid: number
+  oldEuid: number
+  oldGid: number
+  oldEgid: number
+  newUid: number
+  newEuid: number
+  newGid: number
+  newEgid: number
+  oldCaps: string
+  newCaps: string
+}
+
+export interface PrivilegeEvent {
+  id: string
+  type: 'privilege'
+  timestamp: number
+  pid: number
+  ppid?: number
+  cgroupId: string
+  namespaces?: Namespaces
+  processName: string
+  parentComm?: string
+  credentials?: CredentialChange
+  blocked: boolean
+}
+
+export interface KernelLoadEvent {
+  id: string
+  type: 'kernel_load'
+  timestamp: number
+  pid: number
+  cgroupId: string
+  namespaces?: Namespaces
+  processName: string
+  loadKind: 'module' | 'bpf'
+  filename?: string
+  progType?: string
+  progName?: string
+  bpfCommand?: string // link_detach/link_update denied by self-protection
+  blocked: boolean
+  selfProtect?: boolean
+}
+
+export interface PtraceEvent {
+  id: string
+  type: 'ptrace'
+  timestamp: number
+  pid: number
+  ppid?: number
+  cgroupId: string
+  namespaces?: Namespaces
+  processName: string
+  parentComm?: string
+  targetPid: number
+  targetName: string
+  accessMode: string
+  blocked: boolean
+  selfProtect?: boolean
+}
+
+// A signal sent to another process; selfProtect marks a SIGKILL/SIGSTOP
+// aimed at Aegis that the kernel denied.
+export interface SignalEvent {
+  id: string
+  type: 'signal'
+  timestamp: number
+  pid: number
+  ppid?: number
+  cgroupId: string
+  namespaces?: Namespaces
+  processName: string
+  parentComm?: string
+  targetPid: number
+  targetName: string
+  signal: number
+  signalName: string
+  blocked: boolean
+  selfProtect?: boolean
+}
+
+export type SecurityEvent = ExecEvent | FileEvent | ConnectEvent | ExitEvent | PrivilegeEvent | KernelLoadEvent | PtraceEvent | DnsEvent | ListenEvent | NamespaceEvent | SignalEvent
+
+// Summarises how a process ended, e.g. "exit 0 after 1.2s" or "signal 9".
+export function formatExitStatus(event: ExitEvent): string {
+  const status = event.signal ? `signal ${event.signal}` : `exit ${event.exitCode ?? 0}`
+  if (!event.runtimeMs) return status
+  const runtime = event.runtimeMs >= 1000 ? `${(event.runtimeMs / 1000).toFixed(1)}s` : `${event.runtimeMs}ms`
+  return `${status} after ${runtime}`
+}
+
+// Summarises a credential change, e.g. "setuid uid 1000→0".
+export function formatCredentialChange(event: PrivilegeEvent): string {
+  const c = event.credentials
+  if (!c) return '—'
+  if (c.operation === 'capset') return `capset caps ${c.oldCaps}→${c.newCaps}`
+  if (c.operation === 'setgid') return `setgid gid ${c.oldGid}→${c.newEgid}`
+  return `setuid uid ${c.oldUid}→${c.newEuid}`
+}
+
+// Summarises a module or BPF load, e.g. "module /lib/modules/x.ko" or "bpf kprobe prog".
+export function formatKernelLoad(event: KernelLoadEvent): string {
+  if (event.loadKind === 'bpf') {
+    if (event.bpfCommand) return `bpf ${event.bpfCommand} on Aegis link`
+    const name = event.progName ? ` ${event.progName}` : ''
+    return `bpf ${event.progType ?? 'program'}${name}`
+  }
+  return `module ${event.filename || '(in-memory image)'}`
+}
+
+// Summarises an exec, flagging binaries with no file on disk.
+export function formatExec(event: ExecEvent): string {
+  let text = event.commandLine || event.filename || event.processName || '—'
+  if (event.argsTruncated) text += ' …'
+  if (event.fileless) return `[fileless] ${text}`
+  if (event.deletedBinary) return `[deleted] ${text}`
+  return text
+}
+
+// Summarises a ptrace access, e.g. "attach → sshd (1234)".
+export function formatPtrace(event: PtraceEvent): string {
+  return `${event.accessMode} → ${event.targetName || 'unknown'} (${event.targetPid})`
+}
+
+// Summarises a signal, e.g. "SIGKILL → nginx (1234)".
+export function formatSignal(event: SignalEvent): string {
+  return `${event.signalName || `signal ${event.signal}`} → ${event.targetName || 'unknown'} (${event.targetPid})`
+}
+
+// Summarises a DNS message, e.g. "A example.com → 93.184.216.34".
+export function formatDns(event: DnsEvent): string {
+  const name = `${event.queryType ?? 'query'} ${event.domain || '—'}`
+  if (event.operation !== 'response') return name
+  if (!event.answers?.length) return `${name} → no answer`
+  return `${name} → ${event.answers.join(', ')}`
+}
+
+// Summarises a listening socket, e.g. "listen tcp 0.0.0.0:4444".
+export function formatListen(event: ListenEvent): string {
+  return `${event.operation} ${event.protocol} ${event.addr || `:${event.port}`}`
+}
+
+// Summarises a namespace change or mount, e.g. "setns mnt,net → host" or
+// "mount /dev/sda1 (ext4) on /mnt".
+export function formatNamespace(event: NamespaceEvent): string {
+  if (event.operation === 'mount' || event.operation === 'move_mount') {
+    const fsType = event.fsType ? ` (${event.fsType})` : ''
+    return `${event.operation} ${event.source || '—'}${fsType} on ${event.filename || '—'}`
+  }
+  const kinds = event.changedNamespaces?.join(',') || 'namespace'
+  return `${event.operation} ${kinds}${event.intoHost ? ' → host' : ''}`
+}
 
 export interface QueryFilter {
   types?: EventType[]
@@ -77,5 +324,6 @@ export interface QueryResponse {
     exec: number
     file: number
     connect: number
+    exit?: number
   }
 }
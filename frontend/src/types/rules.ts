@@ -1,5 +1,5 @@
 export type RuleState = 'draft' | 'testing' | 'production' | 'archived'
-export type RuleAction = 'block' | 'alert' | 'allow'
+export type RuleAction = 'block' | 'kill' | 'kill_tree' | 'alert' | 'allow'
 export type RuleSeverity = 'critical' | 'high' | 'warning' | 'info'
 export type RuleType = 'exec' | 'file' | 'connect'
 export type MatchType = 'exact' | 'contains' | 'prefix'
@@ -14,7 +14,18 @@ export interface RuleMatch {
   filename?: string
   destPort?: number
   destIp?: string
+  destDomain?: string
+  destSocket?: string
+  destSocketType?: MatchType
+  localPort?: number
   cgroupId?: string
+  hostOnly?: boolean
+  netNs?: number
+  containerOnly?: boolean
+  namespace?: string
+  intoHost?: boolean
+  mountSource?: string
+  fsType?: string
   uid?: number
 }
 
@@ -1,19 +1,32 @@
 package app
 
 import (
+	"errors"
+	"log"
+	"os"
+	"syscall"
+
 	"aegis/internal/platform/events"
 	"aegis/internal/policy"
 	"aegis/internal/shared/stream"
 	"aegis/internal/system"
 	"aegis/internal/telemetry"
+	"aegis/internal/telemetry/proc"
 )
 
+// ProcessParents reports the parent each live process was forked from, as
+// the kernel recorded it.
+type ProcessParents interface {
+	ProcessParents() (map[uint32]uint32, error)
+}
+
 type IngestPipeline struct {
 	telemetry   *telemetry.Service
 	policy      *policy.Service
 	stats       *system.Stats
 	eventStream *stream.Hub[telemetry.Event]
 	alertStream *stream.Hub[system.Alert]
+	parents     ProcessParents
 }
 
 func NewIngestPipeline(
@@ -32,6 +45,12 @@ func NewIngestPipeline(
 	}
 }
 
+// SetProcessParents sets where kill_tree looks up the children the process
+// tree never saw exec. It must be called before samples are processed.
+func (p *IngestPipeline) SetProcessParents(parents ProcessParents) {
+	p.parents = parents
+}
+
 func (p *IngestPipeline) ProcessRawSample(data []byte) (*telemetry.Event, policy.Decision, error) {
 	decoded, err := events.DecodeSample(data)
 	if err != nil {
@@ -41,6 +60,10 @@ func (p *IngestPipeline) ProcessRawSample(data []byte) (*telemetry.Event, policy
 	if err != nil {
 		return nil, policy.Decision{}, err
 	}
+	if record == nil {
+		// An argument chunk, held until its exec event arrives.
+		return nil, policy.Decision{Type: policy.DecisionNoMatch}, nil
+	}
 	decision := p.ProcessRecord(record)
 	return &record.Event, decision, nil
 }
@@ -62,6 +85,10 @@ func (p *IngestPipeline) ProcessRecord(record *telemetry.Record) policy.Decision
 
 	p.eventStream.Publish(*event)
 
+	if event.Response == string(policy.ActionKillTree) {
+		p.killDescendants(event.PID)
+	}
+
 	decision := p.policy.Evaluate(record)
 	for _, alert := range decision.Alerts {
 		p.stats.AddAlert(alert)
@@ -69,5 +96,54 @@ func (p *IngestPipeline) ProcessRecord(record *telemetry.Record) policy.Decision
 		p.alertStream.Publish(alert)
 	}
 
+	// Renaming or deleting under a watched directory may have moved the
+	// directory itself, which the kernel tracks by inode.
+	if event.Type == telemetry.EventTypeFile && !event.Blocked &&
+		(event.Operation == events.FileOpRename.String() || event.Operation == events.FileOpDelete.String()) {
+		if _, err := p.policy.RefreshWatchedDirs(); err != nil {
+			log.Printf("refresh watched directories: %v", err)
+		}
+	}
+
 	return decision
 }
+
+// killDescendants finishes a kill_tree response. The kernel has already
+// SIGKILLed pid itself but cannot walk its children. By now they have been
+// reparented, so the walk follows the parents the kernel recorded at fork,
+// which include children that never exec'd. Edges from the process tree
+// cover processes older than the BPF programs, and are only followed while
+// /proc still agrees, so a pid reused after a lost exit event is spared.
+func (p *IngestPipeline) killDescendants(pid uint32) {
+	parents := make(map[uint32]uint32)
+	if tree := p.telemetry.ProcessTree(); tree != nil {
+		parents = tree.Parents()
+	}
+	kernel := map[uint32]uint32{}
+	if p.parents != nil {
+		var err error
+		if kernel, err = p.parents.ProcessParents(); err != nil {
+			log.Printf("kill_tree: read process parents: %v", err)
+		}
+	}
+	for child, parent := range kernel {
+		parents[child] = parent
+	}
+	confirmed := func(child, parent uint32) bool {
+		if recorded, ok := kernel[child]; ok && recorded == parent {
+			return true
+		}
+		live, err := proc.ReadPPID(child)
+		return err == nil && live == parent
+	}
+
+	self := uint32(os.Getpid())
+	for _, child := range proc.DescendantsOf(pid, parents, confirmed) {
+		if child <= 1 || child == self {
+			continue
+		}
+		if err := syscall.Kill(int(child), syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+			log.Printf("kill_tree: kill descendant %d of %d: %v", child, pid, err)
+		}
+	}
+}
@@ -55,6 +55,7 @@ func NewRuntime(cfg internalconfig.Config, configPath string) *Runtime {
 
 	ruleRepo := persistence.NewRuleRepository(cfg.Policy.RulesPath)
 	policyService := policy.NewService(ruleRepo, nil, cfg.Policy.PromotionMinObservationMinutes, cfg.Policy.PromotionMinHits)
+	policyService.SetDomainResolver(telemetryService)
 	if err := policyService.Load(); err != nil {
 		log.Printf("Warning: failed to load rules from %s: %v", cfg.Policy.RulesPath, err)
 		if err := policyService.Bootstrap([]policy.Rule{}); err != nil {
@@ -104,6 +105,8 @@ func (r *Runtime) Start(ctx context.Context) error {
 	r.mu.Unlock()
 
 	r.policy.SetKernelSync(internalebpf.NewKernelSync(resources, r.cfg.Policy.RulesPath))
+	r.IngestPipeline().SetProcessParents(resources)
+	r.policy.SetMonitorOnly(resources.MonitorOnly)
 	if err := r.policy.Reload(); err != nil {
 		log.Printf("Warning: failed to sync policy rules into kernel maps: %v", err)
 	}
@@ -112,10 +115,17 @@ func (r *Runtime) Start(ctx context.Context) error {
 		r.analysis.StartSentinel(r.cfg)
 	}
 
-	r.wg.Add(2)
+	r.wg.Add(3)
 	go r.runEventLoop()
 	go r.watchRulesFile()
-	r.setProbeStatus(system.ProbeStatusActive, "")
+	go r.watchRingbuf()
+	if resources.MonitorOnly {
+		r.mu.Lock()
+		r.probeState = system.ProbeStatus{Status: system.ProbeStatusDegraded, Warning: resources.Warning}
+		r.mu.Unlock()
+	} else {
+		r.setProbeStatus(system.ProbeStatusActive, "")
+	}
 
 	go func() {
 		<-ctx.Done()
@@ -208,6 +218,19 @@ func (r *Runtime) ProbeStatus() system.ProbeStatus {
 	return r.probeState
 }
 
+// Capabilities reports the kernel's capabilities as probed when the programs
+// were loaded, with the hooks that were attached. Before that, or when the
+// load failed, the kernel is probed afresh.
+func (r *Runtime) Capabilities() system.Capabilities {
+	r.mu.RLock()
+	resources := r.resources
+	r.mu.RUnlock()
+	if resources != nil {
+		return resources.Capabilities
+	}
+	return internalebpf.ProbeCapabilities()
+}
+
 func (r *Runtime) ApplyConfig(oldCfg, newCfg internalconfig.Config, hotReloadedFields []string) error {
 	appliedCfg := r.liveConfigAfterHotReload(oldCfg, newCfg)
 
@@ -263,6 +286,41 @@ func (r *Runtime) runEventLoop() {
 	}
 }
 
+// watchRingbuf samples the kernel's ring buffer drop counters into Stats and
+// raises an alert when events are being lost.
+func (r *Runtime) watchRingbuf() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(internalconfig.DefaultDropPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopWatcher:
+			return
+		case <-ticker.C:
+			r.mu.RLock()
+			resources := r.resources
+			r.mu.RUnlock()
+			if resources == nil {
+				return
+			}
+			sample, err := resources.RingbufStats()
+			if err != nil {
+				log.Printf("read ring buffer drops: %v", err)
+				continue
+			}
+			alert, ok := r.stats.UpdateRingbuf(sample, internalconfig.DefaultDropAlertThreshold)
+			if !ok {
+				continue
+			}
+			log.Printf("Warning: %s", alert.Description)
+			r.stats.AddAlert(alert)
+			r.alertStream.Publish(alert)
+		}
+	}
+}
+
 func (r *Runtime) setProbeStatus(status string, errMsg string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -342,6 +400,11 @@ func (r *Runtime) watchRulesFile() {
 				}
 			} else {
 				r.watcherMu.Unlock()
+				// Directories created or removed without a file event the
+				// kernel reports, such as by rmdir, are picked up here.
+				if _, err := r.policy.RefreshWatchedDirs(); err != nil {
+					log.Printf("refresh watched directories: %v", err)
+				}
 			}
 		}
 	}
@@ -135,6 +135,27 @@ func relatedEventFromStorage(event *storage.Event) types.RelatedEvent {
 	case events.EventTypeConnect:
 		related.Type = "connect"
 		related.Port = view.Port
+	case events.EventTypeExit:
+		related.Type = "exit"
+		related.PPID = view.PPID
+	case events.EventTypePrivilege:
+		related.Type = "privilege"
+	case events.EventTypeKernelLoad:
+		related.Type = "kernel_load"
+		related.Filename = view.Filename
+	case events.EventTypePtrace:
+		related.Type = "ptrace"
+	case events.EventTypeDNS:
+		related.Type = "dns"
+		related.Port = view.Port
+	case events.EventTypeListen:
+		related.Type = "listen"
+		related.Port = view.Port
+	case events.EventTypeNamespace:
+		related.Type = "namespace"
+		related.Filename = view.Filename
+	case events.EventTypeSignal:
+		related.Type = "signal"
 	}
 	related.PID = view.PID
 	related.CgroupID = fmt.Sprintf("%d", view.CgroupID)
@@ -117,8 +117,8 @@ func FormatProcessProfile(profile *proc.ProcessProfile) string {
 }
 
 func FormatWorkloadMetadata(w *workload.Metadata) string {
-	return fmt.Sprintf("CgroupID: %d, CgroupPath: %s, ExecCount: %d, FileCount: %d, ConnectCount: %d, AlertCount: %d",
-		w.ID, w.CgroupPath, w.ExecCount, w.FileCount, w.ConnectCount, w.AlertCount)
+	return fmt.Sprintf("CgroupID: %d, CgroupPath: %s, Host: %t, NetNS: %d, ExecCount: %d, FileCount: %d, ConnectCount: %d, AlertCount: %d",
+		w.ID, w.CgroupPath, w.Host, w.Namespaces.Net, w.ExecCount, w.FileCount, w.ConnectCount, w.AlertCount)
 }
 
 func FormatRuleForAnalysis(rule *rules.Rule, engine *rules.Engine) string {
@@ -160,6 +160,10 @@ func BuildExplainContext(event *storage.Event, pid uint32, store storage.EventSt
 			}
 			b.WriteString(fmt.Sprintf("- Activity: Exec=%d, File=%d, Net=%d\n",
 				profile.Dynamic.ExecCount, profile.Dynamic.FileOpenCount, profile.Dynamic.NetConnectCount))
+			if !profile.Static.EndTime.IsZero() {
+				b.WriteString(fmt.Sprintf("- Ended: %s (%s)\n",
+					profile.Static.EndTime.Format(time.RFC3339), exitStatusLabel(profile.Static.ExitCode, profile.Static.ExitSignal)))
+			}
 
 			// Process ancestry
 			if processTree != nil {
@@ -271,12 +275,54 @@ func FormatEventForExplanation(event *storage.Event, profile *proc.ProcessProfil
 		if view.Filename != "" {
 			b.WriteString(fmt.Sprintf("- Filename: %s\n", view.Filename))
 		}
+		if view.Fileless {
+			b.WriteString("- Binary: fileless (memfd_create)\n")
+		} else if view.Deleted {
+			b.WriteString("- Binary: deleted from disk\n")
+		}
 		if view.Type == events.EventTypeFileOpen {
+			if view.Operation != "" {
+				b.WriteString(fmt.Sprintf("- Operation: %s\n", view.Operation))
+			}
 			b.WriteString(fmt.Sprintf("- Flags: %d, Dev: %d, Ino: %d\n", view.Flags, view.Dev, view.Ino))
 		}
-		if view.Type == events.EventTypeConnect {
+		if view.Type == events.EventTypeConnect && view.Family == events.AFUnix {
+			b.WriteString(fmt.Sprintf("- Remote: unix socket %s\n", view.Address))
+		} else if view.Type == events.EventTypeConnect {
 			b.WriteString(fmt.Sprintf("- Remote: %s:%d (family=%d)\n", view.Address, view.Port, view.Family))
 		}
+		if view.Type == events.EventTypeDNS {
+			b.WriteString(fmt.Sprintf("- DNS %s: %s via %s:%d\n", view.Operation, view.Domain, view.Address, view.Port))
+		}
+		if view.Type == events.EventTypeListen {
+			b.WriteString(fmt.Sprintf("- Local: %s %s:%d (%s)\n", view.Protocol, view.Address, view.Port, view.Operation))
+		}
+		if view.Type == events.EventTypePrivilege {
+			b.WriteString(fmt.Sprintf("- Credentials: %s uid %d -> %d, gid %d -> %d\n", view.Operation, view.FromUID, view.ToUID, view.FromGID, view.ToGID))
+		}
+		if view.Type == events.EventTypeKernelLoad {
+			if view.Operation != "" {
+				b.WriteString(fmt.Sprintf("- BPF: %s on an Aegis link\n", view.Operation))
+			} else if view.ProgType != "" {
+				b.WriteString(fmt.Sprintf("- Load: %s (%s program)\n", view.LoadKind, view.ProgType))
+			} else {
+				b.WriteString(fmt.Sprintf("- Load: %s\n", view.LoadKind))
+			}
+		}
+		if view.Type == events.EventTypeNamespace && view.Source != "" {
+			b.WriteString(fmt.Sprintf("- Mount: %s of %s (%s), flags 0x%x\n", view.Operation, view.Source, view.FSType, view.Flags))
+		} else if view.Type == events.EventTypeNamespace {
+			b.WriteString(fmt.Sprintf("- Namespaces: %s into %s, into host: %t\n", view.Operation, strings.Join(view.Namespaces, ","), view.IntoHost))
+		}
+		if view.Type == events.EventTypePtrace {
+			b.WriteString(fmt.Sprintf("- Target: %s (pid %d), access %s\n", view.TargetName, view.TargetPID, view.Operation))
+		}
+		if view.Type == events.EventTypeSignal {
+			b.WriteString(fmt.Sprintf("- Target: %s (pid %d), signal %s\n", view.TargetName, view.TargetPID, events.SignalName(view.Signal)))
+		}
+		if view.Type == events.EventTypeExit {
+			b.WriteString(fmt.Sprintf("- Exit: %s after %s\n", exitStatusLabel(view.ExitCode, view.Signal), view.Runtime.Round(time.Millisecond)))
+		}
 	}
 
 	if profile != nil {
@@ -284,6 +330,9 @@ func FormatEventForExplanation(event *storage.Event, profile *proc.ProcessProfil
 		if !profile.Static.StartTime.IsZero() {
 			b.WriteString(fmt.Sprintf("- StartTime: %s\n", profile.Static.StartTime.Format(time.RFC3339)))
 		}
+		if !profile.Static.EndTime.IsZero() {
+			b.WriteString(fmt.Sprintf("- EndTime: %s (%s)\n", profile.Static.EndTime.Format(time.RFC3339), exitStatusLabel(profile.Static.ExitCode, profile.Static.ExitSignal)))
+		}
 		if profile.Static.CommandLine != "" {
 			b.WriteString(fmt.Sprintf("- CommandLine: %s\n", profile.Static.CommandLine))
 		}
@@ -310,11 +359,34 @@ func eventTypeLabel(eventType events.EventType) string {
 		return "file"
 	case events.EventTypeConnect:
 		return "connect"
+	case events.EventTypeExit:
+		return "exit"
+	case events.EventTypePrivilege:
+		return "privilege"
+	case events.EventTypeKernelLoad:
+		return "kernel_load"
+	case events.EventTypePtrace:
+		return "ptrace"
+	case events.EventTypeDNS:
+		return "dns"
+	case events.EventTypeListen:
+		return "listen"
+	case events.EventTypeNamespace:
+		return "namespace"
+	case events.EventTypeSignal:
+		return "signal"
 	default:
 		return "unknown"
 	}
 }
 
+func exitStatusLabel(exitCode, signal uint32) string {
+	if signal != 0 {
+		return fmt.Sprintf("killed by signal %d", signal)
+	}
+	return fmt.Sprintf("exit code %d", exitCode)
+}
+
 func BuildRuleGenPrompt(req *types.RuleGenRequest, examplesYAML string) string {
 	user := fmt.Sprintf(
 		"User request: \"%s\"\n\nExisting rules (examples):\n%s\n\nGenerate rule:",
@@ -99,7 +99,7 @@ func convertStorageExecEvents(storageEvents []*storage.Event) []snapshotExecEven
 				CgroupID:    fmt.Sprintf("%d", execEv.Hdr.CgroupID),
 				Comm:        utils.ExtractCString(execEv.Hdr.Comm[:]),
 				ParentComm:  utils.ExtractCString(execEv.PComm[:]),
-				CommandLine: utils.ExtractCString(execEv.CommandLine[:]),
+				CommandLine: utils.ExecCommandLine(execEv),
 				Blocked:     execEv.Hdr.Blocked == 1,
 			})
 		case events.ExecEvent:
@@ -111,7 +111,7 @@ func convertStorageExecEvents(storageEvents []*storage.Event) []snapshotExecEven
 				CgroupID:    fmt.Sprintf("%d", execEv.Hdr.CgroupID),
 				Comm:        utils.ExtractCString(execEv.Hdr.Comm[:]),
 				ParentComm:  utils.ExtractCString(execEv.PComm[:]),
-				CommandLine: utils.ExtractCString(execEv.CommandLine[:]),
+				CommandLine: utils.ExecCommandLine(execEv),
 				Blocked:     execEv.Hdr.Blocked == 1,
 			})
 		default:
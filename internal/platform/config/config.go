@@ -15,9 +15,12 @@ const (
 	DefaultProcessTreeMaxSize        = 10000
 	DefaultProcessTreeMaxChainLength = 50
 	DefaultRingBufferSize            = 256 * 1024
+	DefaultPinPath                   = "/sys/fs/bpf/aegis"
 	DefaultRecentEventsCapacity      = 10000
 	DefaultMaxAlerts                 = 100
 	DefaultAlertDedupWindow          = 10 * time.Second
+	DefaultDropPollInterval          = 5 * time.Second
+	DefaultDropAlertThreshold        = 100 // drops per poll interval
 )
 
 type AIOptions struct {
@@ -50,8 +53,22 @@ type ServerConfig struct {
 }
 
 type KernelConfig struct {
-	BPFPath        string `yaml:"bpf_path" json:"bpf_path"`
-	RingBufferSize int    `yaml:"ring_buffer_size" json:"ring_buffer_size"`
+	BPFPath        string               `yaml:"bpf_path" json:"bpf_path"`
+	RingBufferSize int                  `yaml:"ring_buffer_size" json:"ring_buffer_size"`
+	SelfProtection SelfProtectionConfig `yaml:"self_protection" json:"self_protection"`
+	CaptureEnv     bool                 `yaml:"capture_env" json:"capture_env"` // stream envp along with argv
+	Pin            bool                 `yaml:"pin" json:"pin"`                 // keep enforcing while the daemon is down
+	PinPath        string               `yaml:"pin_path" json:"pin_path"`
+	Unpin          bool                 `yaml:"-" json:"-"` // --unpin: remove the pins and exit
+}
+
+// SelfProtectionConfig guards the agent against SIGKILL, SIGSTOP, ptrace,
+// detaching its BPF links and writing to its BPF maps. Processes running one
+// of AdminPaths or as one of AdminUIDs are exempt.
+type SelfProtectionConfig struct {
+	Enabled    bool     `yaml:"enabled" json:"enabled"`
+	AdminPaths []string `yaml:"admin_paths" json:"admin_paths"`
+	AdminUIDs  []uint32 `yaml:"admin_uids" json:"admin_uids"`
 }
 
 type TelemetryConfig struct {
@@ -103,6 +120,7 @@ func Default(cwd string) Config {
 		Kernel: KernelConfig{
 			BPFPath:        filepath.Join(cwd, "bpf", "main.bpf.o"),
 			RingBufferSize: DefaultRingBufferSize,
+			PinPath:        DefaultPinPath,
 		},
 		Telemetry: TelemetryConfig{
 			ProcessTreeMaxAge:         DefaultProcessTreeMaxAge.String(),
@@ -166,6 +184,7 @@ func LoadFrom(cwd string, args []string) (Config, string, error) {
 	fs := flag.NewFlagSet("aegis", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 	fs.IntVar(&cfg.Server.Port, "port", cfg.Server.Port, "Port for web GUI")
+	fs.BoolVar(&cfg.Kernel.Unpin, "unpin", false, "Remove the pinned BPF maps and links, stopping enforcement, and exit")
 	if err := fs.Parse(args); err != nil {
 		return Config{}, "", err
 	}
@@ -1,9 +1,12 @@
 package ebpf
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
+	"aegis/internal/system"
+
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 )
@@ -13,15 +16,41 @@ type lsmHook struct {
 	program **ebpf.Program
 }
 
-func AttachLSMHooks(objs *LSMObjects) ([]link.Link, error) {
-	hooks := []lsmHook{
+// lsmHooks pairs each enforcing program with the LSM hook it attaches to.
+func lsmHooks(objs *LSMObjects) []lsmHook {
+	return []lsmHook{
 		{"bprm_check_security", &objs.LsmBprmCheck},
 		{"file_open", &objs.LsmFileOpen},
 		{"socket_connect", &objs.LsmSocketConnect},
+		{"task_fix_setuid", &objs.LsmTaskFixSetuid},
+		{"task_fix_setgid", &objs.LsmTaskFixSetgid},
+		{"capset", &objs.LsmCapset},
+		{"kernel_read_file", &objs.LsmKernelRead},
+		{"kernel_load_data", &objs.LsmKernelLoad},
+		{"bpf", &objs.LsmBPF},
+		{"bpf_map", &objs.LsmBPFMap},
+		{"ptrace_access_check", &objs.LsmPtraceAccess},
+		{"inode_unlink", &objs.LsmInodeUnlink},
+		{"path_rename", &objs.LsmPathRename},
+		{"inode_setattr", &objs.LsmInodeSetattr},
+		{"socket_sendmsg", &objs.LsmSocketSendmsg},
+		{"socket_sock_rcv_skb", &objs.LsmSockRcvSkb},
+		{"sk_free_security", &objs.LsmSkFree},
+		{"socket_bind", &objs.LsmSocketBind},
+		{"socket_listen", &objs.LsmSocketListen},
+		{"sb_mount", &objs.LsmSbMount},
+		{"move_mount", &objs.LsmMoveMount},
+		{"task_kill", &objs.LsmTaskKill},
 	}
+}
 
+// AttachLSMHooks attaches the enforcing hooks, pinning each link under
+// pinDir by hook name unless pinDir is empty. A hook that fails to attach is
+// skipped rather than failing the others; caps, when not nil, records which
+// hooks were attached and why the rest were not.
+func AttachLSMHooks(objs *LSMObjects, pinDir string, caps *system.Capabilities) ([]link.Link, error) {
 	var links []link.Link
-	for _, h := range hooks {
+	for _, h := range lsmHooks(objs) {
 		if *h.program == nil {
 			continue
 		}
@@ -29,18 +58,115 @@ func AttachLSMHooks(objs *LSMObjects) ([]link.Link, error) {
 			Program: *h.program,
 		})
 		if err != nil {
-			CloseLinks(links)
-			return nil, fmt.Errorf("attach %s LSM: %w", h.name, err)
+			log.Printf("Skipping %s LSM hook: %v", h.name, err)
+			recordHook(caps, h.name, err)
+			continue
 		}
 		links = append(links, l)
+		if err := pinLink(l, pinDir, h.name); err != nil {
+			discardLinks(links)
+			return nil, err
+		}
+		recordHook(caps, h.name, nil)
 	}
 
 	log.Printf("Attached %d BPF LSM hooks for active defense", len(links))
 	return links, nil
 }
 
+func recordHook(caps *system.Capabilities, name string, err error) {
+	if caps == nil {
+		return
+	}
+	for i := range caps.LSMHooks {
+		if caps.LSMHooks[i].Name != name {
+			continue
+		}
+		caps.LSMHooks[i].Attached = err == nil
+		if err != nil {
+			caps.LSMHooks[i].Error = err.Error()
+		}
+	}
+}
+
+// AttachTracingHooks attaches the tracepoint and fentry programs that only
+// observe and never enforce: process lifecycle and namespace changes, plus
+// the monitor programs when they were loaded in place of the LSM hooks.
+// Links are pinned like AttachLSMHooks's where the kernel supports it; older
+// kernels attach syscall tracepoints without a pinnable link.
+func AttachTracingHooks(objs *LSMObjects, pinDir string) ([]link.Link, error) {
+	hooks := []lsmHook{
+		{"sched_process_fork", &objs.SchedProcessFork},
+		{"sched_process_exit", &objs.SchedProcessExit},
+		{"sched_process_exec", &objs.SchedProcessExec},
+		{"security_file_open", &objs.FentryFileOpen},
+		{"tcp_connect", &objs.FentryTCPConnect},
+	}
+
+	var links []link.Link
+	for _, h := range hooks {
+		if *h.program == nil {
+			continue
+		}
+		l, err := link.AttachTracing(link.TracingOptions{
+			Program: *h.program,
+		})
+		if err != nil {
+			discardLinks(links)
+			return nil, fmt.Errorf("attach %s tracepoint: %w", h.name, err)
+		}
+		links = append(links, l)
+		if err := pinLink(l, pinDir, h.name); err != nil {
+			discardLinks(links)
+			return nil, err
+		}
+	}
+
+	// setns and unshare have no LSM hook, so the syscall tracepoints bracket
+	// them instead. The execve entries keep the argv pointers bprm_check
+	// streams from, and the exits drop them.
+	syscalls := []lsmHook{
+		{"sys_enter_execve", &objs.ExecveEnter},
+		{"sys_enter_execveat", &objs.ExecveatEnter},
+		{"sys_exit_execve", &objs.ExecveExit},
+		{"sys_exit_execveat", &objs.ExecveatExit},
+		{"sys_enter_setns", &objs.SetnsEnter},
+		{"sys_exit_setns", &objs.SetnsExit},
+		{"sys_enter_unshare", &objs.UnshareEnter},
+		{"sys_exit_unshare", &objs.UnshareExit},
+	}
+	for _, tp := range syscalls {
+		if *tp.program == nil {
+			continue
+		}
+		l, err := link.Tracepoint("syscalls", tp.name, *tp.program, nil)
+		if err != nil {
+			discardLinks(links)
+			return nil, fmt.Errorf("attach %s tracepoint: %w", tp.name, err)
+		}
+		links = append(links, l)
+		if err := pinLink(l, pinDir, tp.name); errors.Is(err, ebpf.ErrNotSupported) {
+			log.Printf("Not pinning %s: %v", tp.name, err)
+		} else if err != nil {
+			discardLinks(links)
+			return nil, err
+		}
+	}
+
+	return links, nil
+}
+
 func CloseLinks(links []link.Link) {
 	for _, l := range links {
 		_ = l.Close()
 	}
 }
+
+// discardLinks unpins and closes links attached by a load that then failed,
+// so no half-configured hooks stay behind.
+func discardLinks(links []link.Link) {
+	for _, l := range links {
+		_ = l.Unpin()
+	}
+	CloseLinks(links)
+}
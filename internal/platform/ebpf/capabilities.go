@@ -0,0 +1,137 @@
+package ebpf
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"aegis/internal/system"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+)
+
+// ProbeCapabilities reports what the running kernel offers the programs. An
+// LSM hook is supported when the kernel has its bpf_lsm_ attach point;
+// whether it was attached is filled in by AttachLSMHooks.
+func ProbeCapabilities() system.Capabilities {
+	enabled, _, lsms, known := detectBPFLSMState()
+	caps := system.Capabilities{
+		KernelVersion: kernelRelease(),
+		RingBuffer:    features.HaveMapType(ebpf.RingBuf) == nil,
+		// Like Load, an LSM list that cannot be read counts as enabled.
+		BPFLSM:     enabled || !known,
+		ActiveLSMs: lsms,
+		CgroupMode: cgroupMode(),
+	}
+
+	kernelSpec, err := btf.LoadKernelSpec()
+	caps.BTF = err == nil
+	if caps.BTF {
+		caps.DPath = hasHelper(kernelSpec, "BPF_FUNC_d_path")
+		caps.SendSignal = hasHelper(kernelSpec, "BPF_FUNC_send_signal")
+	}
+	for _, h := range lsmHooks(&LSMObjects{}) {
+		caps.LSMHooks = append(caps.LSMHooks, system.HookCapability{
+			Name:      h.name,
+			Supported: caps.BTF && hasKernelFunc(kernelSpec, "bpf_lsm_"+h.name),
+		})
+	}
+	return caps
+}
+
+// pruneUnsupported drops the LSM and fentry programs whose attach point the
+// kernel lacks, so the rest still load. Their fields stay nil and the attach
+// code skips them.
+func pruneUnsupported(spec *ebpf.CollectionSpec) {
+	kernelSpec, err := btf.LoadKernelSpec()
+	if err != nil {
+		// Without kernel BTF nothing can load; let the load report it.
+		return
+	}
+	for name, prog := range spec.Programs {
+		target := ""
+		switch {
+		case prog.Type == ebpf.LSM:
+			target = "bpf_lsm_" + prog.AttachTo
+		case prog.Type == ebpf.Tracing && prog.AttachType == ebpf.AttachTraceFEntry:
+			target = prog.AttachTo
+		default:
+			continue
+		}
+		if !hasKernelFunc(kernelSpec, target) {
+			log.Printf("Skipping %s: %s is not available on this kernel", name, target)
+			delete(spec.Programs, name)
+		}
+	}
+}
+
+// rejectedProgram returns the name of the program a failed collection load
+// blames, or "" when the error is not about a single program of spec.
+func rejectedProgram(spec *ebpf.CollectionSpec, err error) string {
+	rest, ok := strings.CutPrefix(err.Error(), "program ")
+	if !ok {
+		return ""
+	}
+	name, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return ""
+	}
+	if _, exists := spec.Programs[name]; !exists {
+		return ""
+	}
+	return name
+}
+
+// recordRejected adds a program the kernel refused to caps, and marks the
+// hook of an LSM program as not attached.
+func recordRejected(caps *system.Capabilities, name string, prog *ebpf.ProgramSpec, err error) {
+	if caps == nil {
+		return
+	}
+	caps.RejectedPrograms = append(caps.RejectedPrograms, system.RejectedProgram{Name: name, Error: err.Error()})
+	if prog.Type == ebpf.LSM {
+		recordHook(caps, prog.AttachTo, err)
+	}
+}
+
+func hasKernelFunc(kernelSpec *btf.Spec, name string) bool {
+	var fn *btf.Func
+	return kernelSpec.TypeByName(name, &fn) == nil
+}
+
+// hasHelper reports whether the kernel knows the BPF helper, the same check
+// the programs make with bpf_core_enum_value_exists.
+func hasHelper(kernelSpec *btf.Spec, name string) bool {
+	var ids *btf.Enum
+	if err := kernelSpec.TypeByName("bpf_func_id", &ids); err != nil {
+		return false
+	}
+	for _, v := range ids.Values {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func kernelRelease() string {
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(release))
+}
+
+// cgroupMode tells a cgroup v2 only host from one that also mounts v1
+// hierarchies, with v2 under /sys/fs/cgroup/unified, or has no v2 at all.
+func cgroupMode() string {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		return system.CgroupModeUnified
+	}
+	if _, err := os.Stat("/sys/fs/cgroup/unified/cgroup.controllers"); err == nil {
+		return system.CgroupModeHybrid
+	}
+	return system.CgroupModeLegacy
+}
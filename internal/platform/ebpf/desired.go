@@ -0,0 +1,723 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"strconv"
+
+	"aegis/internal/platform/events"
+	"aegis/internal/policy"
+	"aegis/internal/shared/utils"
+)
+
+// The Desired* functions compute what a map should hold for a rule list
+// without touching the kernel; the Sync* functions in maps.go hand the result
+// to syncMap.
+
+// MapDiff is what syncMap has to do to bring a map from its current to its
+// desired contents.
+type MapDiff[K, V comparable] struct {
+	Put    map[K]V
+	Delete []K
+	Stats  policy.MapSyncStats
+}
+
+// DiffMap compares the current contents of a map with the desired ones.
+func DiffMap[K, V comparable](name string, current, desired map[K]V) MapDiff[K, V] {
+	diff := MapDiff[K, V]{
+		Put:   make(map[K]V),
+		Stats: policy.MapSyncStats{Map: name, Total: len(desired)},
+	}
+
+	for k, val := range desired {
+		old, exists := current[k]
+		if exists && old == val {
+			continue
+		}
+		diff.Put[k] = val
+		if exists {
+			diff.Stats.Changed++
+		} else {
+			diff.Stats.Added++
+		}
+	}
+
+	for k := range current {
+		if _, keep := desired[k]; keep {
+			continue
+		}
+		diff.Delete = append(diff.Delete, k)
+		diff.Stats.Removed++
+	}
+
+	return diff
+}
+
+// DesiredFiles computes monitored_files and scoped_files from exact file
+// rules.
+func DesiredFiles(ruleList []policy.Rule) (map[PathKey]uint16, map[ScopedPathKey]uint16) {
+	fileActions := make(map[PathKey]uint16)
+	scopedActions := make(map[ScopedPathKey]uint16)
+	for _, rule := range ruleList {
+		if !rule.IsActive() || rule.DeriveType() != policy.RuleTypeFile {
+			continue
+		}
+
+		if len(rule.Match.ExactPathKeys()) == 0 {
+			continue
+		}
+
+		path := utils.CanonicalPath(rule.Match.Filename)
+		if path == "" {
+			continue
+		}
+		if len(path) >= events.PathMaxLen {
+			log.Printf("Warning: path for rule %q exceeds %d bytes, kernel enforcement skipped", rule.Name, events.PathMaxLen-1)
+			continue
+		}
+
+		cgroupID, scoped, ok := ruleCgroupID(rule)
+		if !ok {
+			continue
+		}
+
+		var key PathKey
+		copy(key[:], path)
+		mask := FileMaskForRule(rule)
+		if scoped {
+			scopedKey := ScopedPathKey{CgroupID: cgroupID, Path: key}
+			scopedActions[scopedKey] |= mask
+			continue
+		}
+		fileActions[key] |= mask
+	}
+	return fileActions, scopedActions
+}
+
+// DesiredDirs computes monitored_dirs from directory wildcard file rules.
+// check_dir_action only walks MAX_DIR_DEPTH ancestors and stops at the mount
+// a file lives on, so those limits apply to kernel enforcement; see
+// MatchCondition.DirInodeKey.
+func DesiredDirs(ruleList []policy.Rule) map[DirMapKey]uint16 {
+	dirActions := make(map[DirMapKey]uint16)
+	for _, rule := range ruleList {
+		if !rule.IsActive() || rule.DeriveType() != policy.RuleTypeFile {
+			continue
+		}
+
+		inode, ok := rule.Match.DirInodeKey()
+		if !ok || skipScopedRule(rule, "directory") {
+			continue
+		}
+
+		key := DirMapKey{Ino: inode.Ino, Dev: events.KernelDev(inode.Dev)}
+		dirActions[key] |= FileMaskForRule(rule)
+	}
+	return dirActions
+}
+
+// DesiredPorts computes blocked_ports and scoped_ports from port-only
+// connect rules.
+func DesiredPorts(ruleList []policy.Rule) (map[uint16]uint8, map[ScopedPortKey]uint8) {
+	portActions := make(map[uint16]uint8)
+	scopedActions := make(map[ScopedPortKey]uint8)
+	for _, rule := range ruleList {
+		if !rule.IsActive() {
+			continue
+		}
+
+		// Rules that also name a destination live in the address tries or
+		// are enforced per resolved address by SyncDomainAddrs.
+		if rule.Match.DestPort == 0 || rule.Match.DestIP != "" || rule.Match.DestDomain != "" {
+			continue
+		}
+
+		cgroupID, scoped, ok := ruleCgroupID(rule)
+		if !ok {
+			continue
+		}
+
+		action := bpfActionForRule(rule)
+		port := rule.Match.DestPort
+		if scoped {
+			key := ScopedPortKey{CgroupID: cgroupID, Port: port}
+			scopedActions[key] = mergeAction(scopedActions[key], action)
+			continue
+		}
+		portActions[port] = mergeAction(portActions[port], action)
+	}
+	return portActions, scopedActions
+}
+
+// DesiredExecRules computes exec_rules from the exec rules the kernel can
+// evaluate. Allows are stored too, so an allow on (name, parent) overrides a
+// block on the name alone in the kernel as it does in userspace.
+func DesiredExecRules(ruleList []policy.Rule) map[ExecRuleKey]uint8 {
+	desired := make(map[ExecRuleKey]uint8)
+	allowed := make(map[ExecRuleKey]bool)
+	for _, rule := range ruleList {
+		if !rule.IsActive() || rule.DeriveType() != policy.RuleTypeExec {
+			continue
+		}
+		// Testing rules only alert, so neither their blocks nor their allows
+		// reach the kernel.
+		isAllow := rule.Action == policy.ActionAllow && !rule.IsTesting()
+		action := bpfActionForRule(rule)
+		if !isAllow && action < policy.BPFActionBlock {
+			continue
+		}
+
+		key, ok := ExecKeyForRule(rule)
+		if !ok {
+			if !isAllow {
+				log.Printf("Rule %q cannot be enforced in kernel (needs exact process_name/parent_name only), alerting from userspace", rule.Name)
+			}
+			continue
+		}
+		if isAllow {
+			allowed[key] = true
+		} else {
+			desired[key] = mergeAction(desired[key], action)
+		}
+	}
+
+	for key := range allowed {
+		desired[key] = execRuleAllow
+	}
+	return desired
+}
+
+// ExecKeyForRule returns the exec_rules key of a rule, or false when the rule
+// has conditions the key cannot express or names longer than a comm.
+func ExecKeyForRule(rule policy.Rule) (ExecRuleKey, bool) {
+	m := rule.Match
+	if m.PID != 0 || m.PPID != 0 || m.CgroupID != "" || m.Fileless || m.DeletedBinary || namespaceScoped(rule) {
+		return ExecRuleKey{}, false
+	}
+	if m.ProcessName == "" && m.ParentName == "" {
+		return ExecRuleKey{}, false
+	}
+	if m.ProcessName != "" && (m.ProcessNameType != policy.MatchTypeExact || len(m.ProcessName) >= events.TaskCommLen) {
+		return ExecRuleKey{}, false
+	}
+	if m.ParentName != "" && (m.ParentNameType != policy.MatchTypeExact || len(m.ParentName) >= events.TaskCommLen) {
+		return ExecRuleKey{}, false
+	}
+
+	var key ExecRuleKey
+	copy(key.Comm[:], m.ProcessName)
+	copy(key.PComm[:], m.ParentName)
+	return key, true
+}
+
+type addrEntry struct {
+	port   uint16
+	net    *net.IPNet
+	action uint8
+}
+
+// DesiredAddrs computes the IPv4 and IPv6 tries from dest_ip rules.
+func DesiredAddrs(ruleList []policy.Rule) (map[IPv4LPMKey]uint8, map[IPv6LPMKey]uint8) {
+	var v4, v6 []addrEntry
+	for _, rule := range ruleList {
+		if !rule.IsActive() || rule.Match.DestIP == "" || rule.Match.DestDomain != "" || skipScopedRule(rule, "dest_ip") {
+			continue
+		}
+
+		ipNet, ok := parseDestIP(rule.Match.DestIP)
+		if !ok {
+			log.Printf("Warning: dest_ip %q in rule %q is not an IP or CIDR, kernel enforcement skipped", rule.Match.DestIP, rule.Name)
+			continue
+		}
+
+		entry := addrEntry{port: rule.Match.DestPort, net: ipNet, action: bpfActionForRule(rule)}
+		if ipNet.IP.To4() != nil {
+			entry.net = &net.IPNet{IP: ipNet.IP.To4(), Mask: ipNet.Mask[len(ipNet.Mask)-net.IPv4len:]}
+			v4 = mergeAddrEntry(v4, entry)
+		} else {
+			v6 = mergeAddrEntry(v6, entry)
+		}
+	}
+
+	// The trie returns the longest prefix only, so a narrow alert rule inside
+	// a broad block rule must inherit the block.
+	raiseCoveredActions(v4)
+	raiseCoveredActions(v6)
+
+	v4Actions := make(map[IPv4LPMKey]uint8, len(v4))
+	for _, entry := range v4 {
+		v4Actions[newIPv4LPMKey(entry)] = entry.action
+	}
+	v6Actions := make(map[IPv6LPMKey]uint8, len(v6))
+	for _, entry := range v6 {
+		v6Actions[newIPv6LPMKey(entry)] = entry.action
+	}
+	return v4Actions, v6Actions
+}
+
+// DesiredDomainAddrs computes domain_v4 and domain_v6 from resolved domains
+// and the dest_domain rules each matched. An address keeps the strongest
+// action of any rule that wants it.
+func DesiredDomainAddrs(resolved []policy.DomainAddrs) (map[DomainV4Key]uint8, map[DomainV6Key]uint8) {
+	v4Actions := make(map[DomainV4Key]uint8)
+	v6Actions := make(map[DomainV6Key]uint8)
+	for _, domain := range resolved {
+		for _, rule := range domain.Rules {
+			if !rule.IsActive() || rule.Match.DestDomain == "" || rule.Action == policy.ActionAllow {
+				continue
+			}
+			if _, _, ok := ruleCgroupID(rule); !ok {
+				continue
+			}
+
+			// Scoped rules still need the connect event; userspace decides
+			// whether the cgroup matches.
+			action := bpfActionForRule(rule)
+			if rule.Match.CgroupID != "" {
+				action = policy.BPFActionMonitor
+			}
+
+			var port [2]byte
+			binary.BigEndian.PutUint16(port[:], rule.Match.DestPort)
+			for _, ip := range domain.Addrs {
+				if v4 := ip.To4(); v4 != nil {
+					key := DomainV4Key{Port: port}
+					copy(key.Addr[:], v4)
+					v4Actions[key] = mergeAction(v4Actions[key], action)
+					continue
+				}
+				if v6 := ip.To16(); v6 != nil {
+					key := DomainV6Key{Port: port}
+					copy(key.Addr[:], v6)
+					v6Actions[key] = mergeAction(v6Actions[key], action)
+				}
+			}
+		}
+	}
+	return v4Actions, v6Actions
+}
+
+func parseDestIP(raw string) (*net.IPNet, bool) {
+	if _, cidr, err := net.ParseCIDR(raw); err == nil {
+		return cidr, true
+	}
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return nil, false
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}, true
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, true
+}
+
+func mergeAddrEntry(entries []addrEntry, entry addrEntry) []addrEntry {
+	for i := range entries {
+		if entries[i].port == entry.port && entries[i].net.String() == entry.net.String() {
+			entries[i].action = mergeAction(entries[i].action, entry.action)
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
+
+func raiseCoveredActions(entries []addrEntry) {
+	for i := range entries {
+		inner, _ := entries[i].net.Mask.Size()
+		for _, outer := range entries {
+			ones, _ := outer.net.Mask.Size()
+			if outer.port == entries[i].port && ones < inner && outer.net.Contains(entries[i].net.IP) {
+				entries[i].action = mergeAction(entries[i].action, outer.action)
+			}
+		}
+	}
+}
+
+func newIPv4LPMKey(entry addrEntry) IPv4LPMKey {
+	ones, _ := entry.net.Mask.Size()
+	key := IPv4LPMKey{Prefixlen: uint32(16 + ones)}
+	binary.BigEndian.PutUint16(key.Port[:], entry.port)
+	copy(key.Addr[:], entry.net.IP.To4())
+	return key
+}
+
+func newIPv6LPMKey(entry addrEntry) IPv6LPMKey {
+	ones, _ := entry.net.Mask.Size()
+	key := IPv6LPMKey{Prefixlen: uint32(16 + ones)}
+	binary.BigEndian.PutUint16(key.Port[:], entry.port)
+	copy(key.Addr[:], entry.net.IP.To16())
+	return key
+}
+
+// unixEntry is one dest_socket rule in sun_path form. Exact rules keep the
+// trailing NUL so the trie cannot match a longer path.
+type unixEntry struct {
+	path   []byte
+	action uint8
+	allow  bool
+}
+
+// DesiredUnixSockets computes the unix_sockets trie from dest_socket rules.
+// Rules with conditions the kernel cannot see are downgraded to monitor so
+// that userspace decides, and unconditional allow rules are stored as 0 over
+// every path they cover, matching userspace where an allow always wins.
+func DesiredUnixSockets(ruleList []policy.Rule) map[UnixLPMKey]uint8 {
+	var entries []unixEntry
+	for _, rule := range ruleList {
+		if !rule.IsActive() || rule.Match.DestSocket == "" {
+			continue
+		}
+		m := rule.Match
+		conditional := m.PID != 0 || m.CgroupID != "" || m.ProcessName != "" || namespaceScoped(rule)
+		entry := unixEntry{path: unixSocketPath(m)}
+		if rule.Action == policy.ActionAllow && !rule.IsTesting() {
+			if conditional {
+				continue
+			}
+			entry.allow = true
+		} else {
+			entry.action = bpfActionForRule(rule)
+			if conditional {
+				entry.action = policy.BPFActionMonitor
+			}
+		}
+		entries = mergeUnixEntry(entries, entry)
+	}
+
+	// The trie returns the longest prefix only, so a path inherits the
+	// strongest action, or an allow, of every rule covering it.
+	desired := make(map[UnixLPMKey]uint8, len(entries))
+	for _, entry := range entries {
+		action, allow := entry.action, entry.allow
+		for _, outer := range entries {
+			if len(outer.path) < len(entry.path) && bytes.HasPrefix(entry.path, outer.path) {
+				action = mergeAction(action, outer.action)
+				allow = allow || outer.allow
+			}
+		}
+		if allow {
+			action = 0
+		}
+		key := UnixLPMKey{Prefixlen: uint32(len(entry.path) * 8)}
+		copy(key.Path[:], entry.path)
+		desired[key] = action
+	}
+	return desired
+}
+
+// unixSocketPath converts a dest_socket value to the bytes the kernel sees
+// in sun_path.
+func unixSocketPath(m policy.MatchCondition) []byte {
+	path := []byte(m.DestSocket)
+	if path[0] == '@' {
+		path[0] = 0
+	}
+	if m.DestSocketType != policy.MatchTypePrefix {
+		path = append(path, 0)
+	}
+	if len(path) > events.UnixPathLen {
+		path = path[:events.UnixPathLen]
+	}
+	return path
+}
+
+func mergeUnixEntry(entries []unixEntry, entry unixEntry) []unixEntry {
+	for i := range entries {
+		if bytes.Equal(entries[i].path, entry.path) {
+			entries[i].action = mergeAction(entries[i].action, entry.action)
+			entries[i].allow = entries[i].allow || entry.allow
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
+
+// DesiredPtraceRules computes ptrace_rules the same way DesiredExecRules
+// handles names and allows.
+func DesiredPtraceRules(ruleList []policy.Rule) map[PtraceRuleKey]uint8 {
+	desired := make(map[PtraceRuleKey]uint8)
+	allowed := make(map[PtraceRuleKey]bool)
+	for _, rule := range ruleList {
+		if !rule.IsActive() || rule.DeriveType() != policy.RuleTypePtrace {
+			continue
+		}
+		isAllow := rule.Action == policy.ActionAllow && !rule.IsTesting()
+		action := bpfActionForRule(rule)
+		if !isAllow && action < policy.BPFActionBlock {
+			continue
+		}
+
+		key, ok := ptraceKeyForRule(rule)
+		if !ok {
+			if !isAllow {
+				log.Printf("Rule %q cannot be enforced in kernel (needs exact target_name/process_name only), alerting from userspace", rule.Name)
+			}
+			continue
+		}
+		if isAllow {
+			allowed[key] = true
+		} else {
+			desired[key] = mergeAction(desired[key], action)
+		}
+	}
+
+	for key := range allowed {
+		desired[key] = ptraceRuleAllow
+	}
+	return desired
+}
+
+func ptraceKeyForRule(rule policy.Rule) (PtraceRuleKey, bool) {
+	m := rule.Match
+	if m.PID != 0 || m.CgroupID != "" || namespaceScoped(rule) {
+		return PtraceRuleKey{}, false
+	}
+	if m.TargetName == "" && m.ProcessName == "" {
+		return PtraceRuleKey{}, false
+	}
+	if m.TargetName != "" && (m.TargetNameType != policy.MatchTypeExact || len(m.TargetName) >= events.TaskCommLen) {
+		return PtraceRuleKey{}, false
+	}
+	if m.ProcessName != "" && (m.ProcessNameType != policy.MatchTypeExact || len(m.ProcessName) >= events.TaskCommLen) {
+		return PtraceRuleKey{}, false
+	}
+
+	var key PtraceRuleKey
+	copy(key.Target[:], m.TargetName)
+	copy(key.Tracer[:], m.ProcessName)
+	return key, true
+}
+
+// DesiredListenRules computes listen_rules. As with exec and ptrace rules,
+// allows are stored too: the kernel lets any matching allow entry override a
+// block, the way userspace does.
+func DesiredListenRules(ruleList []policy.Rule) map[ListenRuleKey]uint8 {
+	desired := make(map[ListenRuleKey]uint8)
+	allowed := make(map[ListenRuleKey]bool)
+	for _, rule := range ruleList {
+		if !rule.IsActive() || rule.DeriveType() != policy.RuleTypeListen {
+			continue
+		}
+		isAllow := rule.Action == policy.ActionAllow && !rule.IsTesting()
+		action := bpfActionForRule(rule)
+		if !isAllow && action < policy.BPFActionBlock {
+			continue
+		}
+
+		key, ok := listenKeyForRule(rule)
+		if !ok {
+			if !isAllow {
+				log.Printf("Rule %q cannot be enforced in kernel (needs exact process_name/local_port only), alerting from userspace", rule.Name)
+			}
+			continue
+		}
+		if isAllow {
+			allowed[key] = true
+		} else {
+			desired[key] = mergeAction(desired[key], action)
+		}
+	}
+
+	for key := range allowed {
+		desired[key] = listenRuleAllow
+	}
+	return desired
+}
+
+func listenKeyForRule(rule policy.Rule) (ListenRuleKey, bool) {
+	m := rule.Match
+	if m.PID != 0 || m.CgroupID != "" || namespaceScoped(rule) {
+		return ListenRuleKey{}, false
+	}
+	if m.ProcessName != "" && (m.ProcessNameType != policy.MatchTypeExact || len(m.ProcessName) >= events.TaskCommLen) {
+		return ListenRuleKey{}, false
+	}
+
+	key := ListenRuleKey{Port: m.LocalPort}
+	copy(key.Comm[:], m.ProcessName)
+	return key, true
+}
+
+// KernelLoadState is the desired contents of the kernel load maps.
+type KernelLoadState struct {
+	Policies map[uint32]uint8
+	Paths    map[PathKey]uint8
+	Comms    map[[events.TaskCommLen]byte]uint8
+}
+
+// DesiredKernelLoad computes the kernel load maps. Unconditional block rules
+// set load_policy for their kind; allow rules with a module path or an exact
+// process_name become allowlist entries. Anything narrower alerts from
+// userspace only.
+func DesiredKernelLoad(ruleList []policy.Rule) KernelLoadState {
+	// load_policy is an array, so every index stays in the desired set.
+	state := KernelLoadState{
+		Policies: map[uint32]uint8{0: 0, uint32(events.LoadKindModule): 0, uint32(events.LoadKindBPF): 0},
+		Paths:    make(map[PathKey]uint8),
+		Comms:    make(map[[events.TaskCommLen]byte]uint8),
+	}
+	for _, rule := range ruleList {
+		if !rule.IsActive() || rule.DeriveType() != policy.RuleTypeKernelLoad {
+			continue
+		}
+		kinds := loadKindsForRule(rule)
+		match := rule.Match
+
+		if rule.Action == policy.ActionAllow && !rule.IsTesting() {
+			if match.CgroupID != "" || match.PID != 0 || namespaceScoped(rule) {
+				log.Printf("Rule %q allowlists loads by cgroup, pid or namespace, which only userspace evaluates", rule.Name)
+				continue
+			}
+			if match.Filename != "" {
+				path := utils.CanonicalPath(match.Filename)
+				if path == "" || len(path) >= events.PathMaxLen {
+					continue
+				}
+				var key PathKey
+				copy(key[:], path)
+				state.Paths[key] |= loadKindMask(kinds)
+			}
+			if match.ProcessName != "" {
+				if match.ProcessNameType != policy.MatchTypeExact {
+					log.Printf("Rule %q allowlists loads by non-exact process_name, which only userspace evaluates", rule.Name)
+					continue
+				}
+				var key [events.TaskCommLen]byte
+				copy(key[:events.TaskCommLen-1], match.ProcessName)
+				state.Comms[key] |= loadKindMask(kinds)
+			}
+			continue
+		}
+
+		action := bpfActionForRule(rule)
+		if action >= policy.BPFActionBlock && (match.Filename != "" || match.ProcessName != "" || match.CgroupID != "" || match.PID != 0) {
+			log.Printf("Rule %q cannot be enforced in kernel (load blocks must be unconditional), alerting from userspace", rule.Name)
+			action = policy.BPFActionMonitor
+		}
+		for _, kind := range kinds {
+			state.Policies[uint32(kind)] = mergeAction(state.Policies[uint32(kind)], action)
+		}
+	}
+	return state
+}
+
+func loadKindsForRule(rule policy.Rule) []events.LoadKind {
+	switch rule.Match.Operation {
+	case "module":
+		return []events.LoadKind{events.LoadKindModule}
+	case "bpf":
+		return []events.LoadKind{events.LoadKindBPF}
+	default:
+		return []events.LoadKind{events.LoadKindModule, events.LoadKindBPF}
+	}
+}
+
+func loadKindMask(kinds []events.LoadKind) uint8 {
+	var mask uint8
+	for _, kind := range kinds {
+		mask |= 1 << kind
+	}
+	return mask
+}
+
+// ruleCgroupID parses a rule's cgroup_id condition. scoped is false for
+// host-wide rules; ok is false when the condition cannot be parsed, in which
+// case the rule is left to userspace rather than enforced globally.
+func ruleCgroupID(rule policy.Rule) (cgroupID uint64, scoped bool, ok bool) {
+	if rule.Match.CgroupID == "" {
+		return 0, false, true
+	}
+	id, err := strconv.ParseUint(rule.Match.CgroupID, 10, 64)
+	if err != nil {
+		log.Printf("Warning: cgroup_id %q in rule %q is not numeric, kernel enforcement skipped", rule.Match.CgroupID, rule.Name)
+		return 0, false, false
+	}
+	return id, true, true
+}
+
+// skipScopedRule reports (and logs) cgroup-scoped rules for maps that have no
+// per-cgroup variant, so they are not silently enforced host-wide.
+func skipScopedRule(rule policy.Rule, what string) bool {
+	if rule.Match.CgroupID == "" {
+		return false
+	}
+	log.Printf("Rule %q is cgroup-scoped; %s rules are only scoped in userspace", rule.Name, what)
+	return true
+}
+
+// fileMonitor is the report bit of a file map value; bit (1 << FileOp)
+// denies and reports that operation. See FILE_BLOCKS in main.bpf.c. The kill bits turn
+// every denial on the path into a kill, mirroring FILE_KILL and FILE_KILL_TREE.
+const (
+	fileMonitor  uint16 = 0x01
+	fileKill     uint16 = 1 << 9
+	fileKillTree uint16 = 1 << 10
+)
+
+// fileAccessBits are the deny bits for an open's access mode, mirroring
+// FILE_ACCESS_* in main.bpf.c. read and write reuse the operation bits.
+var fileAccessBits = map[string]uint16{
+	"read":     1 << events.FileOpRead,
+	"write":    1 << events.FileOpWrite,
+	"append":   1 << 6,
+	"create":   1 << 7,
+	"truncate": 1 << 8,
+}
+
+// FileMaskForRule encodes a file rule for monitored_files, scoped_files and
+// monitored_dirs. Rules that only alert report every operation; enforced
+// block rules deny, and so report, their access mode or operation, or all
+// operations when neither is given.
+func FileMaskForRule(rule policy.Rule) uint16 {
+	var mask uint16
+	switch bpfActionForRule(rule) {
+	case policy.BPFActionBlock:
+	case policy.BPFActionKill:
+		mask |= fileKill
+	case policy.BPFActionKillTree:
+		mask |= fileKillTree
+	default:
+		return fileMonitor
+	}
+	if bits, ok := fileAccessBits[rule.Match.Access]; ok {
+		return mask | bits
+	}
+	if op, ok := events.ParseFileOp(rule.Match.Operation); ok {
+		return mask | 1<<op
+	}
+	for _, op := range events.FileOps {
+		mask |= 1 << op
+	}
+	return mask
+}
+
+func bpfActionForRule(rule policy.Rule) uint8 {
+	if rule.IsTesting() || namespaceScoped(rule) {
+		return policy.BPFActionMonitor
+	}
+	switch rule.Action {
+	case policy.ActionBlock:
+		return policy.BPFActionBlock
+	case policy.ActionKill:
+		return policy.BPFActionKill
+	case policy.ActionKillTree:
+		return policy.BPFActionKillTree
+	}
+	return policy.BPFActionMonitor
+}
+
+// namespaceScoped reports rules limited by host_only, container_only or
+// net_ns. No map is keyed by namespace, so they only report and userspace
+// decides.
+func namespaceScoped(rule policy.Rule) bool {
+	return rule.Match.HostOnly || rule.Match.ContainerOnly || rule.Match.NetNS != 0
+}
+
+func mergeAction(existing, proposed uint8) uint8 {
+	if proposed > existing {
+		return proposed
+	}
+	return existing
+}
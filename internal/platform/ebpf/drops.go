@@ -0,0 +1,48 @@
+package ebpf
+
+import (
+	"fmt"
+
+	"aegis/internal/platform/events"
+	"aegis/internal/system"
+)
+
+// ringbufDrop mirrors struct ringbuf_drop.
+type ringbufDrop struct {
+	Events  uint64
+	Blocked uint64
+}
+
+// RingbufStats sums the per-CPU ringbuf_drops counters and samples how many
+// bytes are waiting in the ring buffer for the reader.
+func (r *Resources) RingbufStats() (system.RingbufStats, error) {
+	var stats system.RingbufStats
+	if r == nil || r.Objects == nil || r.Objects.RingbufDrops == nil {
+		return stats, fmt.Errorf("ringbuf_drops map is nil")
+	}
+	if r.Reader != nil {
+		stats.PendingBytes = r.Reader.AvailableBytes()
+		stats.BufferSize = r.Reader.BufferSize()
+	}
+
+	var perCPU []ringbufDrop
+	for slot := uint32(0); slot < events.EventTypeSlots; slot++ {
+		if err := r.Objects.RingbufDrops.Lookup(slot, &perCPU); err != nil {
+			return stats, fmt.Errorf("lookup ringbuf_drops[%d]: %w", slot, err)
+		}
+		var dropped uint64
+		for _, cpu := range perCPU {
+			dropped += cpu.Events
+			stats.DroppedBlocked += cpu.Blocked
+		}
+		if dropped == 0 {
+			continue
+		}
+		if stats.DroppedByType == nil {
+			stats.DroppedByType = make(map[string]uint64)
+		}
+		stats.DroppedByType[events.EventType(slot).String()] += dropped
+		stats.Dropped += dropped
+	}
+	return stats, nil
+}
@@ -2,49 +2,248 @@ package ebpf
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+
+	internalconfig "aegis/internal/platform/config"
+	"aegis/internal/system"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
 )
 
+// LSMObjects splits into programs and maps so that adopting pinned objects
+// can load the maps alone. Only one of LSMPrograms and MonitorPrograms is
+// loaded: the monitor programs stand in for the enforcing hooks when BPF LSM
+// is not enabled.
 type LSMObjects struct {
+	LSMPrograms
+	TracingPrograms
+	MonitorPrograms
+	LSMMaps
+}
+
+type LSMPrograms struct {
 	LsmBprmCheck     *ebpf.Program `ebpf:"lsm_bprm_check"`
 	LsmFileOpen      *ebpf.Program `ebpf:"lsm_file_open"`
 	LsmSocketConnect *ebpf.Program `ebpf:"lsm_socket_connect"`
+	LsmTaskFixSetuid *ebpf.Program `ebpf:"lsm_task_fix_setuid"`
+	LsmTaskFixSetgid *ebpf.Program `ebpf:"lsm_task_fix_setgid"`
+	LsmCapset        *ebpf.Program `ebpf:"lsm_capset"`
+	LsmKernelRead    *ebpf.Program `ebpf:"lsm_kernel_read_file"`
+	LsmKernelLoad    *ebpf.Program `ebpf:"lsm_kernel_load_data"`
+	LsmBPF           *ebpf.Program `ebpf:"lsm_bpf"`
+	LsmBPFMap        *ebpf.Program `ebpf:"lsm_bpf_map"`
+	LsmPtraceAccess  *ebpf.Program `ebpf:"lsm_ptrace_access_check"`
+	LsmInodeUnlink   *ebpf.Program `ebpf:"lsm_inode_unlink"`
+	LsmPathRename    *ebpf.Program `ebpf:"lsm_path_rename"`
+	LsmInodeSetattr  *ebpf.Program `ebpf:"lsm_inode_setattr"`
+	LsmSocketSendmsg *ebpf.Program `ebpf:"lsm_socket_sendmsg"`
+	LsmSockRcvSkb    *ebpf.Program `ebpf:"lsm_socket_sock_rcv_skb"`
+	LsmSkFree        *ebpf.Program `ebpf:"lsm_sk_free_security"`
+	LsmSocketBind    *ebpf.Program `ebpf:"lsm_socket_bind"`
+	LsmSocketListen  *ebpf.Program `ebpf:"lsm_socket_listen"`
+	LsmSbMount       *ebpf.Program `ebpf:"lsm_sb_mount"`
+	LsmMoveMount     *ebpf.Program `ebpf:"lsm_move_mount"`
+	LsmTaskKill      *ebpf.Program `ebpf:"lsm_task_kill"`
+	// The execve entries only keep argv for lsm_bprm_check; the exits drop
+	// it again.
+	ExecveEnter   *ebpf.Program `ebpf:"handle_execve_enter"`
+	ExecveatEnter *ebpf.Program `ebpf:"handle_execveat_enter"`
+	ExecveExit    *ebpf.Program `ebpf:"handle_execve_exit"`
+	ExecveatExit  *ebpf.Program `ebpf:"handle_execveat_exit"`
+}
 
+// TracingPrograms observe process lifecycle and namespace changes, which
+// have no LSM hook, and are loaded in both modes.
+type TracingPrograms struct {
+	SchedProcessFork *ebpf.Program `ebpf:"handle_sched_process_fork"`
+	SchedProcessExit *ebpf.Program `ebpf:"handle_sched_process_exit"`
+	SetnsEnter       *ebpf.Program `ebpf:"handle_setns_enter"`
+	SetnsExit        *ebpf.Program `ebpf:"handle_setns_exit"`
+	UnshareEnter     *ebpf.Program `ebpf:"handle_unshare_enter"`
+	UnshareExit      *ebpf.Program `ebpf:"handle_unshare_exit"`
+}
+
+// MonitorPrograms report exec, file open and TCP connect events without
+// enforcing, for kernels where BPF LSM is not enabled.
+type MonitorPrograms struct {
+	SchedProcessExec *ebpf.Program `ebpf:"handle_sched_process_exec"`
+	FentryFileOpen   *ebpf.Program `ebpf:"fentry_file_open"`
+	FentryTCPConnect *ebpf.Program `ebpf:"fentry_tcp_connect"`
+}
+
+type LSMMaps struct {
 	Events         *ebpf.Map `ebpf:"events"`
+	RingbufDrops   *ebpf.Map `ebpf:"ringbuf_drops"`
 	MonitoredFiles *ebpf.Map `ebpf:"monitored_files"`
+	MonitoredDirs  *ebpf.Map `ebpf:"monitored_dirs"`
+	ScopedFiles    *ebpf.Map `ebpf:"scoped_files"`
+	ExecRules      *ebpf.Map `ebpf:"exec_rules"`
 	BlockedPorts   *ebpf.Map `ebpf:"blocked_ports"`
+	ScopedPorts    *ebpf.Map `ebpf:"scoped_ports"`
+	BlockedV4      *ebpf.Map `ebpf:"blocked_v4"`
+	BlockedV6      *ebpf.Map `ebpf:"blocked_v6"`
+	LoadPolicy     *ebpf.Map `ebpf:"load_policy"`
+	LoadAllowPaths *ebpf.Map `ebpf:"load_allow_paths"`
+	LoadAllowComms *ebpf.Map `ebpf:"load_allow_comms"`
+	PtraceRules    *ebpf.Map `ebpf:"ptrace_rules"`
+	DomainV4       *ebpf.Map `ebpf:"domain_v4"`
+	DomainV6       *ebpf.Map `ebpf:"domain_v6"`
+	DNSSocks       *ebpf.Map `ebpf:"dns_socks"`
+	ListenRules    *ebpf.Map `ebpf:"listen_rules"`
+	ListenSocks    *ebpf.Map `ebpf:"listen_socks"`
+	UnixSockets    *ebpf.Map `ebpf:"unix_sockets"`
 	PidToPpid      *ebpf.Map `ebpf:"pid_to_ppid"`
+	AdminUIDs      *ebpf.Map `ebpf:"protect_admin_uids"`
+	AdminExes      *ebpf.Map `ebpf:"protect_admin_exes"`
+	AegisLinks     *ebpf.Map `ebpf:"aegis_links"`
+	AegisMaps      *ebpf.Map `ebpf:"aegis_maps"`
+	AegisConfig    *ebpf.Map `ebpf:"aegis_config"`
+	ProtectPins    *ebpf.Map `ebpf:"protect_pins"`
+	NSPending      *ebpf.Map `ebpf:"ns_pending"`
+	ExecArgv       *ebpf.Map `ebpf:"exec_argv"`
+}
+
+// LoadLSMObjects loads the maps, the tracing programs and either the LSM
+// programs or, with monitorOnly set, the monitor programs. Programs whose
+// hook the kernel lacks are left out and their fields stay nil, as are
+// programs the kernel rejects, which are recorded in caps. When cfg.Pin is
+// set the maps are pinned by name, and maps already pinned are reused with
+// their contents.
+func LoadLSMObjects(cfg internalconfig.KernelConfig, monitorOnly bool, caps *system.Capabilities) (*LSMObjects, error) {
+	spec, opts, err := loadSpec(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &ebpf.CollectionOptions{}
+	}
+
+	objs := &LSMObjects{}
+	var optional any = &objs.LSMPrograms
+	unused := programNames(&MonitorPrograms{})
+	if monitorOnly {
+		optional = &objs.MonitorPrograms
+		unused = programNames(&LSMPrograms{})
+	}
+	for _, name := range unused {
+		delete(spec.Programs, name)
+	}
+	pruneUnsupported(spec)
+
+	// One program failing the verifier should not take the others down, so
+	// the load is retried without it. Each retry drops a program, which
+	// bounds the loop.
+	var coll *ebpf.Collection
+	for {
+		coll, err = ebpf.NewCollectionWithOptions(spec, *opts)
+		if err == nil {
+			break
+		}
+		name := rejectedProgram(spec, err)
+		if name == "" {
+			return nil, fmt.Errorf("load eBPF LSM programs: %w", err)
+		}
+		log.Printf("Skipping %s: %v", name, err)
+		recordRejected(caps, name, spec.Programs[name], err)
+		delete(spec.Programs, name)
+	}
+	defer coll.Close()
+
+	if err := coll.Assign(&objs.LSMMaps); err != nil {
+		return nil, fmt.Errorf("load eBPF LSM programs: %w", err)
+	}
+	assignPrograms(coll, &objs.TracingPrograms)
+	assignPrograms(coll, optional)
+
+	return objs, nil
 }
 
-func LoadLSMObjects(objPath string, ringBufSize int) (*LSMObjects, error) {
-	abspath, err := filepath.Abs(objPath)
+// programNames lists the program names tagged on the fields of group, a
+// pointer to one of the program structs.
+func programNames(group any) []string {
+	t := reflect.TypeOf(group).Elem()
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, t.Field(i).Tag.Get("ebpf"))
+	}
+	return names
+}
+
+// assignPrograms moves the programs of group out of coll by tag, leaving the
+// fields of programs that were not loaded nil.
+func assignPrograms(coll *ebpf.Collection, group any) {
+	v := reflect.ValueOf(group).Elem()
+	for i, name := range programNames(group) {
+		v.Field(i).Set(reflect.ValueOf(coll.DetachProgram(name)))
+	}
+}
+
+func loadSpec(cfg internalconfig.KernelConfig) (*ebpf.CollectionSpec, *ebpf.CollectionOptions, error) {
+	abspath, err := filepath.Abs(cfg.BPFPath)
 	if err != nil {
-		return nil, fmt.Errorf("resolve bpf path: %w", err)
+		return nil, nil, fmt.Errorf("resolve bpf path: %w", err)
 	}
 	if _, err := os.Stat(abspath); err != nil {
-		return nil, fmt.Errorf("stat bpf object: %w", err)
+		return nil, nil, fmt.Errorf("stat bpf object: %w", err)
 	}
 
 	spec, err := ebpf.LoadCollectionSpec(abspath)
 	if err != nil {
-		return nil, fmt.Errorf("load collection spec: %w", err)
+		return nil, nil, fmt.Errorf("load collection spec: %w", err)
 	}
 
-	if ringBufSize > 0 {
+	if cfg.RingBufferSize > 0 {
 		if eventsSpec, ok := spec.Maps["events"]; ok {
-			eventsSpec.MaxEntries = uint32(ringBufSize)
+			eventsSpec.MaxEntries = uint32(cfg.RingBufferSize)
 		}
 	}
 
-	objs := &LSMObjects{}
-	if err := spec.LoadAndAssign(objs, nil); err != nil {
-		return nil, fmt.Errorf("load eBPF LSM programs: %w", err)
+	if v, ok := spec.Variables["setattr_has_idmap"]; ok && inodeSetattrTakesIdmap() {
+		if err := v.Set(uint8(1)); err != nil {
+			return nil, nil, fmt.Errorf("set setattr_has_idmap: %w", err)
+		}
 	}
 
-	return objs, nil
+	if !cfg.Pin {
+		return spec, nil, nil
+	}
+	opts, err := pinMaps(spec, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return spec, opts, nil
+}
+
+// aegisConfig mirrors struct aegis_config in main.bpf.c.
+type aegisConfig struct {
+	TGID           uint32
+	SelfProtection uint8
+	CaptureEnv     uint8
+	_              [2]byte
+}
+
+// ConfigureAgent writes the settings the programs read at run time. It runs
+// on every start, so programs adopted from pins follow the new daemon's pid
+// and configuration.
+func ConfigureAgent(objs *LSMObjects, cfg internalconfig.KernelConfig) error {
+	if objs.AegisConfig == nil {
+		return fmt.Errorf("aegis_config map is nil")
+	}
+	value := aegisConfig{TGID: uint32(os.Getpid())}
+	if cfg.SelfProtection.Enabled {
+		value.SelfProtection = 1
+	}
+	if cfg.CaptureEnv {
+		value.CaptureEnv = 1
+	}
+	if err := objs.AegisConfig.Put(uint32(0), value); err != nil {
+		return fmt.Errorf("update aegis_config: %w", err)
+	}
+	return nil
 }
 
 func (o *LSMObjects) Close() error {
@@ -58,12 +257,69 @@ func (o *LSMObjects) Close() error {
 	firstErr = closeProgram("lsm_bprm_check", o.LsmBprmCheck, firstErr)
 	firstErr = closeProgram("lsm_file_open", o.LsmFileOpen, firstErr)
 	firstErr = closeProgram("lsm_socket_connect", o.LsmSocketConnect, firstErr)
+	firstErr = closeProgram("lsm_task_fix_setuid", o.LsmTaskFixSetuid, firstErr)
+	firstErr = closeProgram("lsm_task_fix_setgid", o.LsmTaskFixSetgid, firstErr)
+	firstErr = closeProgram("lsm_capset", o.LsmCapset, firstErr)
+	firstErr = closeProgram("lsm_kernel_read_file", o.LsmKernelRead, firstErr)
+	firstErr = closeProgram("lsm_kernel_load_data", o.LsmKernelLoad, firstErr)
+	firstErr = closeProgram("lsm_bpf", o.LsmBPF, firstErr)
+	firstErr = closeProgram("lsm_bpf_map", o.LsmBPFMap, firstErr)
+	firstErr = closeProgram("lsm_ptrace_access_check", o.LsmPtraceAccess, firstErr)
+	firstErr = closeProgram("lsm_inode_unlink", o.LsmInodeUnlink, firstErr)
+	firstErr = closeProgram("lsm_path_rename", o.LsmPathRename, firstErr)
+	firstErr = closeProgram("lsm_inode_setattr", o.LsmInodeSetattr, firstErr)
+	firstErr = closeProgram("lsm_socket_sendmsg", o.LsmSocketSendmsg, firstErr)
+	firstErr = closeProgram("lsm_socket_sock_rcv_skb", o.LsmSockRcvSkb, firstErr)
+	firstErr = closeProgram("lsm_sk_free_security", o.LsmSkFree, firstErr)
+	firstErr = closeProgram("lsm_socket_bind", o.LsmSocketBind, firstErr)
+	firstErr = closeProgram("lsm_socket_listen", o.LsmSocketListen, firstErr)
+	firstErr = closeProgram("lsm_sb_mount", o.LsmSbMount, firstErr)
+	firstErr = closeProgram("lsm_move_mount", o.LsmMoveMount, firstErr)
+	firstErr = closeProgram("lsm_task_kill", o.LsmTaskKill, firstErr)
+	firstErr = closeProgram("handle_sched_process_fork", o.SchedProcessFork, firstErr)
+	firstErr = closeProgram("handle_sched_process_exit", o.SchedProcessExit, firstErr)
+	firstErr = closeProgram("handle_setns_enter", o.SetnsEnter, firstErr)
+	firstErr = closeProgram("handle_setns_exit", o.SetnsExit, firstErr)
+	firstErr = closeProgram("handle_unshare_enter", o.UnshareEnter, firstErr)
+	firstErr = closeProgram("handle_unshare_exit", o.UnshareExit, firstErr)
+	firstErr = closeProgram("handle_execve_enter", o.ExecveEnter, firstErr)
+	firstErr = closeProgram("handle_execveat_enter", o.ExecveatEnter, firstErr)
+	firstErr = closeProgram("handle_execve_exit", o.ExecveExit, firstErr)
+	firstErr = closeProgram("handle_execveat_exit", o.ExecveatExit, firstErr)
+	firstErr = closeProgram("handle_sched_process_exec", o.SchedProcessExec, firstErr)
+	firstErr = closeProgram("fentry_file_open", o.FentryFileOpen, firstErr)
+	firstErr = closeProgram("fentry_tcp_connect", o.FentryTCPConnect, firstErr)
 
 	// Close maps
 	firstErr = closeMap("events", o.Events, firstErr)
+	firstErr = closeMap("ringbuf_drops", o.RingbufDrops, firstErr)
 	firstErr = closeMap("monitored_files", o.MonitoredFiles, firstErr)
+	firstErr = closeMap("monitored_dirs", o.MonitoredDirs, firstErr)
+	firstErr = closeMap("scoped_files", o.ScopedFiles, firstErr)
+	firstErr = closeMap("exec_rules", o.ExecRules, firstErr)
 	firstErr = closeMap("blocked_ports", o.BlockedPorts, firstErr)
+	firstErr = closeMap("scoped_ports", o.ScopedPorts, firstErr)
+	firstErr = closeMap("blocked_v4", o.BlockedV4, firstErr)
+	firstErr = closeMap("blocked_v6", o.BlockedV6, firstErr)
+	firstErr = closeMap("load_policy", o.LoadPolicy, firstErr)
+	firstErr = closeMap("load_allow_paths", o.LoadAllowPaths, firstErr)
+	firstErr = closeMap("load_allow_comms", o.LoadAllowComms, firstErr)
+	firstErr = closeMap("ptrace_rules", o.PtraceRules, firstErr)
+	firstErr = closeMap("domain_v4", o.DomainV4, firstErr)
+	firstErr = closeMap("domain_v6", o.DomainV6, firstErr)
+	firstErr = closeMap("dns_socks", o.DNSSocks, firstErr)
+	firstErr = closeMap("listen_rules", o.ListenRules, firstErr)
+	firstErr = closeMap("listen_socks", o.ListenSocks, firstErr)
+	firstErr = closeMap("unix_sockets", o.UnixSockets, firstErr)
 	firstErr = closeMap("pid_to_ppid", o.PidToPpid, firstErr)
+	firstErr = closeMap("ns_pending", o.NSPending, firstErr)
+	firstErr = closeMap("exec_argv", o.ExecArgv, firstErr)
+	firstErr = closeMap("protect_admin_uids", o.AdminUIDs, firstErr)
+	firstErr = closeMap("protect_admin_exes", o.AdminExes, firstErr)
+	firstErr = closeMap("aegis_links", o.AegisLinks, firstErr)
+	firstErr = closeMap("aegis_maps", o.AegisMaps, firstErr)
+	firstErr = closeMap("aegis_config", o.AegisConfig, firstErr)
+	firstErr = closeMap("protect_pins", o.ProtectPins, firstErr)
 
 	return firstErr
 }
@@ -87,3 +343,18 @@ func closeMap(name string, m *ebpf.Map, err error) error {
 	}
 	return err
 }
+
+// inodeSetattrTakesIdmap reports whether the inode_setattr LSM hook has the
+// leading mnt_idmap argument added in Linux 6.8.
+func inodeSetattrTakesIdmap() bool {
+	kernelSpec, err := btf.LoadKernelSpec()
+	if err != nil {
+		return false
+	}
+	var fn *btf.Func
+	if err := kernelSpec.TypeByName("bpf_lsm_inode_setattr", &fn); err != nil {
+		return false
+	}
+	proto, ok := fn.Type.(*btf.FuncProto)
+	return ok && len(proto.Params) == 3
+}
@@ -1,9 +1,10 @@
 package ebpf
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"net"
 
 	"aegis/internal/platform/events"
 	"aegis/internal/policy"
@@ -11,188 +12,305 @@ import (
 	"github.com/cilium/ebpf"
 )
 
-func PopulateMonitoredFiles(bpfMap *ebpf.Map, ruleList []policy.Rule, rulesPath string) error {
-	if bpfMap == nil {
-		return fmt.Errorf("monitored_files map is nil")
-	}
+// Each Sync* function takes the desired contents of its maps from the
+// matching Desired* function in desired.go and hands them to syncMap, which
+// only touches keys that differ. Block rules therefore stay enforced across
+// reloads.
 
-	fileActions := make(map[string]uint8)
-	for _, rule := range ruleList {
-		if !rule.IsActive() {
-			continue
-		}
+// PathKey is a NUL-padded absolute path, as in monitored_files.
+type PathKey [events.PathMaxLen]byte
 
-		paths := rule.Match.ExactPathKeys()
-		if len(paths) == 0 {
-			continue
-		}
+// ScopedPathKey and ScopedPortKey mirror the cgroup-scoped keys in
+// main.bpf.c.
+type ScopedPathKey struct {
+	CgroupID uint64
+	Path     PathKey
+}
 
-		for _, path := range paths {
-			key := extractParentFilename(path)
-			if key == "" {
-				continue
-			}
+type ScopedPortKey struct {
+	CgroupID uint64
+	Port     uint16
+	_        [6]byte
+}
 
-			action := bpfActionForRule(rule)
-			fileActions[key] = mergeAction(fileActions[key], action)
-		}
+// DirMapKey mirrors struct dir_key in main.bpf.c.
+type DirMapKey struct {
+	Ino uint64
+	Dev uint64
+}
+
+// ExecRuleKey mirrors struct exec_rule_key in main.bpf.c.
+type ExecRuleKey struct {
+	Comm  [events.TaskCommLen]byte
+	PComm [events.TaskCommLen]byte
+}
+
+// PtraceRuleKey mirrors struct ptrace_rule_key in main.bpf.c.
+type PtraceRuleKey struct {
+	Target [events.TaskCommLen]byte
+	Tracer [events.TaskCommLen]byte
+}
+
+// ListenRuleKey mirrors struct listen_rule_key in main.bpf.c.
+type ListenRuleKey struct {
+	Comm [events.TaskCommLen]byte
+	Port uint16
+	_    [6]byte
+}
+
+// listenRuleAllow, ptraceRuleAllow and execRuleAllow mark allow entries in
+// listen_rules, ptrace_rules and exec_rules; see the *_RULE_ALLOW defines in
+// main.bpf.c.
+const (
+	listenRuleAllow uint8 = 0
+	ptraceRuleAllow uint8 = 0
+	execRuleAllow   uint8 = 0
+)
+
+// IPv4LPMKey and IPv6LPMKey mirror the trie keys in main.bpf.c. The port is
+// stored in network byte order and always covered by the prefix length.
+type IPv4LPMKey struct {
+	Prefixlen uint32
+	Port      [2]byte
+	Addr      [4]byte
+	_         [2]byte
+}
+
+type IPv6LPMKey struct {
+	Prefixlen uint32
+	Port      [2]byte
+	Addr      [16]byte
+	_         [2]byte
+}
+
+// DomainV4Key and DomainV6Key mirror the dest_domain address keys in
+// main.bpf.c. The port is in network byte order; 0 matches any port.
+type DomainV4Key struct {
+	Addr [4]byte
+	Port [2]byte
+	_    [2]byte
+}
+
+type DomainV6Key struct {
+	Addr [16]byte
+	Port [2]byte
+	_    [2]byte
+}
+
+// UnixLPMKey mirrors struct unix_lpm_key in main.bpf.c.
+type UnixLPMKey struct {
+	Prefixlen uint32
+	Path      [events.UnixPathLen]byte
+}
+
+// SyncMonitoredFiles syncs exact file rules into monitored_files, or into
+// scoped_files when the rule is limited to one cgroup.
+func SyncMonitoredFiles(bpfMap, scopedMap *ebpf.Map, ruleList []policy.Rule, rulesPath string) ([]policy.MapSyncStats, error) {
+	if bpfMap == nil {
+		return nil, fmt.Errorf("monitored_files map is nil")
+	}
+	if scopedMap == nil {
+		return nil, fmt.Errorf("scoped_files map is nil")
 	}
 
-	if len(fileActions) == 0 {
+	fileActions, scopedActions := DesiredFiles(ruleList)
+	if len(fileActions) == 0 && len(scopedActions) == 0 {
 		log.Printf("Warning: No file access rules found in %s", rulesPath)
-		return nil
 	}
 
-	countMonitor := 0
-	countBlock := 0
-	for filename, action := range fileActions {
-		key := make([]byte, events.PathMaxLen)
-		copy(key, []byte(filename))
-		if err := bpfMap.Put(key, action); err != nil {
-			return fmt.Errorf("add file %q to BPF map: %w", filename, err)
-		}
-		if action == policy.BPFActionBlock {
-			countBlock++
-		} else {
-			countMonitor++
-		}
+	files, err := syncMap("monitored_files", bpfMap, fileActions)
+	if err != nil {
+		return nil, err
 	}
+	scopedFiles, err := syncMap("scoped_files", scopedMap, scopedActions)
+	if err != nil {
+		return []policy.MapSyncStats{files}, err
+	}
+	return []policy.MapSyncStats{files, scopedFiles}, nil
+}
 
-	log.Printf("Populated BPF map with %d monitored files (%d block, %d monitor)",
-		len(fileActions), countBlock, countMonitor)
-
-	return nil
+// SyncMonitoredDirs syncs the directory inodes watched by wildcard file rules.
+func SyncMonitoredDirs(bpfMap *ebpf.Map, ruleList []policy.Rule) (policy.MapSyncStats, error) {
+	if bpfMap == nil {
+		return policy.MapSyncStats{}, fmt.Errorf("monitored_dirs map is nil")
+	}
+	return syncMap("monitored_dirs", bpfMap, DesiredDirs(ruleList))
 }
 
-func RepopulateMonitoredFiles(bpfMap *ebpf.Map, ruleList []policy.Rule, rulesPath string) error {
+// SyncBlockedPorts syncs port-only connect rules into blocked_ports, or into
+// scoped_ports when the rule is limited to one cgroup.
+func SyncBlockedPorts(bpfMap, scopedMap *ebpf.Map, ruleList []policy.Rule) ([]policy.MapSyncStats, error) {
 	if bpfMap == nil {
-		return fmt.Errorf("monitored_files map is nil")
+		return nil, fmt.Errorf("blocked_ports map is nil")
 	}
-	if err := clearMonitoredFilesMap(bpfMap); err != nil {
-		return err
+	if scopedMap == nil {
+		return nil, fmt.Errorf("scoped_ports map is nil")
+	}
+
+	portActions, scopedActions := DesiredPorts(ruleList)
+	ports, err := syncMap("blocked_ports", bpfMap, portActions)
+	if err != nil {
+		return nil, err
 	}
-	return PopulateMonitoredFiles(bpfMap, ruleList, rulesPath)
+	scopedPorts, err := syncMap("scoped_ports", scopedMap, scopedActions)
+	if err != nil {
+		return []policy.MapSyncStats{ports}, err
+	}
+	return []policy.MapSyncStats{ports, scopedPorts}, nil
 }
 
-func PopulateBlockedPorts(bpfMap *ebpf.Map, ruleList []policy.Rule) error {
+// SyncExecRules syncs the exec block and allow rules the kernel can
+// evaluate: exact process_name and/or parent_name with no other conditions.
+// Other block rules still alert from userspace.
+func SyncExecRules(bpfMap *ebpf.Map, ruleList []policy.Rule) (policy.MapSyncStats, error) {
 	if bpfMap == nil {
-		return fmt.Errorf("blocked_ports map is nil")
+		return policy.MapSyncStats{}, fmt.Errorf("exec_rules map is nil")
 	}
+	return syncMap("exec_rules", bpfMap, DesiredExecRules(ruleList))
+}
 
-	portActions := make(map[uint16]uint8)
-	for _, rule := range ruleList {
-		if !rule.IsActive() {
-			continue
-		}
+// SyncBlockedAddrs syncs dest_ip rules (optionally with a port) into the
+// IPv4 and IPv6 tries.
+func SyncBlockedAddrs(v4Map, v6Map *ebpf.Map, ruleList []policy.Rule) ([]policy.MapSyncStats, error) {
+	if v4Map == nil || v6Map == nil {
+		return nil, fmt.Errorf("blocked address maps are nil")
+	}
 
-		if rule.Match.DestPort == 0 {
-			continue
-		}
+	v4Actions, v6Actions := DesiredAddrs(ruleList)
+	v4Stats, err := syncMap("blocked_v4", v4Map, v4Actions)
+	if err != nil {
+		return nil, err
+	}
+	v6Stats, err := syncMap("blocked_v6", v6Map, v6Actions)
+	if err != nil {
+		return []policy.MapSyncStats{v4Stats}, err
+	}
+	return []policy.MapSyncStats{v4Stats, v6Stats}, nil
+}
 
-		action := bpfActionForRule(rule)
-		port := rule.Match.DestPort
-		portActions[port] = mergeAction(portActions[port], action)
+// ResyncDomainAddrs brings domain_v4 and domain_v6 in line with the cached
+// DNS answers matched against the current rules. Addresses a rule change
+// leaves enforced are never dropped in between.
+func ResyncDomainAddrs(v4Map, v6Map *ebpf.Map, resolved []policy.DomainAddrs) ([]policy.MapSyncStats, error) {
+	if v4Map == nil || v6Map == nil {
+		return nil, fmt.Errorf("domain address maps are nil")
 	}
 
-	if len(portActions) == 0 {
-		return nil
+	v4Actions, v6Actions := DesiredDomainAddrs(resolved)
+	v4Stats, err := syncMap("domain_v4", v4Map, v4Actions)
+	if err != nil {
+		return nil, err
+	}
+	v6Stats, err := syncMap("domain_v6", v6Map, v6Actions)
+	if err != nil {
+		return []policy.MapSyncStats{v4Stats}, err
+	}
+	return []policy.MapSyncStats{v4Stats, v6Stats}, nil
+}
+
+// SyncDomainAddrs adds the addresses a domain resolved to for the dest_domain
+// rules it matched. Entries are only ever raised here; the maps are LRU and
+// ResyncDomainAddrs drops the ones no rule wants when the rules change.
+func SyncDomainAddrs(v4Map, v6Map *ebpf.Map, matched []policy.Rule, addrs []net.IP) error {
+	if v4Map == nil || v6Map == nil {
+		return fmt.Errorf("domain address maps are nil")
 	}
 
-	countMonitor := 0
-	countBlock := 0
-	for port, action := range portActions {
-		if err := bpfMap.Put(port, action); err != nil {
-			return fmt.Errorf("add port %d to BPF map: %w", port, err)
+	v4Actions, v6Actions := DesiredDomainAddrs([]policy.DomainAddrs{{Rules: matched, Addrs: addrs}})
+	for key, action := range v4Actions {
+		if err := raiseMapAction(v4Map, key, action); err != nil {
+			return fmt.Errorf("update domain_v4: %w", err)
 		}
-		if action == policy.BPFActionBlock {
-			countBlock++
-		} else {
-			countMonitor++
+	}
+	for key, action := range v6Actions {
+		if err := raiseMapAction(v6Map, key, action); err != nil {
+			return fmt.Errorf("update domain_v6: %w", err)
 		}
 	}
-
-	log.Printf("Populated BPF map with %d monitored ports (%d block, %d monitor)",
-		len(portActions), countBlock, countMonitor)
 	return nil
 }
 
-func RepopulateBlockedPorts(bpfMap *ebpf.Map, ruleList []policy.Rule) error {
-	if bpfMap == nil {
-		return fmt.Errorf("blocked_ports map is nil")
-	}
-	if err := clearBlockedPortsMap(bpfMap); err != nil {
-		return err
+func raiseMapAction[K any](bpfMap *ebpf.Map, key K, action uint8) error {
+	var existing uint8
+	if err := bpfMap.Lookup(key, &existing); err == nil && existing >= action {
+		return nil
 	}
-	return PopulateBlockedPorts(bpfMap, ruleList)
+	return bpfMap.Put(key, action)
 }
 
-func bpfActionForRule(rule policy.Rule) uint8 {
-	if rule.IsTesting() {
-		return policy.BPFActionMonitor
-	}
-	if rule.Action == policy.ActionBlock {
-		return policy.BPFActionBlock
+// SyncUnixSockets syncs dest_socket rules into the unix_sockets trie.
+func SyncUnixSockets(bpfMap *ebpf.Map, ruleList []policy.Rule) (policy.MapSyncStats, error) {
+	if bpfMap == nil {
+		return policy.MapSyncStats{}, fmt.Errorf("unix_sockets map is nil")
 	}
-	return policy.BPFActionMonitor
+	return syncMap("unix_sockets", bpfMap, DesiredUnixSockets(ruleList))
 }
 
-func mergeAction(existing, proposed uint8) uint8 {
-	if proposed > existing {
-		return proposed
+// SyncPtraceRules syncs block and allow rules keyed by exact target_name and
+// tracer process_name into ptrace_rules.
+func SyncPtraceRules(bpfMap *ebpf.Map, ruleList []policy.Rule) (policy.MapSyncStats, error) {
+	if bpfMap == nil {
+		return policy.MapSyncStats{}, fmt.Errorf("ptrace_rules map is nil")
 	}
-	return existing
+	return syncMap("ptrace_rules", bpfMap, DesiredPtraceRules(ruleList))
 }
 
-func clearMonitoredFilesMap(bpfMap *ebpf.Map) error {
-	var key [events.PathMaxLen]byte
-	var val uint8
-	iter := bpfMap.Iterate()
-	keysToDelete := make([][]byte, 0)
-	for iter.Next(&key, &val) {
-		keyCopy := make([]byte, events.PathMaxLen)
-		copy(keyCopy, key[:])
-		keysToDelete = append(keysToDelete, keyCopy)
-	}
-	for _, k := range keysToDelete {
-		_ = bpfMap.Delete(k)
+// SyncListenRules syncs listen block and allow rules keyed by exact
+// process_name and local_port into listen_rules.
+func SyncListenRules(bpfMap *ebpf.Map, ruleList []policy.Rule) (policy.MapSyncStats, error) {
+	if bpfMap == nil {
+		return policy.MapSyncStats{}, fmt.Errorf("listen_rules map is nil")
 	}
-	return nil
+	return syncMap("listen_rules", bpfMap, DesiredListenRules(ruleList))
 }
 
-func clearBlockedPortsMap(bpfMap *ebpf.Map) error {
-	var key uint16
-	var val uint8
-	iter := bpfMap.Iterate()
-	keysToDelete := make([]uint16, 0)
-	for iter.Next(&key, &val) {
-		keysToDelete = append(keysToDelete, key)
+// SyncKernelLoadRules syncs kernel_load rules into load_policy and the
+// allowlists.
+func SyncKernelLoadRules(policyMap, allowPaths, allowComms *ebpf.Map, ruleList []policy.Rule) ([]policy.MapSyncStats, error) {
+	if policyMap == nil || allowPaths == nil || allowComms == nil {
+		return nil, fmt.Errorf("kernel load maps are nil")
 	}
-	for _, k := range keysToDelete {
-		_ = bpfMap.Delete(k)
+
+	state := DesiredKernelLoad(ruleList)
+	policyStats, err := syncMap("load_policy", policyMap, state.Policies)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	pathStats, err := syncMap("load_allow_paths", allowPaths, state.Paths)
+	if err != nil {
+		return []policy.MapSyncStats{policyStats}, err
+	}
+	commStats, err := syncMap("load_allow_comms", allowComms, state.Comms)
+	if err != nil {
+		return []policy.MapSyncStats{policyStats, pathStats}, err
+	}
+	return []policy.MapSyncStats{policyStats, pathStats, commStats}, nil
 }
 
-func extractParentFilename(path string) string {
-	for len(path) > 0 && path[0] == '/' {
-		path = path[1:]
+// syncMap brings bpfMap to the desired contents without ever emptying it:
+// new and changed keys are written first, stale keys are deleted last.
+func syncMap[K, V comparable](name string, bpfMap *ebpf.Map, desired map[K]V) (policy.MapSyncStats, error) {
+	current := make(map[K]V)
+	var key K
+	var val V
+	iter := bpfMap.Iterate()
+	for iter.Next(&key, &val) {
+		current[key] = val
 	}
-	if path == "" {
-		return ""
+	if err := iter.Err(); err != nil {
+		return policy.MapSyncStats{Map: name, Total: len(desired)}, fmt.Errorf("iterate %s: %w", name, err)
 	}
 
-	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
-	if len(segments) == 0 {
-		return ""
+	diff := DiffMap(name, current, desired)
+	for k, val := range diff.Put {
+		if err := bpfMap.Put(k, val); err != nil {
+			return diff.Stats, fmt.Errorf("update %s: %w", name, err)
+		}
 	}
-
-	start := max(len(segments)-3, 0)
-	return strings.Join(segments[start:], "/")
-}
-
-func max(a, b int) int {
-	if a > b {
-		return a
+	for _, k := range diff.Delete {
+		if err := bpfMap.Delete(k); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return diff.Stats, fmt.Errorf("delete from %s: %w", name, err)
+		}
 	}
-	return b
+	return diff.Stats, nil
 }
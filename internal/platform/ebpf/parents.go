@@ -0,0 +1,22 @@
+package ebpf
+
+import "fmt"
+
+// ProcessParents reads pid_to_ppid: the parent each live process was forked
+// from, kept after that parent has exited.
+func (r *Resources) ProcessParents() (map[uint32]uint32, error) {
+	if r == nil || r.Objects == nil || r.Objects.PidToPpid == nil {
+		return nil, fmt.Errorf("pid_to_ppid map is nil")
+	}
+
+	parents := make(map[uint32]uint32)
+	var pid, ppid uint32
+	iter := r.Objects.PidToPpid.Iterate()
+	for iter.Next(&pid, &ppid) {
+		parents[pid] = ppid
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pid_to_ppid: %w", err)
+	}
+	return parents, nil
+}
@@ -0,0 +1,252 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	internalconfig "aegis/internal/platform/config"
+	"aegis/internal/platform/events"
+	"aegis/internal/system"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+)
+
+// Pins live in two directories under the pin path: maps by map name and
+// links by hook name. A link stays attached while its pin exists, so the
+// hooks keep enforcing the rules in the pinned maps while the daemon is down.
+const (
+	pinMapsDir  = "maps"
+	pinLinksDir = "links"
+)
+
+func pinDir(cfg internalconfig.KernelConfig, sub string) string {
+	root := cfg.PinPath
+	if root == "" {
+		root = internalconfig.DefaultPinPath
+	}
+	return filepath.Join(root, sub)
+}
+
+// pinMaps marks every map of the spec to be pinned by name, so loading it
+// reuses the maps a previous run pinned.
+func pinMaps(spec *ebpf.CollectionSpec, cfg internalconfig.KernelConfig) (*ebpf.CollectionOptions, error) {
+	dir := pinDir(cfg, pinMapsDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create pin directory %s (is bpffs mounted?): %w", dir, err)
+	}
+	for name, m := range spec.Maps {
+		// .rodata and the other global data sections stay private to the
+		// programs that were loaded with them.
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		m.Pinning = ebpf.PinByName
+	}
+	return &ebpf.CollectionOptions{Maps: ebpf.MapOptions{PinPath: dir}}, nil
+}
+
+// PinnedMaps lists the map pins in dir, so a failed load can tell the ones
+// it created from those it reused.
+func PinnedMaps(dir string) map[string]bool {
+	pins := make(map[string]bool)
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		pins[entry.Name()] = true
+	}
+	return pins
+}
+
+// UnpinNewMaps removes the map pins in dir that were not in before, so a
+// failed load leaves no maps behind for the next start to reuse.
+func UnpinNewMaps(dir string, before map[string]bool) {
+	for name := range PinnedMaps(dir) {
+		if before[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			log.Printf("Warning: failed to unpin map %s: %v", name, err)
+		}
+	}
+}
+
+// pinLink pins l under dir by hook name. An empty dir means pinning is off.
+func pinLink(l link.Link, dir, name string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := l.Pin(filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("pin %s link: %w", name, err)
+	}
+	return nil
+}
+
+// linkPinDir returns the directory AttachLSMHooks and AttachTracingHooks pin
+// links in, creating it, or "" when pinning is off.
+func linkPinDir(cfg internalconfig.KernelConfig) (string, error) {
+	if !cfg.Pin {
+		return "", nil
+	}
+	dir := pinDir(cfg, pinLinksDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("create pin directory %s (is bpffs mounted?): %w", dir, err)
+	}
+	return dir, nil
+}
+
+// AdoptPinned picks up the maps and links a previous run left pinned. It
+// returns nil objects when there are no pinned links to adopt. Programs are
+// not reloaded: the links keep the ones they were attached with, so a new
+// BPF object only takes effect after --unpin. Adopted LSM hooks are marked
+// attached in caps.
+func AdoptPinned(cfg internalconfig.KernelConfig, caps *system.Capabilities) (*LSMObjects, []link.Link, error) {
+	dir := pinDir(cfg, pinLinksDir)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("read pinned links: %w", err)
+	}
+
+	spec, opts, err := loadSpec(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	objs := &LSMObjects{}
+	if err := spec.LoadAndAssign(&objs.LSMMaps, opts); err != nil {
+		return nil, nil, fmt.Errorf("load pinned maps (run with --unpin after changing the BPF object): %w", err)
+	}
+
+	var links []link.Link
+	for _, entry := range entries {
+		l, err := link.LoadPinnedLink(filepath.Join(dir, entry.Name()), nil)
+		if err != nil {
+			CloseLinks(links)
+			objs.Close()
+			return nil, nil, fmt.Errorf("load pinned link %s: %w", entry.Name(), err)
+		}
+		links = append(links, l)
+		recordHook(caps, entry.Name(), nil)
+	}
+
+	log.Printf("Adopted %d pinned BPF links from %s", len(links), dir)
+	return objs, links, nil
+}
+
+// ProtectPins syncs the pin directories into protect_pins, so
+// self-protection denies removing or renaming the pins in them. Without
+// pinning or self-protection the map is emptied.
+func ProtectPins(objs *LSMObjects, cfg internalconfig.KernelConfig) error {
+	if objs.ProtectPins == nil {
+		if !cfg.Pin || !cfg.SelfProtection.Enabled {
+			return nil
+		}
+		return fmt.Errorf("protect_pins map is nil")
+	}
+
+	dirs := make(map[DirMapKey]uint8)
+	if cfg.Pin && cfg.SelfProtection.Enabled {
+		for _, path := range []string{pinDir(cfg, ""), pinDir(cfg, pinMapsDir), pinDir(cfg, pinLinksDir)} {
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("stat pin directory %s: %w", path, err)
+			}
+			stat, ok := info.Sys().(*syscall.Stat_t)
+			if !ok {
+				return fmt.Errorf("stat pin directory %s: unsupported stat type", path)
+			}
+			dirs[DirMapKey{Ino: stat.Ino, Dev: events.KernelDev(uint64(stat.Dev))}] = 1
+		}
+	}
+	_, err := syncMap("protect_pins", objs.ProtectPins, dirs)
+	return err
+}
+
+// Unpin removes every pin under the pin path. Links whose last reference was
+// their pin detach, so enforcement stops unless a daemon still holds them.
+// While the pinned aegis_config has self-protection on, the kernel only lets
+// an admin remove the pins, so Unpin refuses anyone else up front instead of
+// failing halfway.
+func Unpin(cfg internalconfig.KernelConfig) (int, error) {
+	root := pinDir(cfg, "")
+	if err := requirePinAdmin(filepath.Join(root, pinMapsDir)); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, sub := range []string{pinLinksDir, pinMapsDir} {
+		entries, err := os.ReadDir(filepath.Join(root, sub))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("read pin directory: %w", err)
+		}
+		for _, entry := range entries {
+			if err := os.Remove(filepath.Join(root, sub, entry.Name())); err != nil {
+				return removed, fmt.Errorf("remove pin: %w", err)
+			}
+			removed++
+		}
+	}
+	for _, dir := range []string{filepath.Join(root, pinLinksDir), filepath.Join(root, pinMapsDir), root} {
+		if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("remove pin directory: %w", err)
+		}
+	}
+	return removed, nil
+}
+
+// requirePinAdmin returns an error when the maps pinned in dir have
+// self-protection on and the caller is neither an admin uid nor running an
+// admin executable, the same test the kernel's is_protect_admin makes. The
+// maps are opened read-only, which self-protection allows anyone.
+func requirePinAdmin(dir string) error {
+	readOnly := &ebpf.LoadPinOptions{ReadOnly: true}
+	configMap, err := ebpf.LoadPinnedMap(filepath.Join(dir, "aegis_config"), readOnly)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open pinned aegis_config: %w", err)
+	}
+	defer configMap.Close()
+	var value aegisConfig
+	if err := configMap.Lookup(uint32(0), &value); err != nil {
+		return fmt.Errorf("read aegis_config: %w", err)
+	}
+	if value.SelfProtection == 0 {
+		return nil
+	}
+
+	uids, err := ebpf.LoadPinnedMap(filepath.Join(dir, "protect_admin_uids"), readOnly)
+	if err != nil {
+		return fmt.Errorf("open pinned protect_admin_uids: %w", err)
+	}
+	defer uids.Close()
+	var flag uint8
+	if err := uids.Lookup(uint32(os.Getuid()), &flag); err == nil {
+		return nil
+	}
+
+	exes, err := ebpf.LoadPinnedMap(filepath.Join(dir, "protect_admin_exes"), readOnly)
+	if err != nil {
+		return fmt.Errorf("open pinned protect_admin_exes: %w", err)
+	}
+	defer exes.Close()
+	if info, err := os.Stat("/proc/self/exe"); err == nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			key := DirMapKey{Ino: stat.Ino, Dev: events.KernelDev(uint64(stat.Dev))}
+			if err := exes.Lookup(key, &flag); err == nil {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("self-protection is on: only an admin uid or admin executable can remove the pins (uid %d is neither)", os.Getuid())
+}
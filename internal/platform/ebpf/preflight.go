@@ -22,7 +22,7 @@ func ensureBPFLSMEnabled() error {
 	}
 
 	return fmt.Errorf(
-		"BPF LSM is not enabled on this kernel (%s=%q). Aegis uses only lsm/* hooks for exec/file/network telemetry, so no runtime events can be captured until `bpf` is added to the active LSM list (for example `lsm=%s`)",
+		"BPF LSM is not enabled on this kernel (%s=%q). Aegis falls back to tracepoint and fentry programs that only monitor exec/file/network activity, so nothing is blocked until `bpf` is added to the active LSM list (for example `lsm=%s`)",
 		source,
 		current,
 		suggested,
@@ -61,12 +61,12 @@ func readConfiguredLSMs() ([]string, string, bool) {
 		return configured, "/proc/config.gz", true
 	}
 
-	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
-	if err != nil {
+	release := kernelRelease()
+	if release == "" {
 		return nil, "", false
 	}
 
-	path := "/boot/config-" + strings.TrimSpace(string(release))
+	path := "/boot/config-" + release
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, "", false
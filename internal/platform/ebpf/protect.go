@@ -0,0 +1,122 @@
+package ebpf
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"syscall"
+
+	internalconfig "aegis/internal/platform/config"
+	"aegis/internal/platform/events"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+)
+
+// ConfigureSelfProtection syncs the maps the kernel consults before denying
+// a signal, ptrace, link detach or map write aimed at Aegis: the admin uids,
+// the inodes of the admin executables and the IDs of Aegis's own links and
+// maps. With cfg.Enabled unset they are emptied, so maps adopted from pins
+// drop a previous run's admins. The checks themselves are switched on by
+// ConfigureAgent.
+func ConfigureSelfProtection(objs *LSMObjects, links []link.Link, cfg internalconfig.SelfProtectionConfig) error {
+	if objs.AdminUIDs == nil || objs.AdminExes == nil || objs.AegisLinks == nil || objs.AegisMaps == nil {
+		if !cfg.Enabled {
+			return nil
+		}
+		return fmt.Errorf("self-protection maps are nil")
+	}
+
+	uids := make(map[uint32]uint8)
+	exes := make(map[DirMapKey]uint8)
+	guarded := make(map[uint32]uint8)
+	mapIDs := make(map[uint32]uint8)
+	if cfg.Enabled {
+		for _, uid := range cfg.AdminUIDs {
+			uids[uid] = 1
+		}
+		for _, path := range cfg.AdminPaths {
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Printf("Self-protection: skipping admin path %s: %v", path, err)
+				continue
+			}
+			stat, ok := info.Sys().(*syscall.Stat_t)
+			if !ok {
+				log.Printf("Self-protection: skipping admin path %s: unsupported stat type", path)
+				continue
+			}
+			exes[DirMapKey{Ino: stat.Ino, Dev: events.KernelDev(uint64(stat.Dev))}] = 1
+		}
+		// Without an admin nobody could stop Aegis or remove its pins.
+		if len(uids) == 0 && len(exes) == 0 {
+			return fmt.Errorf("self-protection needs at least one admin uid or existing admin path")
+		}
+		for _, l := range links {
+			info, err := l.Info()
+			if err != nil {
+				continue
+			}
+			guarded[uint32(info.ID)] = 1
+		}
+		mapIDs = lsmMapIDs(&objs.LSMMaps)
+	}
+
+	if _, err := syncMap("protect_admin_uids", objs.AdminUIDs, uids); err != nil {
+		return err
+	}
+	if _, err := syncMap("protect_admin_exes", objs.AdminExes, exes); err != nil {
+		return err
+	}
+	if _, err := syncMap("aegis_links", objs.AegisLinks, guarded); err != nil {
+		return err
+	}
+	if _, err := syncMap("aegis_maps", objs.AegisMaps, mapIDs); err != nil {
+		return err
+	}
+
+	if cfg.Enabled {
+		log.Printf("Self-protection enabled: %d admin uids, %d admin executables, %d guarded links, %d guarded maps", len(uids), len(exes), len(guarded), len(mapIDs))
+	}
+	return nil
+}
+
+// lsmMapIDs collects the kernel IDs of the maps in maps.
+func lsmMapIDs(maps *LSMMaps) map[uint32]uint8 {
+	ids := make(map[uint32]uint8)
+	v := reflect.ValueOf(maps).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		m, _ := v.Field(i).Interface().(*ebpf.Map)
+		if m == nil {
+			continue
+		}
+		info, err := m.Info()
+		if err != nil {
+			continue
+		}
+		if id, ok := info.ID(); ok {
+			ids[uint32(id)] = 1
+		}
+	}
+	return ids
+}
+
+// ReleaseAgent clears the daemon's tgid in aegis_config, so a process
+// reusing its pid is not exempt from the hooks. The self-protection flag
+// stays on: the pins and maps of a stopped daemon are still guarded, and
+// only the admins are let through.
+func ReleaseAgent(configMap *ebpf.Map) error {
+	if configMap == nil {
+		return nil
+	}
+	var value aegisConfig
+	if err := configMap.Lookup(uint32(0), &value); err != nil {
+		return fmt.Errorf("read aegis_config: %w", err)
+	}
+	value.TGID = 0
+	if err := configMap.Put(uint32(0), value); err != nil {
+		return fmt.Errorf("update aegis_config: %w", err)
+	}
+	return nil
+}
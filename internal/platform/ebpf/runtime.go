@@ -2,9 +2,14 @@ package ebpf
 
 import (
 	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
 
 	internalconfig "aegis/internal/platform/config"
 	"aegis/internal/policy"
+	"aegis/internal/system"
 
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/ringbuf"
@@ -14,44 +19,132 @@ type Resources struct {
 	Objects *LSMObjects
 	Links   []link.Link
 	Reader  *ringbuf.Reader
+	// MonitorOnly is set when BPF LSM is not enabled and the monitor
+	// programs were attached instead of the enforcing hooks; Warning says why.
+	MonitorOnly  bool
+	Warning      string
+	Capabilities system.Capabilities
 }
 
+// Load loads and attaches the BPF programs. With cfg.Kernel.Pin set, maps and
+// links are pinned so the hooks outlive the daemon, and a later Load adopts
+// the pinned objects instead of loading new ones. Without BPF LSM it falls
+// back to monitor-only programs rather than failing.
 func Load(cfg internalconfig.Config) (*Resources, error) {
+	caps := ProbeCapabilities()
+	monitorOnly := false
+	warning := ""
 	if err := ensureBPFLSMEnabled(); err != nil {
-		return nil, err
+		log.Printf("Warning: %v", err)
+		monitorOnly = true
+		warning = err.Error()
 	}
 
-	objects, err := LoadLSMObjects(cfg.Kernel.BPFPath, cfg.Kernel.RingBufferSize)
-	if err != nil {
-		return nil, fmt.Errorf("load eBPF objects: %w", err)
+	var objects *LSMObjects
+	var links []link.Link
+	adopted := false
+	if cfg.Kernel.Pin {
+		// The kernel denies non-admins write access to self-protected pinned
+		// maps; say so rather than failing on the first map.
+		if err := requirePinAdmin(pinDir(cfg.Kernel, pinMapsDir)); err != nil {
+			return nil, fmt.Errorf("reuse pinned eBPF objects: %w", err)
+		}
+		var err error
+		objects, links, err = AdoptPinned(cfg.Kernel, &caps)
+		if err != nil {
+			return nil, fmt.Errorf("adopt pinned eBPF objects: %w", err)
+		}
+		adopted = objects != nil
 	}
-
-	links, err := AttachLSMHooks(objects)
-	if err != nil {
+	// Links and maps this Load pinned are unpinned again if it fails; adopted
+	// ones are left enforcing.
+	mapDir := pinDir(cfg.Kernel, pinMapsDir)
+	var mapPins map[string]bool
+	if !adopted && cfg.Kernel.Pin {
+		mapPins = PinnedMaps(mapDir)
+	}
+	release := func() {
+		if adopted {
+			CloseLinks(links)
+		} else {
+			discardLinks(links)
+			if cfg.Kernel.Pin {
+				UnpinNewMaps(mapDir, mapPins)
+			}
+		}
 		objects.Close()
-		return nil, fmt.Errorf("attach eBPF hooks: %w", err)
+	}
+
+	if !adopted {
+		var err error
+		objects, err = LoadLSMObjects(cfg.Kernel, monitorOnly, &caps)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("load eBPF objects: %w", err)
+		}
+		pinDir, err := linkPinDir(cfg.Kernel)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if !monitorOnly {
+			links, err = AttachLSMHooks(objects, pinDir, &caps)
+			if err != nil {
+				release()
+				return nil, fmt.Errorf("attach eBPF hooks: %w", err)
+			}
+		}
+		tracingLinks, err := AttachTracingHooks(objects, pinDir)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("attach eBPF tracepoints: %w", err)
+		}
+		links = append(links, tracingLinks...)
+	}
+
+	// The self-protection maps are filled before ConfigureAgent switches the
+	// checks on.
+	if err := ConfigureSelfProtection(objects, links, cfg.Kernel.SelfProtection); err != nil {
+		release()
+		return nil, fmt.Errorf("configure self-protection: %w", err)
+	}
+	if err := ProtectPins(objects, cfg.Kernel); err != nil {
+		release()
+		return nil, fmt.Errorf("configure self-protection: %w", err)
+	}
+	if err := ConfigureAgent(objects, cfg.Kernel); err != nil {
+		release()
+		return nil, fmt.Errorf("configure eBPF programs: %w", err)
 	}
 
 	reader, err := ringbuf.NewReader(objects.Events)
 	if err != nil {
-		CloseLinks(links)
-		objects.Close()
+		release()
 		return nil, fmt.Errorf("create ring buffer reader: %w", err)
 	}
 
 	return &Resources{
-		Objects: objects,
-		Links:   links,
-		Reader:  reader,
+		Objects:      objects,
+		Links:        links,
+		Reader:       reader,
+		MonitorOnly:  monitorOnly,
+		Warning:      warning,
+		Capabilities: caps,
 	}, nil
 }
 
+// Close releases the daemon's handles. Pinned links stay attached and keep
+// enforcing the rules, self-protection of the pins included, until Unpin
+// removes them.
 func (r *Resources) Close() error {
 	if r == nil {
 		return nil
 	}
 
 	var firstErr error
+	if r.Objects != nil {
+		firstErr = ReleaseAgent(r.Objects.AegisConfig)
+	}
 	if r.Reader != nil {
 		if err := r.Reader.Close(); err != nil && firstErr == nil {
 			firstErr = err
@@ -78,20 +171,119 @@ func NewKernelSync(resources *Resources, rulesPath string) *KernelSync {
 	}
 }
 
-func (k *KernelSync) SyncRules(ruleList []policy.Rule) error {
+func (k *KernelSync) SyncRules(ruleList []policy.Rule) (policy.SyncReport, error) {
+	report := policy.SyncReport{Timestamp: time.Now()}
 	if k == nil || k.resources == nil || k.resources.Objects == nil {
-		return nil
+		return report, nil
 	}
 
-	if monitored := k.resources.Objects.MonitoredFiles; monitored != nil {
-		if err := RepopulateMonitoredFiles(monitored, ruleList, k.rulesPath); err != nil {
-			return err
+	objs := k.resources.Objects
+	add := func(stats ...policy.MapSyncStats) {
+		report.Maps = append(report.Maps, stats...)
+	}
+
+	if objs.MonitoredFiles != nil && objs.ScopedFiles != nil {
+		stats, err := SyncMonitoredFiles(objs.MonitoredFiles, objs.ScopedFiles, ruleList, k.rulesPath)
+		add(stats...)
+		if err != nil {
+			return report, err
+		}
+	}
+	if objs.MonitoredDirs != nil {
+		stats, err := SyncMonitoredDirs(objs.MonitoredDirs, ruleList)
+		add(stats)
+		if err != nil {
+			return report, err
 		}
 	}
-	if blocked := k.resources.Objects.BlockedPorts; blocked != nil {
-		if err := RepopulateBlockedPorts(blocked, ruleList); err != nil {
-			return err
+	if objs.ExecRules != nil {
+		stats, err := SyncExecRules(objs.ExecRules, ruleList)
+		add(stats)
+		if err != nil {
+			return report, err
+		}
+	}
+	if objs.BlockedPorts != nil && objs.ScopedPorts != nil {
+		stats, err := SyncBlockedPorts(objs.BlockedPorts, objs.ScopedPorts, ruleList)
+		add(stats...)
+		if err != nil {
+			return report, err
+		}
+	}
+	if objs.BlockedV4 != nil && objs.BlockedV6 != nil {
+		stats, err := SyncBlockedAddrs(objs.BlockedV4, objs.BlockedV6, ruleList)
+		add(stats...)
+		if err != nil {
+			return report, err
+		}
+	}
+	if objs.UnixSockets != nil {
+		stats, err := SyncUnixSockets(objs.UnixSockets, ruleList)
+		add(stats)
+		if err != nil {
+			return report, err
+		}
+	}
+	if objs.LoadPolicy != nil && objs.LoadAllowPaths != nil && objs.LoadAllowComms != nil {
+		stats, err := SyncKernelLoadRules(objs.LoadPolicy, objs.LoadAllowPaths, objs.LoadAllowComms, ruleList)
+		add(stats...)
+		if err != nil {
+			return report, err
+		}
+	}
+	if objs.PtraceRules != nil {
+		stats, err := SyncPtraceRules(objs.PtraceRules, ruleList)
+		add(stats)
+		if err != nil {
+			return report, err
+		}
+	}
+	if objs.ListenRules != nil {
+		stats, err := SyncListenRules(objs.ListenRules, ruleList)
+		add(stats)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	logSyncReport(report)
+	return report, nil
+}
+
+// SyncDomainAddrs implements policy.DomainSync.
+func (k *KernelSync) SyncDomainAddrs(matched []policy.Rule, addrs []net.IP) error {
+	if k == nil || k.resources == nil || k.resources.Objects == nil {
+		return nil
+	}
+	objs := k.resources.Objects
+	if objs.DomainV4 == nil || objs.DomainV6 == nil {
+		return nil
+	}
+	return SyncDomainAddrs(objs.DomainV4, objs.DomainV6, matched, addrs)
+}
+
+// ResyncDomainAddrs implements policy.DomainSync.
+func (k *KernelSync) ResyncDomainAddrs(resolved []policy.DomainAddrs) ([]policy.MapSyncStats, error) {
+	if k == nil || k.resources == nil || k.resources.Objects == nil {
+		return nil, nil
+	}
+	objs := k.resources.Objects
+	if objs.DomainV4 == nil || objs.DomainV6 == nil {
+		return nil, nil
+	}
+	return ResyncDomainAddrs(objs.DomainV4, objs.DomainV6, resolved)
+}
+
+func logSyncReport(report policy.SyncReport) {
+	if !report.Changed() {
+		return
+	}
+	parts := make([]string, 0, len(report.Maps))
+	for _, m := range report.Maps {
+		if m.Added+m.Removed+m.Changed == 0 {
+			continue
 		}
+		parts = append(parts, fmt.Sprintf("%s +%d -%d ~%d (%d total)", m.Map, m.Added, m.Removed, m.Changed, m.Total))
 	}
-	return nil
+	log.Printf("Kernel rule sync: %s", strings.Join(parts, ", "))
 }
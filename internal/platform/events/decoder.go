@@ -10,9 +10,18 @@ import (
 
 const (
 	// Event sizes with new unified header
-	ExecEventSize     = EventHeaderSize + 4 + 4 + TaskCommLen + PathMaxLen + CommandLineLen // 56 + 4 + 4 + 16 + 256 + 512 = 848
-	FileOpenEventSize = EventHeaderSize + 8 + 8 + 4 + 4 + PathMaxLen                        // 56 + 8 + 8 + 4 + 4 + 256 = 336
-	ConnectEventSize  = EventHeaderSize + 4 + 2 + 2 + 16                                    // 56 + 4 + 2 + 2 + 16 = 80
+	ExecEventSize       = EventHeaderSize + 8 + 8 + TaskCommLen + PathMaxLen + CommandLineLen // 80 + 4 + 4 + 8 + 16 + 256 + 512 = 880
+	FileOpenEventSize   = EventHeaderSize + 8 + 8 + 4 + 4 + PathMaxLen + 8 + 8                // 80 + 8 + 8 + 4 + 4 + 256 + 8 + 8 = 376
+	ConnectEventSize    = EventHeaderSize + 4 + 2 + 2 + 16 + UnixPathLen + 4                  // 80 + 4 + 2 + 2 + 16 + 108 + 4 = 216
+	ExitEventSize       = EventHeaderSize + 4 + 4 + 4 + 1 + 3 + 8                             // 80 + 4 + 4 + 4 + 1 + 3 + 8 = 104
+	PrivilegeEventSize  = EventHeaderSize + 4 + 8*4 + 4 + 8 + 8                               // 80 + 4 + 32 + 4 + 8 + 8 = 136
+	KernelLoadEventSize = EventHeaderSize + 4 + 4 + 4 + 4 + PathMaxLen                        // 80 + 4 + 4 + 4 + 4 + 256 = 352
+	PtraceEventSize     = EventHeaderSize + 4 + 4 + TaskCommLen                               // 80 + 4 + 4 + 16 = 104
+	DNSEventSize        = EventHeaderSize + 2 + 2 + 1 + 1 + 2 + 4 + 16 + 4 + DNSPayloadLen    // 80 + 32 + 512 = 624
+	ListenEventSize     = EventHeaderSize + 8 + 2 + 2 + 1 + 1 + 2 + 4 + 16 + 4                // 80 + 8 + 8 + 4 + 16 + 4 = 120
+	NamespaceEventSize  = EventHeaderSize + 1 + 3 + 4 + 20 + 4 + FSTypeLen + 2*PathMaxLen     // 80 + 32 + 16 + 512 = 640
+	SignalEventSize     = EventHeaderSize + 4 + 4 + TaskCommLen                               // 80 + 4 + 4 + 16 = 104
+	ExecArgsHeaderSize  = EventHeaderSize + 8 + 2 + 1 + 1 + 4                                 // 80 + 16 = 96, followed by Len bytes
 )
 
 // bootTimeOnce ensures bootTime is calculated only once
@@ -44,8 +53,24 @@ func DecodeHeader(data []byte) (EventHeader, error) {
 	hdr.Type = EventType(data[offset])
 	offset += 1
 	hdr.Blocked = data[offset]
-	offset += 7 // skip padding
+	offset += 1
+	hdr.Response = data[offset]
+	offset += 1
+	hdr.SelfProtect = data[offset]
+	offset += 1
+	hdr.PathCut = data[offset]
+	offset += 4 // skip padding
 	copy(hdr.Comm[:], data[offset:offset+TaskCommLen])
+	offset += TaskCommLen
+	hdr.NS.PID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	hdr.NS.Mnt = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	hdr.NS.Net = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	hdr.NS.User = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	hdr.NS.UTS = binary.LittleEndian.Uint32(data[offset : offset+4])
 
 	return hdr, nil
 }
@@ -69,7 +94,10 @@ func DecodeExecEvent(data []byte) (ExecEvent, error) {
 
 	// Decode exec-specific fields
 	ev.PPID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	ev.ExecFlags = ExecFlags(data[offset+4])
 	offset += 8 // skip padding
+	ev.ArgsSeq = binary.LittleEndian.Uint64(data[offset : offset+8])
+	offset += 8
 	copy(ev.PComm[:], data[offset:offset+TaskCommLen])
 	offset += TaskCommLen
 	copy(ev.Filename[:], data[offset:offset+PathMaxLen])
@@ -101,11 +129,19 @@ func DecodeFileOpenEvent(data []byte) (FileOpenEvent, error) {
 	// Decode file-specific fields
 	ev.Ino = binary.LittleEndian.Uint64(data[offset : offset+8])
 	offset += 8
-	ev.Dev = binary.LittleEndian.Uint64(data[offset : offset+8])
+	ev.Dev = UserDev(binary.LittleEndian.Uint64(data[offset : offset+8]))
 	offset += 8
 	ev.Flags = binary.LittleEndian.Uint32(data[offset : offset+4])
-	offset += 8 // skip padding
+	offset += 4
+	ev.Op = FileOp(binary.LittleEndian.Uint32(data[offset : offset+4]))
+	offset += 4
 	copy(ev.Filename[:], data[offset:offset+PathMaxLen])
+	offset += PathMaxLen
+	ev.DirIno = binary.LittleEndian.Uint64(data[offset : offset+8])
+	offset += 8
+	if ev.DirIno != 0 {
+		ev.DirDev = UserDev(binary.LittleEndian.Uint64(data[offset : offset+8]))
+	}
 
 	return ev, nil
 }
@@ -135,6 +171,313 @@ func DecodeConnectEvent(data []byte) (ConnectEvent, error) {
 	ev.Port = binary.LittleEndian.Uint16(data[offset : offset+2])
 	offset += 2
 	copy(ev.AddrV6[:], data[offset:offset+16])
+	offset += 16
+	copy(ev.UnixPath[:], data[offset:offset+UnixPathLen])
+
+	return ev, nil
+}
+
+// DecodeExitEvent decodes a process exit event with the new unified header format.
+func DecodeExitEvent(data []byte) (ExitEvent, error) {
+	if len(data) < ExitEventSize {
+		return ExitEvent{}, fmt.Errorf("exit event too small: %d bytes, expected %d", len(data), ExitEventSize)
+	}
+
+	var ev ExitEvent
+	offset := 0
+
+	// Decode header
+	hdr, err := DecodeHeader(data[offset:])
+	if err != nil {
+		return ExitEvent{}, fmt.Errorf("decode header: %w", err)
+	}
+	ev.Hdr = hdr
+	offset += EventHeaderSize
+
+	// Decode exit-specific fields
+	ev.PPID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.ExitCode = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.Signal = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.CoreDumped = data[offset]
+	offset += 4 // skip padding
+	ev.RuntimeNs = binary.LittleEndian.Uint64(data[offset : offset+8])
+
+	return ev, nil
+}
+
+// DecodePrivilegeEvent decodes a credential change event with the new unified header format.
+func DecodePrivilegeEvent(data []byte) (PrivilegeEvent, error) {
+	if len(data) < PrivilegeEventSize {
+		return PrivilegeEvent{}, fmt.Errorf("privilege event too small: %d bytes, expected %d", len(data), PrivilegeEventSize)
+	}
+
+	var ev PrivilegeEvent
+	offset := 0
+
+	// Decode header
+	hdr, err := DecodeHeader(data[offset:])
+	if err != nil {
+		return PrivilegeEvent{}, fmt.Errorf("decode header: %w", err)
+	}
+	ev.Hdr = hdr
+	offset += EventHeaderSize
+
+	// Decode privilege-specific fields
+	ev.Op = PrivilegeOp(binary.LittleEndian.Uint32(data[offset : offset+4]))
+	offset += 4
+	ev.OldUID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.OldEUID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.OldGID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.OldEGID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.NewUID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.NewEUID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.NewGID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.NewEGID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	offset += 4 // skip padding
+	ev.OldCaps = binary.LittleEndian.Uint64(data[offset : offset+8])
+	offset += 8
+	ev.NewCaps = binary.LittleEndian.Uint64(data[offset : offset+8])
+
+	return ev, nil
+}
+
+// DecodeKernelLoadEvent decodes a module or BPF load event with the new unified header format.
+func DecodeKernelLoadEvent(data []byte) (KernelLoadEvent, error) {
+	if len(data) < KernelLoadEventSize {
+		return KernelLoadEvent{}, fmt.Errorf("kernel load event too small: %d bytes, expected %d", len(data), KernelLoadEventSize)
+	}
+
+	var ev KernelLoadEvent
+	offset := 0
+
+	// Decode header
+	hdr, err := DecodeHeader(data[offset:])
+	if err != nil {
+		return KernelLoadEvent{}, fmt.Errorf("decode header: %w", err)
+	}
+	ev.Hdr = hdr
+	offset += EventHeaderSize
+
+	// Decode load-specific fields
+	ev.Kind = LoadKind(binary.LittleEndian.Uint32(data[offset : offset+4]))
+	offset += 4
+	ev.BPFCmd = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.ProgType = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 8 // skip padding
+	copy(ev.Name[:], data[offset:offset+PathMaxLen])
+
+	return ev, nil
+}
+
+// DecodePtraceEvent decodes a ptrace access event with the new unified header format.
+func DecodePtraceEvent(data []byte) (PtraceEvent, error) {
+	if len(data) < PtraceEventSize {
+		return PtraceEvent{}, fmt.Errorf("ptrace event too small: %d bytes, expected %d", len(data), PtraceEventSize)
+	}
+
+	var ev PtraceEvent
+	offset := 0
+
+	// Decode header
+	hdr, err := DecodeHeader(data[offset:])
+	if err != nil {
+		return PtraceEvent{}, fmt.Errorf("decode header: %w", err)
+	}
+	ev.Hdr = hdr
+	offset += EventHeaderSize
+
+	// Decode ptrace-specific fields
+	ev.TargetPID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.Mode = PtraceMode(binary.LittleEndian.Uint32(data[offset : offset+4]))
+	offset += 4
+	copy(ev.TargetComm[:], data[offset:offset+TaskCommLen])
+
+	return ev, nil
+}
+
+// DecodeDNSEvent decodes a DNS message event with the new unified header format.
+func DecodeDNSEvent(data []byte) (DNSEvent, error) {
+	if len(data) < DNSEventSize {
+		return DNSEvent{}, fmt.Errorf("dns event too small: %d bytes, expected %d", len(data), DNSEventSize)
+	}
+
+	var ev DNSEvent
+	offset := 0
+
+	// Decode header
+	hdr, err := DecodeHeader(data[offset:])
+	if err != nil {
+		return DNSEvent{}, fmt.Errorf("decode header: %w", err)
+	}
+	ev.Hdr = hdr
+	offset += EventHeaderSize
+
+	// Decode dns-specific fields
+	ev.Family = binary.LittleEndian.Uint16(data[offset : offset+2])
+	offset += 2
+	ev.Port = binary.LittleEndian.Uint16(data[offset : offset+2])
+	offset += 2
+	ev.Transport = DNSTransport(data[offset])
+	ev.Response = data[offset+1]
+	offset += 4 // skip padding
+	ev.AddrV4 = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	copy(ev.AddrV6[:], data[offset:offset+16])
+	offset += 16
+	ev.Len = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	copy(ev.Payload[:], data[offset:offset+DNSPayloadLen])
+
+	return ev, nil
+}
+
+// DecodeListenEvent decodes a bind/listen event with the new unified header format.
+func DecodeListenEvent(data []byte) (ListenEvent, error) {
+	if len(data) < ListenEventSize {
+		return ListenEvent{}, fmt.Errorf("listen event too small: %d bytes, expected %d", len(data), ListenEventSize)
+	}
+
+	var ev ListenEvent
+	offset := 0
+
+	// Decode header
+	hdr, err := DecodeHeader(data[offset:])
+	if err != nil {
+		return ListenEvent{}, fmt.Errorf("decode header: %w", err)
+	}
+	ev.Hdr = hdr
+	offset += EventHeaderSize
+
+	// Decode listen-specific fields
+	ev.Cookie = binary.LittleEndian.Uint64(data[offset : offset+8])
+	offset += 8
+	ev.Family = binary.LittleEndian.Uint16(data[offset : offset+2])
+	offset += 2
+	ev.Port = binary.LittleEndian.Uint16(data[offset : offset+2])
+	offset += 2
+	ev.Protocol = data[offset]
+	ev.Op = ListenOp(data[offset+1])
+	offset += 4 // skip padding
+	ev.AddrV4 = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	copy(ev.AddrV6[:], data[offset:offset+16])
+	offset += 16
+	ev.Backlog = binary.LittleEndian.Uint32(data[offset : offset+4])
+
+	return ev, nil
+}
+
+// DecodeNamespaceEvent decodes a setns/unshare/mount event with the new unified header format.
+func DecodeNamespaceEvent(data []byte) (NamespaceEvent, error) {
+	if len(data) < NamespaceEventSize {
+		return NamespaceEvent{}, fmt.Errorf("namespace event too small: %d bytes, expected %d", len(data), NamespaceEventSize)
+	}
+
+	var ev NamespaceEvent
+	offset := 0
+
+	// Decode header
+	hdr, err := DecodeHeader(data[offset:])
+	if err != nil {
+		return NamespaceEvent{}, fmt.Errorf("decode header: %w", err)
+	}
+	ev.Hdr = hdr
+	offset += EventHeaderSize
+
+	// Decode namespace-specific fields
+	ev.Op = NamespaceOp(data[offset])
+	offset += 4 // skip padding
+	ev.Flags = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.NewNS.PID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.NewNS.Mnt = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.NewNS.Net = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.NewNS.User = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.NewNS.UTS = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 8 // skip padding
+	copy(ev.FSType[:], data[offset:offset+FSTypeLen])
+	offset += FSTypeLen
+	copy(ev.Source[:], data[offset:offset+PathMaxLen])
+	offset += PathMaxLen
+	copy(ev.Target[:], data[offset:offset+PathMaxLen])
+
+	return ev, nil
+}
+
+// DecodeExecArgsEvent decodes one argv/envp chunk with the new unified header format.
+func DecodeExecArgsEvent(data []byte) (ExecArgsEvent, error) {
+	if len(data) < ExecArgsHeaderSize {
+		return ExecArgsEvent{}, fmt.Errorf("exec args event too small: %d bytes, expected %d", len(data), ExecArgsHeaderSize)
+	}
+
+	var ev ExecArgsEvent
+	offset := 0
+
+	// Decode header
+	hdr, err := DecodeHeader(data[offset:])
+	if err != nil {
+		return ExecArgsEvent{}, fmt.Errorf("decode header: %w", err)
+	}
+	ev.Hdr = hdr
+	offset += EventHeaderSize
+
+	// Decode chunk fields
+	ev.Seq = binary.LittleEndian.Uint64(data[offset : offset+8])
+	offset += 8
+	ev.Chunk = binary.LittleEndian.Uint16(data[offset : offset+2])
+	offset += 2
+	ev.Flags = ExecArgsFlags(data[offset])
+	offset += 2 // skip padding
+	ev.Len = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	if int(ev.Len) > len(data)-offset {
+		return ExecArgsEvent{}, fmt.Errorf("exec args event truncated: %d data bytes, expected %d", len(data)-offset, ev.Len)
+	}
+	ev.Data = append([]byte(nil), data[offset:offset+int(ev.Len)]...)
+
+	return ev, nil
+}
+
+// DecodeSignalEvent decodes a task_kill event with the new unified header format.
+func DecodeSignalEvent(data []byte) (SignalEvent, error) {
+	if len(data) < SignalEventSize {
+		return SignalEvent{}, fmt.Errorf("signal event too small: %d bytes, expected %d", len(data), SignalEventSize)
+	}
+
+	var ev SignalEvent
+	offset := 0
+
+	// Decode header
+	hdr, err := DecodeHeader(data[offset:])
+	if err != nil {
+		return SignalEvent{}, fmt.Errorf("decode header: %w", err)
+	}
+	ev.Hdr = hdr
+	offset += EventHeaderSize
+
+	// Decode signal-specific fields
+	ev.TargetPID = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	ev.Signal = binary.LittleEndian.Uint32(data[offset : offset+4])
+	offset += 4
+	copy(ev.TargetComm[:], data[offset:offset+TaskCommLen])
 
 	return ev, nil
 }
@@ -201,12 +544,118 @@ func (e *ConnectEvent) GetBlocked() uint8 {
 	return e.Hdr.Blocked
 }
 
+func (e *ExitEvent) GetPID() uint32 {
+	return e.Hdr.PID
+}
+
+func (e *ExitEvent) GetCgroupID() uint64 {
+	return e.Hdr.CgroupID
+}
+
+// Runtime returns how long the process ran before exiting.
+func (e *ExitEvent) Runtime() time.Duration {
+	return time.Duration(e.RuntimeNs)
+}
+
+func (e *PrivilegeEvent) GetPID() uint32 {
+	return e.Hdr.PID
+}
+
+func (e *PrivilegeEvent) GetCgroupID() uint64 {
+	return e.Hdr.CgroupID
+}
+
+func (e *KernelLoadEvent) GetPID() uint32 {
+	return e.Hdr.PID
+}
+
+func (e *KernelLoadEvent) GetCgroupID() uint64 {
+	return e.Hdr.CgroupID
+}
+
+func (e *KernelLoadEvent) GetBlocked() uint8 {
+	return e.Hdr.Blocked
+}
+
+func (e *PtraceEvent) GetPID() uint32 {
+	return e.Hdr.PID
+}
+
+func (e *PtraceEvent) GetCgroupID() uint64 {
+	return e.Hdr.CgroupID
+}
+
+func (e *PtraceEvent) GetBlocked() uint8 {
+	return e.Hdr.Blocked
+}
+
+func (e *DNSEvent) GetPID() uint32 {
+	return e.Hdr.PID
+}
+
+func (e *DNSEvent) GetCgroupID() uint64 {
+	return e.Hdr.CgroupID
+}
+
+func (e *DNSEvent) GetBlocked() uint8 {
+	return e.Hdr.Blocked
+}
+
+func (e *ListenEvent) GetPID() uint32 {
+	return e.Hdr.PID
+}
+
+func (e *ListenEvent) GetCgroupID() uint64 {
+	return e.Hdr.CgroupID
+}
+
+func (e *ListenEvent) GetBlocked() uint8 {
+	return e.Hdr.Blocked
+}
+
+func (e *NamespaceEvent) GetPID() uint32 {
+	return e.Hdr.PID
+}
+
+func (e *NamespaceEvent) GetCgroupID() uint64 {
+	return e.Hdr.CgroupID
+}
+
+func (e *ExecArgsEvent) GetPID() uint32 {
+	return e.Hdr.PID
+}
+
+func (e *ExecArgsEvent) GetCgroupID() uint64 {
+	return e.Hdr.CgroupID
+}
+
+func (e *SignalEvent) GetPID() uint32 {
+	return e.Hdr.PID
+}
+
+func (e *SignalEvent) GetCgroupID() uint64 {
+	return e.Hdr.CgroupID
+}
+
+func (e *SignalEvent) GetBlocked() uint8 {
+	return e.Hdr.Blocked
+}
+
 type DecodedRecord struct {
-	Type      EventType
-	Timestamp time.Time
-	Exec      *ExecEvent
-	FileOpen  *FileOpenEvent
-	Connect   *ConnectEvent
+	Type       EventType
+	Timestamp  time.Time
+	Exec       *ExecEvent
+	FileOpen   *FileOpenEvent
+	Connect    *ConnectEvent
+	Exit       *ExitEvent
+	Privilege  *PrivilegeEvent
+	KernelLoad *KernelLoadEvent
+	Ptrace     *PtraceEvent
+	DNS        *DNSEvent
+	Listen     *ListenEvent
+	Namespace  *NamespaceEvent
+	Signal     *SignalEvent
+	ExecArgs   *ExecArgsEvent
 }
 
 func DecodeSample(data []byte) (*DecodedRecord, error) {
@@ -248,7 +697,122 @@ func DecodeSample(data []byte) (*DecodedRecord, error) {
 			Timestamp: ts,
 			Connect:   &ev,
 		}, nil
+	case EventTypeExit:
+		ev, err := DecodeExitEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		ts := ev.Hdr.Timestamp()
+		return &DecodedRecord{
+			Type:      EventTypeExit,
+			Timestamp: ts,
+			Exit:      &ev,
+		}, nil
+	case EventTypePrivilege:
+		ev, err := DecodePrivilegeEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		ts := ev.Hdr.Timestamp()
+		return &DecodedRecord{
+			Type:      EventTypePrivilege,
+			Timestamp: ts,
+			Privilege: &ev,
+		}, nil
+	case EventTypeKernelLoad:
+		ev, err := DecodeKernelLoadEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		ts := ev.Hdr.Timestamp()
+		return &DecodedRecord{
+			Type:       EventTypeKernelLoad,
+			Timestamp:  ts,
+			KernelLoad: &ev,
+		}, nil
+	case EventTypePtrace:
+		ev, err := DecodePtraceEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		ts := ev.Hdr.Timestamp()
+		return &DecodedRecord{
+			Type:      EventTypePtrace,
+			Timestamp: ts,
+			Ptrace:    &ev,
+		}, nil
+	case EventTypeDNS:
+		ev, err := DecodeDNSEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		ts := ev.Hdr.Timestamp()
+		return &DecodedRecord{
+			Type:      EventTypeDNS,
+			Timestamp: ts,
+			DNS:       &ev,
+		}, nil
+	case EventTypeListen:
+		ev, err := DecodeListenEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		ts := ev.Hdr.Timestamp()
+		return &DecodedRecord{
+			Type:      EventTypeListen,
+			Timestamp: ts,
+			Listen:    &ev,
+		}, nil
+	case EventTypeNamespace:
+		ev, err := DecodeNamespaceEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		ts := ev.Hdr.Timestamp()
+		return &DecodedRecord{
+			Type:      EventTypeNamespace,
+			Timestamp: ts,
+			Namespace: &ev,
+		}, nil
+	case EventTypeSignal:
+		ev, err := DecodeSignalEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		ts := ev.Hdr.Timestamp()
+		return &DecodedRecord{
+			Type:      EventTypeSignal,
+			Timestamp: ts,
+			Signal:    &ev,
+		}, nil
+	case EventTypeExecArgs:
+		ev, err := DecodeExecArgsEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		ts := ev.Hdr.Timestamp()
+		return &DecodedRecord{
+			Type:      EventTypeExecArgs,
+			Timestamp: ts,
+			ExecArgs:  &ev,
+		}, nil
 	default:
 		return nil, fmt.Errorf("unknown event type")
 	}
 }
+
+// UserDev converts a kernel-internal dev_t (MAJOR<<20 | MINOR, as stored in
+// super_block.s_dev) into the userspace st_dev encoding returned by stat(2).
+func UserDev(kdev uint64) uint64 {
+	major := kdev >> 20
+	minor := kdev & 0xfffff
+	return (minor & 0xff) | (major&0xfff)<<8 | (minor&^0xff)<<12 | (major&^0xfff)<<32
+}
+
+// KernelDev is the inverse of UserDev and is used when building BPF map keys
+// from stat(2) results.
+func KernelDev(dev uint64) uint64 {
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	return major<<20 | minor
+}
@@ -0,0 +1,173 @@
+package events
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	dnsHeaderLen   = 12
+	dnsMaxNameLen  = 255
+	dnsMaxPointers = 16
+
+	DNSTypeA     uint16 = 1
+	DNSTypeCNAME uint16 = 5
+	DNSTypePTR   uint16 = 12
+	DNSTypeMX    uint16 = 15
+	DNSTypeTXT   uint16 = 16
+	DNSTypeAAAA  uint16 = 28
+	DNSTypeSRV   uint16 = 33
+	DNSTypeHTTPS uint16 = 65
+	DNSTypeANY   uint16 = 255
+)
+
+var errDNSTruncated = errors.New("dns message truncated")
+
+// DNSMessage is the first question of a DNS message and, for replies, the
+// A and AAAA records answering it.
+type DNSMessage struct {
+	ID       uint16
+	Response bool
+	Name     string // lower-cased, without the trailing dot
+	QType    uint16
+	Answers  []DNSAnswer
+}
+
+// DNSAnswer is one address record. Name is the owner name, which differs
+// from the question when the answer follows a CNAME chain.
+type DNSAnswer struct {
+	Name string
+	IP   net.IP
+	TTL  uint32
+}
+
+var dnsTypeNames = map[uint16]string{
+	DNSTypeA:     "A",
+	DNSTypeCNAME: "CNAME",
+	DNSTypePTR:   "PTR",
+	DNSTypeMX:    "MX",
+	DNSTypeTXT:   "TXT",
+	DNSTypeAAAA:  "AAAA",
+	DNSTypeSRV:   "SRV",
+	DNSTypeHTTPS: "HTTPS",
+	DNSTypeANY:   "ANY",
+}
+
+// QueryType names the question type, e.g. "A" or "TYPE64".
+func (m DNSMessage) QueryType() string {
+	if name, ok := dnsTypeNames[m.QType]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", m.QType)
+}
+
+// ParseDNSMessage parses the question and address answers of a wire-format
+// DNS message. A reply cut short by the capture limit still returns the
+// answers read before the cut.
+func ParseDNSMessage(msg []byte) (DNSMessage, error) {
+	if len(msg) < dnsHeaderLen {
+		return DNSMessage{}, errDNSTruncated
+	}
+
+	m := DNSMessage{
+		ID:       binary.BigEndian.Uint16(msg[0:2]),
+		Response: msg[2]&0x80 != 0,
+	}
+	qdcount := binary.BigEndian.Uint16(msg[4:6])
+	ancount := binary.BigEndian.Uint16(msg[6:8])
+	if qdcount == 0 {
+		return DNSMessage{}, fmt.Errorf("dns message has no question")
+	}
+
+	name, offset, err := readDNSName(msg, dnsHeaderLen)
+	if err != nil {
+		return DNSMessage{}, err
+	}
+	if offset+4 > len(msg) {
+		return DNSMessage{}, errDNSTruncated
+	}
+	m.Name = name
+	m.QType = binary.BigEndian.Uint16(msg[offset : offset+2])
+	offset += 4
+
+	// Skip any further questions; resolvers send exactly one.
+	for i := uint16(1); i < qdcount; i++ {
+		if _, offset, err = readDNSName(msg, offset); err != nil || offset+4 > len(msg) {
+			return m, nil
+		}
+		offset += 4
+	}
+
+	for i := uint16(0); i < ancount; i++ {
+		owner, next, err := readDNSName(msg, offset)
+		if err != nil || next+10 > len(msg) {
+			break
+		}
+		rrType := binary.BigEndian.Uint16(msg[next : next+2])
+		ttl := binary.BigEndian.Uint32(msg[next+4 : next+8])
+		rdlen := int(binary.BigEndian.Uint16(msg[next+8 : next+10]))
+		rdata := next + 10
+		if rdata+rdlen > len(msg) {
+			break
+		}
+		switch {
+		case rrType == DNSTypeA && rdlen == net.IPv4len:
+			m.Answers = append(m.Answers, DNSAnswer{Name: owner, IP: net.IP(append([]byte(nil), msg[rdata:rdata+rdlen]...)), TTL: ttl})
+		case rrType == DNSTypeAAAA && rdlen == net.IPv6len:
+			m.Answers = append(m.Answers, DNSAnswer{Name: owner, IP: net.IP(append([]byte(nil), msg[rdata:rdata+rdlen]...)), TTL: ttl})
+		}
+		offset = rdata + rdlen
+	}
+
+	return m, nil
+}
+
+// readDNSName reads a possibly compressed name at offset and returns it with
+// the offset just past it in the original message.
+func readDNSName(msg []byte, offset int) (string, int, error) {
+	var b strings.Builder
+	end := -1
+	pointers := 0
+
+	for {
+		if offset >= len(msg) {
+			return "", 0, errDNSTruncated
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.ToLower(b.String()), end, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(msg) {
+				return "", 0, errDNSTruncated
+			}
+			if pointers++; pointers > dnsMaxPointers {
+				return "", 0, fmt.Errorf("dns name has too many compression pointers")
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:offset+2]) & 0x3fff)
+		case length&0xc0 != 0:
+			return "", 0, fmt.Errorf("dns name uses unsupported label type %#x", length&0xc0)
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, errDNSTruncated
+			}
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.Write(msg[offset+1 : offset+1+length])
+			if b.Len() > dnsMaxNameLen {
+				return "", 0, fmt.Errorf("dns name longer than %d bytes", dnsMaxNameLen)
+			}
+			offset += 1 + length
+		}
+	}
+}
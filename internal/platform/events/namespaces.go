@@ -0,0 +1,66 @@
+package events
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	hostNSOnce sync.Once
+	hostNS     Namespaces
+)
+
+// HostNamespaces returns the namespaces of PID 1, read once from /proc. When
+// PID 1 cannot be inspected (hidepid, missing CAP_SYS_PTRACE) the agent's own
+// namespaces stand in, as it is expected to run on the host.
+func HostNamespaces() Namespaces {
+	hostNSOnce.Do(func() {
+		hostNS = Namespaces{
+			PID:  readNamespace("pid"),
+			Mnt:  readNamespace("mnt"),
+			Net:  readNamespace("net"),
+			User: readNamespace("user"),
+			UTS:  readNamespace("uts"),
+		}
+	})
+	return hostNS
+}
+
+// readNamespace parses the inode out of a link such as "net:[4026531840]".
+func readNamespace(kind string) uint32 {
+	link, err := os.Readlink("/proc/1/ns/" + kind)
+	if err != nil {
+		if link, err = os.Readlink("/proc/self/ns/" + kind); err != nil {
+			return 0
+		}
+	}
+	start := strings.LastIndexByte(link, '[')
+	if start < 0 || !strings.HasSuffix(link, "]") {
+		return 0
+	}
+	inum, err := strconv.ParseUint(link[start+1:len(link)-1], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(inum)
+}
+
+// Known reports whether the kernel filled in the namespaces.
+func (n Namespaces) Known() bool {
+	return n != Namespaces{}
+}
+
+// IsHost reports whether every namespace matches PID 1's. Namespaces the
+// kernel or /proc left zero are not compared, and unknown namespaces are
+// never on the host.
+func (n Namespaces) IsHost() bool {
+	if !n.Known() {
+		return false
+	}
+	host := HostNamespaces()
+	same := func(a, b uint32) bool { return a == 0 || b == 0 || a == b }
+	return same(n.PID, host.PID) && same(n.Mnt, host.Mnt) && same(n.Net, host.Net) &&
+		same(n.User, host.User) && same(n.UTS, host.UTS)
+}
@@ -1,23 +1,59 @@
 package events
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type EventType uint8
 
 const (
-	EventTypeExec     EventType = 1
-	EventTypeFileOpen EventType = 2
-	EventTypeConnect  EventType = 3
+	EventTypeExec       EventType = 1
+	EventTypeFileOpen   EventType = 2
+	EventTypeConnect    EventType = 3
+	EventTypeExit       EventType = 4
+	EventTypePrivilege  EventType = 5
+	EventTypeKernelLoad EventType = 6
+	EventTypePtrace     EventType = 7
+	EventTypeDNS        EventType = 8
+	EventTypeListen     EventType = 9
+	EventTypeNamespace  EventType = 10
+	EventTypeSignal     EventType = 11
+	EventTypeExecArgs   EventType = 12
 
 	// Buffer sizes (must match BPF definitions)
 	TaskCommLen    = 16
 	PathMaxLen     = 256
-	CommandLineLen = 512 // Full command line (executable + all args)
+	CommandLineLen = 512 // Fallback command line when no argument records arrived
+	ArgsChunkLen   = 1024
+	DNSPayloadLen  = 512
+	UnixPathLen    = 108 // sizeof(sockaddr_un.sun_path)
+	FSTypeLen      = 16
+
+	// AFUnix is the address family of a connect to a UNIX domain socket.
+	AFUnix = 1
+
+	// EventHeaderSize is the size of the unified event header (80 bytes)
+	EventHeaderSize = 80
 
-	// EventHeaderSize is the size of the unified event header (56 bytes)
-	EventHeaderSize = 56
+	// EventTypeSlots is the number of entries in the ringbuf_drops map.
+	EventTypeSlots = 16
 )
 
+var eventTypeNames = []string{
+	"", "exec", "file", "connect", "exit", "privilege", "kernel_load", "ptrace",
+	"dns", "listen", "namespace", "signal", "exec_args",
+}
+
+// String returns the name telemetry uses for the event type, e.g. "file".
+func (t EventType) String() string {
+	if t > 0 && int(t) < len(eventTypeNames) {
+		return eventTypeNames[t]
+	}
+	return fmt.Sprintf("type_%d", t)
+}
+
 type EventHeader struct {
 	TimestampNs uint64
 	CgroupID    uint64
@@ -27,26 +63,165 @@ type EventHeader struct {
 	GID         uint32
 	Type        EventType
 	Blocked     uint8
-	_           [6]byte // padding
+	Response    uint8   // BPF action applied when Blocked, e.g. kill
+	SelfProtect uint8   // 1 when Blocked to protect the Aegis agent itself
+	PathCut     uint8   // 1 when the event's path is only the tail of the real one
+	_           [3]byte // padding
 	Comm        [TaskCommLen]byte
+	NS          Namespaces
+	_           [4]byte // padding
+}
+
+// Kernel responses in EventHeader.Response, matching the ACTION_* values in
+// main.bpf.c. Kill responses have already SIGKILLed the process.
+const (
+	ResponseBlock    uint8 = 2
+	ResponseKill     uint8 = 3
+	ResponseKillTree uint8 = 4
+)
+
+// ResponseName names what the kernel did about the event: "block", "kill",
+// "kill_tree", or "" when it was let through.
+func (h *EventHeader) ResponseName() string {
+	switch {
+	case h.Blocked != 1:
+		return ""
+	case h.Response == ResponseKill:
+		return "kill"
+	case h.Response == ResponseKillTree:
+		return "kill_tree"
+	}
+	return "block"
+}
+
+// Namespaces holds the inode numbers of a task's namespaces, as shown by
+// readlink /proc/<pid>/ns/*. Zero means the kernel could not read them.
+type Namespaces struct {
+	PID  uint32
+	Mnt  uint32
+	Net  uint32
+	User uint32
+	UTS  uint32
 }
 
 type ExecEvent struct {
 	Hdr         EventHeader
 	PPID        uint32
-	_           [4]byte // padding
+	ExecFlags   ExecFlags
+	_           [3]byte // padding
+	ArgsSeq     uint64  // Seq of the ExecArgsEvents sent ahead of this event, 0 if none
 	PComm       [TaskCommLen]byte
 	Filename    [PathMaxLen]byte
 	CommandLine [CommandLineLen]byte
+
+	// Args is the full argv reassembled from the event's ExecArgsEvents; it
+	// is not part of the kernel record.
+	Args []string
 }
 
+// ExecArgsFlags describe one ExecArgsEvent.
+type ExecArgsFlags uint8
+
+const (
+	// ExecArgsEnv marks a chunk of envp rather than argv.
+	ExecArgsEnv ExecArgsFlags = 0x01
+	// ExecArgsTruncated marks a vector the kernel stopped reading early.
+	ExecArgsTruncated ExecArgsFlags = 0x02
+	// ExecArgsLast marks the final chunk of argv or envp.
+	ExecArgsLast ExecArgsFlags = 0x04
+)
+
+// ExecArgsEvent carries part of an exec's argv or envp as NUL-separated
+// strings. The kernel sends them ahead of the ExecEvent with the same seq,
+// numbered by Chunk; their Data concatenated in order is the whole vector.
+// Records are variable length: only Len bytes of data follow the fixed part.
+type ExecArgsEvent struct {
+	Hdr   EventHeader
+	Seq   uint64
+	Chunk uint16
+	Flags ExecArgsFlags
+	_     uint8 // padding
+	Len   uint32
+	Data  []byte
+}
+
+// SplitArgs splits reassembled ExecArgsEvent data into its strings. A
+// string cut off by truncation is kept.
+func SplitArgs(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	args := strings.Split(string(data), "\x00")
+	if args[len(args)-1] == "" {
+		args = args[:len(args)-1]
+	}
+	return args
+}
+
+// ExecFlags describes where an executed binary came from.
+type ExecFlags uint8
+
+const (
+	// ExecFlagFileless marks a binary run from a memfd_create descriptor.
+	ExecFlagFileless ExecFlags = 0x01
+	// ExecFlagDeleted marks a binary whose last link was removed.
+	ExecFlagDeleted ExecFlags = 0x02
+)
+
+func (f ExecFlags) Fileless() bool { return f&ExecFlagFileless != 0 }
+
+func (f ExecFlags) Deleted() bool { return f&ExecFlagDeleted != 0 }
+
+// FileOp is the operation a file event reports.
+type FileOp uint32
+
+const (
+	FileOpRead   FileOp = 1
+	FileOpWrite  FileOp = 2
+	FileOpDelete FileOp = 3
+	FileOpRename FileOp = 4
+	FileOpChmod  FileOp = 5 // mode or ownership change
+)
+
+var fileOpNames = map[FileOp]string{
+	FileOpRead:   "read",
+	FileOpWrite:  "write",
+	FileOpDelete: "delete",
+	FileOpRename: "rename",
+	FileOpChmod:  "chmod",
+}
+
+// FileOps lists every operation the kernel reports.
+var FileOps = []FileOp{FileOpRead, FileOpWrite, FileOpDelete, FileOpRename, FileOpChmod}
+
+func (op FileOp) String() string {
+	if name, ok := fileOpNames[op]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseFileOp returns the FileOp named by s.
+func ParseFileOp(s string) (FileOp, bool) {
+	for op, name := range fileOpNames {
+		if name == s {
+			return op, true
+		}
+	}
+	return 0, false
+}
+
+// FileOpenEvent is named for its original hook but reports every file
+// operation; Op says which one.
 type FileOpenEvent struct {
 	Hdr      EventHeader
 	Ino      uint64
 	Dev      uint64
 	Flags    uint32
-	_        [4]byte // padding
+	Op       FileOp
 	Filename [PathMaxLen]byte
+	DirIno   uint64 // monitored directory the kernel matched, if any
+	DirDev   uint64
 }
 
 type ConnectEvent struct {
@@ -55,6 +230,413 @@ type ConnectEvent struct {
 	Family uint16
 	Port   uint16
 	AddrV6 [16]byte
+	// UnixPath is the raw sun_path of an AF_UNIX connect. Abstract names
+	// start with a NUL byte.
+	UnixPath [UnixPathLen]byte
+	_        [4]byte
+}
+
+// ExitEvent is emitted when a thread group leader exits. ExitCode and Signal
+// are already split out of the kernel wait status.
+type ExitEvent struct {
+	Hdr        EventHeader
+	PPID       uint32
+	ExitCode   uint32
+	Signal     uint32
+	CoreDumped uint8
+	_          [3]byte // padding
+	RuntimeNs  uint64  // time since the task started
+}
+
+// PrivilegeOp identifies the credential hook that produced a PrivilegeEvent.
+type PrivilegeOp uint32
+
+const (
+	PrivilegeOpSetuid PrivilegeOp = 1
+	PrivilegeOpSetgid PrivilegeOp = 2
+	PrivilegeOpCapset PrivilegeOp = 3
+)
+
+func (op PrivilegeOp) String() string {
+	switch op {
+	case PrivilegeOpSetuid:
+		return "setuid"
+	case PrivilegeOpSetgid:
+		return "setgid"
+	case PrivilegeOpCapset:
+		return "capset"
+	default:
+		return "unknown"
+	}
+}
+
+// PrivilegeEvent carries the credentials before and after a setuid, setgid
+// or capset transition. Caps are the effective capability sets.
+type PrivilegeEvent struct {
+	Hdr     EventHeader
+	Op      PrivilegeOp
+	OldUID  uint32
+	OldEUID uint32
+	OldGID  uint32
+	OldEGID uint32
+	NewUID  uint32
+	NewEUID uint32
+	NewGID  uint32
+	NewEGID uint32
+	_       [4]byte // padding
+	OldCaps uint64
+	NewCaps uint64
+}
+
+// LoadKind says whether a KernelLoadEvent is a module or a BPF program.
+type LoadKind uint32
+
+const (
+	LoadKindModule LoadKind = 1
+	LoadKindBPF    LoadKind = 2
+)
+
+func (k LoadKind) String() string {
+	switch k {
+	case LoadKindModule:
+		return "module"
+	case LoadKindBPF:
+		return "bpf"
+	default:
+		return "unknown"
+	}
+}
+
+// KernelLoadEvent reports a kernel module load or a BPF_PROG_LOAD. Name is
+// the module path (empty for init_module) or the BPF program name.
+type KernelLoadEvent struct {
+	Hdr      EventHeader
+	Kind     LoadKind
+	BPFCmd   uint32
+	ProgType uint32
+	_        [4]byte // padding
+	Name     [PathMaxLen]byte
+}
+
+// BPF commands reported in KernelLoadEvent.BPFCmd, from enum bpf_cmd.
+// Self-protection reports the link and map commands it denied.
+const (
+	BPFCmdMapUpdateElem          uint32 = 2
+	BPFCmdMapDeleteElem          uint32 = 3
+	BPFCmdProgLoad               uint32 = 5
+	BPFCmdObjGet                 uint32 = 7
+	BPFCmdMapGetFDByID           uint32 = 14
+	BPFCmdMapLookupAndDeleteElem uint32 = 21
+	BPFCmdMapFreeze              uint32 = 22
+	BPFCmdMapLookupAndDelBatch   uint32 = 25
+	BPFCmdMapUpdateBatch         uint32 = 26
+	BPFCmdMapDeleteBatch         uint32 = 27
+	BPFCmdLinkUpdate             uint32 = 29
+	BPFCmdLinkDetach             uint32 = 34
+)
+
+// BPFCmdName names the bpf(2) command of a BPF kernel load event.
+func BPFCmdName(cmd uint32) string {
+	switch cmd {
+	case BPFCmdMapUpdateElem:
+		return "map_update_elem"
+	case BPFCmdMapDeleteElem:
+		return "map_delete_elem"
+	case BPFCmdProgLoad:
+		return "prog_load"
+	case BPFCmdObjGet:
+		return "obj_get"
+	case BPFCmdMapGetFDByID:
+		return "map_get_fd_by_id"
+	case BPFCmdMapLookupAndDeleteElem:
+		return "map_lookup_and_delete_elem"
+	case BPFCmdMapFreeze:
+		return "map_freeze"
+	case BPFCmdMapLookupAndDelBatch:
+		return "map_lookup_and_delete_batch"
+	case BPFCmdMapUpdateBatch:
+		return "map_update_batch"
+	case BPFCmdMapDeleteBatch:
+		return "map_delete_batch"
+	case BPFCmdLinkUpdate:
+		return "link_update"
+	case BPFCmdLinkDetach:
+		return "link_detach"
+	default:
+		return fmt.Sprintf("cmd_%d", cmd)
+	}
+}
+
+// bpfProgTypeNames follows enum bpf_prog_type.
+var bpfProgTypeNames = []string{
+	"unspec", "socket_filter", "kprobe", "sched_cls", "sched_act", "tracepoint",
+	"xdp", "perf_event", "cgroup_skb", "cgroup_sock", "lwt_in", "lwt_out",
+	"lwt_xmit", "sock_ops", "sk_skb", "cgroup_device", "sk_msg", "raw_tracepoint",
+	"cgroup_sock_addr", "lwt_seg6local", "lirc_mode2", "sk_reuseport",
+	"flow_dissector", "cgroup_sysctl", "raw_tracepoint_writable", "cgroup_sockopt",
+	"tracing", "struct_ops", "ext", "lsm", "sk_lookup", "syscall", "netfilter",
+}
+
+// BPFProgTypeName returns the name of a BPF program type.
+func BPFProgTypeName(progType uint32) string {
+	if int(progType) < len(bpfProgTypeNames) {
+		return bpfProgTypeNames[progType]
+	}
+	return fmt.Sprintf("type_%d", progType)
+}
+
+// PtraceMode holds the kernel's PTRACE_MODE_* access bits.
+type PtraceMode uint32
+
+const (
+	PtraceModeRead      PtraceMode = 0x01
+	PtraceModeAttach    PtraceMode = 0x02
+	PtraceModeFSCreds   PtraceMode = 0x08
+	PtraceModeRealCreds PtraceMode = 0x10
+)
+
+// String names the access. FSCREDS checks come from procfs (/proc/<pid>/mem
+// and friends); REALCREDS checks come from ptrace(2) and process_vm_*.
+func (m PtraceMode) String() string {
+	access := "read"
+	if m&PtraceModeAttach != 0 {
+		access = "attach"
+	}
+	if m&PtraceModeFSCreds != 0 {
+		return access + "_procfs"
+	}
+	return access
+}
+
+// PtraceEvent reports one process requesting attach-level access to another.
+// The header describes the tracer.
+type PtraceEvent struct {
+	Hdr        EventHeader
+	TargetPID  uint32
+	Mode       PtraceMode
+	TargetComm [TaskCommLen]byte
+}
+
+// SignalEvent reports a signal sent to another process. The header describes
+// the sender.
+type SignalEvent struct {
+	Hdr        EventHeader
+	TargetPID  uint32
+	Signal     uint32
+	TargetComm [TaskCommLen]byte
+}
+
+// signalNames follows the x86/arm64 signal numbering.
+var signalNames = []string{
+	"", "SIGHUP", "SIGINT", "SIGQUIT", "SIGILL", "SIGTRAP", "SIGABRT", "SIGBUS",
+	"SIGFPE", "SIGKILL", "SIGUSR1", "SIGSEGV", "SIGUSR2", "SIGPIPE", "SIGALRM",
+	"SIGTERM", "SIGSTKFLT", "SIGCHLD", "SIGCONT", "SIGSTOP", "SIGTSTP", "SIGTTIN",
+	"SIGTTOU", "SIGURG", "SIGXCPU", "SIGXFSZ", "SIGVTALRM", "SIGPROF", "SIGWINCH",
+	"SIGIO", "SIGPWR", "SIGSYS",
+}
+
+// SignalName returns the name of a signal number, e.g. "SIGKILL".
+func SignalName(sig uint32) string {
+	if sig > 0 && int(sig) < len(signalNames) {
+		return signalNames[sig]
+	}
+	return fmt.Sprintf("SIG%d", sig)
+}
+
+// DNSTransport is the socket type a DNS message travelled over.
+type DNSTransport uint8
+
+const (
+	DNSTransportUDP DNSTransport = 1
+	DNSTransportTCP DNSTransport = 2
+)
+
+func (t DNSTransport) String() string {
+	if t == DNSTransportTCP {
+		return "tcp"
+	}
+	return "udp"
+}
+
+// DNSEvent carries a raw DNS query sent to port 53, or the UDP reply to one.
+// The address fields name the resolver; replies carry the header of the
+// process that sent the query.
+type DNSEvent struct {
+	Hdr       EventHeader
+	Family    uint16
+	Port      uint16
+	Transport DNSTransport
+	Response  uint8
+	_         [2]byte // padding
+	AddrV4    uint32
+	AddrV6    [16]byte
+	Len       uint32
+	Payload   [DNSPayloadLen]byte
+}
+
+// Message returns the captured DNS message without the TCP length prefix.
+func (e *DNSEvent) Message() []byte {
+	n := int(e.Len)
+	if n > len(e.Payload) {
+		n = len(e.Payload)
+	}
+	msg := e.Payload[:n]
+	if e.Transport == DNSTransportTCP && len(msg) >= 2 {
+		msg = msg[2:]
+	}
+	return msg
+}
+
+// ListenOp is the socket operation a listen event reports.
+type ListenOp uint8
+
+const (
+	ListenOpBind   ListenOp = 1 // UDP bind to a fixed port
+	ListenOpListen ListenOp = 2 // TCP listen()
+	ListenOpClose  ListenOp = 3 // a reported socket was freed
+)
+
+func (o ListenOp) String() string {
+	switch o {
+	case ListenOpBind:
+		return "bind"
+	case ListenOpListen:
+		return "listen"
+	case ListenOpClose:
+		return "close"
+	default:
+		return "unknown"
+	}
+}
+
+// ListenEvent reports a socket accepting traffic on a local port. Cookie is
+// the kernel socket cookie and identifies the socket until it is closed.
+type ListenEvent struct {
+	Hdr      EventHeader
+	Cookie   uint64
+	Family   uint16
+	Port     uint16
+	Protocol uint8 // IPPROTO_TCP or IPPROTO_UDP
+	Op       ListenOp
+	_        [2]byte // padding
+	AddrV4   uint32
+	AddrV6   [16]byte
+	Backlog  uint32
+}
+
+// ProtocolName returns "tcp" or "udp".
+func (e *ListenEvent) ProtocolName() string {
+	if e.Protocol == 17 {
+		return "udp"
+	}
+	return "tcp"
+}
+
+// NamespaceOp is the call a namespace event reports.
+type NamespaceOp uint8
+
+const (
+	NamespaceOpSetns     NamespaceOp = 1
+	NamespaceOpUnshare   NamespaceOp = 2
+	NamespaceOpMount     NamespaceOp = 3
+	NamespaceOpMoveMount NamespaceOp = 4
+)
+
+func (o NamespaceOp) String() string {
+	switch o {
+	case NamespaceOpSetns:
+		return "setns"
+	case NamespaceOpUnshare:
+		return "unshare"
+	case NamespaceOpMount:
+		return "mount"
+	case NamespaceOpMoveMount:
+		return "move_mount"
+	default:
+		return "unknown"
+	}
+}
+
+// IsMount reports whether the op is a mount rather than a namespace switch.
+func (o NamespaceOp) IsMount() bool {
+	return o == NamespaceOpMount || o == NamespaceOpMoveMount
+}
+
+// CLONE_NEW* flags naming the namespaces setns and unshare act on.
+const (
+	CloneNewNS   uint32 = 0x00020000
+	CloneNewUTS  uint32 = 0x04000000
+	CloneNewUser uint32 = 0x10000000
+	CloneNewPID  uint32 = 0x20000000
+	CloneNewNet  uint32 = 0x40000000
+)
+
+// namespaceKinds pairs each kind name, as used under /proc/<pid>/ns, with its
+// clone flag.
+var namespaceKinds = []struct {
+	name string
+	flag uint32
+}{
+	{"pid", CloneNewPID},
+	{"mnt", CloneNewNS},
+	{"net", CloneNewNet},
+	{"user", CloneNewUser},
+	{"uts", CloneNewUTS},
+}
+
+// NamespaceFlag returns the clone flag for a namespace kind such as "mnt", or
+// zero for an unknown kind.
+func NamespaceFlag(kind string) uint32 {
+	for _, k := range namespaceKinds {
+		if k.name == kind {
+			return k.flag
+		}
+	}
+	return 0
+}
+
+// NamespaceKinds lists the namespace kinds named by CLONE_NEW* bits in flags.
+func NamespaceKinds(flags uint32) []string {
+	var kinds []string
+	for _, k := range namespaceKinds {
+		if flags&k.flag != 0 {
+			kinds = append(kinds, k.name)
+		}
+	}
+	return kinds
+}
+
+// NamespaceEvent reports a setns, unshare or mount. For setns and unshare the
+// header holds the namespaces before the call, NewNS those after it (PID being
+// the namespace for children) and Flags the CLONE_NEW* bits, including one for
+// every namespace that changed. For mounts Flags are the MS_* mount flags,
+// Source is the device or source tree and Target the mount point.
+type NamespaceEvent struct {
+	Hdr    EventHeader
+	Op     NamespaceOp
+	_      [3]byte // padding
+	Flags  uint32
+	NewNS  Namespaces
+	_      [4]byte // padding
+	FSType [FSTypeLen]byte
+	Source [PathMaxLen]byte
+	Target [PathMaxLen]byte
+}
+
+// IntoHost reports whether a setns or unshare left the task in one of PID 1's
+// namespaces that it was not in before.
+func (e *NamespaceEvent) IntoHost() bool {
+	if e.Op.IsMount() {
+		return false
+	}
+	host := HostNamespaces()
+	changed := e.Flags
+	into := func(flag, ns, hostNS uint32) bool {
+		return changed&flag != 0 && ns != 0 && ns == hostNS
+	}
+	return into(CloneNewPID, e.NewNS.PID, host.PID) || into(CloneNewNS, e.NewNS.Mnt, host.Mnt) ||
+		into(CloneNewNet, e.NewNS.Net, host.Net) || into(CloneNewUser, e.NewNS.User, host.User) ||
+		into(CloneNewUTS, e.NewNS.UTS, host.UTS)
 }
 
 type Event struct {
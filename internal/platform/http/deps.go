@@ -25,6 +25,7 @@ type TelemetryService interface {
 	Query(telemetry.Query) telemetry.PageResult
 	Get(id string) (*telemetry.Record, bool)
 	ProcessTree() *proc.ProcessTree
+	Listeners() []telemetry.Listener
 }
 
 type PolicyService interface {
@@ -36,6 +37,7 @@ type PolicyService interface {
 	Update(name string, rule policy.Rule) (policy.Rule, error)
 	Delete(name string) error
 	Promote(name string) error
+	LastSync() policy.SyncReport
 }
 
 type AnalysisService interface {
@@ -62,21 +64,27 @@ type StatsService interface {
 	WorkloadCount() int
 	TotalAlertCount() int64
 	Alerts() []system.Alert
+	Ringbuf() system.RingbufStats
 }
 
 type ProbeStatusService interface {
 	ProbeStatus() system.ProbeStatus
 }
 
+type CapabilitiesService interface {
+	Capabilities() system.Capabilities
+}
+
 type Dependencies struct {
-	Telemetry   TelemetryService
-	Policy      PolicyService
-	Analysis    AnalysisService
-	Settings    SettingsService
-	Stats       StatsService
-	ProbeStatus ProbeStatusService
-	EventStream EventStream
-	AlertStream AlertStream
+	Telemetry    TelemetryService
+	Policy       PolicyService
+	Analysis     AnalysisService
+	Settings     SettingsService
+	Stats        StatsService
+	ProbeStatus  ProbeStatusService
+	Capabilities CapabilitiesService
+	EventStream  EventStream
+	AlertStream  AlertStream
 }
 
 type runtimeView interface {
@@ -88,17 +96,19 @@ type runtimeView interface {
 	EventStream() *stream.Hub[telemetry.Event]
 	AlertStream() *stream.Hub[system.Alert]
 	ProbeStatus() system.ProbeStatus
+	Capabilities() system.Capabilities
 }
 
 func DependenciesFromRuntime(runtime runtimeView) Dependencies {
 	deps := Dependencies{
-		Telemetry:   runtime.Telemetry(),
-		Policy:      runtime.Policy(),
-		Settings:    runtime.Settings(),
-		Stats:       runtime.Stats(),
-		ProbeStatus: runtime,
-		EventStream: runtime.EventStream(),
-		AlertStream: runtime.AlertStream(),
+		Telemetry:    runtime.Telemetry(),
+		Policy:       runtime.Policy(),
+		Settings:     runtime.Settings(),
+		Stats:        runtime.Stats(),
+		ProbeStatus:  runtime,
+		Capabilities: runtime,
+		EventStream:  runtime.EventStream(),
+		AlertStream:  runtime.AlertStream(),
 	}
 	if analysisService := runtime.Analysis(); analysisService != nil {
 		deps.Analysis = analysisService
@@ -33,17 +33,71 @@ type eventDTO struct {
 	PID         uint32              `json:"pid"`
 	PPID        uint32              `json:"ppid,omitempty"`
 	CgroupID    string              `json:"cgroupId"`
+	Namespaces  *namespaceDTO       `json:"namespaces,omitempty"`
 	ProcessName string              `json:"processName"`
 	ParentComm  string              `json:"parentComm,omitempty"`
 	CommandLine string              `json:"commandLine,omitempty"`
+	Args        []string            `json:"args,omitempty"`
+	Env         []string            `json:"env,omitempty"`
+	ArgsCut     bool                `json:"argsTruncated,omitempty"`
+	Fileless    bool                `json:"fileless,omitempty"`
+	Deleted     bool                `json:"deletedBinary,omitempty"`
 	Filename    string              `json:"filename,omitempty"`
 	Flags       uint32              `json:"flags,omitempty"`
 	Ino         uint64              `json:"ino,omitempty"`
 	Dev         uint64              `json:"dev,omitempty"`
+	Operation   string              `json:"operation,omitempty"`
 	Family      uint16              `json:"family,omitempty"`
 	Port        uint16              `json:"port,omitempty"`
 	Addr        string              `json:"addr,omitempty"`
+	Domain      string              `json:"domain,omitempty"`
+	QueryType   string              `json:"queryType,omitempty"`
+	Transport   string              `json:"transport,omitempty"`
+	Answers     []string            `json:"answers,omitempty"`
+	Protocol    string              `json:"protocol,omitempty"`
+	ExitCode    *uint32             `json:"exitCode,omitempty"`
+	Signal      uint32              `json:"signal,omitempty"`
+	SignalName  string              `json:"signalName,omitempty"`
+	RuntimeMs   int64               `json:"runtimeMs,omitempty"`
+	Credentials *credentialDTO      `json:"credentials,omitempty"`
+	LoadKind    string              `json:"loadKind,omitempty"`
+	ProgType    string              `json:"progType,omitempty"`
+	ProgName    string              `json:"progName,omitempty"`
+	BPFCommand  string              `json:"bpfCommand,omitempty"`
+	TargetPID   uint32              `json:"targetPid,omitempty"`
+	TargetName  string              `json:"targetName,omitempty"`
+	AccessMode  string              `json:"accessMode,omitempty"`
+	Changed     []string            `json:"changedNamespaces,omitempty"`
+	NewNS       *namespaceDTO       `json:"newNamespaces,omitempty"`
+	IntoHost    bool                `json:"intoHost,omitempty"`
+	FSType      string              `json:"fsType,omitempty"`
+	Source      string              `json:"source,omitempty"`
 	Blocked     bool                `json:"blocked"`
+	Response    string              `json:"response,omitempty"`
+	SelfProtect bool                `json:"selfProtect,omitempty"`
+}
+
+type namespaceDTO struct {
+	PID  uint32 `json:"pid"`
+	Mnt  uint32 `json:"mnt"`
+	Net  uint32 `json:"net"`
+	User uint32 `json:"user"`
+	UTS  uint32 `json:"uts"`
+	Host bool   `json:"host"`
+}
+
+type credentialDTO struct {
+	Operation string `json:"operation"`
+	OldUID    uint32 `json:"oldUid"`
+	OldEUID   uint32 `json:"oldEuid"`
+	OldGID    uint32 `json:"oldGid"`
+	OldEGID   uint32 `json:"oldEgid"`
+	NewUID    uint32 `json:"newUid"`
+	NewEUID   uint32 `json:"newEuid"`
+	NewGID    uint32 `json:"newGid"`
+	NewEGID   uint32 `json:"newEgid"`
+	OldCaps   string `json:"oldCaps"`
+	NewCaps   string `json:"newCaps"`
 }
 
 type eventPageResponse struct {
@@ -151,22 +205,115 @@ func eventToDTO(event telemetry.Event) eventDTO {
 		CgroupID:    strconv.FormatUint(event.CgroupID, 10),
 		ProcessName: event.ProcessName,
 		Blocked:     event.Blocked,
+		Response:    event.Response,
+		SelfProtect: event.SelfProtect,
+	}
+	if ns := event.Namespaces; ns != nil {
+		dto.Namespaces = &namespaceDTO{PID: ns.PID, Mnt: ns.Mnt, Net: ns.Net, User: ns.User, UTS: ns.UTS, Host: ns.Host}
 	}
 	switch event.Type {
 	case telemetry.EventTypeExec:
 		dto.PPID = event.PPID
 		dto.ParentComm = event.ParentName
 		dto.CommandLine = event.CommandLine
+		dto.Args = event.Args
+		dto.Env = event.Env
+		dto.ArgsCut = event.ArgsCut
 		dto.Filename = event.Filename
+		dto.Fileless = event.Fileless
+		dto.Deleted = event.Deleted
 	case telemetry.EventTypeFile:
 		dto.Filename = event.Filename
 		dto.Flags = event.Flags
 		dto.Ino = event.Ino
 		dto.Dev = event.Dev
+		dto.Operation = event.Operation
 	case telemetry.EventTypeConnect:
 		dto.Family = event.Family
 		dto.Port = event.Port
 		dto.Addr = event.Address
+		dto.Domain = event.Domain
+	case telemetry.EventTypeExit:
+		exitCode := event.ExitCode
+		dto.PPID = event.PPID
+		dto.ExitCode = &exitCode
+		dto.Signal = event.Signal
+		dto.RuntimeMs = event.RuntimeMs
+	case telemetry.EventTypePrivilege:
+		dto.PPID = event.PPID
+		dto.ParentComm = event.ParentName
+		if creds := event.Credentials; creds != nil {
+			dto.Credentials = &credentialDTO{
+				Operation: creds.Operation,
+				OldUID:    creds.OldUID,
+				OldEUID:   creds.OldEUID,
+				OldGID:    creds.OldGID,
+				OldEGID:   creds.OldEGID,
+				NewUID:    creds.NewUID,
+				NewEUID:   creds.NewEUID,
+				NewGID:    creds.NewGID,
+				NewEGID:   creds.NewEGID,
+				OldCaps:   strconv.FormatUint(creds.OldCaps, 16),
+				NewCaps:   strconv.FormatUint(creds.NewCaps, 16),
+			}
+		}
+	case telemetry.EventTypeKernelLoad:
+		dto.Filename = event.Filename
+		if load := event.KernelLoad; load != nil {
+			dto.LoadKind = load.Kind
+			dto.ProgType = load.ProgType
+			dto.ProgName = load.ProgName
+			dto.BPFCommand = load.Command
+		}
+	case telemetry.EventTypeDNS:
+		dto.Family = event.Family
+		dto.Port = event.Port
+		dto.Addr = event.Address
+		dto.Domain = event.Domain
+		dto.Operation = event.Operation
+		if dns := event.DNS; dns != nil {
+			dto.QueryType = dns.QueryType
+			dto.Transport = dns.Transport
+			dto.Answers = dns.Answers
+		}
+	case telemetry.EventTypeListen:
+		dto.Family = event.Family
+		dto.Port = event.Port
+		dto.Addr = event.Address
+		dto.Operation = event.Operation
+		dto.Protocol = event.Protocol
+	case telemetry.EventTypeNamespace:
+		dto.PPID = event.PPID
+		dto.ParentComm = event.ParentName
+		dto.Operation = event.Operation
+		dto.Flags = event.Flags
+		dto.Filename = event.Filename
+		if change := event.NSChange; change != nil {
+			dto.Changed = change.Changed
+			dto.IntoHost = change.IntoHost
+			dto.FSType = change.FSType
+			dto.Source = change.Source
+			if ns := change.New; ns != nil {
+				dto.NewNS = &namespaceDTO{PID: ns.PID, Mnt: ns.Mnt, Net: ns.Net, User: ns.User, UTS: ns.UTS, Host: ns.Host}
+			}
+		}
+	case telemetry.EventTypePtrace:
+		dto.PPID = event.PPID
+		dto.ParentComm = event.ParentName
+		if trace := event.Ptrace; trace != nil {
+			dto.TargetPID = trace.TargetPID
+			dto.TargetName = trace.TargetName
+			dto.AccessMode = trace.Mode
+		}
+	case telemetry.EventTypeSignal:
+		dto.PPID = event.PPID
+		dto.ParentComm = event.ParentName
+		dto.Signal = event.Signal
+		if sent := event.SignalSent; sent != nil {
+			dto.TargetPID = sent.TargetPID
+			dto.TargetName = sent.TargetName
+			dto.SignalName = sent.Name
+		}
 	}
 	return dto
 }
@@ -226,6 +373,22 @@ func parseEventType(raw string) telemetry.EventType {
 		return telemetry.EventTypeFile
 	case "connect", "network":
 		return telemetry.EventTypeConnect
+	case "exit":
+		return telemetry.EventTypeExit
+	case "privilege", "cred":
+		return telemetry.EventTypePrivilege
+	case "kernel_load", "module", "bpf":
+		return telemetry.EventTypeKernelLoad
+	case "ptrace":
+		return telemetry.EventTypePtrace
+	case "dns":
+		return telemetry.EventTypeDNS
+	case "listen", "bind":
+		return telemetry.EventTypeListen
+	case "namespace", "setns", "unshare", "mount":
+		return telemetry.EventTypeNamespace
+	case "signal", "kill":
+		return telemetry.EventTypeSignal
 	default:
 		return ""
 	}
@@ -12,16 +12,37 @@ import (
 )
 
 type policyMatchDTO struct {
-	ProcessName     string `json:"processName,omitempty"`
-	ProcessNameType string `json:"processNameType,omitempty"`
-	ParentName      string `json:"parentName,omitempty"`
-	ParentNameType  string `json:"parentNameType,omitempty"`
-	PID             uint32 `json:"pid,omitempty"`
-	PPID            uint32 `json:"ppid,omitempty"`
-	Filename        string `json:"filename,omitempty"`
-	DestPort        uint16 `json:"destPort,omitempty"`
-	DestIP          string `json:"destIp,omitempty"`
-	CgroupID        string `json:"cgroupId,omitempty"`
+	ProcessName     string  `json:"processName,omitempty"`
+	ProcessNameType string  `json:"processNameType,omitempty"`
+	ParentName      string  `json:"parentName,omitempty"`
+	ParentNameType  string  `json:"parentNameType,omitempty"`
+	PID             uint32  `json:"pid,omitempty"`
+	PPID            uint32  `json:"ppid,omitempty"`
+	Filename        string  `json:"filename,omitempty"`
+	DestPort        uint16  `json:"destPort,omitempty"`
+	DestIP          string  `json:"destIp,omitempty"`
+	DestDomain      string  `json:"destDomain,omitempty"`
+	DestSocket      string  `json:"destSocket,omitempty"`
+	DestSocketType  string  `json:"destSocketType,omitempty"`
+	LocalPort       uint16  `json:"localPort,omitempty"`
+	CgroupID        string  `json:"cgroupId,omitempty"`
+	HostOnly        bool    `json:"hostOnly,omitempty"`
+	NetNS           uint32  `json:"netNs,omitempty"`
+	ContainerOnly   bool    `json:"containerOnly,omitempty"`
+	Operation       string  `json:"operation,omitempty"`
+	Access          string  `json:"access,omitempty"`
+	FromUID         *uint32 `json:"fromUid,omitempty"`
+	ToUID           *uint32 `json:"toUid,omitempty"`
+	FromGID         *uint32 `json:"fromGid,omitempty"`
+	ToGID           *uint32 `json:"toGid,omitempty"`
+	TargetName      string  `json:"targetName,omitempty"`
+	TargetNameType  string  `json:"targetNameType,omitempty"`
+	Namespace       string  `json:"namespace,omitempty"`
+	IntoHost        bool    `json:"intoHost,omitempty"`
+	MountSource     string  `json:"mountSource,omitempty"`
+	FSType          string  `json:"fsType,omitempty"`
+	Fileless        bool    `json:"fileless,omitempty"`
+	DeletedBinary   bool    `json:"deletedBinary,omitempty"`
 }
 
 type policyRuleDTO struct {
@@ -97,6 +118,14 @@ func registerPolicyRoutes(mux *http.ServeMux, deps Dependencies) {
 		})
 	})
 
+	registerAliases(mux, []string{"/api/v1/policies/sync"}, func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, deps.Policy.LastSync())
+	})
+
 	registerAliases(mux, []string{"/api/v1/policies"}, func(w http.ResponseWriter, r *http.Request) {
 		setCORS(w)
 		switch r.Method {
@@ -128,7 +157,7 @@ func registerPolicyRoutes(mux *http.ServeMux, deps Dependencies) {
 
 	registerAliasesWithPrefix(mux, []string{"/api/v1/policies/"}, func(w http.ResponseWriter, r *http.Request, suffix string) {
 		setCORS(w)
-		if suffix == "" || suffix == "testing" || strings.HasPrefix(suffix, "validation/") {
+		if suffix == "" || suffix == "testing" || suffix == "sync" || strings.HasPrefix(suffix, "validation/") {
 			http.NotFound(w, r)
 			return
 		}
@@ -202,7 +231,28 @@ func toPolicyRuleDTO(rule policy.Rule) policyRuleDTO {
 			Filename:        rule.Match.Filename,
 			DestPort:        rule.Match.DestPort,
 			DestIP:          rule.Match.DestIP,
+			DestDomain:      rule.Match.DestDomain,
+			DestSocket:      rule.Match.DestSocket,
+			DestSocketType:  string(rule.Match.DestSocketType),
+			LocalPort:       rule.Match.LocalPort,
 			CgroupID:        rule.Match.CgroupID,
+			HostOnly:        rule.Match.HostOnly,
+			NetNS:           rule.Match.NetNS,
+			ContainerOnly:   rule.Match.ContainerOnly,
+			Operation:       rule.Match.Operation,
+			Access:          rule.Match.Access,
+			FromUID:         rule.Match.FromUID,
+			ToUID:           rule.Match.ToUID,
+			FromGID:         rule.Match.FromGID,
+			ToGID:           rule.Match.ToGID,
+			TargetName:      rule.Match.TargetName,
+			TargetNameType:  string(rule.Match.TargetNameType),
+			Namespace:       rule.Match.Namespace,
+			IntoHost:        rule.Match.IntoHost,
+			MountSource:     rule.Match.MountSource,
+			FSType:          rule.Match.FSType,
+			Fileless:        rule.Match.Fileless,
+			DeletedBinary:   rule.Match.DeletedBinary,
 		},
 		YAML:       string(yamlBytes),
 		CreatedAt:  rule.CreatedAt,
@@ -236,7 +286,28 @@ func fromPolicyRuleDTO(dto policyRuleDTO) policy.Rule {
 			Filename:        dto.Match.Filename,
 			DestPort:        dto.Match.DestPort,
 			DestIP:          dto.Match.DestIP,
+			DestDomain:      dto.Match.DestDomain,
+			DestSocket:      dto.Match.DestSocket,
+			DestSocketType:  policy.MatchType(dto.Match.DestSocketType),
+			LocalPort:       dto.Match.LocalPort,
 			CgroupID:        dto.Match.CgroupID,
+			HostOnly:        dto.Match.HostOnly,
+			NetNS:           dto.Match.NetNS,
+			ContainerOnly:   dto.Match.ContainerOnly,
+			Operation:       dto.Match.Operation,
+			Access:          dto.Match.Access,
+			FromUID:         dto.Match.FromUID,
+			ToUID:           dto.Match.ToUID,
+			FromGID:         dto.Match.FromGID,
+			ToGID:           dto.Match.ToGID,
+			TargetName:      dto.Match.TargetName,
+			TargetNameType:  policy.MatchType(dto.Match.TargetNameType),
+			Namespace:       dto.Match.Namespace,
+			IntoHost:        dto.Match.IntoHost,
+			MountSource:     dto.Match.MountSource,
+			FSType:          dto.Match.FSType,
+			Fileless:        dto.Match.Fileless,
+			DeletedBinary:   dto.Match.DeletedBinary,
 		},
 	}
 }
@@ -2,17 +2,31 @@ package httpapi
 
 import (
 	"net/http"
+	"strconv"
 
 	"aegis/internal/system"
 )
 
 type systemStatsResponse struct {
-	ProcessCount  int     `json:"processCount"`
-	WorkloadCount int     `json:"workloadCount"`
-	EventsPerSec  float64 `json:"eventsPerSec"`
-	AlertCount    int     `json:"alertCount"`
-	ProbeStatus   string  `json:"probeStatus"`
-	ProbeError    string  `json:"probeError,omitempty"`
+	ProcessCount  int                 `json:"processCount"`
+	WorkloadCount int                 `json:"workloadCount"`
+	EventsPerSec  float64             `json:"eventsPerSec"`
+	AlertCount    int                 `json:"alertCount"`
+	ProbeStatus   string              `json:"probeStatus"`
+	ProbeError    string              `json:"probeError,omitempty"`
+	ProbeWarning  string              `json:"probeWarning,omitempty"`
+	Ringbuf       system.RingbufStats `json:"ringbuf"`
+}
+
+type listenerDTO struct {
+	PID         uint32 `json:"pid"`
+	CgroupID    string `json:"cgroupId"`
+	ProcessName string `json:"processName"`
+	Protocol    string `json:"protocol"`
+	Family      uint16 `json:"family"`
+	Addr        string `json:"addr"`
+	Port        uint16 `json:"port"`
+	Since       int64  `json:"since"`
 }
 
 func registerSystemRoutes(mux *http.ServeMux, deps Dependencies) {
@@ -40,9 +54,23 @@ func registerSystemRoutes(mux *http.ServeMux, deps Dependencies) {
 			AlertCount:    int(deps.Stats.TotalAlertCount()),
 			ProbeStatus:   probe.Status,
 			ProbeError:    probe.Error,
+			ProbeWarning:  probe.Warning,
+			Ringbuf:       deps.Stats.Ringbuf(),
 		})
 	})
 
+	registerAliases(mux, []string{"/api/v1/system/capabilities"}, func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		if deps.Capabilities == nil {
+			writeErrorString(w, http.StatusServiceUnavailable, "capabilities not available")
+			return
+		}
+		writeJSON(w, http.StatusOK, deps.Capabilities.Capabilities())
+	})
+
 	registerAliases(mux, []string{"/api/v1/system/alerts"}, func(w http.ResponseWriter, r *http.Request) {
 		setCORS(w)
 		if !requireMethod(w, r, http.MethodGet) {
@@ -60,6 +88,28 @@ func registerSystemRoutes(mux *http.ServeMux, deps Dependencies) {
 		streamSSE(w, r, subscription.C, subscription.Cancel, nil)
 	})
 
+	registerAliases(mux, []string{"/api/v1/system/listeners"}, func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w)
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		listeners := deps.Telemetry.Listeners()
+		result := make([]listenerDTO, 0, len(listeners))
+		for _, l := range listeners {
+			result = append(result, listenerDTO{
+				PID:         l.PID,
+				CgroupID:    strconv.FormatUint(l.CgroupID, 10),
+				ProcessName: l.ProcessName,
+				Protocol:    l.Protocol,
+				Family:      l.Family,
+				Addr:        l.Address,
+				Port:        l.Port,
+				Since:       l.Since.UnixMilli(),
+			})
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+
 	registerAliases(mux, []string{"/api/v1/system/settings"}, func(w http.ResponseWriter, r *http.Request) {
 		setCORS(w)
 		switch r.Method {
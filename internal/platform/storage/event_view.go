@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"aegis/internal/platform/events"
 	"aegis/internal/shared/utils"
 )
@@ -20,6 +22,26 @@ type EventView struct {
 	Family      uint16
 	Port        uint16
 	Address     string
+	ExitCode    uint32
+	Signal      uint32
+	Runtime     time.Duration
+	Operation   string
+	FromUID     uint32
+	ToUID       uint32
+	FromGID     uint32
+	ToGID       uint32
+	LoadKind    string
+	ProgType    string
+	TargetPID   uint32
+	TargetName  string
+	Domain      string
+	Protocol    string
+	Namespaces  []string
+	FSType      string
+	Source      string
+	IntoHost    bool
+	Fileless    bool
+	Deleted     bool
 	Blocked     bool
 }
 
@@ -74,6 +96,142 @@ func ConnectPayload(event *Event) (events.ConnectEvent, bool) {
 	}
 }
 
+func ExitPayload(event *Event) (events.ExitEvent, bool) {
+	if event == nil {
+		return events.ExitEvent{}, false
+	}
+	switch data := event.Data.(type) {
+	case events.ExitEvent:
+		return data, true
+	case *events.ExitEvent:
+		if data == nil {
+			return events.ExitEvent{}, false
+		}
+		return *data, true
+	default:
+		return events.ExitEvent{}, false
+	}
+}
+
+func PrivilegePayload(event *Event) (events.PrivilegeEvent, bool) {
+	if event == nil {
+		return events.PrivilegeEvent{}, false
+	}
+	switch data := event.Data.(type) {
+	case events.PrivilegeEvent:
+		return data, true
+	case *events.PrivilegeEvent:
+		if data == nil {
+			return events.PrivilegeEvent{}, false
+		}
+		return *data, true
+	default:
+		return events.PrivilegeEvent{}, false
+	}
+}
+
+func KernelLoadPayload(event *Event) (events.KernelLoadEvent, bool) {
+	if event == nil {
+		return events.KernelLoadEvent{}, false
+	}
+	switch data := event.Data.(type) {
+	case events.KernelLoadEvent:
+		return data, true
+	case *events.KernelLoadEvent:
+		if data == nil {
+			return events.KernelLoadEvent{}, false
+		}
+		return *data, true
+	default:
+		return events.KernelLoadEvent{}, false
+	}
+}
+
+func DNSPayload(event *Event) (events.DNSEvent, bool) {
+	if event == nil {
+		return events.DNSEvent{}, false
+	}
+	switch data := event.Data.(type) {
+	case events.DNSEvent:
+		return data, true
+	case *events.DNSEvent:
+		if data == nil {
+			return events.DNSEvent{}, false
+		}
+		return *data, true
+	default:
+		return events.DNSEvent{}, false
+	}
+}
+
+func ListenPayload(event *Event) (events.ListenEvent, bool) {
+	if event == nil {
+		return events.ListenEvent{}, false
+	}
+	switch data := event.Data.(type) {
+	case events.ListenEvent:
+		return data, true
+	case *events.ListenEvent:
+		if data == nil {
+			return events.ListenEvent{}, false
+		}
+		return *data, true
+	default:
+		return events.ListenEvent{}, false
+	}
+}
+
+func PtracePayload(event *Event) (events.PtraceEvent, bool) {
+	if event == nil {
+		return events.PtraceEvent{}, false
+	}
+	switch data := event.Data.(type) {
+	case events.PtraceEvent:
+		return data, true
+	case *events.PtraceEvent:
+		if data == nil {
+			return events.PtraceEvent{}, false
+		}
+		return *data, true
+	default:
+		return events.PtraceEvent{}, false
+	}
+}
+
+func NamespacePayload(event *Event) (events.NamespaceEvent, bool) {
+	if event == nil {
+		return events.NamespaceEvent{}, false
+	}
+	switch data := event.Data.(type) {
+	case events.NamespaceEvent:
+		return data, true
+	case *events.NamespaceEvent:
+		if data == nil {
+			return events.NamespaceEvent{}, false
+		}
+		return *data, true
+	default:
+		return events.NamespaceEvent{}, false
+	}
+}
+
+func SignalPayload(event *Event) (events.SignalEvent, bool) {
+	if event == nil {
+		return events.SignalEvent{}, false
+	}
+	switch data := event.Data.(type) {
+	case events.SignalEvent:
+		return data, true
+	case *events.SignalEvent:
+		if data == nil {
+			return events.SignalEvent{}, false
+		}
+		return *data, true
+	default:
+		return events.SignalEvent{}, false
+	}
+}
+
 func View(event *Event) (EventView, bool) {
 	if execEvent, ok := ExecPayload(event); ok {
 		return EventView{
@@ -83,13 +241,15 @@ func View(event *Event) (EventView, bool) {
 			CgroupID:    execEvent.Hdr.CgroupID,
 			ProcessName: utils.ExtractCString(execEvent.Hdr.Comm[:]),
 			ParentName:  utils.ExtractCString(execEvent.PComm[:]),
-			CommandLine: utils.ExtractCString(execEvent.CommandLine[:]),
+			CommandLine: utils.ExecCommandLine(&execEvent),
 			Filename:    utils.ExtractCString(execEvent.Filename[:]),
+			Fileless:    execEvent.ExecFlags.Fileless(),
+			Deleted:     execEvent.ExecFlags.Deleted(),
 			Blocked:     execEvent.Hdr.Blocked == 1,
 		}, true
 	}
 	if fileEvent, ok := FileOpenPayload(event); ok {
-		return EventView{
+		view := EventView{
 			Type:        events.EventTypeFileOpen,
 			PID:         fileEvent.Hdr.PID,
 			CgroupID:    fileEvent.Hdr.CgroupID,
@@ -99,7 +259,11 @@ func View(event *Event) (EventView, bool) {
 			Ino:         fileEvent.Ino,
 			Dev:         fileEvent.Dev,
 			Blocked:     fileEvent.Hdr.Blocked == 1,
-		}, true
+		}
+		if fileEvent.Op != 0 {
+			view.Operation = fileEvent.Op.String()
+		}
+		return view, true
 	}
 	if connectEvent, ok := ConnectPayload(event); ok {
 		return EventView{
@@ -113,5 +277,126 @@ func View(event *Event) (EventView, bool) {
 			Blocked:     connectEvent.Hdr.Blocked == 1,
 		}, true
 	}
+	if exitEvent, ok := ExitPayload(event); ok {
+		return EventView{
+			Type:        events.EventTypeExit,
+			PID:         exitEvent.Hdr.PID,
+			PPID:        exitEvent.PPID,
+			CgroupID:    exitEvent.Hdr.CgroupID,
+			ProcessName: utils.ExtractCString(exitEvent.Hdr.Comm[:]),
+			ExitCode:    exitEvent.ExitCode,
+			Signal:      exitEvent.Signal,
+			Runtime:     exitEvent.Runtime(),
+		}, true
+	}
+	if privEvent, ok := PrivilegePayload(event); ok {
+		return EventView{
+			Type:        events.EventTypePrivilege,
+			PID:         privEvent.Hdr.PID,
+			CgroupID:    privEvent.Hdr.CgroupID,
+			ProcessName: utils.ExtractCString(privEvent.Hdr.Comm[:]),
+			Operation:   privEvent.Op.String(),
+			FromUID:     privEvent.OldUID,
+			ToUID:       privEvent.NewEUID,
+			FromGID:     privEvent.OldGID,
+			ToGID:       privEvent.NewEGID,
+		}, true
+	}
+	if loadEvent, ok := KernelLoadPayload(event); ok {
+		view := EventView{
+			Type:        events.EventTypeKernelLoad,
+			PID:         loadEvent.Hdr.PID,
+			CgroupID:    loadEvent.Hdr.CgroupID,
+			ProcessName: utils.ExtractCString(loadEvent.Hdr.Comm[:]),
+			LoadKind:    loadEvent.Kind.String(),
+			Blocked:     loadEvent.Hdr.Blocked == 1,
+		}
+		if loadEvent.Kind == events.LoadKindBPF {
+			view.ProgType = events.BPFProgTypeName(loadEvent.ProgType)
+			if loadEvent.BPFCmd != events.BPFCmdProgLoad {
+				view.Operation = events.BPFCmdName(loadEvent.BPFCmd)
+			}
+		} else {
+			view.Filename = utils.ExtractCString(loadEvent.Name[:])
+		}
+		return view, true
+	}
+	if ptraceEvent, ok := PtracePayload(event); ok {
+		return EventView{
+			Type:        events.EventTypePtrace,
+			PID:         ptraceEvent.Hdr.PID,
+			CgroupID:    ptraceEvent.Hdr.CgroupID,
+			ProcessName: utils.ExtractCString(ptraceEvent.Hdr.Comm[:]),
+			Operation:   ptraceEvent.Mode.String(),
+			TargetPID:   ptraceEvent.TargetPID,
+			TargetName:  utils.ExtractCString(ptraceEvent.TargetComm[:]),
+			Blocked:     ptraceEvent.Hdr.Blocked == 1,
+		}, true
+	}
+	if dnsEvent, ok := DNSPayload(event); ok {
+		view := EventView{
+			Type:        events.EventTypeDNS,
+			PID:         dnsEvent.Hdr.PID,
+			CgroupID:    dnsEvent.Hdr.CgroupID,
+			ProcessName: utils.ExtractCString(dnsEvent.Hdr.Comm[:]),
+			Family:      dnsEvent.Family,
+			Port:        dnsEvent.Port,
+			Address:     utils.ExtractDNSServer(&dnsEvent),
+			Operation:   "query",
+			Blocked:     dnsEvent.Hdr.Blocked == 1,
+		}
+		if dnsEvent.Response != 0 {
+			view.Operation = "response"
+		}
+		if msg, err := events.ParseDNSMessage(dnsEvent.Message()); err == nil {
+			view.Domain = msg.Name
+		}
+		return view, true
+	}
+	if listenEvent, ok := ListenPayload(event); ok {
+		return EventView{
+			Type:        events.EventTypeListen,
+			PID:         listenEvent.Hdr.PID,
+			CgroupID:    listenEvent.Hdr.CgroupID,
+			ProcessName: utils.ExtractCString(listenEvent.Hdr.Comm[:]),
+			Family:      listenEvent.Family,
+			Port:        listenEvent.Port,
+			Address:     utils.ExtractListenAddr(&listenEvent),
+			Operation:   listenEvent.Op.String(),
+			Protocol:    listenEvent.ProtocolName(),
+			Blocked:     listenEvent.Hdr.Blocked == 1,
+		}, true
+	}
+	if nsEvent, ok := NamespacePayload(event); ok {
+		view := EventView{
+			Type:        events.EventTypeNamespace,
+			PID:         nsEvent.Hdr.PID,
+			CgroupID:    nsEvent.Hdr.CgroupID,
+			ProcessName: utils.ExtractCString(nsEvent.Hdr.Comm[:]),
+			Operation:   nsEvent.Op.String(),
+			Flags:       nsEvent.Flags,
+		}
+		if nsEvent.Op.IsMount() {
+			view.Filename = utils.ExtractCString(nsEvent.Target[:])
+			view.FSType = utils.ExtractCString(nsEvent.FSType[:])
+			view.Source = utils.ExtractCString(nsEvent.Source[:])
+		} else {
+			view.Namespaces = events.NamespaceKinds(nsEvent.Flags)
+			view.IntoHost = nsEvent.IntoHost()
+		}
+		return view, true
+	}
+	if signalEvent, ok := SignalPayload(event); ok {
+		return EventView{
+			Type:        events.EventTypeSignal,
+			PID:         signalEvent.Hdr.PID,
+			CgroupID:    signalEvent.Hdr.CgroupID,
+			ProcessName: utils.ExtractCString(signalEvent.Hdr.Comm[:]),
+			Signal:      signalEvent.Signal,
+			TargetPID:   signalEvent.TargetPID,
+			TargetName:  utils.ExtractCString(signalEvent.TargetComm[:]),
+			Blocked:     signalEvent.Hdr.Blocked == 1,
+		}, true
+	}
 	return EventView{}, false
 }
@@ -6,6 +6,13 @@ import (
 	"time"
 )
 
+// connectEvent pairs a raw connect with the domain telemetry resolved for
+// its address, if any.
+type connectEvent struct {
+	raw    *events.ConnectEvent
+	domain string
+}
+
 type connectMatcher struct {
 	rules         []*Rule
 	testingBuffer *TestingBuffer
@@ -17,18 +24,29 @@ func newConnectMatcher(rules []Rule, testingBuffer *TestingBuffer) *connectMatch
 		testingBuffer: testingBuffer,
 	}
 	for i := range rules {
-		if rules[i].Match.DestPort != 0 || rules[i].Match.DestIP != "" {
+		if isConnectMatch(rules[i].Match) {
 			matcher.rules = append(matcher.rules, &rules[i])
 		}
 	}
 	return matcher
 }
 
-func (m *connectMatcher) Match(event *events.ConnectEvent) (matched bool, rule *Rule, allowed bool) {
-	return filterRulesByAction(m.rules, m.matchRule, event)
+func (m *connectMatcher) Match(event *events.ConnectEvent, domain string) (matched bool, rule *Rule, allowed bool) {
+	return filterRulesByAction(m.rules, m.matchRule, connectEvent{raw: event, domain: domain})
+}
+
+// DomainRules returns the active dest_domain rules that match domain.
+func (m *connectMatcher) DomainRules(domain string) []Rule {
+	var matched []Rule
+	for _, rule := range m.rules {
+		if rule.Match.DestDomain != "" && rule.Match.MatchDomain(domain) {
+			matched = append(matched, *rule)
+		}
+	}
+	return matched
 }
 
-func (m *connectMatcher) CollectAlerts(event *events.ConnectEvent, processName string) []MatchedAlert {
+func (m *connectMatcher) CollectAlerts(event *events.ConnectEvent, domain string, processName string) []MatchedAlert {
 	var alerts []MatchedAlert
 	seen := make(map[*Rule]bool)
 
@@ -36,7 +54,7 @@ func (m *connectMatcher) CollectAlerts(event *events.ConnectEvent, processName s
 		if seen[rule] {
 			continue
 		}
-		if m.matchRule(rule, event) {
+		if m.matchRule(rule, connectEvent{raw: event, domain: domain}) {
 			seen[rule] = true
 			if rule.IsTesting() {
 				if m.testingBuffer != nil {
@@ -61,18 +79,33 @@ func (m *connectMatcher) CollectAlerts(event *events.ConnectEvent, processName s
 	return alerts
 }
 
-func (m *connectMatcher) matchRule(rule *Rule, event *events.ConnectEvent) bool {
+func (m *connectMatcher) matchRule(rule *Rule, event connectEvent) bool {
 	match := rule.Match
-	if match.DestPort == 0 && match.DestIP == "" {
+	if !isConnectMatch(match) {
 		return false
 	}
-	if match.DestPort != 0 && event.Port != match.DestPort {
+	// dest_socket rules only see unix connects, and the others never do.
+	if (match.DestSocket != "") != (event.raw.Family == events.AFUnix) {
+		return false
+	}
+	if !match.MatchSocket(utils.ExtractIP(event.raw)) {
+		return false
+	}
+	if match.DestPort != 0 && event.raw.Port != match.DestPort {
 		return false
 	}
 	if match.DestIP != "" {
-		if eventIP := utils.ExtractIP(event); eventIP == "" || !match.MatchIP(eventIP) {
+		if eventIP := utils.ExtractIP(event.raw); eventIP == "" || !match.MatchIP(eventIP) {
 			return false
 		}
 	}
-	return matchCgroupID(match.CgroupID, event.Hdr.CgroupID) && matchPID(match.PID, event.Hdr.PID)
+	if !match.MatchDomain(event.domain) {
+		return false
+	}
+	return matchCgroupID(match.CgroupID, event.raw.Hdr.CgroupID) && matchPID(match.PID, event.raw.Hdr.PID) &&
+		matchNamespaces(match, event.raw.Hdr.NS)
+}
+
+func isConnectMatch(match MatchCondition) bool {
+	return match.DestPort != 0 || match.DestIP != "" || match.DestDomain != "" || match.DestSocket != ""
 }
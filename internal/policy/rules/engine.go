@@ -5,11 +5,16 @@ import (
 )
 
 type Engine struct {
-	rules          []Rule
-	execMatcher    *execMatcher
-	fileMatcher    *fileMatcher
-	connectMatcher *connectMatcher
-	testingBuffer  *TestingBuffer
+	rules            []Rule
+	execMatcher      *execMatcher
+	fileMatcher      *fileMatcher
+	connectMatcher   *connectMatcher
+	privilegeMatcher *privilegeMatcher
+	loadMatcher      *kernelLoadMatcher
+	ptraceMatcher    *ptraceMatcher
+	listenMatcher    *listenMatcher
+	escapeMatcher    *escapeMatcher
+	testingBuffer    *TestingBuffer
 }
 
 func NewEngine(rules []Rule) *Engine {
@@ -26,11 +31,16 @@ func NewEngine(rules []Rule) *Engine {
 	}
 	b := NewTestingBuffer(10000)
 	return &Engine{
-		rules:          rules, // Keep all rules for GetRules(), but only active ones in matchers
-		execMatcher:    newExecMatcher(activeRules, b),
-		fileMatcher:    newFileMatcher(activeRules, b),
-		connectMatcher: newConnectMatcher(activeRules, b),
-		testingBuffer:  b,
+		rules:            rules, // Keep all rules for GetRules(), but only active ones in matchers
+		execMatcher:      newExecMatcher(activeRules, b),
+		fileMatcher:      newFileMatcher(activeRules, b),
+		connectMatcher:   newConnectMatcher(activeRules, b),
+		privilegeMatcher: newPrivilegeMatcher(activeRules),
+		loadMatcher:      newKernelLoadMatcher(activeRules),
+		ptraceMatcher:    newPtraceMatcher(activeRules),
+		listenMatcher:    newListenMatcher(activeRules),
+		escapeMatcher:    newEscapeMatcher(activeRules),
+		testingBuffer:    b,
 	}
 }
 
@@ -52,7 +62,28 @@ func (e *Engine) MatchFile(ino, dev uint64, filename string, pid uint32, cgroupI
 	if e.fileMatcher == nil {
 		return false, nil, false
 	}
-	return e.fileMatcher.Match(ino, dev, filename, pid, cgroupID)
+	return e.fileMatcher.Match(ino, dev, newFileEvent(filename, pid, cgroupID))
+}
+
+// MatchFileOpen matches a decoded file event, taking the monitored directory
+// the kernel attributed it to into account. filename is the resolved path; a
+// path the kernel cut short is not matched against path rules, as its tail
+// could pass for a shorter absolute path.
+func (e *Engine) MatchFileOpen(event *events.FileOpenEvent, filename string) (matched bool, rule *Rule, allowed bool) {
+	if e.fileMatcher == nil || event == nil {
+		return false, nil, false
+	}
+	if event.Hdr.PathCut != 0 {
+		filename = ""
+	}
+	fe := newFileEvent(filename, event.Hdr.PID, event.Hdr.CgroupID)
+	fe.ns = event.Hdr.NS
+	fe.dir = InodeKey{Ino: event.DirIno, Dev: event.DirDev}
+	fe.flags = event.Flags
+	if event.Op != 0 {
+		fe.op = event.Op.String()
+	}
+	return e.fileMatcher.Match(event.Ino, event.Dev, fe)
 }
 
 func (e *Engine) CollectFileAlerts(ino, dev uint64, filename string, pid uint32, cgroupID uint64, processName string) []MatchedAlert {
@@ -62,18 +93,68 @@ func (e *Engine) CollectFileAlerts(ino, dev uint64, filename string, pid uint32,
 	return e.fileMatcher.CollectAlerts(ino, dev, filename, pid, cgroupID, processName)
 }
 
-func (e *Engine) MatchConnect(event *events.ConnectEvent) (matched bool, rule *Rule, allowed bool) {
+// MatchConnect matches a decoded connect event. domain is the name the
+// process resolved the destination from, or "" when unknown.
+func (e *Engine) MatchConnect(event *events.ConnectEvent, domain string) (matched bool, rule *Rule, allowed bool) {
 	if e.connectMatcher == nil {
 		return false, nil, false
 	}
-	return e.connectMatcher.Match(event)
+	return e.connectMatcher.Match(event, domain)
+}
+
+// DomainRules returns the dest_domain connect rules matching domain.
+func (e *Engine) DomainRules(domain string) []Rule {
+	if e.connectMatcher == nil {
+		return nil
+	}
+	return e.connectMatcher.DomainRules(domain)
 }
 
-func (e *Engine) CollectConnectAlerts(event *events.ConnectEvent, processName string) []MatchedAlert {
+func (e *Engine) CollectConnectAlerts(event *events.ConnectEvent, domain string, processName string) []MatchedAlert {
 	if e.connectMatcher == nil {
 		return nil
 	}
-	return e.connectMatcher.CollectAlerts(event, processName)
+	return e.connectMatcher.CollectAlerts(event, domain, processName)
+}
+
+func (e *Engine) MatchPrivilege(event *events.PrivilegeEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	if e.privilegeMatcher == nil || event == nil {
+		return false, nil, false
+	}
+	return e.privilegeMatcher.Match(event, processName)
+}
+
+func (e *Engine) MatchKernelLoad(event *events.KernelLoadEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	if e.loadMatcher == nil || event == nil {
+		return false, nil, false
+	}
+	return e.loadMatcher.Match(event, processName)
+}
+
+// MatchPtrace matches a ptrace event; processName is the tracer's name.
+func (e *Engine) MatchPtrace(event *events.PtraceEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	if e.ptraceMatcher == nil || event == nil {
+		return false, nil, false
+	}
+	return e.ptraceMatcher.Match(event, processName)
+}
+
+// MatchListen matches a bind or listen event; processName is the listener's
+// name.
+func (e *Engine) MatchListen(event *events.ListenEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	if e.listenMatcher == nil || event == nil {
+		return false, nil, false
+	}
+	return e.listenMatcher.Match(event, processName)
+}
+
+// MatchEscape matches a setns, unshare or mount event; processName is the
+// caller's name.
+func (e *Engine) MatchEscape(event *events.NamespaceEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	if e.escapeMatcher == nil || event == nil {
+		return false, nil, false
+	}
+	return e.escapeMatcher.Match(event, processName)
 }
 
 func (e *Engine) GetRules() []Rule {
@@ -0,0 +1,83 @@
+package rules
+
+import (
+	"strings"
+
+	"aegis/internal/platform/events"
+	"aegis/internal/shared/utils"
+)
+
+type escapeMatcher struct {
+	rules []*Rule
+}
+
+type escapeEvent struct {
+	event       *events.NamespaceEvent
+	processName string
+}
+
+func newEscapeMatcher(rules []Rule) *escapeMatcher {
+	matcher := &escapeMatcher{
+		rules: make([]*Rule, 0),
+	}
+	for i := range rules {
+		if rules[i].DeriveType() == RuleTypeEscape {
+			matcher.rules = append(matcher.rules, &rules[i])
+		}
+	}
+	return matcher
+}
+
+func (m *escapeMatcher) Match(event *events.NamespaceEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	return filterRulesByAction(m.rules, m.matchRule, escapeEvent{event: event, processName: processName})
+}
+
+// matchRule checks the scope (container_only, host_only, ...) against the
+// namespaces the process was in before the call, so "container_only,
+// into_host" catches a containerized process joining the host.
+func (m *escapeMatcher) matchRule(rule *Rule, ee escapeEvent) bool {
+	match := rule.Match
+	event := ee.event
+	return (match.Operation == "" || match.Operation == event.Op.String()) &&
+		matchNamespaceChange(match, event) &&
+		matchMount(match, event) &&
+		(match.ProcessName == "" || matchString(ee.processName, match.ProcessName, match.ProcessNameType)) &&
+		matchPID(match.PID, event.Hdr.PID) &&
+		matchCgroupID(match.CgroupID, event.Hdr.CgroupID) &&
+		matchNamespaces(match, event.Hdr.NS)
+}
+
+func matchNamespaceChange(match MatchCondition, event *events.NamespaceEvent) bool {
+	if match.Namespace == "" && !match.IntoHost {
+		return true
+	}
+	if event.Op.IsMount() {
+		return false
+	}
+	if match.Namespace != "" && event.Flags&events.NamespaceFlag(match.Namespace) == 0 {
+		return false
+	}
+	return !match.IntoHost || event.IntoHost()
+}
+
+// matchMount compares mount_source exactly, or as a prefix when it ends in
+// "*", and fs_type exactly.
+func matchMount(match MatchCondition, event *events.NamespaceEvent) bool {
+	if match.MountSource == "" && match.FSType == "" {
+		return true
+	}
+	if !event.Op.IsMount() {
+		return false
+	}
+	if match.FSType != "" && utils.ExtractCString(event.FSType[:]) != match.FSType {
+		return false
+	}
+	if match.MountSource == "" {
+		return true
+	}
+	source := utils.ExtractCString(event.Source[:])
+	if prefix, ok := strings.CutSuffix(match.MountSource, "*"); ok {
+		return strings.HasPrefix(source, prefix)
+	}
+	return source == match.MountSource
+}
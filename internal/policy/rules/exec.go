@@ -37,11 +37,23 @@ func setDefaultMatchTypes(rule *Rule) {
 	if rule.Match.ParentName != "" && rule.Match.ParentNameType == "" {
 		rule.Match.ParentNameType = MatchTypeContains
 	}
+	if rule.Match.TargetName != "" && rule.Match.TargetNameType == "" {
+		rule.Match.TargetNameType = MatchTypeContains
+	}
 }
 
 func hasExecCriteria(rule *Rule) bool {
+	switch rule.DeriveType() {
+	case RuleTypePrivilege, RuleTypeKernelLoad, RuleTypePtrace, RuleTypeListen, RuleTypeEscape:
+		return false
+	}
 	m := rule.Match
-	return m.ProcessName != "" || m.ParentName != "" || m.PID != 0 || m.PPID != 0
+	if m.ProcessName != "" || m.ParentName != "" || m.PID != 0 || m.PPID != 0 ||
+		m.Fileless || m.DeletedBinary {
+		return true
+	}
+	// Namespace scopes narrow other rule types; alone they make an exec rule.
+	return rule.DeriveType() == RuleTypeExec && (m.HostOnly || m.ContainerOnly || m.NetNS != 0)
 }
 
 func (m *execMatcher) indexRule(rule *Rule) {
@@ -123,5 +135,8 @@ func (m *execMatcher) matchRule(rule *Rule, event events.ProcessedEvent) bool {
 		(match.ParentName == "" || matchString(event.Parent, match.ParentName, match.ParentNameType)) &&
 		matchPID(match.PID, event.Event.Hdr.PID) &&
 		(match.PPID == 0 || event.Event.PPID == match.PPID) &&
-		matchCgroupID(match.CgroupID, event.Event.Hdr.CgroupID)
+		(!match.Fileless || event.Event.ExecFlags.Fileless()) &&
+		(!match.DeletedBinary || event.Event.ExecFlags.Deleted()) &&
+		matchCgroupID(match.CgroupID, event.Event.Hdr.CgroupID) &&
+		matchNamespaces(match, event.Event.Hdr.NS)
 }
@@ -11,10 +11,15 @@ import (
 
 type fileEvent struct {
 	filename       string
+	op             string
+	flags          uint32
 	pathVariants   []string
 	pid            uint32
 	cgroupID       uint64
+	ns             events.Namespaces
+	dir            InodeKey
 	matchedByInode bool
+	matchedByDir   bool
 }
 
 func (e fileEvent) hasExactPath(target string) bool {
@@ -31,6 +36,7 @@ type pathPrefixBucket struct {
 
 type fileMatcher struct {
 	inodeRules    map[InodeKey][]*Rule
+	dirRules      map[InodeKey][]*Rule
 	pathRules     map[string][]*Rule
 	prefixes      []pathPrefixBucket
 	testingBuffer *TestingBuffer
@@ -39,6 +45,7 @@ type fileMatcher struct {
 func newFileMatcher(rules []Rule, testingBuffer *TestingBuffer) *fileMatcher {
 	matcher := &fileMatcher{
 		inodeRules:    make(map[InodeKey][]*Rule),
+		dirRules:      make(map[InodeKey][]*Rule),
 		pathRules:     make(map[string][]*Rule),
 		prefixes:      make([]pathPrefixBucket, 0),
 		testingBuffer: testingBuffer,
@@ -48,10 +55,16 @@ func newFileMatcher(rules []Rule, testingBuffer *TestingBuffer) *fileMatcher {
 
 	for i := range rules {
 		rule := &rules[i]
+		if rule.DeriveType() != RuleTypeFile {
+			continue
+		}
 
 		if key, ok := rule.Match.InodeKey(); ok {
 			matcher.inodeRules[key] = append(matcher.inodeRules[key], rule)
 		}
+		if key, ok := rule.Match.DirInodeKey(); ok {
+			matcher.dirRules[key] = append(matcher.dirRules[key], rule)
+		}
 
 		if keys := rule.Match.ExactPathKeys(); len(keys) > 0 {
 			for _, key := range keys {
@@ -87,13 +100,11 @@ func newFileMatcher(rules []Rule, testingBuffer *TestingBuffer) *fileMatcher {
 	return matcher
 }
 
-func (m *fileMatcher) Match(ino, dev uint64, filename string, pid uint32, cgroupID uint64) (matched bool, rule *Rule, allowed bool) {
+func (m *fileMatcher) Match(ino, dev uint64, event fileEvent) (matched bool, rule *Rule, allowed bool) {
 	if m == nil {
 		return false, nil, false
 	}
 
-	event := newFileEvent(filename, pid, cgroupID)
-
 	if rules := m.inodeRules[InodeKey{Ino: ino, Dev: dev}]; len(rules) > 0 {
 		inodeEvent := event
 		inodeEvent.matchedByInode = true
@@ -102,6 +113,18 @@ func (m *fileMatcher) Match(ino, dev uint64, filename string, pid uint32, cgroup
 		}
 	}
 
+	// The kernel reports which monitored directory it matched; the filename it
+	// sends is too short to carry the directory prefix for nested files.
+	if event.dir.Ino != 0 {
+		if rules := m.dirRules[event.dir]; len(rules) > 0 {
+			dirEvent := event
+			dirEvent.matchedByDir = true
+			if matched, rule, allowed := filterRulesByAction(rules, m.matchRule, dirEvent); matched {
+				return matched, rule, allowed
+			}
+		}
+	}
+
 	for _, key := range event.pathVariants {
 		if key == "" {
 			continue
@@ -135,6 +158,18 @@ func (m *fileMatcher) matchRule(rule *Rule, event fileEvent) bool {
 	if match.Filename == "" && len(match.PrefixPathKeys()) == 0 {
 		return false
 	}
+	if match.Operation != "" && event.op != "" && match.Operation != event.op {
+		return false
+	}
+	// Access modes describe opens; deletes, renames and chmods never match.
+	if match.Access != "" {
+		if event.op != "" && event.op != "read" && event.op != "write" {
+			return false
+		}
+		if !matchAccess(match.Access, event.flags) {
+			return false
+		}
+	}
 
 	// 1) Exact path keys (skip if matched by inode already)
 	if len(match.ExactPathKeys()) > 0 && !event.matchedByInode {
@@ -147,8 +182,8 @@ func (m *fileMatcher) matchRule(rule *Rule, event fileEvent) bool {
 		}
 	}
 
-	// 2) Prefix directory keys
-	if len(match.PrefixPathKeys()) > 0 {
+	// 2) Prefix directory keys (skip if matched by directory inode already)
+	if len(match.PrefixPathKeys()) > 0 && !event.matchedByDir {
 		found := false
 		for _, prefix := range match.PrefixPathKeys() {
 			if prefix == "" {
@@ -214,7 +249,8 @@ func (m *fileMatcher) matchRule(rule *Rule, event fileEvent) bool {
 		}
 	}
 
-	return matchCgroupID(match.CgroupID, event.cgroupID) && matchPID(match.PID, event.pid)
+	return matchCgroupID(match.CgroupID, event.cgroupID) && matchPID(match.PID, event.pid) &&
+		matchNamespaces(match, event.ns)
 }
 
 // pathBase is a minimal, allocation-free base path extractor for both absolute and relative paths.
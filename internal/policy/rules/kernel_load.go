@@ -0,0 +1,60 @@
+package rules
+
+import (
+	"aegis/internal/platform/events"
+	"aegis/internal/shared/utils"
+)
+
+type kernelLoadMatcher struct {
+	rules []*Rule
+}
+
+type kernelLoadEvent struct {
+	event       *events.KernelLoadEvent
+	processName string
+	name        string
+}
+
+func newKernelLoadMatcher(rules []Rule) *kernelLoadMatcher {
+	matcher := &kernelLoadMatcher{
+		rules: make([]*Rule, 0),
+	}
+	for i := range rules {
+		if rules[i].DeriveType() == RuleTypeKernelLoad {
+			matcher.rules = append(matcher.rules, &rules[i])
+		}
+	}
+	return matcher
+}
+
+func (m *kernelLoadMatcher) Match(event *events.KernelLoadEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	name := utils.ExtractCString(event.Name[:])
+	if event.Hdr.PathCut != 0 {
+		// Only the tail of the module path; no filename rule may match it.
+		name = ""
+	}
+	return filterRulesByAction(m.rules, m.matchRule, kernelLoadEvent{
+		event:       event,
+		processName: processName,
+		name:        name,
+	})
+}
+
+// matchRule treats filename as the module path, so a filename rule never
+// matches BPF loads.
+func (m *kernelLoadMatcher) matchRule(rule *Rule, le kernelLoadEvent) bool {
+	match := rule.Match
+	event := le.event
+	if match.Operation != "" && match.Operation != event.Kind.String() {
+		return false
+	}
+	if match.Filename != "" {
+		if event.Kind != events.LoadKindModule || le.name == "" || utils.CanonicalPath(match.Filename) != le.name {
+			return false
+		}
+	}
+	return (match.ProcessName == "" || matchString(le.processName, match.ProcessName, match.ProcessNameType)) &&
+		matchPID(match.PID, event.Hdr.PID) &&
+		matchCgroupID(match.CgroupID, event.Hdr.CgroupID) &&
+		matchNamespaces(match, event.Hdr.NS)
+}
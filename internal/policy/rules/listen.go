@@ -0,0 +1,43 @@
+package rules
+
+import (
+	"aegis/internal/platform/events"
+)
+
+type listenMatcher struct {
+	rules []*Rule
+}
+
+type listenEvent struct {
+	event       *events.ListenEvent
+	processName string
+}
+
+func newListenMatcher(rules []Rule) *listenMatcher {
+	matcher := &listenMatcher{
+		rules: make([]*Rule, 0),
+	}
+	for i := range rules {
+		if rules[i].DeriveType() == RuleTypeListen {
+			matcher.rules = append(matcher.rules, &rules[i])
+		}
+	}
+	return matcher
+}
+
+func (m *listenMatcher) Match(event *events.ListenEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	return filterRulesByAction(m.rules, m.matchRule, listenEvent{event: event, processName: processName})
+}
+
+// matchRule applies local_port to the bound port and process_name, pid and
+// cgroup_id to the listening process. A rule without local_port covers every
+// port.
+func (m *listenMatcher) matchRule(rule *Rule, le listenEvent) bool {
+	match := rule.Match
+	event := le.event
+	return (match.LocalPort == 0 || event.Port == match.LocalPort) &&
+		(match.ProcessName == "" || matchString(le.processName, match.ProcessName, match.ProcessNameType)) &&
+		matchPID(match.PID, event.Hdr.PID) &&
+		matchCgroupID(match.CgroupID, event.Hdr.CgroupID) &&
+		matchNamespaces(match, event.Hdr.NS)
+}
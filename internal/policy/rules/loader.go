@@ -8,6 +8,8 @@ import (
 	"syscall"
 	"time"
 
+	"aegis/internal/platform/events"
+
 	"gopkg.in/yaml.v3"
 )
 
@@ -155,37 +157,124 @@ func ValidateRules(rules []Rule) []error {
 		}
 
 		if !isValidAction(rule.Action) {
-			errs = append(errs, fmt.Errorf("%s: action must be one of allow, alert, block", displayName))
+			errs = append(errs, fmt.Errorf("%s: action must be one of allow, alert, block, kill, kill_tree", displayName))
+		}
+
+		if rule.Match.HostOnly && rule.Match.ContainerOnly {
+			errs = append(errs, fmt.Errorf("%s: host_only and container_only cannot both be set", displayName))
 		}
 
 		switch rule.DeriveType() {
 		case RuleTypeExec:
 			if !hasExecCondition(rule.Match) {
-				errs = append(errs, fmt.Errorf("%s: exec rules require process_name, parent_name, cgroup_id, pid, or ppid", displayName))
+				errs = append(errs, fmt.Errorf("%s: exec rules require process_name, parent_name, cgroup_id, host_only, container_only, net_ns, pid, ppid, fileless, or deleted_binary", displayName))
 			}
 		case RuleTypeFile:
 			if strings.TrimSpace(rule.Match.Filename) == "" {
 				errs = append(errs, fmt.Errorf("%s: file rules require filename", displayName))
 			}
+			if rule.Match.Operation != "" && !IsFileOperation(rule.Match.Operation) {
+				errs = append(errs, fmt.Errorf("%s: file operation must be one of read, write, delete, rename, chmod", displayName))
+			}
+			if rule.Match.Access != "" {
+				if !IsFileAccess(rule.Match.Access) {
+					errs = append(errs, fmt.Errorf("%s: file access must be one of read, write, append, create, truncate", displayName))
+				} else if op := rule.Match.Operation; op != "" && op != "read" && op != "write" {
+					errs = append(errs, fmt.Errorf("%s: file access only applies to read and write operations", displayName))
+				}
+			}
 		case RuleTypeConnect:
-			if rule.Match.DestPort == 0 && strings.TrimSpace(rule.Match.DestIP) == "" && strings.TrimSpace(rule.Match.ProcessName) == "" {
-				errs = append(errs, fmt.Errorf("%s: connect rules require dest_port, dest_ip, or process_name", displayName))
+			if rule.Match.DestPort == 0 && strings.TrimSpace(rule.Match.DestIP) == "" && strings.TrimSpace(rule.Match.DestDomain) == "" && strings.TrimSpace(rule.Match.DestSocket) == "" && strings.TrimSpace(rule.Match.ProcessName) == "" {
+				errs = append(errs, fmt.Errorf("%s: connect rules require dest_port, dest_ip, dest_domain, dest_socket, or process_name", displayName))
+			}
+			if rule.Match.DestSocket != "" {
+				if !IsSocketPattern(rule.Match.DestSocket) {
+					errs = append(errs, fmt.Errorf("%s: dest_socket %q must be an absolute path or @name shorter than %d bytes", displayName, rule.Match.DestSocket, events.UnixPathLen))
+				}
+				if rule.Match.DestPort != 0 || rule.Match.DestIP != "" || rule.Match.DestDomain != "" {
+					errs = append(errs, fmt.Errorf("%s: dest_socket cannot be combined with dest_port, dest_ip, or dest_domain", displayName))
+				}
+			}
+			if t := rule.Match.DestSocketType; t != "" && t != MatchTypeExact && t != MatchTypePrefix {
+				errs = append(errs, fmt.Errorf("%s: dest_socket_type must be exact or prefix", displayName))
+			}
+			if rule.Match.DestDomain != "" && !IsDomainPattern(rule.Match.DestDomain) {
+				errs = append(errs, fmt.Errorf("%s: dest_domain %q is not a valid domain pattern", displayName, rule.Match.DestDomain))
+			}
+		case RuleTypePrivilege:
+			if rule.Match.Operation != "" && !IsPrivilegeOperation(rule.Match.Operation) {
+				errs = append(errs, fmt.Errorf("%s: privilege operation must be one of setuid, setgid, capset", displayName))
+			}
+			if !rule.Match.hasPrivilegeCriteria() && strings.TrimSpace(rule.Match.ProcessName) == "" {
+				errs = append(errs, fmt.Errorf("%s: privilege rules require operation, from_uid, to_uid, from_gid, to_gid, or process_name", displayName))
+			}
+			if rule.Action.Blocks() {
+				errs = append(errs, fmt.Errorf("%s: privilege rules are observed only; use alert instead of %s", displayName, rule.Action))
+			}
+		case RuleTypeKernelLoad:
+			if rule.Match.Operation != "" && !IsKernelLoadOperation(rule.Match.Operation) {
+				errs = append(errs, fmt.Errorf("%s: kernel_load operation must be module or bpf", displayName))
+			}
+			if rule.Match.Operation == "bpf" && strings.TrimSpace(rule.Match.Filename) != "" {
+				errs = append(errs, fmt.Errorf("%s: filename only applies to module loads", displayName))
+			}
+		case RuleTypePtrace:
+			if strings.TrimSpace(rule.Match.TargetName) == "" && strings.TrimSpace(rule.Match.ProcessName) == "" {
+				errs = append(errs, fmt.Errorf("%s: ptrace rules require target_name or process_name", displayName))
+			}
+		case RuleTypeEscape:
+			errs = append(errs, validateEscapeRule(rule.Match, displayName)...)
+			if rule.Action.Blocks() {
+				errs = append(errs, fmt.Errorf("%s: escape rules are observed only; use alert instead of %s", displayName, rule.Action))
+			}
+		case RuleTypeListen:
+			if rule.Match.DestPort != 0 || rule.Match.DestIP != "" || rule.Match.DestDomain != "" || rule.Match.DestSocket != "" {
+				errs = append(errs, fmt.Errorf("%s: listen rules match local_port, not a destination", displayName))
 			}
 		}
 	}
 	return errs
 }
 
+// validateEscapeRule checks that a rule's namespace and mount criteria fit
+// the operation they are paired with.
+func validateEscapeRule(match MatchCondition, displayName string) []error {
+	var errs []error
+	op := match.Operation
+	if op != "" && !IsEscapeOperation(op) {
+		errs = append(errs, fmt.Errorf("%s: escape operation must be one of setns, unshare, mount, move_mount", displayName))
+	}
+	if match.Namespace != "" && !IsNamespaceKind(match.Namespace) {
+		errs = append(errs, fmt.Errorf("%s: namespace must be one of pid, mnt, net, user, uts", displayName))
+	}
+	nsCriteria := match.Namespace != "" || match.IntoHost
+	mountCriteria := match.MountSource != "" || match.FSType != ""
+	switch {
+	case nsCriteria && mountCriteria:
+		errs = append(errs, fmt.Errorf("%s: namespace and into_host cannot be combined with mount_source or fs_type", displayName))
+	case nsCriteria && (op == "mount" || op == "move_mount"):
+		errs = append(errs, fmt.Errorf("%s: namespace and into_host only apply to setns and unshare", displayName))
+	case mountCriteria && (op == "setns" || op == "unshare"):
+		errs = append(errs, fmt.Errorf("%s: mount_source and fs_type only apply to mount and move_mount", displayName))
+	}
+	return errs
+}
+
 func hasExecCondition(match MatchCondition) bool {
 	return strings.TrimSpace(match.ProcessName) != "" ||
 		strings.TrimSpace(match.ParentName) != "" ||
 		strings.TrimSpace(match.CgroupID) != "" ||
 		match.PID != 0 ||
-		match.PPID != 0
+		match.PPID != 0 ||
+		match.HostOnly ||
+		match.ContainerOnly ||
+		match.NetNS != 0 ||
+		match.Fileless ||
+		match.DeletedBinary
 }
 
 func isValidAction(action ActionType) bool {
-	return action == ActionAllow || action == ActionAlert || action == ActionBlock
+	return action == ActionAllow || action == ActionAlert || action.Blocks()
 }
 
 func ruleDisplayName(name string, idx int) string {
@@ -0,0 +1,51 @@
+package rules
+
+import (
+	"aegis/internal/platform/events"
+)
+
+type privilegeMatcher struct {
+	rules []*Rule
+}
+
+type privilegeEvent struct {
+	event       *events.PrivilegeEvent
+	processName string
+}
+
+func newPrivilegeMatcher(rules []Rule) *privilegeMatcher {
+	matcher := &privilegeMatcher{
+		rules: make([]*Rule, 0),
+	}
+	for i := range rules {
+		if rules[i].DeriveType() == RuleTypePrivilege {
+			matcher.rules = append(matcher.rules, &rules[i])
+		}
+	}
+	return matcher
+}
+
+func (m *privilegeMatcher) Match(event *events.PrivilegeEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	return filterRulesByAction(m.rules, m.matchRule, privilegeEvent{event: event, processName: processName})
+}
+
+// matchRule compares from_* against the real ids before the change and to_*
+// against the effective ids after it, so "from_uid: 1000, to_uid: 0" catches
+// a user gaining root regardless of which id the syscall touched.
+func (m *privilegeMatcher) matchRule(rule *Rule, pe privilegeEvent) bool {
+	match := rule.Match
+	event := pe.event
+	return (match.Operation == "" || match.Operation == event.Op.String()) &&
+		matchID(match.FromUID, event.OldUID) &&
+		matchID(match.ToUID, event.NewEUID) &&
+		matchID(match.FromGID, event.OldGID) &&
+		matchID(match.ToGID, event.NewEGID) &&
+		(match.ProcessName == "" || matchString(pe.processName, match.ProcessName, match.ProcessNameType)) &&
+		matchPID(match.PID, event.Hdr.PID) &&
+		matchCgroupID(match.CgroupID, event.Hdr.CgroupID) &&
+		matchNamespaces(match, event.Hdr.NS)
+}
+
+func matchID(pattern *uint32, id uint32) bool {
+	return pattern == nil || *pattern == id
+}
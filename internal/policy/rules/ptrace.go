@@ -0,0 +1,48 @@
+package rules
+
+import (
+	"aegis/internal/platform/events"
+	"aegis/internal/shared/utils"
+)
+
+type ptraceMatcher struct {
+	rules []*Rule
+}
+
+type ptraceEvent struct {
+	event       *events.PtraceEvent
+	processName string
+	targetName  string
+}
+
+func newPtraceMatcher(rules []Rule) *ptraceMatcher {
+	matcher := &ptraceMatcher{
+		rules: make([]*Rule, 0),
+	}
+	for i := range rules {
+		if rules[i].DeriveType() == RuleTypePtrace {
+			matcher.rules = append(matcher.rules, &rules[i])
+		}
+	}
+	return matcher
+}
+
+func (m *ptraceMatcher) Match(event *events.PtraceEvent, processName string) (matched bool, rule *Rule, allowed bool) {
+	return filterRulesByAction(m.rules, m.matchRule, ptraceEvent{
+		event:       event,
+		processName: processName,
+		targetName:  utils.ExtractCString(event.TargetComm[:]),
+	})
+}
+
+// matchRule applies process_name, pid and cgroup_id to the tracer and
+// target_name to the process being accessed.
+func (m *ptraceMatcher) matchRule(rule *Rule, pe ptraceEvent) bool {
+	match := rule.Match
+	event := pe.event
+	return (match.TargetName == "" || matchString(pe.targetName, match.TargetName, match.TargetNameType)) &&
+		(match.ProcessName == "" || matchString(pe.processName, match.ProcessName, match.ProcessNameType)) &&
+		matchPID(match.PID, event.Hdr.PID) &&
+		matchCgroupID(match.CgroupID, event.Hdr.CgroupID) &&
+		matchNamespaces(match, event.Hdr.NS)
+}
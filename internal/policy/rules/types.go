@@ -1,9 +1,11 @@
 package rules
 
 import (
+	"fmt"
 	"log"
 	"net"
 	"os"
+	"path"
 	"strings"
 	"syscall"
 	"time"
@@ -20,13 +22,31 @@ const (
 	ActionAllow ActionType = "allow"
 	ActionAlert ActionType = "alert"
 	ActionBlock ActionType = "block"
+	// ActionKill denies the operation like block and SIGKILLs the process;
+	// ActionKillTree also kills its descendants.
+	ActionKill     ActionType = "kill"
+	ActionKillTree ActionType = "kill_tree"
 )
 
+// BPF map actions, ordered by strength so merges keep the largest. Mirrors
+// ACTION_* in main.bpf.c.
 const (
-	BPFActionMonitor uint8 = 1
-	BPFActionBlock   uint8 = 2
+	BPFActionMonitor  uint8 = 1
+	BPFActionBlock    uint8 = 2
+	BPFActionKill     uint8 = 3
+	BPFActionKillTree uint8 = 4
 )
 
+// Blocks reports whether the action denies the operation.
+func (a ActionType) Blocks() bool {
+	return a == ActionBlock || a == ActionKill || a == ActionKillTree
+}
+
+// Kills reports whether the action kills the offending process.
+func (a ActionType) Kills() bool {
+	return a == ActionKill || a == ActionKillTree
+}
+
 const (
 	MatchTypeExact    MatchType = "exact"
 	MatchTypeContains MatchType = "contains"
@@ -36,9 +56,14 @@ const (
 type RuleType string
 
 const (
-	RuleTypeExec    RuleType = "exec"
-	RuleTypeFile    RuleType = "file"
-	RuleTypeConnect RuleType = "connect"
+	RuleTypeExec       RuleType = "exec"
+	RuleTypeFile       RuleType = "file"
+	RuleTypeConnect    RuleType = "connect"
+	RuleTypePrivilege  RuleType = "privilege"
+	RuleTypeKernelLoad RuleType = "kernel_load"
+	RuleTypePtrace     RuleType = "ptrace"
+	RuleTypeListen     RuleType = "listen"
+	RuleTypeEscape     RuleType = "escape"
 )
 
 type InodeKey struct {
@@ -100,6 +125,13 @@ func (r *Rule) DeriveType() RuleType {
 	if r.Type != "" {
 		return r.Type
 	}
+	// Module allowlists carry a filename, so check load operations first.
+	if IsKernelLoadOperation(r.Match.Operation) {
+		return RuleTypeKernelLoad
+	}
+	if r.Match.hasEscapeCriteria() {
+		return RuleTypeEscape
+	}
 	// Check filename first (before path keys which require Prepare())
 	if r.Match.Filename != "" {
 		return RuleTypeFile
@@ -107,9 +139,18 @@ func (r *Rule) DeriveType() RuleType {
 	if len(r.Match.ExactPathKeys()) > 0 || len(r.Match.PrefixPathKeys()) > 0 {
 		return RuleTypeFile
 	}
-	if r.Match.DestPort != 0 || r.Match.DestIP != "" {
+	if r.Match.LocalPort != 0 {
+		return RuleTypeListen
+	}
+	if r.Match.DestPort != 0 || r.Match.DestIP != "" || r.Match.DestDomain != "" || r.Match.DestSocket != "" {
 		return RuleTypeConnect
 	}
+	if r.Match.hasPrivilegeCriteria() {
+		return RuleTypePrivilege
+	}
+	if r.Match.TargetName != "" {
+		return RuleTypePtrace
+	}
 	return RuleTypeExec
 }
 
@@ -121,13 +162,35 @@ type MatchCondition struct {
 	PID             uint32     `yaml:"pid,omitempty"`
 	PPID            uint32     `yaml:"ppid,omitempty"`
 	CgroupID        string     `yaml:"cgroup_id,omitempty"`
-	Filename        string     `yaml:"filename,omitempty"`
+	HostOnly        bool       `yaml:"host_only,omitempty"`      // only processes in PID 1's namespaces
+	NetNS           uint32     `yaml:"net_ns,omitempty"`         // network namespace inode
+	ContainerOnly   bool       `yaml:"container_only,omitempty"` // only processes outside PID 1's namespaces
+	Filename        string     `yaml:"filename,omitempty"`       // relative to the daemon's working directory unless absolute; "dir/*" covers the tree below dir
 	DestPort        uint16     `yaml:"dest_port,omitempty"`
 	DestIP          string     `yaml:"dest_ip,omitempty"`
+	DestDomain      string     `yaml:"dest_domain,omitempty"` // e.g. "*.pastebin.com"
+	DestSocket      string     `yaml:"dest_socket,omitempty"` // AF_UNIX path, "@name" for abstract sockets
+	DestSocketType  MatchType  `yaml:"dest_socket_type,omitempty"`
+	LocalPort       uint16     `yaml:"local_port,omitempty"` // port a listen rule applies to
+	Operation       string     `yaml:"operation,omitempty"`
+	Access          string     `yaml:"access,omitempty"`   // open access mode a file rule applies to
+	FromUID         *uint32    `yaml:"from_uid,omitempty"` // real uid before a privilege change
+	ToUID           *uint32    `yaml:"to_uid,omitempty"`   // effective uid after a privilege change
+	FromGID         *uint32    `yaml:"from_gid,omitempty"`
+	ToGID           *uint32    `yaml:"to_gid,omitempty"`
+	TargetName      string     `yaml:"target_name,omitempty"` // process a ptrace rule protects
+	TargetNameType  MatchType  `yaml:"target_name_type,omitempty"`
+	Namespace       string     `yaml:"namespace,omitempty"`      // namespace kind an escape rule's setns/unshare must change
+	IntoHost        bool       `yaml:"into_host,omitempty"`      // setns/unshare that lands in one of PID 1's namespaces
+	MountSource     string     `yaml:"mount_source,omitempty"`   // mount device or source tree, "/dev/*" for a prefix
+	FSType          string     `yaml:"fs_type,omitempty"`        // filesystem type of a mount
+	Fileless        bool       `yaml:"fileless,omitempty"`       // exec from a memfd_create descriptor
+	DeletedBinary   bool       `yaml:"deleted_binary,omitempty"` // exec of an unlinked file
 	destIPNet       *net.IPNet `yaml:"-"`
 	destIPPrepared  bool       `yaml:"-"`
 	inode           InodeKey   `yaml:"-"`
 	inodeResolved   bool       `yaml:"-"`
+	inodeIsDir      bool       `yaml:"-"`
 	pathExactKeys   []string   `yaml:"-"`
 	pathPrefixKeys  []string   `yaml:"-"`
 }
@@ -169,6 +232,137 @@ func (m *MatchCondition) Prepare() {
 	}
 }
 
+// IsPrivilegeOperation reports whether op names a credential hook.
+func IsPrivilegeOperation(op string) bool {
+	switch op {
+	case "setuid", "setgid", "capset":
+		return true
+	default:
+		return false
+	}
+}
+
+// IsFileOperation reports whether op names a file operation.
+func IsFileOperation(op string) bool {
+	_, ok := events.ParseFileOp(op)
+	return ok
+}
+
+// IsFileAccess reports whether access names an open access mode.
+func IsFileAccess(access string) bool {
+	switch access {
+	case "read", "write", "append", "create", "truncate":
+		return true
+	default:
+		return false
+	}
+}
+
+// matchAccess reports whether open flags grant the named access mode.
+func matchAccess(access string, flags uint32) bool {
+	accmode := flags & syscall.O_ACCMODE
+	switch access {
+	case "read":
+		return accmode == syscall.O_RDONLY || accmode == syscall.O_RDWR
+	case "write":
+		return accmode == syscall.O_WRONLY || accmode == syscall.O_RDWR
+	case "append":
+		return flags&syscall.O_APPEND != 0
+	case "create":
+		return flags&syscall.O_CREAT != 0
+	case "truncate":
+		return flags&syscall.O_TRUNC != 0
+	default:
+		return false
+	}
+}
+
+// IsKernelLoadOperation reports whether op names a kernel load kind.
+func IsKernelLoadOperation(op string) bool {
+	return op == "module" || op == "bpf"
+}
+
+// IsEscapeOperation reports whether op names a namespace or mount call.
+func IsEscapeOperation(op string) bool {
+	switch op {
+	case "setns", "unshare", "mount", "move_mount":
+		return true
+	default:
+		return false
+	}
+}
+
+// IsNamespaceKind reports whether kind names a namespace setns and unshare
+// can change.
+func IsNamespaceKind(kind string) bool {
+	return events.NamespaceFlag(kind) != 0
+}
+
+func (m *MatchCondition) hasEscapeCriteria() bool {
+	return IsEscapeOperation(m.Operation) || m.Namespace != "" || m.IntoHost ||
+		m.MountSource != "" || m.FSType != ""
+}
+
+func (m *MatchCondition) hasPrivilegeCriteria() bool {
+	return m.FromUID != nil || m.ToUID != nil || m.FromGID != nil || m.ToGID != nil ||
+		IsPrivilegeOperation(m.Operation)
+}
+
+// MatchDomain reports whether domain satisfies DestDomain. "*" matches any
+// run of characters, so "*.example.com" matches every subdomain but not
+// example.com itself. An unknown domain never matches.
+func (m *MatchCondition) MatchDomain(domain string) bool {
+	if m == nil || m.DestDomain == "" {
+		return true
+	}
+	domain = NormalizeDomain(domain)
+	if domain == "" {
+		return false
+	}
+	matched, err := path.Match(NormalizeDomain(m.DestDomain), domain)
+	return err == nil && matched
+}
+
+// NormalizeDomain lower-cases a domain and drops its trailing dot.
+func NormalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// IsDomainPattern reports whether pattern is a usable dest_domain value.
+func IsDomainPattern(pattern string) bool {
+	pattern = NormalizeDomain(pattern)
+	if pattern == "" || strings.ContainsAny(pattern, "/ ") {
+		return false
+	}
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// MatchSocket reports whether an AF_UNIX socket path satisfies DestSocket.
+// Only exact and prefix matches are supported, so the kernel can enforce
+// them; exact is the default.
+func (m *MatchCondition) MatchSocket(socketPath string) bool {
+	if m == nil || m.DestSocket == "" {
+		return true
+	}
+	if socketPath == "" {
+		return false
+	}
+	if m.DestSocketType == MatchTypePrefix {
+		return strings.HasPrefix(socketPath, m.DestSocket)
+	}
+	return socketPath == m.DestSocket
+}
+
+// IsSocketPattern reports whether path is a usable dest_socket value: an
+// absolute path or an abstract "@name" that fits in sun_path.
+func IsSocketPattern(path string) bool {
+	if len(path) < 2 || len(path) >= events.UnixPathLen {
+		return false
+	}
+	return path[0] == '/' || path[0] == '@'
+}
+
 func (m *MatchCondition) MatchIP(eventIP string) bool {
 	if m == nil || m.DestIP == "" {
 		return true
@@ -181,7 +375,20 @@ func (m *MatchCondition) MatchIP(eventIP string) bool {
 }
 
 func (m *MatchCondition) InodeKey() (InodeKey, bool) {
-	if m == nil || !m.inodeResolved {
+	if m == nil || !m.inodeResolved || m.inodeIsDir {
+		return InodeKey{}, false
+	}
+	return m.inode, true
+}
+
+// DirInodeKey returns the directory inode watched by a wildcard filename
+// rule such as "/var/log/*". The kernel only finds it among the first 16
+// ancestors of a file on the same mount, so deeper files and files under a
+// nested mount are matched by path here but never blocked. The inode is
+// looked up when the rule is prepared; RefreshDirInode picks up a directory
+// renamed, removed or created since.
+func (m *MatchCondition) DirInodeKey() (InodeKey, bool) {
+	if m == nil || !m.inodeResolved || !m.inodeIsDir {
 		return InodeKey{}, false
 	}
 	return m.inode, true
@@ -205,28 +412,75 @@ func (m *MatchCondition) prepareInode() {
 	if m.inodeResolved {
 		return
 	}
+	inode, isDir, err := m.statInode()
+	if err != nil {
+		log.Printf("Skipping file rule for %s: %v", strings.TrimSpace(m.Filename), err)
+		return
+	}
+	if inode == nil {
+		return
+	}
+	m.inode = *inode
+	m.inodeIsDir = isDir
+	m.inodeResolved = true
+}
 
-	path := m.Filename
+// RefreshDirInode re-resolves the directory a "/*" rule watches and reports
+// whether its inode changed since the rule was prepared: the directory was
+// renamed or removed, or created after the rules were loaded.
+func (m *MatchCondition) RefreshDirInode() bool {
+	if m == nil {
+		return false
+	}
+	if _, ok := dirWildcard(strings.TrimSpace(m.Filename)); !ok {
+		return false
+	}
+	inode, _, err := m.statInode()
+	if err != nil || inode == nil {
+		changed := m.inodeResolved
+		m.inode, m.inodeIsDir, m.inodeResolved = InodeKey{}, false, false
+		return changed
+	}
+	if m.inodeResolved && m.inode == *inode {
+		return false
+	}
+	m.inode, m.inodeIsDir, m.inodeResolved = *inode, true, true
+	return true
+}
+
+// statInode looks up the inode a file rule matches by: the file itself, or
+// the directory of a "/*" pattern. It returns nil without an error for
+// patterns that only match by name.
+func (m *MatchCondition) statInode() (*InodeKey, bool, error) {
+	path := strings.TrimSpace(m.Filename)
 	if path == "" {
-		return
+		return nil, false, nil
+	}
+
+	isDir := false
+	if strings.HasSuffix(path, "*") {
+		// Name globs such as "/var/log/app*" are matched in userspace only;
+		// their stem is not a directory to watch.
+		dir, ok := dirWildcard(path)
+		if !ok {
+			return nil, false, nil
+		}
+		path = dir
+		isDir = true
 	}
 
 	info, err := os.Stat(path)
 	if err != nil {
-		log.Printf("Skipping file rule for %s: %v", path, err)
-		return
+		return nil, false, err
+	}
+	if isDir && !info.IsDir() {
+		return nil, false, fmt.Errorf("not a directory")
 	}
 	stat, ok := info.Sys().(*syscall.Stat_t)
 	if !ok {
-		log.Printf("Skipping file rule for %s: unsupported stat type", path)
-		return
-	}
-
-	m.inode = InodeKey{
-		Ino: stat.Ino,
-		Dev: uint64(stat.Dev),
+		return nil, false, fmt.Errorf("unsupported stat type")
 	}
-	m.inodeResolved = true
+	return &InodeKey{Ino: stat.Ino, Dev: uint64(stat.Dev)}, isDir, nil
 }
 
 func (m *MatchCondition) prepareFilenameKeys(raw string) {
@@ -237,12 +491,16 @@ func (m *MatchCondition) prepareFilenameKeys(raw string) {
 		return
 	}
 
+	if dir, ok := dirWildcard(path); ok {
+		m.pathExactKeys = nil
+		m.pathPrefixKeys = normalizePrefixVariants(utils.PathVariants(dir))
+		return
+	}
 	if strings.HasSuffix(path, "*") {
-		base := strings.TrimSuffix(path, "*")
-		base = strings.TrimSuffix(base, "/")
-		variants := utils.PathVariants(base)
+		// A name prefix: "/var/log/app*" covers "/var/log/app.log" too, so
+		// no trailing slash is added.
 		m.pathExactKeys = nil
-		m.pathPrefixKeys = normalizePrefixVariants(variants)
+		m.pathPrefixKeys = utils.PathVariants(strings.TrimSuffix(path, "*"))
 		return
 	}
 
@@ -251,6 +509,19 @@ func (m *MatchCondition) prepareFilenameKeys(raw string) {
 	m.pathPrefixKeys = nil
 }
 
+// dirWildcard returns the directory a "/*" pattern such as "/var/log/*"
+// covers. Other trailing globs are name prefixes and report false.
+func dirWildcard(path string) (string, bool) {
+	if !strings.HasSuffix(path, "/*") {
+		return "", false
+	}
+	dir := strings.TrimSuffix(path, "/*")
+	if dir == "" {
+		dir = "/"
+	}
+	return dir, true
+}
+
 func normalizePrefixVariants(variants []string) []string {
 	if len(variants) == 0 {
 		return nil
@@ -3,6 +3,8 @@ package rules
 import (
 	"strconv"
 	"strings"
+
+	"aegis/internal/platform/events"
 )
 
 // match a value against a pattern using the specified match type.
@@ -23,6 +25,18 @@ func matchCgroupID(pattern string, cgroupID uint64) bool {
 	return pattern == "" || strconv.FormatUint(cgroupID, 10) == pattern
 }
 
+// matchNamespaces checks host_only, container_only and net_ns. Events
+// without namespace information satisfy none of them.
+func matchNamespaces(match MatchCondition, ns events.Namespaces) bool {
+	if match.HostOnly && !ns.IsHost() {
+		return false
+	}
+	if match.ContainerOnly && (!ns.Known() || ns.IsHost()) {
+		return false
+	}
+	return match.NetNS == 0 || ns.Net == match.NetNS
+}
+
 func matchPID(pattern uint32, pid uint32) bool {
 	return pattern == 0 || pid == pattern
 }
@@ -49,7 +63,7 @@ func filterRulesByAction[T any](rules []*Rule, matchFn func(*Rule, T) bool, even
 		switch r.Action {
 		case ActionAllow:
 			return true, r, true
-		case ActionBlock:
+		case ActionBlock, ActionKill, ActionKillTree:
 			if blockRule == nil {
 				blockRule = r
 			}
@@ -2,13 +2,17 @@ package policy
 
 import (
 	"fmt"
+	"log"
+	"net"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"aegis/internal/platform/events"
 	"aegis/internal/platform/storage"
 	"aegis/internal/policy/rules"
+	"aegis/internal/shared/utils"
 	"aegis/internal/system"
 	"aegis/internal/telemetry"
 )
@@ -19,7 +23,58 @@ type RuleRepository interface {
 }
 
 type KernelSync interface {
-	SyncRules([]Rule) error
+	SyncRules([]Rule) (SyncReport, error)
+}
+
+// DomainSync is implemented by kernel syncs that can enforce dest_domain
+// rules on the addresses a domain was resolved to. SyncDomainAddrs adds the
+// addresses of one DNS answer; ResyncDomainAddrs replaces all of them after a
+// rule change.
+type DomainSync interface {
+	SyncDomainAddrs(matched []Rule, addrs []net.IP) error
+	ResyncDomainAddrs(resolved []DomainAddrs) ([]MapSyncStats, error)
+}
+
+// DomainAddrs is a resolved domain's addresses with the dest_domain rules the
+// domain matches.
+type DomainAddrs struct {
+	Rules []Rule
+	Addrs []net.IP
+}
+
+// DomainResolver lists the addresses domains currently resolve to, as seen
+// in DNS answers.
+type DomainResolver interface {
+	ResolvedDomains() map[string][]net.IP
+}
+
+// MapSyncStats counts the entries written to or removed from one kernel map
+// during a sync.
+type MapSyncStats struct {
+	Map     string `json:"map"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Changed int    `json:"changed"`
+	Total   int    `json:"total"`
+}
+
+// SyncReport describes the most recent rule sync into the kernel. Warning is
+// set when active block rules cannot be enforced.
+type SyncReport struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Maps      []MapSyncStats `json:"maps"`
+	Error     string         `json:"error,omitempty"`
+	Warning   string         `json:"warning,omitempty"`
+}
+
+// Changed reports whether the sync modified any kernel map.
+func (r SyncReport) Changed() bool {
+	for _, m := range r.Maps {
+		if m.Added+m.Removed+m.Changed > 0 {
+			return true
+		}
+	}
+	return false
 }
 
 type DecisionType string
@@ -42,11 +97,14 @@ type Service struct {
 	mu             sync.RWMutex
 	repo           RuleRepository
 	kernelSync     KernelSync
+	resolver       DomainResolver
+	lastSync       SyncReport
 	ruleList       []Rule
 	engine         *rules.Engine
 	validation     *rules.ValidationService
 	observationMin int
 	minHits        int
+	monitorOnly    bool
 }
 
 func NewService(repo RuleRepository, kernelSync KernelSync, observationMin int, minHits int) *Service {
@@ -64,6 +122,23 @@ func (s *Service) SetKernelSync(kernelSync KernelSync) {
 	s.kernelSync = kernelSync
 }
 
+// SetDomainResolver sets where rule changes take the already resolved
+// addresses of dest_domain rules from.
+func (s *Service) SetDomainResolver(resolver DomainResolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resolver = resolver
+}
+
+// SetMonitorOnly tells the service whether the kernel only observes. While
+// set, block, kill and kill_tree rules are evaluated as alert rules and their
+// alerts say the operation was not blocked.
+func (s *Service) SetMonitorOnly(monitorOnly bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.monitorOnly = monitorOnly
+}
+
 func (s *Service) UpdateThresholds(observationMin, minHits int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -250,12 +325,26 @@ func (s *Service) TestingRules() []TestingRuleStatus {
 func (s *Service) Evaluate(record *telemetry.Record) Decision {
 	s.mu.RLock()
 	engine := s.engine
+	kernelSync := s.kernelSync
+	monitorOnly := s.monitorOnly
 	s.mu.RUnlock()
 	if engine == nil || record == nil {
 		return Decision{Type: DecisionNoMatch}
 	}
 	event := &record.Event
+	if event.SelfProtect {
+		return selfProtectDecision(event)
+	}
 
+	decision := s.evaluate(engine, kernelSync, record)
+	if monitorOnly {
+		return monitorOnlyDecision(decision)
+	}
+	return decision
+}
+
+func (s *Service) evaluate(engine *rules.Engine, kernelSync KernelSync, record *telemetry.Record) Decision {
+	event := &record.Event
 	switch event.Type {
 	case telemetry.EventTypeExec:
 		return s.evaluateExec(engine, record)
@@ -263,6 +352,18 @@ func (s *Service) Evaluate(record *telemetry.Record) Decision {
 		return s.evaluateFile(engine, record)
 	case telemetry.EventTypeConnect:
 		return s.evaluateConnect(engine, record)
+	case telemetry.EventTypePrivilege:
+		return s.evaluatePrivilege(engine, record)
+	case telemetry.EventTypeKernelLoad:
+		return s.evaluateKernelLoad(engine, record)
+	case telemetry.EventTypePtrace:
+		return s.evaluatePtrace(engine, record)
+	case telemetry.EventTypeDNS:
+		return s.evaluateDNS(engine, kernelSync, record)
+	case telemetry.EventTypeListen:
+		return s.evaluateListen(engine, record)
+	case telemetry.EventTypeNamespace:
+		return s.evaluateEscape(engine, record)
 	default:
 		return Decision{Type: DecisionNoMatch}
 	}
@@ -280,13 +381,94 @@ func (s *Service) replaceRulesLocked(ruleList []Rule) error {
 	s.engine = engine
 	s.validation = rules.NewValidationService(engine.GetTestingBuffer(), s.observationMin, s.minHits)
 	if s.kernelSync != nil {
-		if err := s.kernelSync.SyncRules(ruleList); err != nil {
+		report, err := s.kernelSync.SyncRules(ruleList)
+		if err == nil {
+			var stats []MapSyncStats
+			stats, err = s.resyncDomainAddrsLocked(engine)
+			report.Maps = append(report.Maps, stats...)
+		}
+		if report.Timestamp.IsZero() {
+			report.Timestamp = time.Now()
+		}
+		if err != nil {
+			report.Error = err.Error()
+		}
+		if s.monitorOnly {
+			report.Warning = monitorOnlyWarning(ruleList)
+		}
+		s.lastSync = report
+		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// RefreshWatchedDirs re-resolves the directories "/*" file rules watch.
+// monitored_dirs is keyed by inode, so when one was renamed, removed or
+// created since the rules were loaded, the rules are rebuilt and the kernel
+// maps resynced. It reports whether anything changed.
+func (s *Service) RefreshWatchedDirs() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ruleList := append([]Rule(nil), s.ruleList...)
+	changed := false
+	for i := range ruleList {
+		if ruleList[i].Match.RefreshDirInode() {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, s.replaceRulesLocked(ruleList)
+}
+
+// resyncDomainAddrsLocked matches the cached DNS answers against the new
+// rules and hands the result to the kernel, so addresses stay enforced for
+// the rules that still want them and no others.
+func (s *Service) resyncDomainAddrsLocked(engine *rules.Engine) ([]MapSyncStats, error) {
+	domainSync, ok := s.kernelSync.(DomainSync)
+	if !ok {
+		return nil, nil
+	}
+	var resolved []DomainAddrs
+	if s.resolver != nil {
+		for domain, addrs := range s.resolver.ResolvedDomains() {
+			if matched := engine.DomainRules(domain); len(matched) > 0 {
+				resolved = append(resolved, DomainAddrs{Rules: matched, Addrs: addrs})
+			}
+		}
+	}
+	return domainSync.ResyncDomainAddrs(resolved)
+}
+
+// monitorOnlyWarning describes the production block rules that are
+// downgraded to alerts, or returns "" when there are none.
+func monitorOnlyWarning(ruleList []Rule) string {
+	count := 0
+	for _, rule := range ruleList {
+		if rule.IsProduction() && rule.Action.Blocks() {
+			count++
+		}
+	}
+	if count == 0 {
+		return ""
+	}
+	warning := fmt.Sprintf("BPF LSM is not enabled: %d block rules only alert", count)
+	log.Printf("Warning: %s", warning)
+	return warning
+}
+
+// LastSync returns the report of the most recent kernel map sync.
+func (s *Service) LastSync() SyncReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	report := s.lastSync
+	report.Maps = append([]MapSyncStats(nil), s.lastSync.Maps...)
+	return report
+}
+
 func (s *Service) saveAndReplaceLocked(ruleList []Rule) error {
 	if err := s.repo.Save(ruleList); err != nil {
 		return err
@@ -324,7 +506,7 @@ func (s *Service) evaluateExec(engine *rules.Engine, record *telemetry.Record) D
 			ProcessName: event.ProcessName,
 			ParentName:  event.ParentName,
 			CgroupID:    strconv.FormatUint(event.CgroupID, 10),
-			Action:      "block",
+			Action:      event.Response,
 			Blocked:     true,
 		}
 		return Decision{Type: DecisionBlock, Alerts: []system.Alert{alert}}
@@ -344,7 +526,7 @@ func (s *Service) evaluateExec(engine *rules.Engine, record *telemetry.Record) D
 		if event.Blocked && severity != "critical" {
 			severity = "critical"
 		}
-		if alert.Rule.Action == rules.ActionBlock || event.Blocked {
+		if alert.Rule.Action.Blocks() || event.Blocked {
 			decisionType = DecisionBlock
 		}
 		out = append(out, system.Alert{
@@ -357,7 +539,7 @@ func (s *Service) evaluateExec(engine *rules.Engine, record *telemetry.Record) D
 			ProcessName: event.ProcessName,
 			ParentName:  event.ParentName,
 			CgroupID:    strconv.FormatUint(event.CgroupID, 10),
-			Action:      string(alert.Rule.Action),
+			Action:      alertAction(alert.Rule.Action, event),
 			Blocked:     event.Blocked,
 		})
 	}
@@ -371,9 +553,14 @@ func (s *Service) evaluateFile(engine *rules.Engine, record *telemetry.Record) D
 		return Decision{Type: DecisionNoMatch}
 	}
 
-	matched, rule, allowed := engine.MatchFile(raw.Ino, raw.Dev, event.Filename, raw.Hdr.PID, raw.Hdr.CgroupID)
+	operation, target := "access", event.Filename
+	if event.Operation != "" {
+		operation = event.Operation
+		target = fmt.Sprintf("%s %s", event.Operation, event.Filename)
+	}
+	matched, rule, allowed := engine.MatchFileOpen(&raw, event.Filename)
 	if event.Blocked && (!matched || rule == nil) {
-		return blockedKernelDecision("file", "Kernel Blocked File Access", fmt.Sprintf("File access blocked by kernel: %s", event.Filename), event)
+		return blockedKernelDecision("file", "Kernel Blocked File Access", fmt.Sprintf("File %s blocked by kernel: %s", operation, event.Filename), event)
 	}
 	if !matched || rule == nil {
 		return Decision{Type: DecisionNoMatch}
@@ -385,7 +572,7 @@ func (s *Service) evaluateFile(engine *rules.Engine, record *telemetry.Record) D
 		recordTestingHit(engine, rule.Name, raw.Hdr.Timestamp(), events.EventTypeFileOpen, &raw, raw.Hdr.PID, event.ProcessName)
 		return Decision{Type: DecisionTestingHit, Rule: rule}
 	}
-	return ruleAlertDecision("file", fmt.Sprintf("%s: %s", rule.Description, event.Filename), event, rule)
+	return ruleAlertDecision("file", fmt.Sprintf("%s: %s", rule.Description, target), event, rule)
 }
 
 func (s *Service) evaluateConnect(engine *rules.Engine, record *telemetry.Record) Decision {
@@ -395,9 +582,13 @@ func (s *Service) evaluateConnect(engine *rules.Engine, record *telemetry.Record
 		return Decision{Type: DecisionNoMatch}
 	}
 
-	matched, rule, allowed := engine.MatchConnect(&raw)
+	target := event.Address
+	if event.Domain != "" {
+		target = fmt.Sprintf("%s (%s)", event.Domain, event.Address)
+	}
+	matched, rule, allowed := engine.MatchConnect(&raw, event.Domain)
 	if event.Blocked && (!matched || rule == nil) {
-		return blockedKernelDecision("net", "Kernel Blocked Connection", fmt.Sprintf("Network connection blocked by kernel: %s", event.Address), event)
+		return blockedKernelDecision("net", "Kernel Blocked Connection", fmt.Sprintf("Network connection blocked by kernel: %s", target), event)
 	}
 	if !matched || rule == nil {
 		return Decision{Type: DecisionNoMatch}
@@ -412,6 +603,194 @@ func (s *Service) evaluateConnect(engine *rules.Engine, record *telemetry.Record
 	return ruleAlertDecision("net", rule.Description, event, rule)
 }
 
+// evaluateDNS hands the addresses in a DNS reply to the kernel when the name
+// matches dest_domain rules, so the connect that follows is reported, and
+// blocked, although the kernel never sees the name. A connect racing ahead
+// of the reply being processed is only caught by later connects.
+func (s *Service) evaluateDNS(engine *rules.Engine, kernelSync KernelSync, record *telemetry.Record) Decision {
+	event := &record.Event
+	if event.Operation != "response" || event.Domain == "" || event.DNS == nil || len(event.DNS.Answers) == 0 {
+		return Decision{Type: DecisionNoMatch}
+	}
+	domainSync, ok := kernelSync.(DomainSync)
+	if !ok {
+		return Decision{Type: DecisionNoMatch}
+	}
+	matched := engine.DomainRules(event.Domain)
+	if len(matched) == 0 {
+		return Decision{Type: DecisionNoMatch}
+	}
+
+	addrs := make([]net.IP, 0, len(event.DNS.Answers))
+	for _, answer := range event.DNS.Answers {
+		if ip := net.ParseIP(answer); ip != nil {
+			addrs = append(addrs, ip)
+		}
+	}
+	if err := domainSync.SyncDomainAddrs(matched, addrs); err != nil {
+		log.Printf("Warning: failed to sync addresses for %s: %v", event.Domain, err)
+	}
+	return Decision{Type: DecisionNoMatch}
+}
+
+func (s *Service) evaluatePrivilege(engine *rules.Engine, record *telemetry.Record) Decision {
+	event := &record.Event
+	raw, ok := eventFromRawPrivilege(record)
+	if !ok {
+		return Decision{Type: DecisionNoMatch}
+	}
+
+	matched, rule, allowed := engine.MatchPrivilege(&raw, event.ProcessName)
+	if !matched || rule == nil {
+		return Decision{Type: DecisionNoMatch}
+	}
+	if allowed {
+		return Decision{Type: DecisionAllow, Rule: rule}
+	}
+	if rule.IsTesting() {
+		recordTestingHit(engine, rule.Name, raw.Hdr.Timestamp(), events.EventTypePrivilege, &raw, raw.Hdr.PID, event.ProcessName)
+		return Decision{Type: DecisionTestingHit, Rule: rule}
+	}
+	description := fmt.Sprintf("%s: %s uid %d->%d gid %d->%d", rule.Description, raw.Op, raw.OldUID, raw.NewEUID, raw.OldGID, raw.NewEGID)
+	return ruleAlertDecision("priv", description, event, rule)
+}
+
+func (s *Service) evaluateEscape(engine *rules.Engine, record *telemetry.Record) Decision {
+	event := &record.Event
+	raw, ok := eventFromRawNamespace(record)
+	if !ok {
+		return Decision{Type: DecisionNoMatch}
+	}
+
+	matched, rule, allowed := engine.MatchEscape(&raw, event.ProcessName)
+	if !matched || rule == nil {
+		return Decision{Type: DecisionNoMatch}
+	}
+	if allowed {
+		return Decision{Type: DecisionAllow, Rule: rule}
+	}
+	if rule.IsTesting() {
+		recordTestingHit(engine, rule.Name, raw.Hdr.Timestamp(), events.EventTypeNamespace, &raw, raw.Hdr.PID, event.ProcessName)
+		return Decision{Type: DecisionTestingHit, Rule: rule}
+	}
+	var description string
+	if raw.Op.IsMount() {
+		description = fmt.Sprintf("%s: %s of %s (%s) on %s", rule.Description, raw.Op,
+			utils.ExtractCString(raw.Source[:]), utils.ExtractCString(raw.FSType[:]), event.Filename)
+	} else {
+		description = fmt.Sprintf("%s: %s into %s namespace", rule.Description, raw.Op,
+			strings.Join(events.NamespaceKinds(raw.Flags), ","))
+		if raw.IntoHost() {
+			description += " of the host"
+		}
+	}
+	return ruleAlertDecision("escape", description, event, rule)
+}
+
+func (s *Service) evaluateKernelLoad(engine *rules.Engine, record *telemetry.Record) Decision {
+	event := &record.Event
+	raw, ok := eventFromRawKernelLoad(record)
+	if !ok {
+		return Decision{Type: DecisionNoMatch}
+	}
+
+	target := event.Filename
+	if raw.Kind == events.LoadKindBPF && event.KernelLoad != nil {
+		target = fmt.Sprintf("%s program %q", event.KernelLoad.ProgType, event.KernelLoad.ProgName)
+	}
+	if target == "" {
+		target = "(in-memory image)"
+	}
+	matched, rule, allowed := engine.MatchKernelLoad(&raw, event.ProcessName)
+	if event.Blocked && (!matched || rule == nil) {
+		ruleName := "Kernel Blocked Module Load"
+		if raw.Kind == events.LoadKindBPF {
+			ruleName = "Kernel Blocked BPF Load"
+		}
+		return blockedKernelDecision("load", ruleName, fmt.Sprintf("%s load blocked by kernel: %s", raw.Kind, target), event)
+	}
+	if !matched || rule == nil {
+		return Decision{Type: DecisionNoMatch}
+	}
+	if allowed {
+		return Decision{Type: DecisionAllow, Rule: rule}
+	}
+	if rule.IsTesting() {
+		recordTestingHit(engine, rule.Name, raw.Hdr.Timestamp(), events.EventTypeKernelLoad, &raw, raw.Hdr.PID, event.ProcessName)
+		return Decision{Type: DecisionTestingHit, Rule: rule}
+	}
+	return ruleAlertDecision("load", fmt.Sprintf("%s: %s", rule.Description, target), event, rule)
+}
+
+func (s *Service) evaluatePtrace(engine *rules.Engine, record *telemetry.Record) Decision {
+	event := &record.Event
+	raw, ok := eventFromRawPtrace(record)
+	if !ok {
+		return Decision{Type: DecisionNoMatch}
+	}
+
+	target := fmt.Sprintf("%s (pid %d)", utils.ExtractCString(raw.TargetComm[:]), raw.TargetPID)
+	matched, rule, allowed := engine.MatchPtrace(&raw, event.ProcessName)
+	if event.Blocked && (!matched || rule == nil) {
+		return blockedKernelDecision("ptrace", "Kernel Blocked Ptrace", fmt.Sprintf("Ptrace %s of %s blocked by kernel", raw.Mode, target), event)
+	}
+	if !matched || rule == nil {
+		return Decision{Type: DecisionNoMatch}
+	}
+	if allowed {
+		return Decision{Type: DecisionAllow, Rule: rule}
+	}
+	if rule.IsTesting() {
+		recordTestingHit(engine, rule.Name, raw.Hdr.Timestamp(), events.EventTypePtrace, &raw, raw.Hdr.PID, event.ProcessName)
+		return Decision{Type: DecisionTestingHit, Rule: rule}
+	}
+	return ruleAlertDecision("ptrace", fmt.Sprintf("%s: %s", rule.Description, target), event, rule)
+}
+
+// evaluateListen checks binds and listens; closes only update the listener
+// inventory.
+func (s *Service) evaluateListen(engine *rules.Engine, record *telemetry.Record) Decision {
+	event := &record.Event
+	raw, ok := eventFromRawListen(record)
+	if !ok || raw.Op == events.ListenOpClose {
+		return Decision{Type: DecisionNoMatch}
+	}
+
+	target := fmt.Sprintf("%s %s", event.Protocol, event.Address)
+	matched, rule, allowed := engine.MatchListen(&raw, event.ProcessName)
+	if event.Blocked && (!matched || rule == nil) {
+		return blockedKernelDecision("listen", "Kernel Blocked Listen", fmt.Sprintf("Listen on %s blocked by kernel", target), event)
+	}
+	if !matched || rule == nil {
+		return Decision{Type: DecisionNoMatch}
+	}
+	if allowed {
+		return Decision{Type: DecisionAllow, Rule: rule}
+	}
+	if rule.IsTesting() {
+		recordTestingHit(engine, rule.Name, raw.Hdr.Timestamp(), events.EventTypeListen, &raw, raw.Hdr.PID, event.ProcessName)
+		return Decision{Type: DecisionTestingHit, Rule: rule}
+	}
+	return ruleAlertDecision("listen", fmt.Sprintf("%s: %s", rule.Description, target), event, rule)
+}
+
+// selfProtectDecision raises a critical alert for a signal, ptrace or BPF link
+// change the kernel denied because it targeted Aegis itself.
+func selfProtectDecision(event *telemetry.Event) Decision {
+	var what string
+	switch {
+	case event.SignalSent != nil:
+		what = fmt.Sprintf("%s to Aegis (pid %d)", event.SignalSent.Name, event.SignalSent.TargetPID)
+	case event.Ptrace != nil:
+		what = fmt.Sprintf("Ptrace %s of Aegis (pid %d)", event.Ptrace.Mode, event.Ptrace.TargetPID)
+	case event.KernelLoad != nil && event.KernelLoad.Command != "":
+		what = fmt.Sprintf("BPF %s on an Aegis link", event.KernelLoad.Command)
+	default:
+		what = fmt.Sprintf("%s event against Aegis", event.Type)
+	}
+	return blockedKernelDecision("protect", "Aegis Self-Protection", what+" blocked by self-protection", event)
+}
+
 func alertID(prefix string, pid uint32) string {
 	return fmt.Sprintf("%s-%d-%d", prefix, pid, time.Now().UnixNano())
 }
@@ -440,6 +819,46 @@ func eventFromRawConnect(record *telemetry.Record) (events.ConnectEvent, bool) {
 	return storage.ConnectPayload(raw)
 }
 
+func eventFromRawPrivilege(record *telemetry.Record) (events.PrivilegeEvent, bool) {
+	raw, ok := rawEvent(record)
+	if !ok {
+		return events.PrivilegeEvent{}, false
+	}
+	return storage.PrivilegePayload(raw)
+}
+
+func eventFromRawKernelLoad(record *telemetry.Record) (events.KernelLoadEvent, bool) {
+	raw, ok := rawEvent(record)
+	if !ok {
+		return events.KernelLoadEvent{}, false
+	}
+	return storage.KernelLoadPayload(raw)
+}
+
+func eventFromRawPtrace(record *telemetry.Record) (events.PtraceEvent, bool) {
+	raw, ok := rawEvent(record)
+	if !ok {
+		return events.PtraceEvent{}, false
+	}
+	return storage.PtracePayload(raw)
+}
+
+func eventFromRawListen(record *telemetry.Record) (events.ListenEvent, bool) {
+	raw, ok := rawEvent(record)
+	if !ok {
+		return events.ListenEvent{}, false
+	}
+	return storage.ListenPayload(raw)
+}
+
+func eventFromRawNamespace(record *telemetry.Record) (events.NamespaceEvent, bool) {
+	raw, ok := rawEvent(record)
+	if !ok {
+		return events.NamespaceEvent{}, false
+	}
+	return storage.NamespacePayload(raw)
+}
+
 func rawEvent(record *telemetry.Record) (*storage.Event, bool) {
 	if record == nil || record.Raw == nil {
 		return nil, false
@@ -458,12 +877,40 @@ func blockedKernelDecision(prefix, ruleName, description string, event *telemetr
 		ProcessName: event.ProcessName,
 		ParentName:  event.ParentName,
 		CgroupID:    strconv.FormatUint(event.CgroupID, 10),
-		Action:      "block",
+		Action:      event.Response,
 		Blocked:     true,
 	}
 	return Decision{Type: DecisionBlock, Alerts: []system.Alert{alert}}
 }
 
+// monitorOnlyDecision turns a block decision into the alert it amounts to
+// when the kernel only observes.
+func monitorOnlyDecision(decision Decision) Decision {
+	if decision.Type != DecisionBlock {
+		return decision
+	}
+	decision.Type = DecisionAlert
+	alerts := make([]system.Alert, len(decision.Alerts))
+	for i, alert := range decision.Alerts {
+		if rules.ActionType(alert.Action).Blocks() {
+			alert.Action = string(ActionAlert)
+			alert.Description += " (not blocked: BPF LSM is not enabled, monitor-only mode)"
+		}
+		alerts[i] = alert
+	}
+	decision.Alerts = alerts
+	return decision
+}
+
+// alertAction is the rule's action, unless the kernel killed the process, in
+// which case the kill it carried out is reported instead.
+func alertAction(action rules.ActionType, event *telemetry.Event) string {
+	if rules.ActionType(event.Response).Kills() {
+		return event.Response
+	}
+	return string(action)
+}
+
 func recordTestingHit(engine *rules.Engine, ruleName string, hitTime time.Time, eventType events.EventType, eventData any, pid uint32, processName string) {
 	if testingBuffer := engine.GetTestingBuffer(); testingBuffer != nil {
 		testingBuffer.RecordHit(&rules.TestingHit{
@@ -492,11 +939,11 @@ func ruleAlertDecision(prefix, description string, event *telemetry.Event, rule
 		ProcessName: event.ProcessName,
 		ParentName:  event.ParentName,
 		CgroupID:    strconv.FormatUint(event.CgroupID, 10),
-		Action:      string(rule.Action),
+		Action:      alertAction(rule.Action, event),
 		Blocked:     event.Blocked,
 	}
 	decisionType := DecisionAlert
-	if rule.Action == rules.ActionBlock || event.Blocked {
+	if rule.Action.Blocks() || event.Blocked {
 		decisionType = DecisionBlock
 	}
 	return Decision{Type: decisionType, Rule: rule, Alerts: []system.Alert{alert}}
@@ -17,14 +17,18 @@ type TestingStats = rules.TestingStats
 type PromotionReadiness = rules.PromotionReadiness
 
 const (
-	ActionAllow ActionType = rules.ActionAllow
-	ActionAlert ActionType = rules.ActionAlert
-	ActionBlock ActionType = rules.ActionBlock
+	ActionAllow    ActionType = rules.ActionAllow
+	ActionAlert    ActionType = rules.ActionAlert
+	ActionBlock    ActionType = rules.ActionBlock
+	ActionKill     ActionType = rules.ActionKill
+	ActionKillTree ActionType = rules.ActionKillTree
 )
 
 const (
-	BPFActionMonitor uint8 = rules.BPFActionMonitor
-	BPFActionBlock   uint8 = rules.BPFActionBlock
+	BPFActionMonitor  uint8 = rules.BPFActionMonitor
+	BPFActionBlock    uint8 = rules.BPFActionBlock
+	BPFActionKill     uint8 = rules.BPFActionKill
+	BPFActionKillTree uint8 = rules.BPFActionKillTree
 )
 
 const (
@@ -34,9 +38,14 @@ const (
 )
 
 const (
-	RuleTypeExec    RuleType = rules.RuleTypeExec
-	RuleTypeFile    RuleType = rules.RuleTypeFile
-	RuleTypeConnect RuleType = rules.RuleTypeConnect
+	RuleTypeExec       RuleType = rules.RuleTypeExec
+	RuleTypeFile       RuleType = rules.RuleTypeFile
+	RuleTypeConnect    RuleType = rules.RuleTypeConnect
+	RuleTypePrivilege  RuleType = rules.RuleTypePrivilege
+	RuleTypeKernelLoad RuleType = rules.RuleTypeKernelLoad
+	RuleTypePtrace     RuleType = rules.RuleTypePtrace
+	RuleTypeListen     RuleType = rules.RuleTypeListen
+	RuleTypeEscape     RuleType = rules.RuleTypeEscape
 )
 
 const (
@@ -36,19 +36,59 @@ func ExtractCString(data []byte) string {
 	return string(data)
 }
 
-// extract the IP address from a ConnectEvent
+// the full command line of an ExecEvent, from its reassembled argv when there
+// is one
+func ExecCommandLine(event *events.ExecEvent) string {
+	if len(event.Args) > 0 {
+		return strings.Join(event.Args, " ")
+	}
+	return ExtractCString(event.CommandLine[:])
+}
+
+// extract the IP address from a ConnectEvent, or the socket path of an
+// AF_UNIX connect
 func ExtractIP(event *events.ConnectEvent) string {
-	switch event.Family {
+	if event.Family == events.AFUnix {
+		return FormatUnixPath(event.UnixPath[:])
+	}
+	return FormatIP(event.Family, event.AddrV4, event.AddrV6)
+}
+
+// FormatUnixPath renders a sun_path, writing abstract names with a leading
+// "@" as ss(8) does.
+func FormatUnixPath(path []byte) string {
+	if len(path) > 0 && path[0] == 0 {
+		if name := ExtractCString(path[1:]); name != "" {
+			return "@" + name
+		}
+		return ""
+	}
+	return ExtractCString(path)
+}
+
+// extract the resolver address from a DNSEvent
+func ExtractDNSServer(event *events.DNSEvent) string {
+	return FormatIP(event.Family, event.AddrV4, event.AddrV6)
+}
+
+// extract the local address from a ListenEvent
+func ExtractListenAddr(event *events.ListenEvent) string {
+	return FormatIP(event.Family, event.AddrV4, event.AddrV6)
+}
+
+// FormatIP renders a kernel address pair (v4 in network byte order as read
+// little-endian) for the given address family.
+func FormatIP(family uint16, v4 uint32, v6 [16]byte) string {
+	switch family {
 	case 2: // AF_INET (IPv4)
-		addr := event.AddrV4
 		return net.IPv4(
-			byte(addr),
-			byte(addr>>8),
-			byte(addr>>16),
-			byte(addr>>24),
+			byte(v4),
+			byte(v4>>8),
+			byte(v4>>16),
+			byte(v4>>24),
 		).String()
 	case 10: // AF_INET6 (IPv6)
-		return net.IP(event.AddrV6[:]).String()
+		return net.IP(v6[:]).String()
 	}
 	return ""
 }
@@ -65,6 +105,27 @@ func NormalizeFilename(path string) string {
 	return strings.TrimLeft(clean, "/")
 }
 
+// CanonicalPath returns the form shared by file rules and kernel map keys:
+// an absolute, cleaned path. Relative paths resolve against the working
+// directory; bare names (no slash) are kept as basename keys.
+func CanonicalPath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ""
+	}
+	if !strings.Contains(path, "/") {
+		return path
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
 // return canonical and relative forms of a path for matching.
 func PathVariants(path string) []string {
 	if path == "" {
@@ -89,6 +150,9 @@ func PathVariants(path string) []string {
 	add(clean)
 	trimmed := strings.TrimLeft(clean, "/")
 	add(trimmed)
+	if strings.Contains(clean, "/") && !filepath.IsAbs(clean) {
+		add(CanonicalPath(clean))
+	}
 	return variants
 }
 
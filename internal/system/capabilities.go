@@ -0,0 +1,39 @@
+package system
+
+const (
+	CgroupModeUnified = "unified"
+	CgroupModeHybrid  = "hybrid"
+	CgroupModeLegacy  = "legacy"
+)
+
+// Capabilities describes what the running kernel offers the BPF programs.
+type Capabilities struct {
+	KernelVersion string           `json:"kernelVersion"`
+	BTF           bool             `json:"btf"`
+	RingBuffer    bool             `json:"ringBuffer"`
+	BPFLSM        bool             `json:"bpfLsm"`
+	ActiveLSMs    []string         `json:"activeLsms"`
+	DPath         bool             `json:"dPath"`
+	SendSignal    bool             `json:"sendSignal"`
+	CgroupMode    string           `json:"cgroupMode"`
+	LSMHooks      []HookCapability `json:"lsmHooks"`
+	// RejectedPrograms lists the programs the kernel refused to load, e.g.
+	// by the verifier. The rest were loaded without them.
+	RejectedPrograms []RejectedProgram `json:"rejectedPrograms,omitempty"`
+}
+
+// HookCapability reports whether an LSM hook exists in the kernel and
+// whether Aegis attached to it. Error says why an existing hook is not
+// attached.
+type HookCapability struct {
+	Name      string `json:"name"`
+	Supported bool   `json:"supported"`
+	Attached  bool   `json:"attached"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RejectedProgram names a BPF program that failed to load and why.
+type RejectedProgram struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
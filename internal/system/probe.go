@@ -3,11 +3,14 @@ package system
 const (
 	ProbeStatusStarting = "starting"
 	ProbeStatusActive   = "active"
+	// ProbeStatusDegraded means events are captured but not enforced.
+	ProbeStatusDegraded = "degraded"
 	ProbeStatusError    = "error"
 	ProbeStatusStopped  = "stopped"
 )
 
 type ProbeStatus struct {
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Warning string `json:"warning,omitempty"`
 }
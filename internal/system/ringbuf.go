@@ -0,0 +1,64 @@
+package system
+
+import (
+	"fmt"
+	"time"
+)
+
+// RingbufStats describes the ring buffer between the kernel and the agent:
+// the records the kernel had no room for since the probes were loaded, and
+// how far the reader is behind.
+type RingbufStats struct {
+	Dropped        uint64            `json:"dropped"`
+	DroppedBlocked uint64            `json:"droppedBlocked"`
+	DroppedByType  map[string]uint64 `json:"droppedByType,omitempty"`
+	PendingBytes   int               `json:"pendingBytes"`
+	BufferSize     int               `json:"bufferSize"`
+}
+
+// UpdateRingbuf stores a fresh sample. It returns an alert when the drops
+// since the previous sample reach threshold, or when any of them was a block
+// decision, since the denial then never showed up as an event.
+func (s *Stats) UpdateRingbuf(sample RingbufStats, threshold uint64) (Alert, bool) {
+	s.ringbufMu.Lock()
+	prev := s.ringbuf
+	s.ringbuf = sample
+	s.ringbufMu.Unlock()
+
+	// The counters restart from zero when the probes are reloaded.
+	dropped, blocked := sample.Dropped, sample.DroppedBlocked
+	if dropped >= prev.Dropped {
+		dropped -= prev.Dropped
+		blocked -= min(blocked, prev.DroppedBlocked)
+	}
+	if dropped == 0 || (blocked == 0 && dropped < threshold) {
+		return Alert{}, false
+	}
+
+	severity := "high"
+	description := fmt.Sprintf("Ring buffer full: %d events dropped", dropped)
+	if blocked > 0 {
+		severity = "critical"
+		description += fmt.Sprintf(", including %d block decisions", blocked)
+	}
+	if sample.BufferSize > 0 {
+		description += fmt.Sprintf(" (%d of %d bytes pending)", sample.PendingBytes, sample.BufferSize)
+	}
+	now := time.Now()
+	return Alert{
+		ID:          fmt.Sprintf("ringbuf-%d", now.UnixNano()),
+		Timestamp:   now.UnixMilli(),
+		Severity:    severity,
+		RuleName:    "Ring Buffer Drops",
+		Description: description,
+		Action:      "alert",
+		Blocked:     blocked > 0,
+	}, true
+}
+
+// Ringbuf returns the latest ring buffer sample.
+func (s *Stats) Ringbuf() RingbufStats {
+	s.ringbufMu.RLock()
+	defer s.ringbufMu.RUnlock()
+	return s.ringbuf
+}
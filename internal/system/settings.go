@@ -90,6 +90,8 @@ func validateConfig(cfg Settings) error {
 		return fmt.Errorf("kernel.bpf_path must not be empty")
 	case cfg.Kernel.RingBufferSize <= 0:
 		return fmt.Errorf("kernel.ring_buffer_size must be greater than 0")
+	case cfg.Kernel.Pin && cfg.Kernel.PinPath == "":
+		return fmt.Errorf("kernel.pin_path must not be empty when kernel.pin is set")
 	case cfg.Telemetry.ProcessTreeMaxSize <= 0:
 		return fmt.Errorf("telemetry.process_tree_max_size must be greater than 0")
 	case cfg.Telemetry.ProcessTreeMaxChainLength <= 0:
@@ -142,6 +144,10 @@ func classifyConfigFields(oldCfg, newCfg Settings) ([]string, []string) {
 	appendIfChanged("server.port", oldCfg.Server.Port, newCfg.Server.Port)
 	appendIfChanged("kernel.bpf_path", oldCfg.Kernel.BPFPath, newCfg.Kernel.BPFPath)
 	appendIfChanged("kernel.ring_buffer_size", oldCfg.Kernel.RingBufferSize, newCfg.Kernel.RingBufferSize)
+	appendIfChanged("kernel.self_protection", oldCfg.Kernel.SelfProtection, newCfg.Kernel.SelfProtection)
+	appendIfChanged("kernel.capture_env", oldCfg.Kernel.CaptureEnv, newCfg.Kernel.CaptureEnv)
+	appendIfChanged("kernel.pin", oldCfg.Kernel.Pin, newCfg.Kernel.Pin)
+	appendIfChanged("kernel.pin_path", oldCfg.Kernel.PinPath, newCfg.Kernel.PinPath)
 	appendIfChanged("telemetry.process_tree_max_age", oldCfg.Telemetry.ProcessTreeMaxAge, newCfg.Telemetry.ProcessTreeMaxAge)
 	appendIfChanged("telemetry.process_tree_max_size", oldCfg.Telemetry.ProcessTreeMaxSize, newCfg.Telemetry.ProcessTreeMaxSize)
 	appendIfChanged("telemetry.process_tree_max_chain_length", oldCfg.Telemetry.ProcessTreeMaxChainLength, newCfg.Telemetry.ProcessTreeMaxChainLength)
@@ -27,6 +27,9 @@ type Stats struct {
 	alertDedup  map[alertKey]time.Time
 	dedupWindow time.Duration
 
+	ringbuf   RingbufStats
+	ringbufMu sync.RWMutex
+
 	workloadCountFn WorkloadCountFunc
 }
 
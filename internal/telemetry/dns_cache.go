@@ -0,0 +1,119 @@
+package telemetry
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"aegis/internal/platform/events"
+)
+
+const (
+	dnsCacheMinTTL    = 30 * time.Second
+	dnsCacheMaxTTL    = time.Hour
+	dnsCacheMaxPerPID = 512
+)
+
+type dnsCacheEntry struct {
+	domain  string
+	expires time.Time
+}
+
+// dnsCache remembers, per process, the domain each address it resolved came
+// from so its later connect events can name the domain. Answer TTLs are
+// clamped so short-lived records still cover the connect that follows.
+type dnsCache struct {
+	mu    sync.Mutex
+	byPID map[uint32]map[string]dnsCacheEntry
+}
+
+func newDNSCache() *dnsCache {
+	return &dnsCache{byPID: make(map[uint32]map[string]dnsCacheEntry)}
+}
+
+func (c *dnsCache) record(pid uint32, domain string, answers []events.DNSAnswer, now time.Time) {
+	if domain == "" || len(answers) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := c.byPID[pid]
+	if entries == nil {
+		entries = make(map[string]dnsCacheEntry)
+		c.byPID[pid] = entries
+	}
+	for _, answer := range answers {
+		if len(entries) >= dnsCacheMaxPerPID {
+			evictDNSEntries(entries, now)
+		}
+		ttl := time.Duration(answer.TTL) * time.Second
+		ttl = max(dnsCacheMinTTL, min(ttl, dnsCacheMaxTTL))
+		entries[answer.IP.String()] = dnsCacheEntry{domain: domain, expires: now.Add(ttl)}
+	}
+}
+
+func (c *dnsCache) lookup(pid uint32, ip string, now time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.byPID[pid][ip]
+	if !ok {
+		return ""
+	}
+	if now.After(entry.expires) {
+		delete(c.byPID[pid], ip)
+		return ""
+	}
+	return entry.domain
+}
+
+// resolved returns the unexpired addresses of every cached domain, across
+// all processes.
+func (c *dnsCache) resolved(now time.Time) map[string][]net.IP {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make(map[string][]net.IP)
+	seen := make(map[string]map[string]bool)
+	for _, entries := range c.byPID {
+		for ip, entry := range entries {
+			if now.After(entry.expires) || seen[entry.domain][ip] {
+				continue
+			}
+			if seen[entry.domain] == nil {
+				seen[entry.domain] = make(map[string]bool)
+			}
+			seen[entry.domain][ip] = true
+			if addr := net.ParseIP(ip); addr != nil {
+				result[entry.domain] = append(result[entry.domain], addr)
+			}
+		}
+	}
+	return result
+}
+
+func (c *dnsCache) forget(pid uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.byPID, pid)
+}
+
+// evictDNSEntries drops expired entries, or the one closest to expiry when
+// none have expired.
+func evictDNSEntries(entries map[string]dnsCacheEntry, now time.Time) {
+	oldest := ""
+	for ip, entry := range entries {
+		if now.After(entry.expires) {
+			delete(entries, ip)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(entries[oldest].expires) {
+			oldest = ip
+		}
+	}
+	if len(entries) >= dnsCacheMaxPerPID && oldest != "" {
+		delete(entries, oldest)
+	}
+}
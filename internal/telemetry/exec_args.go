@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"slices"
+	"sync"
+
+	"aegis/internal/platform/events"
+)
+
+// execArgsMaxPending bounds the sequences held for exec events that never
+// arrive, e.g. because the ring buffer dropped them.
+const execArgsMaxPending = 1024
+
+type pendingArgs struct {
+	argv      []byte
+	env       []byte
+	nextChunk uint16
+	done      bool
+	truncated bool
+}
+
+// execArgs reassembles the argv and envp records the kernel sends ahead of
+// each exec event, keyed by their seq. The oldest sequences are evicted first.
+type execArgs struct {
+	mu      sync.Mutex
+	pending map[uint64]*pendingArgs
+	order   []uint64
+}
+
+func newExecArgs() *execArgs {
+	return &execArgs{pending: make(map[uint64]*pendingArgs)}
+}
+
+func (a *execArgs) add(ev *events.ExecArgsEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p := a.pending[ev.Seq]
+	if p == nil {
+		if len(a.order) >= execArgsMaxPending {
+			delete(a.pending, a.order[0])
+			a.order = a.order[1:]
+		}
+		p = &pendingArgs{}
+		a.pending[ev.Seq] = p
+		a.order = append(a.order, ev.Seq)
+	}
+	// A gap in the chunk numbers means a record was lost.
+	if ev.Chunk != p.nextChunk {
+		p.truncated = true
+	}
+	p.nextChunk = ev.Chunk + 1
+	if ev.Flags&events.ExecArgsTruncated != 0 {
+		p.truncated = true
+	}
+	if ev.Flags&events.ExecArgsEnv != 0 {
+		p.env = append(p.env, ev.Data...)
+		return
+	}
+	p.argv = append(p.argv, ev.Data...)
+	if ev.Flags&events.ExecArgsLast != 0 {
+		p.done = true
+	}
+}
+
+// take returns and forgets the arguments sent under seq. They are truncated
+// when any record is missing, including all of them.
+func (a *execArgs) take(seq uint64) (argv, env []string, truncated bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p, ok := a.pending[seq]
+	if !ok {
+		return nil, nil, true
+	}
+	delete(a.pending, seq)
+	if i := slices.Index(a.order, seq); i >= 0 {
+		a.order = slices.Delete(a.order, i, i+1)
+	}
+	return events.SplitArgs(p.argv), events.SplitArgs(p.env), p.truncated || !p.done
+}
@@ -0,0 +1,71 @@
+package telemetry
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Listener is a socket accepting traffic on a local port: a TCP socket in
+// the listening state or a UDP socket bound to a fixed port.
+type Listener struct {
+	PID         uint32    `json:"pid"`
+	CgroupID    uint64    `json:"cgroup_id"`
+	ProcessName string    `json:"process_name"`
+	Protocol    string    `json:"protocol"`
+	Family      uint16    `json:"family"`
+	Address     string    `json:"address"`
+	Port        uint16    `json:"port"`
+	Since       time.Time `json:"since"`
+}
+
+// listenerInventory tracks open listeners by kernel socket cookie. Entries
+// are added by bind/listen events and removed when the kernel reports the
+// socket freed, so sockets inherited across fork stay listed until the last
+// holder closes them. Listeners opened before the agent started are not
+// known.
+type listenerInventory struct {
+	mu       sync.Mutex
+	byCookie map[uint64]Listener
+}
+
+func newListenerInventory() *listenerInventory {
+	return &listenerInventory{byCookie: make(map[uint64]Listener)}
+}
+
+func (l *listenerInventory) open(cookie uint64, listener Listener) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A repeated listen() only changes the backlog; keep the first sighting.
+	if existing, ok := l.byCookie[cookie]; ok {
+		listener.Since = existing.Since
+	}
+	l.byCookie[cookie] = listener
+}
+
+func (l *listenerInventory) close(cookie uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.byCookie, cookie)
+}
+
+func (l *listenerInventory) list() []Listener {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make([]Listener, 0, len(l.byCookie))
+	for _, listener := range l.byCookie {
+		result = append(result, listener)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Port != result[j].Port {
+			return result[i].Port < result[j].Port
+		}
+		if result[i].Protocol != result[j].Protocol {
+			return result[i].Protocol < result[j].Protocol
+		}
+		return result[i].PID < result[j].PID
+	})
+	return result
+}
@@ -12,12 +12,17 @@ type ProcessProfile struct {
 	Dynamic  DynamicProfile
 	Baseline *BaselineProfile // Optional baseline for anomaly detection
 	mu       sync.RWMutex
+
+	finalizedAt time.Time // wall clock of FinalizeProfile, drives pruning
 }
 
 type StaticProfile struct {
 	StartTime   time.Time
 	CommandLine string
-	Genealogy   []uint32 // Parent process chain
+	Genealogy   []uint32  // Parent process chain
+	EndTime     time.Time // zero while the process is alive
+	ExitCode    uint32
+	ExitSignal  uint32
 }
 
 type DynamicProfile struct {
@@ -37,9 +42,14 @@ type BaselineProfile struct {
 	CommonNetPorts     []uint16
 }
 
+// exitedProfileRetention is how long a finalized profile stays queryable
+// after its process exits.
+const exitedProfileRetention = 10 * time.Minute
+
 // ProfileRegistry manages process profiles.
 type ProfileRegistry struct {
-	profiles sync.Map // map[uint32]*ProcessProfile
+	profiles  sync.Map // map[uint32]*ProcessProfile
+	lastPrune atomic.Int64
 }
 
 func NewProfileRegistry() *ProfileRegistry {
@@ -56,7 +66,7 @@ func (pr *ProfileRegistry) GetProfile(pid uint32) (*ProcessProfile, bool) {
 
 func (pr *ProfileRegistry) GetOrCreateProfile(pid uint32, startTime time.Time, commandLine string, genealogy []uint32) *ProcessProfile {
 	profile, ok := pr.profiles.Load(pid)
-	if ok {
+	if ok && !profile.(*ProcessProfile).Exited() {
 		return profile.(*ProcessProfile)
 	}
 
@@ -69,10 +79,58 @@ func (pr *ProfileRegistry) GetOrCreateProfile(pid uint32, startTime time.Time, c
 		},
 	}
 
+	if ok {
+		// The PID was reused after the previous owner exited.
+		if pr.profiles.CompareAndSwap(pid, profile, newProfile) {
+			return newProfile
+		}
+		actual, _ := pr.profiles.Load(pid)
+		return actual.(*ProcessProfile)
+	}
 	actual, _ := pr.profiles.LoadOrStore(pid, newProfile)
 	return actual.(*ProcessProfile)
 }
 
+// Exited reports whether the profile has been finalized by an exit event.
+func (p *ProcessProfile) Exited() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return !p.Static.EndTime.IsZero()
+}
+
+// FinalizeProfile records the end of a process lifetime and drops profiles
+// whose processes exited more than exitedProfileRetention ago.
+func (pr *ProfileRegistry) FinalizeProfile(pid uint32, endTime time.Time, exitCode, signal uint32) {
+	if profile, ok := pr.GetProfile(pid); ok {
+		profile.mu.Lock()
+		profile.Static.EndTime = endTime
+		profile.Static.ExitCode = exitCode
+		profile.Static.ExitSignal = signal
+		profile.finalizedAt = time.Now()
+		profile.mu.Unlock()
+	}
+
+	now := time.Now()
+	last := pr.lastPrune.Load()
+	if now.UnixNano()-last < int64(time.Minute) || !pr.lastPrune.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	pr.pruneExited(now.Add(-exitedProfileRetention))
+}
+
+func (pr *ProfileRegistry) pruneExited(cutoff time.Time) {
+	pr.profiles.Range(func(key, value any) bool {
+		profile := value.(*ProcessProfile)
+		profile.mu.RLock()
+		finalizedAt := profile.finalizedAt
+		profile.mu.RUnlock()
+		if !finalizedAt.IsZero() && finalizedAt.Before(cutoff) {
+			pr.profiles.CompareAndDelete(key, value)
+		}
+		return true
+	})
+}
+
 // RecordFileOpen records a file open event for a process.
 func (pr *ProfileRegistry) RecordFileOpen(pid uint32) {
 	profile, ok := pr.GetProfile(pid)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +18,14 @@ type ProcessInfo struct {
 	CgroupID  uint64
 	Comm      string
 	Timestamp time.Time
+	ExitTime  time.Time // zero while the process is alive
+	ExitCode  uint32
+	Signal    uint32
+}
+
+// Exited reports whether an exit event has been seen for the process.
+func (p *ProcessInfo) Exited() bool {
+	return !p.ExitTime.IsZero()
 }
 
 type PIDResolver func(pid uint32) (uint32, bool)
@@ -89,42 +98,50 @@ func (pt *ProcessTree) seedFromProc() error {
 }
 
 func (pt *ProcessTree) readProcInfo(pid uint32) (*ProcessInfo, string, error) {
-	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	info, err := readStat(pid)
 	if err != nil {
 		return nil, "", err
 	}
+	cgroupID, cgroupPath := readCgroupIDAndPath(pid)
+	info.CgroupID = cgroupID
+	return info, cgroupPath, nil
+}
+
+// readStat reads the comm and parent of pid from /proc/<pid>/stat.
+func readStat(pid uint32) (*ProcessInfo, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
 
 	// pid (comm) state ppid ...
 	str := string(data)
 
 	commEnd := strings.LastIndex(str, ")")
 	if commEnd == -1 {
-		return nil, "", fmt.Errorf("invalid stat format")
+		return nil, fmt.Errorf("invalid stat format")
 	}
 	commStart := strings.Index(str, "(")
 	if commStart == -1 {
-		return nil, "", fmt.Errorf("invalid stat format")
+		return nil, fmt.Errorf("invalid stat format")
 	}
 	comm := str[commStart+1 : commEnd]
 
 	fields := strings.Fields(str[commEnd+1:])
 	if len(fields) < 2 {
-		return nil, "", fmt.Errorf("invalid stat format")
+		return nil, fmt.Errorf("invalid stat format")
 	}
 	ppid, err := strconv.ParseUint(fields[1], 10, 32)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	cgroupID, cgroupPath := readCgroupIDAndPath(pid)
-
 	return &ProcessInfo{
 		PID:       pid,
 		PPID:      uint32(ppid),
-		CgroupID:  cgroupID,
 		Comm:      comm,
 		Timestamp: time.Now(),
-	}, cgroupPath, nil
+	}, nil
 }
 
 func (pt *ProcessTree) AddProcess(pid, ppid uint32, cgroupID uint64, comm string) {
@@ -148,6 +165,25 @@ func (pt *ProcessTree) AddProcess(pid, ppid uint32, cgroupID uint64, comm string
 	pt.timeIndex.Add(pid, info.Timestamp)
 }
 
+// MarkExited records that pid has exited. The entry is kept so descendants can
+// still resolve their ancestry, and ages out maxAge after the exit.
+func (pt *ProcessTree) MarkExited(pid uint32, exitTime time.Time, exitCode, signal uint32) bool {
+	val, ok := pt.processes.Load(pid)
+	if !ok {
+		return false
+	}
+
+	info := *val.(*ProcessInfo)
+	info.ExitTime = exitTime
+	info.ExitCode = exitCode
+	info.Signal = signal
+	if !pt.processes.CompareAndSwap(pid, val, &info) {
+		return false
+	}
+	pt.timeIndex.Add(pid, exitTime)
+	return true
+}
+
 func (pt *ProcessTree) evictOldest() {
 	oldestPID, ok := pt.timeIndex.PopOldest()
 	if !ok {
@@ -207,6 +243,63 @@ func (pt *ProcessTree) GetAncestors(pid uint32) []*ProcessInfo {
 	return chain
 }
 
+// Descendants returns the live descendants of pid, parents before children.
+func (pt *ProcessTree) Descendants(pid uint32) []uint32 {
+	return DescendantsOf(pid, pt.Parents(), nil)
+}
+
+// Parents returns the recorded parent of every live process in the tree.
+func (pt *ProcessTree) Parents() map[uint32]uint32 {
+	parents := make(map[uint32]uint32)
+	pt.processes.Range(func(_, val any) bool {
+		info := val.(*ProcessInfo)
+		if !info.Exited() && info.PID != info.PPID {
+			parents[info.PID] = info.PPID
+		}
+		return true
+	})
+	return parents
+}
+
+// DescendantsOf walks parents, a map from child to parent pid, down from pid
+// and returns the descendants, parents before children. keep, when not nil,
+// vets each child before it and its own children are included.
+func DescendantsOf(pid uint32, parents map[uint32]uint32, keep func(child, parent uint32) bool) []uint32 {
+	children := make(map[uint32][]uint32)
+	for child, parent := range parents {
+		if child != parent {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	var out []uint32
+	visited := map[uint32]bool{pid: true}
+	queue := []uint32{pid}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		slices.Sort(children[parent])
+		for _, child := range children[parent] {
+			if visited[child] || (keep != nil && !keep(child, parent)) {
+				continue
+			}
+			visited[child] = true
+			out = append(out, child)
+			queue = append(queue, child)
+		}
+	}
+	return out
+}
+
+// ReadPPID returns the current parent of pid from /proc.
+func ReadPPID(pid uint32) (uint32, error) {
+	info, err := readStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	return info.PPID, nil
+}
+
 func (pt *ProcessTree) SetPIDResolver(resolver PIDResolver) {
 	pt.resolverMu.Lock()
 	defer pt.resolverMu.Unlock()
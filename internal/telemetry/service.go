@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -18,29 +19,135 @@ import (
 type EventType string
 
 const (
-	EventTypeExec    EventType = "exec"
-	EventTypeFile    EventType = "file"
-	EventTypeConnect EventType = "connect"
+	EventTypeExec       EventType = "exec"
+	EventTypeFile       EventType = "file"
+	EventTypeConnect    EventType = "connect"
+	EventTypeExit       EventType = "exit"
+	EventTypePrivilege  EventType = "privilege"
+	EventTypeKernelLoad EventType = "kernel_load"
+	EventTypePtrace     EventType = "ptrace"
+	EventTypeDNS        EventType = "dns"
+	EventTypeListen     EventType = "listen"
+	EventTypeNamespace  EventType = "namespace"
+	EventTypeSignal     EventType = "signal"
 )
 
 type Event struct {
-	ID          string    `json:"id"`
-	Type        EventType `json:"type"`
-	Timestamp   time.Time `json:"timestamp"`
-	PID         uint32    `json:"pid"`
-	PPID        uint32    `json:"ppid,omitempty"`
-	CgroupID    uint64    `json:"cgroup_id"`
-	ProcessName string    `json:"process_name"`
-	ParentName  string    `json:"parent_name,omitempty"`
-	CommandLine string    `json:"command_line,omitempty"`
-	Filename    string    `json:"filename,omitempty"`
-	Flags       uint32    `json:"flags,omitempty"`
-	Ino         uint64    `json:"ino,omitempty"`
-	Dev         uint64    `json:"dev,omitempty"`
-	Family      uint16    `json:"family,omitempty"`
-	Port        uint16    `json:"port,omitempty"`
-	Address     string    `json:"address,omitempty"`
-	Blocked     bool      `json:"blocked"`
+	ID          string            `json:"id"`
+	Type        EventType         `json:"type"`
+	Timestamp   time.Time         `json:"timestamp"`
+	PID         uint32            `json:"pid"`
+	PPID        uint32            `json:"ppid,omitempty"`
+	CgroupID    uint64            `json:"cgroup_id"`
+	Namespaces  *NamespaceInfo    `json:"namespaces,omitempty"`
+	ProcessName string            `json:"process_name"`
+	ParentName  string            `json:"parent_name,omitempty"`
+	CommandLine string            `json:"command_line,omitempty"`
+	Args        []string          `json:"args,omitempty"`
+	Env         []string          `json:"env,omitempty"`
+	ArgsCut     bool              `json:"args_truncated,omitempty"`
+	Fileless    bool              `json:"fileless,omitempty"`
+	Deleted     bool              `json:"deleted_binary,omitempty"`
+	Filename    string            `json:"filename,omitempty"`
+	Flags       uint32            `json:"flags,omitempty"`
+	Ino         uint64            `json:"ino,omitempty"`
+	Dev         uint64            `json:"dev,omitempty"`
+	Operation   string            `json:"operation,omitempty"`
+	Family      uint16            `json:"family,omitempty"`
+	Port        uint16            `json:"port,omitempty"`
+	Address     string            `json:"address,omitempty"` // ip:port, or the socket path of a unix connect
+	Domain      string            `json:"domain,omitempty"`
+	Protocol    string            `json:"protocol,omitempty"`
+	ExitCode    uint32            `json:"exit_code,omitempty"`
+	Signal      uint32            `json:"signal,omitempty"`
+	RuntimeMs   int64             `json:"runtime_ms,omitempty"`
+	Credentials *CredentialChange `json:"credentials,omitempty"`
+	KernelLoad  *KernelLoadInfo   `json:"kernel_load,omitempty"`
+	Ptrace      *PtraceInfo       `json:"ptrace,omitempty"`
+	DNS         *DNSInfo          `json:"dns,omitempty"`
+	NSChange    *NamespaceChange  `json:"namespace_change,omitempty"`
+	SignalSent  *SignalInfo       `json:"signal_sent,omitempty"`
+	Blocked     bool              `json:"blocked"`
+	Response    string            `json:"response,omitempty"` // block, kill or kill_tree when Blocked
+	SelfProtect bool              `json:"self_protect,omitempty"`
+}
+
+// NamespaceInfo identifies the namespaces of the process behind an event.
+// Host is set when they are PID 1's, i.e. the process is not containerized.
+type NamespaceInfo struct {
+	PID  uint32 `json:"pid"`
+	Mnt  uint32 `json:"mnt"`
+	Net  uint32 `json:"net"`
+	User uint32 `json:"user"`
+	UTS  uint32 `json:"uts"`
+	Host bool   `json:"host"`
+}
+
+func namespaceInfo(ns events.Namespaces) *NamespaceInfo {
+	if !ns.Known() {
+		return nil
+	}
+	return &NamespaceInfo{PID: ns.PID, Mnt: ns.Mnt, Net: ns.Net, User: ns.User, UTS: ns.UTS, Host: ns.IsHost()}
+}
+
+// CredentialChange is the before/after view of a privilege event.
+type CredentialChange struct {
+	Operation string `json:"operation"`
+	OldUID    uint32 `json:"old_uid"`
+	OldEUID   uint32 `json:"old_euid"`
+	OldGID    uint32 `json:"old_gid"`
+	OldEGID   uint32 `json:"old_egid"`
+	NewUID    uint32 `json:"new_uid"`
+	NewEUID   uint32 `json:"new_euid"`
+	NewGID    uint32 `json:"new_gid"`
+	NewEGID   uint32 `json:"new_egid"`
+	OldCaps   uint64 `json:"old_caps"`
+	NewCaps   uint64 `json:"new_caps"`
+}
+
+// KernelLoadInfo describes a module or BPF program load. Module paths are
+// reported in Event.Filename.
+type KernelLoadInfo struct {
+	Kind     string `json:"kind"`
+	Command  string `json:"command,omitempty"` // link_detach or link_update for a denied BPF link change
+	ProgType string `json:"prog_type,omitempty"`
+	ProgName string `json:"prog_name,omitempty"`
+}
+
+// PtraceInfo names the process a ptrace event's tracer tried to access.
+type PtraceInfo struct {
+	TargetPID  uint32 `json:"target_pid"`
+	TargetName string `json:"target_name"`
+	Mode       string `json:"mode"`
+}
+
+// SignalInfo names the process a signal event's sender targeted. The signal
+// number is reported in Event.Signal.
+type SignalInfo struct {
+	TargetPID  uint32 `json:"target_pid"`
+	TargetName string `json:"target_name"`
+	Name       string `json:"name"`
+}
+
+// DNSInfo describes a DNS query or reply. The queried name is reported in
+// Event.Domain and the resolver in Event.Address.
+type DNSInfo struct {
+	QueryType string   `json:"query_type,omitempty"`
+	Transport string   `json:"transport"`
+	Answers   []string `json:"answers,omitempty"`
+}
+
+// NamespaceChange describes a setns, unshare or mount; the call is reported
+// in Event.Operation, its flags in Event.Flags and a mount point in
+// Event.Filename. For setns and unshare, Event.Namespaces holds the namespaces
+// before the call and New those after it, with New.PID the namespace for
+// children.
+type NamespaceChange struct {
+	Changed  []string       `json:"changed,omitempty"`
+	New      *NamespaceInfo `json:"new,omitempty"`
+	IntoHost bool           `json:"into_host"`
+	FSType   string         `json:"fs_type,omitempty"`
+	Source   string         `json:"source,omitempty"`
 }
 
 type Record struct {
@@ -64,9 +171,17 @@ type Query struct {
 }
 
 type TypeCounts struct {
-	Exec    int `json:"exec"`
-	File    int `json:"file"`
-	Connect int `json:"connect"`
+	Exec       int `json:"exec"`
+	File       int `json:"file"`
+	Connect    int `json:"connect"`
+	Exit       int `json:"exit"`
+	Privilege  int `json:"privilege"`
+	KernelLoad int `json:"kernel_load"`
+	Ptrace     int `json:"ptrace"`
+	DNS        int `json:"dns"`
+	Listen     int `json:"listen"`
+	Namespace  int `json:"namespace"`
+	Signal     int `json:"signal"`
 }
 
 type PageResult struct {
@@ -88,6 +203,9 @@ type Service struct {
 	processTree *proc.ProcessTree
 	workloads   *workload.Registry
 	profiles    *proc.ProfileRegistry
+	dns         *dnsCache
+	listeners   *listenerInventory
+	execArgs    *execArgs
 }
 
 func NewService(capacity int, indexSize int, processTree *proc.ProcessTree, workloads *workload.Registry, profiles *proc.ProfileRegistry) *Service {
@@ -105,9 +223,14 @@ func NewService(capacity int, indexSize int, processTree *proc.ProcessTree, work
 		processTree: processTree,
 		workloads:   workloads,
 		profiles:    profiles,
+		dns:         newDNSCache(),
+		listeners:   newListenerInventory(),
+		execArgs:    newExecArgs(),
 	}
 }
 
+// Ingest stores a decoded event. Argument records are held until the exec
+// event they belong to, so Ingest returns a nil Record for them.
 func (s *Service) Ingest(record *events.DecodedRecord) (*Record, error) {
 	if record == nil {
 		return nil, fmt.Errorf("decoded record is nil")
@@ -120,6 +243,25 @@ func (s *Service) Ingest(record *events.DecodedRecord) (*Record, error) {
 		return s.ingestFile(record)
 	case record.Connect != nil:
 		return s.ingestConnect(record)
+	case record.Exit != nil:
+		return s.ingestExit(record)
+	case record.Privilege != nil:
+		return s.ingestPrivilege(record)
+	case record.KernelLoad != nil:
+		return s.ingestKernelLoad(record)
+	case record.Ptrace != nil:
+		return s.ingestPtrace(record)
+	case record.DNS != nil:
+		return s.ingestDNS(record)
+	case record.Listen != nil:
+		return s.ingestListen(record)
+	case record.Namespace != nil:
+		return s.ingestNamespace(record)
+	case record.Signal != nil:
+		return s.ingestSignal(record)
+	case record.ExecArgs != nil:
+		s.execArgs.add(record.ExecArgs)
+		return nil, nil
 	default:
 		return nil, fmt.Errorf("decoded record has no event payload")
 	}
@@ -170,6 +312,22 @@ func (s *Service) Query(req Query) PageResult {
 			counts.File++
 		case EventTypeConnect:
 			counts.Connect++
+		case EventTypeExit:
+			counts.Exit++
+		case EventTypePrivilege:
+			counts.Privilege++
+		case EventTypeKernelLoad:
+			counts.KernelLoad++
+		case EventTypePtrace:
+			counts.Ptrace++
+		case EventTypeDNS:
+			counts.DNS++
+		case EventTypeListen:
+			counts.Listen++
+		case EventTypeNamespace:
+			counts.Namespace++
+		case EventTypeSignal:
+			counts.Signal++
 		}
 	}
 
@@ -226,6 +384,17 @@ func (s *Service) Profiles() *proc.ProfileRegistry {
 	return s.profiles
 }
 
+// Listeners returns the sockets currently listening, ordered by port.
+func (s *Service) Listeners() []Listener {
+	return s.listeners.list()
+}
+
+// ResolvedDomains returns the addresses each domain resolved to that are
+// still cached for some process.
+func (s *Service) ResolvedDomains() map[string][]net.IP {
+	return s.dns.resolved(time.Now())
+}
+
 func (s *Service) RecordAlert(cgroupID uint64, blocked bool) {
 	if s.workloads != nil && cgroupID != 0 {
 		s.workloads.RecordAlert(cgroupID, blocked)
@@ -234,14 +403,19 @@ func (s *Service) RecordAlert(cgroupID uint64, blocked bool) {
 
 func (s *Service) ingestExec(record *events.DecodedRecord) (*Record, error) {
 	ev := *record.Exec
+	var env []string
+	argsCut := false
+	if ev.ArgsSeq != 0 {
+		ev.Args, env, argsCut = s.execArgs.take(ev.ArgsSeq)
+	}
 	processName := utils.ExtractCString(ev.Hdr.Comm[:])
 	parentName := utils.ExtractCString(ev.PComm[:])
-	commandLine := utils.ExtractCString(ev.CommandLine[:])
+	commandLine := utils.ExecCommandLine(&ev)
 	if s.processTree != nil {
 		s.processTree.AddProcess(ev.Hdr.PID, ev.PPID, ev.Hdr.CgroupID, processName)
 	}
 	if s.workloads != nil {
-		s.workloads.RecordExec(ev.Hdr.CgroupID, proc.ResolveCgroupPath(ev.Hdr.PID, ev.Hdr.CgroupID))
+		s.workloads.RecordExec(ev.Hdr.CgroupID, proc.ResolveCgroupPath(ev.Hdr.PID, ev.Hdr.CgroupID), ev.Hdr.NS)
 	}
 	if s.profiles != nil {
 		var genealogy []uint32
@@ -268,10 +442,17 @@ func (s *Service) ingestExec(record *events.DecodedRecord) (*Record, error) {
 		PID:         ev.Hdr.PID,
 		PPID:        ev.PPID,
 		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
 		ProcessName: processName,
 		ParentName:  parentName,
 		CommandLine: commandLine,
+		Args:        ev.Args,
+		Env:         env,
+		ArgsCut:     argsCut,
+		Fileless:    ev.ExecFlags.Fileless(),
+		Deleted:     ev.ExecFlags.Deleted(),
 		Blocked:     ev.Hdr.Blocked == 1,
+		Response:    ev.Hdr.ResponseName(),
 	}
 	event.ID = generateEventID(raw)
 
@@ -287,7 +468,7 @@ func (s *Service) ingestFile(record *events.DecodedRecord) (*Record, error) {
 		}
 	}
 	if s.workloads != nil {
-		s.workloads.RecordFile(ev.Hdr.CgroupID, proc.ResolveCgroupPath(ev.Hdr.PID, ev.Hdr.CgroupID))
+		s.workloads.RecordFile(ev.Hdr.CgroupID, proc.ResolveCgroupPath(ev.Hdr.PID, ev.Hdr.CgroupID), ev.Hdr.NS)
 	}
 	if s.profiles != nil {
 		s.profiles.RecordFileOpen(ev.Hdr.PID)
@@ -301,12 +482,17 @@ func (s *Service) ingestFile(record *events.DecodedRecord) (*Record, error) {
 		Timestamp:   ev.Hdr.Timestamp(),
 		PID:         ev.Hdr.PID,
 		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
 		ProcessName: processName,
 		Filename:    utils.ExtractCString(ev.Filename[:]),
 		Flags:       ev.Flags,
 		Ino:         ev.Ino,
 		Dev:         ev.Dev,
 		Blocked:     ev.Hdr.Blocked == 1,
+		Response:    ev.Hdr.ResponseName(),
+	}
+	if ev.Op != 0 {
+		event.Operation = ev.Op.String()
 	}
 	event.ID = generateEventID(raw)
 
@@ -322,7 +508,7 @@ func (s *Service) ingestConnect(record *events.DecodedRecord) (*Record, error) {
 		}
 	}
 	if s.workloads != nil {
-		s.workloads.RecordConnect(ev.Hdr.CgroupID, proc.ResolveCgroupPath(ev.Hdr.PID, ev.Hdr.CgroupID))
+		s.workloads.RecordConnect(ev.Hdr.CgroupID, proc.ResolveCgroupPath(ev.Hdr.PID, ev.Hdr.CgroupID), ev.Hdr.NS)
 	}
 	if s.profiles != nil {
 		s.profiles.RecordConnect(ev.Hdr.PID)
@@ -333,16 +519,148 @@ func (s *Service) ingestConnect(record *events.DecodedRecord) (*Record, error) {
 
 	ip := utils.ExtractIP(&ev)
 	address := fmt.Sprintf("%s:%d", ip, ev.Port)
+	domain := ""
+	if ev.Family == events.AFUnix {
+		address = ip
+	} else {
+		domain = s.dns.lookup(ev.Hdr.PID, ip, ev.Hdr.Timestamp())
+	}
 	event := Event{
 		Type:        EventTypeConnect,
 		Timestamp:   ev.Hdr.Timestamp(),
 		PID:         ev.Hdr.PID,
 		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
 		ProcessName: processName,
 		Family:      ev.Family,
 		Port:        ev.Port,
 		Address:     address,
+		Domain:      domain,
+		Blocked:     ev.Hdr.Blocked == 1,
+		Response:    ev.Hdr.ResponseName(),
+	}
+	event.ID = generateEventID(raw)
+
+	return s.appendRecord(event, raw), nil
+}
+
+func (s *Service) ingestExit(record *events.DecodedRecord) (*Record, error) {
+	ev := *record.Exit
+	processName := utils.ExtractCString(ev.Hdr.Comm[:])
+	exitTime := ev.Hdr.Timestamp()
+	if s.processTree != nil {
+		if info, ok := s.processTree.GetProcess(ev.Hdr.PID); ok && info.Comm != "" {
+			processName = info.Comm
+		}
+		s.processTree.MarkExited(ev.Hdr.PID, exitTime, ev.ExitCode, ev.Signal)
+	}
+	if s.profiles != nil {
+		s.profiles.FinalizeProfile(ev.Hdr.PID, exitTime, ev.ExitCode, ev.Signal)
+	}
+	s.dns.forget(ev.Hdr.PID)
+
+	raw := storage.EventFromBackend(events.EventTypeExit, exitTime, ev)
+	_ = s.rawStore.Append(raw)
+
+	event := Event{
+		Type:        EventTypeExit,
+		Timestamp:   exitTime,
+		PID:         ev.Hdr.PID,
+		PPID:        ev.PPID,
+		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
+		ProcessName: processName,
+		ExitCode:    ev.ExitCode,
+		Signal:      ev.Signal,
+		RuntimeMs:   ev.Runtime().Milliseconds(),
+	}
+	event.ID = generateEventID(raw)
+
+	return s.appendRecord(event, raw), nil
+}
+
+func (s *Service) ingestPrivilege(record *events.DecodedRecord) (*Record, error) {
+	ev := *record.Privilege
+	processName := utils.ExtractCString(ev.Hdr.Comm[:])
+	var ppid uint32
+	var parentName string
+	if s.processTree != nil {
+		if info, ok := s.processTree.GetProcess(ev.Hdr.PID); ok {
+			if info.Comm != "" {
+				processName = info.Comm
+			}
+			ppid = info.PPID
+			if parent, ok := s.processTree.GetProcess(info.PPID); ok {
+				parentName = parent.Comm
+			}
+		}
+	}
+
+	raw := storage.EventFromBackend(events.EventTypePrivilege, ev.Hdr.Timestamp(), ev)
+	_ = s.rawStore.Append(raw)
+
+	event := Event{
+		Type:        EventTypePrivilege,
+		Timestamp:   ev.Hdr.Timestamp(),
+		PID:         ev.Hdr.PID,
+		PPID:        ppid,
+		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
+		ProcessName: processName,
+		ParentName:  parentName,
+		Credentials: &CredentialChange{
+			Operation: ev.Op.String(),
+			OldUID:    ev.OldUID,
+			OldEUID:   ev.OldEUID,
+			OldGID:    ev.OldGID,
+			OldEGID:   ev.OldEGID,
+			NewUID:    ev.NewUID,
+			NewEUID:   ev.NewEUID,
+			NewGID:    ev.NewGID,
+			NewEGID:   ev.NewEGID,
+			OldCaps:   ev.OldCaps,
+			NewCaps:   ev.NewCaps,
+		},
+	}
+	event.ID = generateEventID(raw)
+
+	return s.appendRecord(event, raw), nil
+}
+
+func (s *Service) ingestKernelLoad(record *events.DecodedRecord) (*Record, error) {
+	ev := *record.KernelLoad
+	processName := utils.ExtractCString(ev.Hdr.Comm[:])
+	if s.processTree != nil {
+		if info, ok := s.processTree.GetProcess(ev.Hdr.PID); ok && info.Comm != "" {
+			processName = info.Comm
+		}
+	}
+
+	raw := storage.EventFromBackend(events.EventTypeKernelLoad, ev.Hdr.Timestamp(), ev)
+	_ = s.rawStore.Append(raw)
+
+	name := utils.ExtractCString(ev.Name[:])
+	info := &KernelLoadInfo{Kind: ev.Kind.String()}
+	event := Event{
+		Type:        EventTypeKernelLoad,
+		Timestamp:   ev.Hdr.Timestamp(),
+		PID:         ev.Hdr.PID,
+		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
+		ProcessName: processName,
+		KernelLoad:  info,
 		Blocked:     ev.Hdr.Blocked == 1,
+		Response:    ev.Hdr.ResponseName(),
+		SelfProtect: ev.Hdr.SelfProtect == 1,
+	}
+	if ev.Kind == events.LoadKindBPF {
+		info.ProgType = events.BPFProgTypeName(ev.ProgType)
+		info.ProgName = name
+		if ev.BPFCmd != events.BPFCmdProgLoad {
+			info.Command = events.BPFCmdName(ev.BPFCmd)
+		}
+	} else {
+		event.Filename = name
 	}
 	event.ID = generateEventID(raw)
 
@@ -364,6 +682,232 @@ func (s *Service) appendRecord(event Event, raw *storage.Event) *Record {
 	return record
 }
 
+func (s *Service) ingestPtrace(record *events.DecodedRecord) (*Record, error) {
+	ev := *record.Ptrace
+	processName := utils.ExtractCString(ev.Hdr.Comm[:])
+	var ppid uint32
+	var parentName string
+	if s.processTree != nil {
+		if info, ok := s.processTree.GetProcess(ev.Hdr.PID); ok {
+			if info.Comm != "" {
+				processName = info.Comm
+			}
+			ppid = info.PPID
+			if parent, ok := s.processTree.GetProcess(info.PPID); ok {
+				parentName = parent.Comm
+			}
+		}
+	}
+
+	raw := storage.EventFromBackend(events.EventTypePtrace, ev.Hdr.Timestamp(), ev)
+	_ = s.rawStore.Append(raw)
+
+	event := Event{
+		Type:        EventTypePtrace,
+		Timestamp:   ev.Hdr.Timestamp(),
+		PID:         ev.Hdr.PID,
+		PPID:        ppid,
+		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
+		ProcessName: processName,
+		ParentName:  parentName,
+		Ptrace: &PtraceInfo{
+			TargetPID:  ev.TargetPID,
+			TargetName: utils.ExtractCString(ev.TargetComm[:]),
+			Mode:       ev.Mode.String(),
+		},
+		Blocked:     ev.Hdr.Blocked == 1,
+		Response:    ev.Hdr.ResponseName(),
+		SelfProtect: ev.Hdr.SelfProtect == 1,
+	}
+	event.ID = generateEventID(raw)
+
+	return s.appendRecord(event, raw), nil
+}
+
+func (s *Service) ingestDNS(record *events.DecodedRecord) (*Record, error) {
+	ev := *record.DNS
+	processName := utils.ExtractCString(ev.Hdr.Comm[:])
+	if s.processTree != nil {
+		if info, ok := s.processTree.GetProcess(ev.Hdr.PID); ok && info.Comm != "" {
+			processName = info.Comm
+		}
+	}
+
+	raw := storage.EventFromBackend(events.EventTypeDNS, ev.Hdr.Timestamp(), ev)
+	_ = s.rawStore.Append(raw)
+
+	event := Event{
+		Type:        EventTypeDNS,
+		Timestamp:   ev.Hdr.Timestamp(),
+		PID:         ev.Hdr.PID,
+		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
+		ProcessName: processName,
+		Family:      ev.Family,
+		Port:        ev.Port,
+		Address:     fmt.Sprintf("%s:%d", utils.ExtractDNSServer(&ev), ev.Port),
+		Operation:   "query",
+		DNS:         &DNSInfo{Transport: ev.Transport.String()},
+		Blocked:     ev.Hdr.Blocked == 1,
+		Response:    ev.Hdr.ResponseName(),
+	}
+	if ev.Response != 0 {
+		event.Operation = "response"
+	}
+	if msg, err := events.ParseDNSMessage(ev.Message()); err == nil {
+		event.Domain = msg.Name
+		event.DNS.QueryType = msg.QueryType()
+		for _, answer := range msg.Answers {
+			event.DNS.Answers = append(event.DNS.Answers, answer.IP.String())
+		}
+		if ev.Response != 0 {
+			s.dns.record(ev.Hdr.PID, msg.Name, msg.Answers, ev.Hdr.Timestamp())
+		}
+	}
+	event.ID = generateEventID(raw)
+
+	return s.appendRecord(event, raw), nil
+}
+
+func (s *Service) ingestListen(record *events.DecodedRecord) (*Record, error) {
+	ev := *record.Listen
+	processName := utils.ExtractCString(ev.Hdr.Comm[:])
+	if s.processTree != nil {
+		if info, ok := s.processTree.GetProcess(ev.Hdr.PID); ok && info.Comm != "" {
+			processName = info.Comm
+		}
+	}
+
+	raw := storage.EventFromBackend(events.EventTypeListen, ev.Hdr.Timestamp(), ev)
+	_ = s.rawStore.Append(raw)
+
+	event := Event{
+		Type:        EventTypeListen,
+		Timestamp:   ev.Hdr.Timestamp(),
+		PID:         ev.Hdr.PID,
+		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
+		ProcessName: processName,
+		Family:      ev.Family,
+		Port:        ev.Port,
+		Address:     fmt.Sprintf("%s:%d", utils.ExtractListenAddr(&ev), ev.Port),
+		Operation:   ev.Op.String(),
+		Protocol:    ev.ProtocolName(),
+		Blocked:     ev.Hdr.Blocked == 1,
+		Response:    ev.Hdr.ResponseName(),
+	}
+	switch {
+	case ev.Op == events.ListenOpClose:
+		s.listeners.close(ev.Cookie)
+	case !event.Blocked:
+		s.listeners.open(ev.Cookie, Listener{
+			PID:         event.PID,
+			CgroupID:    event.CgroupID,
+			ProcessName: processName,
+			Protocol:    event.Protocol,
+			Family:      event.Family,
+			Address:     event.Address,
+			Port:        event.Port,
+			Since:       event.Timestamp,
+		})
+	}
+	event.ID = generateEventID(raw)
+
+	return s.appendRecord(event, raw), nil
+}
+
+func (s *Service) ingestNamespace(record *events.DecodedRecord) (*Record, error) {
+	ev := *record.Namespace
+	processName := utils.ExtractCString(ev.Hdr.Comm[:])
+	var ppid uint32
+	var parentName string
+	if s.processTree != nil {
+		if info, ok := s.processTree.GetProcess(ev.Hdr.PID); ok {
+			if info.Comm != "" {
+				processName = info.Comm
+			}
+			ppid = info.PPID
+			if parent, ok := s.processTree.GetProcess(info.PPID); ok {
+				parentName = parent.Comm
+			}
+		}
+	}
+
+	raw := storage.EventFromBackend(events.EventTypeNamespace, ev.Hdr.Timestamp(), ev)
+	_ = s.rawStore.Append(raw)
+
+	event := Event{
+		Type:        EventTypeNamespace,
+		Timestamp:   ev.Hdr.Timestamp(),
+		PID:         ev.Hdr.PID,
+		PPID:        ppid,
+		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
+		ProcessName: processName,
+		ParentName:  parentName,
+		Operation:   ev.Op.String(),
+		Flags:       ev.Flags,
+		NSChange:    &NamespaceChange{},
+	}
+	if ev.Op.IsMount() {
+		event.Filename = utils.ExtractCString(ev.Target[:])
+		event.NSChange.FSType = utils.ExtractCString(ev.FSType[:])
+		event.NSChange.Source = utils.ExtractCString(ev.Source[:])
+	} else {
+		event.NSChange.Changed = events.NamespaceKinds(ev.Flags)
+		event.NSChange.New = namespaceInfo(ev.NewNS)
+		event.NSChange.IntoHost = ev.IntoHost()
+	}
+	event.ID = generateEventID(raw)
+
+	return s.appendRecord(event, raw), nil
+}
+
+func (s *Service) ingestSignal(record *events.DecodedRecord) (*Record, error) {
+	ev := *record.Signal
+	processName := utils.ExtractCString(ev.Hdr.Comm[:])
+	var ppid uint32
+	var parentName string
+	if s.processTree != nil {
+		if info, ok := s.processTree.GetProcess(ev.Hdr.PID); ok {
+			if info.Comm != "" {
+				processName = info.Comm
+			}
+			ppid = info.PPID
+			if parent, ok := s.processTree.GetProcess(info.PPID); ok {
+				parentName = parent.Comm
+			}
+		}
+	}
+
+	raw := storage.EventFromBackend(events.EventTypeSignal, ev.Hdr.Timestamp(), ev)
+	_ = s.rawStore.Append(raw)
+
+	event := Event{
+		Type:        EventTypeSignal,
+		Timestamp:   ev.Hdr.Timestamp(),
+		PID:         ev.Hdr.PID,
+		PPID:        ppid,
+		CgroupID:    ev.Hdr.CgroupID,
+		Namespaces:  namespaceInfo(ev.Hdr.NS),
+		ProcessName: processName,
+		ParentName:  parentName,
+		Signal:      ev.Signal,
+		SignalSent: &SignalInfo{
+			TargetPID:  ev.TargetPID,
+			TargetName: utils.ExtractCString(ev.TargetComm[:]),
+			Name:       events.SignalName(ev.Signal),
+		},
+		Blocked:     ev.Hdr.Blocked == 1,
+		Response:    ev.Hdr.ResponseName(),
+		SelfProtect: ev.Hdr.SelfProtect == 1,
+	}
+	event.ID = generateEventID(raw)
+
+	return s.appendRecord(event, raw), nil
+}
+
 func generateEventID(event *storage.Event) string {
 	h := sha256.New()
 	h.Write([]byte(event.Timestamp.Format(time.RFC3339Nano)))
@@ -377,6 +921,26 @@ func generateEventID(event *storage.Event) string {
 		fmt.Fprintf(h, "%d", fileEvent.Hdr.PID)
 	} else if connectEvent, ok := storage.ConnectPayload(event); ok {
 		fmt.Fprintf(h, "%d:%d", connectEvent.Port, connectEvent.Hdr.PID)
+	} else if exitEvent, ok := storage.ExitPayload(event); ok {
+		h.Write(exitEvent.Hdr.Comm[:])
+		fmt.Fprintf(h, "%d:%d", exitEvent.Hdr.PID, exitEvent.ExitCode)
+	} else if privEvent, ok := storage.PrivilegePayload(event); ok {
+		fmt.Fprintf(h, "%d:%d:%d:%d", privEvent.Hdr.PID, privEvent.Op, privEvent.NewEUID, privEvent.NewCaps)
+	} else if loadEvent, ok := storage.KernelLoadPayload(event); ok {
+		h.Write(loadEvent.Name[:])
+		fmt.Fprintf(h, "%d:%d", loadEvent.Hdr.PID, loadEvent.Kind)
+	} else if ptraceEvent, ok := storage.PtracePayload(event); ok {
+		fmt.Fprintf(h, "%d:%d:%d", ptraceEvent.Hdr.PID, ptraceEvent.TargetPID, ptraceEvent.Mode)
+	} else if dnsEvent, ok := storage.DNSPayload(event); ok {
+		h.Write(dnsEvent.Payload[:min(int(dnsEvent.Len), len(dnsEvent.Payload))])
+		fmt.Fprintf(h, "%d:%d", dnsEvent.Hdr.PID, dnsEvent.Response)
+	} else if listenEvent, ok := storage.ListenPayload(event); ok {
+		fmt.Fprintf(h, "%d:%d:%d", listenEvent.Hdr.PID, listenEvent.Cookie, listenEvent.Op)
+	} else if nsEvent, ok := storage.NamespacePayload(event); ok {
+		h.Write(nsEvent.Target[:])
+		fmt.Fprintf(h, "%d:%d:%d", nsEvent.Hdr.PID, nsEvent.Op, nsEvent.Flags)
+	} else if signalEvent, ok := storage.SignalPayload(event); ok {
+		fmt.Fprintf(h, "%d:%d:%d", signalEvent.Hdr.PID, signalEvent.TargetPID, signalEvent.Signal)
 	}
 
 	return hex.EncodeToString(h.Sum(nil))[:16]
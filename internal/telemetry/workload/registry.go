@@ -5,6 +5,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"aegis/internal/platform/events"
 )
 
 type WorkloadID uint64
@@ -12,6 +14,8 @@ type WorkloadID uint64
 type Metadata struct {
 	ID           WorkloadID
 	CgroupPath   string
+	Namespaces   events.Namespaces // of the most recent event
+	Host         bool              // Namespaces are PID 1's
 	FirstSeen    time.Time
 	LastSeen     time.Time
 	ExecCount    int64
@@ -42,34 +46,37 @@ func NewRegistry(maxSize int) *Registry {
 	}
 }
 
-func (r *Registry) RecordExec(cgroupID uint64, cgroupPath string) {
+func (r *Registry) RecordExec(cgroupID uint64, cgroupPath string, ns events.Namespaces) {
 	id := WorkloadID(cgroupID)
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	m := r.getOrCreate(id, cgroupPath)
+	m.setNamespaces(ns)
 	m.ExecCount++
 	m.LastSeen = time.Now()
 	r.touch(id)
 }
 
-func (r *Registry) RecordFile(cgroupID uint64, cgroupPath string) {
+func (r *Registry) RecordFile(cgroupID uint64, cgroupPath string, ns events.Namespaces) {
 	id := WorkloadID(cgroupID)
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	m := r.getOrCreate(id, cgroupPath)
+	m.setNamespaces(ns)
 	m.FileCount++
 	m.LastSeen = time.Now()
 	r.touch(id)
 }
 
-func (r *Registry) RecordConnect(cgroupID uint64, cgroupPath string) {
+func (r *Registry) RecordConnect(cgroupID uint64, cgroupPath string, ns events.Namespaces) {
 	id := WorkloadID(cgroupID)
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	m := r.getOrCreate(id, cgroupPath)
+	m.setNamespaces(ns)
 	m.ConnectCount++
 	m.LastSeen = time.Now()
 	r.touch(id)
@@ -142,6 +149,16 @@ func (r *Registry) getOrCreate(id WorkloadID, cgroupPath string) *Metadata {
 	return m
 }
 
+// setNamespaces keeps the latest known namespaces; a cgroup can hold
+// processes in different namespaces, e.g. during a container's startup.
+func (m *Metadata) setNamespaces(ns events.Namespaces) {
+	if !ns.Known() {
+		return
+	}
+	m.Namespaces = ns
+	m.Host = ns.IsHost()
+}
+
 func (r *Registry) touch(id WorkloadID) {
 	if elem, ok := r.lruIndex[id]; ok {
 		r.lru.MoveToFront(elem)
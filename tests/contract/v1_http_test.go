@@ -35,10 +35,13 @@ func TestV1HTTP_GetEndpointsReturnOKForRegisteredSurfaces(t *testing.T) {
 		path string
 	}{
 		{name: "system stats", path: "/api/v1/system/stats"},
+		{name: "system capabilities", path: "/api/v1/system/capabilities"},
 		{name: "system settings", path: "/api/v1/system/settings"},
 		{name: "system alerts", path: "/api/v1/system/alerts"},
+		{name: "system listeners", path: "/api/v1/system/listeners"},
 		{name: "events", path: "/api/v1/events"},
 		{name: "policies", path: "/api/v1/policies"},
+		{name: "policy sync", path: "/api/v1/policies/sync"},
 		{name: "analysis status", path: "/api/v1/analysis/status"},
 		{name: "sentinel insights", path: "/api/v1/sentinel/insights"},
 	}
@@ -231,6 +234,39 @@ func TestV1HTTP_SystemStatsReflectProbeLifecycle(t *testing.T) {
 	}
 }
 
+func TestV1HTTP_SystemCapabilitiesListsEveryLSMHook(t *testing.T) {
+	runtime := newRuntime(t)
+	handler := httpapi.NewHandler(httpapi.DependenciesFromRuntime(runtime), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/system/capabilities", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected capabilities status 200, got %d with body %s", rec.Code, rec.Body.String())
+	}
+
+	var caps system.Capabilities
+	if err := json.Unmarshal(rec.Body.Bytes(), &caps); err != nil {
+		t.Fatalf("decode capabilities response: %v", err)
+	}
+	if caps.CgroupMode == "" {
+		t.Fatal("expected a cgroup mode")
+	}
+	hooks := make(map[string]system.HookCapability, len(caps.LSMHooks))
+	for _, hook := range caps.LSMHooks {
+		hooks[hook.Name] = hook
+	}
+	for _, name := range []string{"bprm_check_security", "file_open", "socket_connect", "task_kill"} {
+		hook, ok := hooks[name]
+		if !ok {
+			t.Fatalf("expected hook %s in %+v", name, caps.LSMHooks)
+		}
+		if hook.Attached {
+			t.Fatalf("expected %s not to be attached before the runtime starts", name)
+		}
+	}
+}
+
 func TestV1HTTP_PolicyTestingEndpointsReturnEmptyAndPopulatedStates(t *testing.T) {
 	runtime := newRuntime(t)
 	handler := httpapi.NewHandler(httpapi.DependenciesFromRuntime(runtime), nil)
@@ -1,6 +1,7 @@
 package fakes
 
 import (
+	"net"
 	"sync"
 
 	"aegis/internal/policy"
@@ -30,15 +31,43 @@ func (r *RuleRepository) Save(ruleList []policy.Rule) error {
 }
 
 type KernelSync struct {
-	mu       sync.Mutex
-	SyncCall int
-	Rules    [][]policy.Rule
+	mu          sync.Mutex
+	SyncCall    int
+	Rules       [][]policy.Rule
+	Report      policy.SyncReport
+	Err         error
+	DomainRules [][]policy.Rule
+	DomainAddrs [][]net.IP
+	Resynced    [][]policy.DomainAddrs
 }
 
-func (k *KernelSync) SyncRules(ruleList []policy.Rule) error {
+func (k *KernelSync) SyncRules(ruleList []policy.Rule) (policy.SyncReport, error) {
 	k.mu.Lock()
 	defer k.mu.Unlock()
 	k.SyncCall++
 	k.Rules = append(k.Rules, append([]policy.Rule(nil), ruleList...))
-	return nil
+	return k.Report, k.Err
+}
+
+func (k *KernelSync) SyncDomainAddrs(matched []policy.Rule, addrs []net.IP) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.DomainRules = append(k.DomainRules, append([]policy.Rule(nil), matched...))
+	k.DomainAddrs = append(k.DomainAddrs, append([]net.IP(nil), addrs...))
+	return k.Err
+}
+
+func (k *KernelSync) ResyncDomainAddrs(resolved []policy.DomainAddrs) ([]policy.MapSyncStats, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.Resynced = append(k.Resynced, append([]policy.DomainAddrs(nil), resolved...))
+	return nil, k.Err
+}
+
+type DomainResolver struct {
+	Domains map[string][]net.IP
+}
+
+func (r *DomainResolver) ResolvedDomains() map[string][]net.IP {
+	return r.Domains
 }
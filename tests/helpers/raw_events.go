@@ -3,6 +3,7 @@ package helpers
 import (
 	"encoding/binary"
 	"net"
+	"strings"
 	"time"
 
 	"aegis/internal/platform/events"
@@ -13,7 +14,7 @@ func RawExecSample(pid, ppid uint32, cgroupID uint64, comm, parentComm, filename
 	encodeHeader(buf, events.EventTypeExec, pid, cgroupID, comm, blocked)
 	offset := events.EventHeaderSize
 	binary.LittleEndian.PutUint32(buf[offset:offset+4], ppid)
-	offset += 8
+	offset += 16
 	copyCString(buf[offset:offset+events.TaskCommLen], parentComm)
 	offset += events.TaskCommLen
 	copyCString(buf[offset:offset+events.PathMaxLen], filename)
@@ -22,6 +23,61 @@ func RawExecSample(pid, ppid uint32, cgroupID uint64, comm, parentComm, filename
 	return buf
 }
 
+func RawExecFlagsSample(pid, ppid uint32, cgroupID uint64, comm, parentComm, filename, commandLine string, flags events.ExecFlags, blocked bool) []byte {
+	buf := RawExecSample(pid, ppid, cgroupID, comm, parentComm, filename, commandLine, blocked)
+	buf[events.EventHeaderSize+4] = byte(flags)
+	return buf
+}
+
+// WithArgsSeq links a raw exec event to the argument records sent under seq.
+func WithArgsSeq(buf []byte, seq uint64) []byte {
+	binary.LittleEndian.PutUint64(buf[events.EventHeaderSize+8:], seq)
+	return buf
+}
+
+// RawExecArgsSamples splits argv, then env, into the chunk records the
+// kernel sends ahead of an exec event, chunkLen bytes of strings per record.
+func RawExecArgsSamples(pid uint32, cgroupID uint64, comm string, seq uint64, argv, env []string, chunkLen int) [][]byte {
+	var samples [][]byte
+	chunk := uint16(0)
+	emit := func(vector []string, flags events.ExecArgsFlags) {
+		var data []byte
+		for _, arg := range vector {
+			data = append(append(data, arg...), 0)
+		}
+		for {
+			n := min(len(data), chunkLen)
+			recordFlags := flags
+			if n == len(data) {
+				recordFlags |= events.ExecArgsLast
+			}
+			buf := make([]byte, events.ExecArgsHeaderSize+n)
+			encodeHeader(buf, events.EventTypeExecArgs, pid, cgroupID, comm, false)
+			offset := events.EventHeaderSize
+			binary.LittleEndian.PutUint64(buf[offset:offset+8], seq)
+			offset += 8
+			binary.LittleEndian.PutUint16(buf[offset:offset+2], chunk)
+			offset += 2
+			buf[offset] = byte(recordFlags)
+			offset += 2
+			binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(n))
+			offset += 4
+			copy(buf[offset:], data[:n])
+			samples = append(samples, buf)
+			chunk++
+			data = data[n:]
+			if len(data) == 0 {
+				return
+			}
+		}
+	}
+	emit(argv, 0)
+	if env != nil {
+		emit(env, events.ExecArgsEnv)
+	}
+	return samples
+}
+
 func RawFileSample(pid uint32, cgroupID uint64, comm, filename string, flags uint32, ino, dev uint64, blocked bool) []byte {
 	buf := make([]byte, events.FileOpenEventSize)
 	encodeHeader(buf, events.EventTypeFileOpen, pid, cgroupID, comm, blocked)
@@ -36,6 +92,13 @@ func RawFileSample(pid uint32, cgroupID uint64, comm, filename string, flags uin
 	return buf
 }
 
+func RawFileOpSample(pid uint32, cgroupID uint64, comm, filename string, op events.FileOp, blocked bool) []byte {
+	buf := RawFileSample(pid, cgroupID, comm, filename, 0, 0, 0, blocked)
+	offset := events.EventHeaderSize + 8 + 8 + 4
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(op))
+	return buf
+}
+
 func RawConnectSample(pid uint32, cgroupID uint64, comm, ip string, family, port uint16, blocked bool) []byte {
 	buf := make([]byte, events.ConnectEventSize)
 	encodeHeader(buf, events.EventTypeConnect, pid, cgroupID, comm, blocked)
@@ -59,6 +122,179 @@ func RawConnectSample(pid uint32, cgroupID uint64, comm, ip string, family, port
 	return buf
 }
 
+// RawUnixConnectSample builds an AF_UNIX connect; a leading "@" in path
+// encodes an abstract socket name.
+func RawUnixConnectSample(pid uint32, cgroupID uint64, comm, path string, blocked bool) []byte {
+	buf := make([]byte, events.ConnectEventSize)
+	encodeHeader(buf, events.EventTypeConnect, pid, cgroupID, comm, blocked)
+	offset := events.EventHeaderSize + 4
+	binary.LittleEndian.PutUint16(buf[offset:offset+2], events.AFUnix)
+	offset += 2 + 2 + 16
+	sunPath := buf[offset : offset+events.UnixPathLen]
+	if strings.HasPrefix(path, "@") {
+		copy(sunPath[1:], path[1:])
+	} else {
+		copyCString(sunPath, path)
+	}
+	return buf
+}
+
+func RawExitSample(pid, ppid uint32, cgroupID uint64, comm string, exitCode, signal uint32, runtime time.Duration) []byte {
+	buf := make([]byte, events.ExitEventSize)
+	encodeHeader(buf, events.EventTypeExit, pid, cgroupID, comm, false)
+	offset := events.EventHeaderSize
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], ppid)
+	offset += 4
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], exitCode)
+	offset += 4
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], signal)
+	offset += 8
+	binary.LittleEndian.PutUint64(buf[offset:offset+8], uint64(runtime))
+	return buf
+}
+
+func RawPrivilegeSample(pid uint32, cgroupID uint64, comm string, op events.PrivilegeOp, oldUID, newUID uint32) []byte {
+	buf := make([]byte, events.PrivilegeEventSize)
+	encodeHeader(buf, events.EventTypePrivilege, pid, cgroupID, comm, false)
+	offset := events.EventHeaderSize
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(op))
+	offset += 4
+	for _, id := range []uint32{oldUID, oldUID, 0, 0, newUID, newUID, 0, 0} {
+		binary.LittleEndian.PutUint32(buf[offset:offset+4], id)
+		offset += 4
+	}
+	return buf
+}
+
+func RawKernelLoadSample(pid uint32, cgroupID uint64, comm string, kind events.LoadKind, name string, progType uint32, blocked bool) []byte {
+	buf := make([]byte, events.KernelLoadEventSize)
+	encodeHeader(buf, events.EventTypeKernelLoad, pid, cgroupID, comm, blocked)
+	offset := events.EventHeaderSize
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(kind))
+	offset += 8
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], progType)
+	offset += 8
+	copyCString(buf[offset:offset+events.PathMaxLen], name)
+	return buf
+}
+
+func RawPtraceSample(pid uint32, cgroupID uint64, comm string, targetPID uint32, targetComm string, mode events.PtraceMode, blocked bool) []byte {
+	buf := make([]byte, events.PtraceEventSize)
+	encodeHeader(buf, events.EventTypePtrace, pid, cgroupID, comm, blocked)
+	offset := events.EventHeaderSize
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], targetPID)
+	offset += 4
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(mode))
+	offset += 4
+	copyCString(buf[offset:offset+events.TaskCommLen], targetComm)
+	return buf
+}
+
+func RawSignalSample(pid uint32, cgroupID uint64, comm string, targetPID uint32, targetComm string, signal uint32) []byte {
+	buf := make([]byte, events.SignalEventSize)
+	encodeHeader(buf, events.EventTypeSignal, pid, cgroupID, comm, false)
+	offset := events.EventHeaderSize
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], targetPID)
+	offset += 4
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], signal)
+	offset += 4
+	copyCString(buf[offset:offset+events.TaskCommLen], targetComm)
+	return buf
+}
+
+func RawDNSSample(pid uint32, cgroupID uint64, comm, server string, response bool, payload []byte) []byte {
+	buf := make([]byte, events.DNSEventSize)
+	encodeHeader(buf, events.EventTypeDNS, pid, cgroupID, comm, false)
+	offset := events.EventHeaderSize
+	binary.LittleEndian.PutUint16(buf[offset:offset+2], 2)
+	offset += 2
+	binary.LittleEndian.PutUint16(buf[offset:offset+2], 53)
+	offset += 2
+	buf[offset] = byte(events.DNSTransportUDP)
+	if response {
+		buf[offset+1] = 1
+	}
+	offset += 4
+	if parsed := net.ParseIP(server).To4(); parsed != nil {
+		copy(buf[offset:offset+4], parsed)
+	}
+	offset += 4 + 16
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], uint32(len(payload)))
+	offset += 4
+	copy(buf[offset:offset+events.DNSPayloadLen], payload)
+	return buf
+}
+
+func RawListenSample(pid uint32, cgroupID uint64, comm, ip string, port uint16, protocol uint8, op events.ListenOp, cookie uint64, blocked bool) []byte {
+	buf := make([]byte, events.ListenEventSize)
+	encodeHeader(buf, events.EventTypeListen, pid, cgroupID, comm, blocked)
+	offset := events.EventHeaderSize
+	binary.LittleEndian.PutUint64(buf[offset:offset+8], cookie)
+	offset += 8
+	binary.LittleEndian.PutUint16(buf[offset:offset+2], 2)
+	offset += 2
+	binary.LittleEndian.PutUint16(buf[offset:offset+2], port)
+	offset += 2
+	buf[offset] = protocol
+	buf[offset+1] = byte(op)
+	offset += 4
+	if parsed := net.ParseIP(ip).To4(); parsed != nil {
+		copy(buf[offset:offset+4], parsed)
+	}
+	return buf
+}
+
+// RawNamespaceSample builds a setns/unshare event; newNS is where the call
+// left the task.
+func RawNamespaceSample(pid uint32, cgroupID uint64, comm string, op events.NamespaceOp, flags uint32, newNS events.Namespaces) []byte {
+	buf := make([]byte, events.NamespaceEventSize)
+	encodeHeader(buf, events.EventTypeNamespace, pid, cgroupID, comm, false)
+	offset := events.EventHeaderSize
+	buf[offset] = byte(op)
+	offset += 4
+	binary.LittleEndian.PutUint32(buf[offset:offset+4], flags)
+	offset += 4
+	for _, inum := range []uint32{newNS.PID, newNS.Mnt, newNS.Net, newNS.User, newNS.UTS} {
+		binary.LittleEndian.PutUint32(buf[offset:offset+4], inum)
+		offset += 4
+	}
+	return buf
+}
+
+// RawMountSample builds a mount event of source (of type fsType) on target.
+func RawMountSample(pid uint32, cgroupID uint64, comm, source, fsType, target string, flags uint32) []byte {
+	buf := RawNamespaceSample(pid, cgroupID, comm, events.NamespaceOpMount, flags, events.Namespaces{})
+	offset := events.EventHeaderSize + 32
+	copyCString(buf[offset:offset+events.FSTypeLen], fsType)
+	offset += events.FSTypeLen
+	copyCString(buf[offset:offset+events.PathMaxLen], source)
+	offset += events.PathMaxLen
+	copyCString(buf[offset:offset+events.PathMaxLen], target)
+	return buf
+}
+
+// DNSMessage builds a single-question DNS message for name. With answers it
+// is a response carrying one A record per address.
+func DNSMessage(name string, answers ...string) []byte {
+	msg := []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+	if len(answers) > 0 {
+		msg[2] = 0x81
+		msg[3] = 0x80
+		binary.BigEndian.PutUint16(msg[6:8], uint16(len(answers)))
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 1, 0, 1)
+	for _, answer := range answers {
+		// Name is a compression pointer back to the question.
+		msg = append(msg, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0x0e, 0x10, 0, 4)
+		msg = append(msg, net.ParseIP(answer).To4()...)
+	}
+	return msg
+}
+
 func encodeHeader(buf []byte, eventType events.EventType, pid uint32, cgroupID uint64, comm string, blocked bool) {
 	offset := 0
 	binary.LittleEndian.PutUint64(buf[offset:offset+8], uint64(time.Second))
@@ -82,6 +318,37 @@ func encodeHeader(buf []byte, eventType events.EventType, pid uint32, cgroupID u
 	copyCString(buf[offset:offset+events.TaskCommLen], comm)
 }
 
+// WithNamespaces sets the namespace inodes in a raw event's header.
+func WithNamespaces(buf []byte, ns events.Namespaces) []byte {
+	offset := events.EventHeaderSize - 24
+	for _, inum := range []uint32{ns.PID, ns.Mnt, ns.Net, ns.User, ns.UTS} {
+		binary.LittleEndian.PutUint32(buf[offset:offset+4], inum)
+		offset += 4
+	}
+	return buf
+}
+
+// WithResponse marks a raw event as blocked by the kernel with the given
+// response, such as events.ResponseKill.
+func WithResponse(buf []byte, response uint8) []byte {
+	buf[33] = 1
+	buf[34] = response
+	return buf
+}
+
+// WithSelfProtect marks a raw event as blocked by the kernel to protect Aegis.
+func WithSelfProtect(buf []byte) []byte {
+	WithResponse(buf, events.ResponseBlock)
+	buf[35] = 1
+	return buf
+}
+
+// WithPathCut marks a raw event's path as only the tail of the real one.
+func WithPathCut(buf []byte) []byte {
+	buf[36] = 1
+	return buf
+}
+
 func copyCString(dst []byte, value string) {
 	for i := range dst {
 		dst[i] = 0
@@ -0,0 +1,356 @@
+package ebpf_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"aegis/internal/platform/ebpf"
+	"aegis/internal/platform/events"
+	"aegis/internal/policy"
+	"aegis/tests/helpers"
+)
+
+func preparedFileRule(name, filename string, action policy.ActionType) policy.Rule {
+	rule := helpers.ActiveFileRule(name, filename, action)
+	rule.Match.Prepare()
+	return rule
+}
+
+func execRule(name, processName, parentName string) policy.Rule {
+	return policy.Rule{
+		Name:   name,
+		Action: policy.ActionBlock,
+		Type:   policy.RuleTypeExec,
+		State:  policy.RuleStateProduction,
+		Match: policy.MatchCondition{
+			ProcessName:     processName,
+			ProcessNameType: policy.MatchTypeExact,
+			ParentName:      parentName,
+			ParentNameType:  policy.MatchTypeExact,
+		},
+	}
+}
+
+func pathKey(path string) ebpf.PathKey {
+	var key ebpf.PathKey
+	copy(key[:], path)
+	return key
+}
+
+func TestDiffMap_CountsAddedChangedAndRemoved(t *testing.T) {
+	current := map[string]uint8{"same": 1, "changed": 1, "stale": 2}
+	desired := map[string]uint8{"same": 1, "changed": 3, "new": 2}
+
+	diff := ebpf.DiffMap("test_map", current, desired)
+
+	want := policy.MapSyncStats{Map: "test_map", Added: 1, Changed: 1, Removed: 1, Total: 3}
+	if diff.Stats != want {
+		t.Fatalf("expected stats %+v, got %+v", want, diff.Stats)
+	}
+	if len(diff.Put) != 2 || diff.Put["changed"] != 3 || diff.Put["new"] != 2 {
+		t.Fatalf("expected puts for changed and new keys only, got %v", diff.Put)
+	}
+	if len(diff.Delete) != 1 || diff.Delete[0] != "stale" {
+		t.Fatalf("expected only the stale key to be deleted, got %v", diff.Delete)
+	}
+}
+
+func TestDesiredFiles_UnchangedResyncIsANoop(t *testing.T) {
+	ruleList := []policy.Rule{
+		preparedFileRule("Protect shadow", "/etc/shadow", policy.ActionBlock),
+		preparedFileRule("Watch hosts", "/etc/hosts", policy.ActionAlert),
+	}
+	current, _ := ebpf.DesiredFiles(ruleList)
+	desired, _ := ebpf.DesiredFiles(ruleList)
+
+	diff := ebpf.DiffMap("monitored_files", current, desired)
+	if diff.Stats.Added != 0 || diff.Stats.Changed != 0 || diff.Stats.Removed != 0 {
+		t.Fatalf("expected an unchanged resync to touch nothing, got %+v", diff.Stats)
+	}
+	if diff.Stats.Total != 2 {
+		t.Fatalf("expected 2 entries, got %d", diff.Stats.Total)
+	}
+}
+
+func TestDesiredFiles_RemovingARuleDeletesOnlyItsKey(t *testing.T) {
+	shadow := preparedFileRule("Protect shadow", "/etc/shadow", policy.ActionBlock)
+	hosts := preparedFileRule("Watch hosts", "/etc/hosts", policy.ActionAlert)
+	current, _ := ebpf.DesiredFiles([]policy.Rule{shadow, hosts})
+	desired, _ := ebpf.DesiredFiles([]policy.Rule{hosts})
+
+	diff := ebpf.DiffMap("monitored_files", current, desired)
+	if diff.Stats.Added != 0 || diff.Stats.Changed != 0 || diff.Stats.Removed != 1 {
+		t.Fatalf("expected exactly one removal, got %+v", diff.Stats)
+	}
+	if len(diff.Delete) != 1 || diff.Delete[0] != pathKey("/etc/shadow") {
+		t.Fatalf("expected only /etc/shadow to be deleted, got %d keys", len(diff.Delete))
+	}
+}
+
+func TestFileMaskForRule_MergesBitsOfRulesOnTheSamePath(t *testing.T) {
+	read := preparedFileRule("No reads", "/etc/shadow", policy.ActionBlock)
+	read.Match.Access = "read"
+	write := preparedFileRule("No writes", "/etc/shadow", policy.ActionBlock)
+	write.Match.Access = "write"
+	alert := preparedFileRule("Watch", "/etc/shadow", policy.ActionAlert)
+
+	readMask := ebpf.FileMaskForRule(read)
+	writeMask := ebpf.FileMaskForRule(write)
+	if readMask&(1<<events.FileOpRead) == 0 || readMask&(1<<events.FileOpWrite) != 0 {
+		t.Fatalf("expected read rule to deny reads only, got %#x", readMask)
+	}
+	// Without the report bit the kernel leaves the path's writes alone.
+	if readMask&1 != 0 {
+		t.Fatalf("expected read rule not to report other operations, got %#x", readMask)
+	}
+	alertMask := ebpf.FileMaskForRule(alert)
+	if alertMask != 1 {
+		t.Fatalf("expected alert rule to only set the report bit, got %#x", alertMask)
+	}
+
+	files, _ := ebpf.DesiredFiles([]policy.Rule{read, write, alert})
+	if got := files[pathKey("/etc/shadow")]; got != readMask|writeMask|alertMask {
+		t.Fatalf("expected merged mask %#x, got %#x", readMask|writeMask|alertMask, got)
+	}
+}
+
+func TestExecKeyForRule_RejectsNamesLongerThanAComm(t *testing.T) {
+	longest := strings.Repeat("a", events.TaskCommLen-1)
+	tooLong := strings.Repeat("a", events.TaskCommLen)
+
+	key, ok := ebpf.ExecKeyForRule(execRule("Fits", longest, ""))
+	if !ok {
+		t.Fatalf("expected a %d byte process name to fit", len(longest))
+	}
+	if got := string(key.Comm[:len(longest)]); got != longest || key.Comm[len(longest)] != 0 {
+		t.Fatalf("expected NUL-terminated comm %q, got %q", longest, key.Comm)
+	}
+	if _, ok := ebpf.ExecKeyForRule(execRule("Long name", tooLong, "")); ok {
+		t.Fatal("expected a process name of TaskCommLen bytes to be rejected")
+	}
+	if _, ok := ebpf.ExecKeyForRule(execRule("Long parent", "sh", tooLong)); ok {
+		t.Fatal("expected a parent name of TaskCommLen bytes to be rejected")
+	}
+
+	contains := execRule("Contains", "sh", "")
+	contains.Match.ProcessNameType = policy.MatchTypeContains
+	if _, ok := ebpf.ExecKeyForRule(contains); ok {
+		t.Fatal("expected a non-exact process name to be rejected")
+	}
+
+	desired := ebpf.DesiredExecRules([]policy.Rule{execRule("Fits", longest, ""), execRule("Long name", tooLong, "")})
+	if len(desired) != 1 {
+		t.Fatalf("expected only the fitting rule in exec_rules, got %d entries", len(desired))
+	}
+}
+
+func TestDesiredDomainAddrs_KeepsStrongestActionPerAddress(t *testing.T) {
+	domainRule := func(name string, action policy.ActionType) policy.Rule {
+		return policy.Rule{
+			Name:   name,
+			Action: action,
+			State:  policy.RuleStateProduction,
+			Match:  policy.MatchCondition{DestDomain: "*.pastebin.com"},
+		}
+	}
+	addrs := []net.IP{net.ParseIP("104.20.1.1"), net.ParseIP("2606:4700::1")}
+	resolved := []policy.DomainAddrs{
+		{Rules: []policy.Rule{domainRule("watch", policy.ActionAlert)}, Addrs: addrs},
+		{Rules: []policy.Rule{domainRule("block", policy.ActionBlock), domainRule("allow", policy.ActionAllow)}, Addrs: addrs[:1]},
+	}
+
+	v4, v6 := ebpf.DesiredDomainAddrs(resolved)
+	if len(v4) != 1 || len(v6) != 1 {
+		t.Fatalf("expected one entry per family, got %d v4 and %d v6", len(v4), len(v6))
+	}
+	for _, action := range v4 {
+		if action != policy.BPFActionBlock {
+			t.Fatalf("expected the v4 address to be blocked, got %d", action)
+		}
+	}
+	for _, action := range v6 {
+		if action != policy.BPFActionMonitor {
+			t.Fatalf("expected the v6 address to be monitored, got %d", action)
+		}
+	}
+
+	again, _ := ebpf.DesiredDomainAddrs(resolved)
+	if diff := ebpf.DiffMap("domain_v4", v4, again); diff.Stats.Added+diff.Stats.Changed+diff.Stats.Removed != 0 {
+		t.Fatalf("expected an unchanged resync to touch nothing, got %+v", diff.Stats)
+	}
+}
+
+func ptraceRule(name string, action policy.ActionType, targetName, tracerName string) policy.Rule {
+	rule := policy.Rule{
+		Name:   name,
+		Action: action,
+		Type:   policy.RuleTypePtrace,
+		State:  policy.RuleStateProduction,
+	}
+	if targetName != "" {
+		rule.Match.TargetName = targetName
+		rule.Match.TargetNameType = policy.MatchTypeExact
+	}
+	if tracerName != "" {
+		rule.Match.ProcessName = tracerName
+		rule.Match.ProcessNameType = policy.MatchTypeExact
+	}
+	return rule
+}
+
+func ptraceKey(target, tracer string) ebpf.PtraceRuleKey {
+	var key ebpf.PtraceRuleKey
+	copy(key.Target[:], target)
+	copy(key.Tracer[:], tracer)
+	return key
+}
+
+// kernelPtraceAction mirrors check_ptrace_action in main.bpf.c: the exact
+// key and both wildcard keys are looked up, and an allow entry (0) wins.
+func kernelPtraceAction(rules map[ebpf.PtraceRuleKey]uint8, target, tracer string) uint8 {
+	var result uint8
+	for _, key := range []ebpf.PtraceRuleKey{ptraceKey(target, tracer), ptraceKey(target, ""), ptraceKey("", tracer)} {
+		action, ok := rules[key]
+		if !ok {
+			continue
+		}
+		if action == 0 {
+			return 0
+		}
+		result = max(result, action)
+	}
+	return result
+}
+
+func TestDesiredPtraceRules_AllowOverridesWildcardBlock(t *testing.T) {
+	desired := ebpf.DesiredPtraceRules([]policy.Rule{
+		ptraceRule("strace allowed", policy.ActionAllow, "sshd", "strace"),
+		ptraceRule("protect sshd", policy.ActionBlock, "sshd", ""),
+	})
+
+	if got := kernelPtraceAction(desired, "sshd", "strace"); got != 0 {
+		t.Fatalf("expected the kernel to allow strace on sshd, got action %d", got)
+	}
+	if got := kernelPtraceAction(desired, "sshd", "gdb"); got != policy.BPFActionBlock {
+		t.Fatalf("expected the kernel to block gdb on sshd, got action %d", got)
+	}
+}
+
+func execKey(comm, pcomm string) ebpf.ExecRuleKey {
+	var key ebpf.ExecRuleKey
+	copy(key.Comm[:], comm)
+	copy(key.PComm[:], pcomm)
+	return key
+}
+
+// kernelExecAction mirrors check_exec_action in main.bpf.c.
+func kernelExecAction(rules map[ebpf.ExecRuleKey]uint8, comm, pcomm string) uint8 {
+	var result uint8
+	for _, key := range []ebpf.ExecRuleKey{execKey(comm, pcomm), execKey(comm, ""), execKey("", pcomm)} {
+		action, ok := rules[key]
+		if !ok {
+			continue
+		}
+		if action == 0 {
+			return 0
+		}
+		result = max(result, action)
+	}
+	return result
+}
+
+func TestDesiredExecRules_AllowOverridesWildcardBlock(t *testing.T) {
+	allow := execRule("bash from sshd", "bash", "sshd")
+	allow.Action = policy.ActionAllow
+	desired := ebpf.DesiredExecRules([]policy.Rule{allow, execRule("no bash", "bash", "")})
+
+	if got := kernelExecAction(desired, "bash", "sshd"); got != 0 {
+		t.Fatalf("expected the kernel to allow bash under sshd, got action %d", got)
+	}
+	if got := kernelExecAction(desired, "bash", "cron"); got != policy.BPFActionBlock {
+		t.Fatalf("expected the kernel to block bash under cron, got action %d", got)
+	}
+}
+
+func connectRule(name string, action policy.ActionType, destIP string, destPort uint16) policy.Rule {
+	return policy.Rule{
+		Name:   name,
+		Action: action,
+		Type:   policy.RuleTypeConnect,
+		State:  policy.RuleStateProduction,
+		Match: policy.MatchCondition{
+			DestIP:   destIP,
+			DestPort: destPort,
+		},
+	}
+}
+
+func TestDesiredAddrs_PrefixLengthCoversPortAndMask(t *testing.T) {
+	v4, v6 := ebpf.DesiredAddrs([]policy.Rule{
+		connectRule("block ten", policy.ActionBlock, "10.0.0.0/8", 0),
+		connectRule("alert inside ten", policy.ActionAlert, "10.9.9.9", 0),
+		connectRule("alert https", policy.ActionAlert, "10.1.2.3", 443),
+		connectRule("block ssh v6", policy.ActionBlock, "2001:db8::/32", 22),
+	})
+
+	// The prefix length counts the 16 port bits ahead of the address. The
+	// alert inside the /8 inherits its block; the one on another port does
+	// not.
+	wantV4 := map[ebpf.IPv4LPMKey]uint8{
+		{Prefixlen: 16 + 8, Addr: [4]byte{10, 0, 0, 0}}:                             policy.BPFActionBlock,
+		{Prefixlen: 16 + 32, Addr: [4]byte{10, 9, 9, 9}}:                            policy.BPFActionBlock,
+		{Prefixlen: 16 + 32, Port: [2]byte{0x01, 0xbb}, Addr: [4]byte{10, 1, 2, 3}}: policy.BPFActionMonitor,
+	}
+	if len(v4) != len(wantV4) {
+		t.Fatalf("expected %d IPv4 keys, got %+v", len(wantV4), v4)
+	}
+	for key, action := range wantV4 {
+		if got, ok := v4[key]; !ok || got != action {
+			t.Fatalf("expected %+v -> %d, got %d (present %v)", key, action, got, ok)
+		}
+	}
+
+	var v6Key ebpf.IPv6LPMKey
+	v6Key.Prefixlen = 16 + 32
+	v6Key.Port = [2]byte{0, 22}
+	copy(v6Key.Addr[:], net.ParseIP("2001:db8::"))
+	if len(v6) != 1 || v6[v6Key] != policy.BPFActionBlock {
+		t.Fatalf("expected %+v -> block, got %+v", v6Key, v6)
+	}
+}
+
+func TestDesiredFilesAndPorts_ScopedRulesUseCgroupKeys(t *testing.T) {
+	global := preparedFileRule("global", "/etc/shadow", policy.ActionBlock)
+	scoped := preparedFileRule("scoped", "/etc/passwd", policy.ActionBlock)
+	scoped.Match.CgroupID = "4242"
+	badScope := preparedFileRule("bad scope", "/etc/hosts", policy.ActionBlock)
+	badScope.Match.CgroupID = "not-a-number"
+
+	files, scopedFiles := ebpf.DesiredFiles([]policy.Rule{global, scoped, badScope})
+	if _, ok := files[pathKey("/etc/shadow")]; !ok || len(files) != 1 {
+		t.Fatalf("expected only the unscoped rule in monitored_files, got %d keys", len(files))
+	}
+	fileKey := ebpf.ScopedPathKey{CgroupID: 4242, Path: pathKey("/etc/passwd")}
+	if mask, ok := scopedFiles[fileKey]; !ok || mask != ebpf.FileMaskForRule(scoped) || len(scopedFiles) != 1 {
+		t.Fatalf("expected /etc/passwd under cgroup 4242 in scoped_files, got %d keys", len(scopedFiles))
+	}
+
+	portRule := func(name string, port uint16, cgroupID string) policy.Rule {
+		rule := connectRule(name, policy.ActionBlock, "", port)
+		rule.Match.CgroupID = cgroupID
+		return rule
+	}
+	ports, scopedPorts := ebpf.DesiredPorts([]policy.Rule{
+		portRule("global", 4444, ""),
+		portRule("scoped", 6667, "4242"),
+		portRule("bad scope", 1337, "not-a-number"),
+	})
+	if len(ports) != 1 || ports[4444] != policy.BPFActionBlock {
+		t.Fatalf("expected only port 4444 in blocked_ports, got %v", ports)
+	}
+	portKey := ebpf.ScopedPortKey{CgroupID: 4242, Port: 6667}
+	if len(scopedPorts) != 1 || scopedPorts[portKey] != policy.BPFActionBlock {
+		t.Fatalf("expected %+v in scoped_ports, got %+v", portKey, scopedPorts)
+	}
+}
@@ -0,0 +1,93 @@
+package ebpf_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aegis/internal/platform/config"
+	"aegis/internal/platform/ebpf"
+	"aegis/internal/system"
+)
+
+func writePins(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("create pin directory: %v", err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("write pin %s: %v", name, err)
+		}
+	}
+}
+
+func TestUnpinNewMaps_KeepsReusedPins(t *testing.T) {
+	dir := t.TempDir()
+	writePins(t, dir, "monitored_files", "exec_rules")
+	before := ebpf.PinnedMaps(dir)
+
+	writePins(t, dir, "listen_rules", "ptrace_rules")
+	ebpf.UnpinNewMaps(dir, before)
+
+	after := ebpf.PinnedMaps(dir)
+	if len(after) != 2 || !after["monitored_files"] || !after["exec_rules"] {
+		t.Fatalf("expected only the reused pins to remain, got %v", after)
+	}
+}
+
+func TestPinnedMaps_MissingDirectoryIsEmpty(t *testing.T) {
+	if pins := ebpf.PinnedMaps(filepath.Join(t.TempDir(), "maps")); len(pins) != 0 {
+		t.Fatalf("expected no pins, got %v", pins)
+	}
+}
+
+func TestUnpin_RemovesPinsAndDirectories(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "aegis")
+	writePins(t, filepath.Join(root, "links"), "file_open", "bprm_check_security")
+	writePins(t, filepath.Join(root, "maps"), "monitored_files")
+
+	removed, err := ebpf.Unpin(config.KernelConfig{PinPath: root})
+	if err != nil {
+		t.Fatalf("unpin: %v", err)
+	}
+	if removed != 3 {
+		t.Fatalf("expected 3 pins removed, got %d", removed)
+	}
+	if _, err := os.Stat(root); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the pin path to be removed, got %v", err)
+	}
+
+	if removed, err := ebpf.Unpin(config.KernelConfig{PinPath: root}); err != nil || removed != 0 {
+		t.Fatalf("expected unpinning nothing to succeed, got %d, %v", removed, err)
+	}
+}
+
+func TestAdoptPinned_NothingToAdoptWithoutLinks(t *testing.T) {
+	root := t.TempDir()
+	cfg := config.KernelConfig{
+		PinPath: root,
+		BPFPath: filepath.Join(root, "missing.bpf.o"),
+	}
+
+	// Neither a missing nor an empty links directory gets as far as the BPF
+	// object.
+	var caps system.Capabilities
+	if objs, links, err := ebpf.AdoptPinned(cfg, &caps); err != nil || objs != nil || links != nil {
+		t.Fatalf("expected nothing to adopt without a links directory, got %v, %v, %v", objs, links, err)
+	}
+	writePins(t, filepath.Join(root, "links"))
+	if objs, links, err := ebpf.AdoptPinned(cfg, &caps); err != nil || objs != nil || links != nil {
+		t.Fatalf("expected nothing to adopt from an empty links directory, got %v, %v, %v", objs, links, err)
+	}
+
+	writePins(t, filepath.Join(root, "links"), "file_open")
+	if _, _, err := ebpf.AdoptPinned(cfg, &caps); err == nil || !strings.Contains(err.Error(), "bpf object") {
+		t.Fatalf("expected pinned links to need the BPF object, got %v", err)
+	}
+	if pins := ebpf.PinnedMaps(filepath.Join(root, "links")); !pins["file_open"] {
+		t.Fatalf("expected a failed adopt to leave the pins alone, got %v", pins)
+	}
+}
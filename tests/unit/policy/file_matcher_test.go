@@ -6,6 +6,7 @@ import (
 	"syscall"
 	"testing"
 
+	"aegis/internal/platform/events"
 	"aegis/internal/policy"
 	"aegis/internal/policy/rules"
 	"aegis/tests/helpers"
@@ -91,3 +92,111 @@ func TestFileMatcher_MatchesWildcardRulesAgainstRelativeAndCanonicalForms(t *tes
 		t.Fatal("expected wildcard rule to match canonical form")
 	}
 }
+
+func TestFileMatcher_MatchesNestedFilesByDirectoryInode(t *testing.T) {
+	dir := t.TempDir()
+	engine := rules.NewEngine([]policy.Rule{
+		helpers.ActiveFileRule("Watch dir", dir+"/*", policy.ActionBlock),
+	})
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("failed to stat dir: %v", err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+
+	// The kernel only reports the last path segments of deeply nested files.
+	event := &events.FileOpenEvent{DirIno: stat.Ino, DirDev: uint64(stat.Dev)}
+	matched, rule, _ := engine.MatchFileOpen(event, "c/d.txt")
+	if !matched || rule == nil || rule.Name != "Watch dir" {
+		t.Fatalf("expected directory inode match, got matched=%v rule=%+v", matched, rule)
+	}
+
+	event.DirIno++
+	if matched, _, _ := engine.MatchFileOpen(event, "c/d.txt"); matched {
+		t.Fatal("expected no match for a different directory inode")
+	}
+}
+
+func TestFileMatcher_NameGlobIsNotADirectoryRule(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "app"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	rule := helpers.ActiveFileRule("App logs", dir+"/app*", policy.ActionBlock)
+	rule.Match.Prepare()
+	if _, ok := rule.Match.DirInodeKey(); ok {
+		t.Fatal("expected a name glob not to watch the directory its stem names")
+	}
+
+	engine := rules.NewEngine([]policy.Rule{rule})
+	if matched, _, _ := engine.MatchFile(0, 0, dir+"/app.log", 0, 0); !matched {
+		t.Fatal("expected name glob to match a file sharing its prefix")
+	}
+	if matched, _, _ := engine.MatchFile(0, 0, dir+"/other.log", 0, 0); matched {
+		t.Fatal("expected name glob not to match other files")
+	}
+}
+
+func TestKernelDev_RoundTripsUserspaceEncoding(t *testing.T) {
+	// 8:1 is /dev/sda1; 259:3 exercises the extended minor/major bits.
+	for _, dev := range []uint64{0x801, 0x10303, 0x1} {
+		if got := events.UserDev(events.KernelDev(dev)); got != dev {
+			t.Fatalf("round trip of %#x produced %#x", dev, got)
+		}
+	}
+	if got := events.KernelDev(0x801); got != 8<<20|1 {
+		t.Fatalf("unexpected kernel encoding %#x", got)
+	}
+}
+
+func TestFileMatcher_RelativeRuleMatchesKernelAbsolutePath(t *testing.T) {
+	engine := rules.NewEngine([]policy.Rule{
+		helpers.ActiveFileRule("Config alert", "config/settings.yaml", policy.ActionAlert),
+	})
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if matched, _, _ := engine.MatchFile(0, 0, filepath.Join(cwd, "config/settings.yaml"), 0, 0); !matched {
+		t.Fatal("expected relative rule to match the absolute path reported by the kernel")
+	}
+	if matched, _, _ := engine.MatchFile(0, 0, "/srv/other/config/settings.yaml", 0, 0); matched {
+		t.Fatal("expected a same-named file elsewhere not to match")
+	}
+}
+
+func TestFileMatcher_CutPathSkipsPathRules(t *testing.T) {
+	engine := rules.NewEngine([]policy.Rule{
+		helpers.ActiveFileRule("Protect passwd", "/etc/passwd", policy.ActionBlock),
+	})
+
+	event := &events.FileOpenEvent{}
+	if matched, _, _ := engine.MatchFileOpen(event, "/etc/passwd"); !matched {
+		t.Fatal("expected full path to match")
+	}
+
+	// "/etc/passwd" may be the tail of a deeper path such as /srv/x/etc/passwd.
+	event.Hdr.PathCut = 1
+	if matched, _, _ := engine.MatchFileOpen(event, "/etc/passwd"); matched {
+		t.Fatal("expected cut path not to match a path rule")
+	}
+}
+
+func TestFileMatcher_DecodedCutPathSkipsPathRules(t *testing.T) {
+	engine := rules.NewEngine([]policy.Rule{
+		helpers.ActiveFileRule("Protect passwd", "/etc/passwd", policy.ActionBlock),
+	})
+
+	record, err := events.DecodeSample(helpers.WithPathCut(helpers.RawFileSample(42, 7, "cat", "/etc/passwd", 0, 0, 0, false)))
+	if err != nil {
+		t.Fatalf("decode sample: %v", err)
+	}
+	if record.FileOpen == nil || record.FileOpen.Hdr.PathCut != 1 {
+		t.Fatalf("expected the decoded header to carry path_cut, got %+v", record.FileOpen)
+	}
+	if matched, _, _ := engine.MatchFileOpen(record.FileOpen, "/etc/passwd"); matched {
+		t.Fatal("expected a decoded cut path not to match a path rule")
+	}
+}
@@ -1,6 +1,11 @@
 package policy_test
 
 import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -38,32 +43,37 @@ func fileRecord(t *testing.T, sample []byte, filename string, blocked bool) *tel
 	}
 }
 
-func execRecord(t *testing.T, sample []byte) *telemetry.Record {
+// ingestRecord decodes a raw sample and ingests it into a throwaway
+// telemetry service, the way the pipeline hands records to Evaluate.
+func ingestRecord(t *testing.T, raw []byte) *telemetry.Record {
+	t.Helper()
+	return ingestInto(t, telemetry.NewService(10, 10, nil, nil, nil), raw)
+}
+
+// ingestInto is ingestRecord for tests whose samples build on each other,
+// such as a DNS reply annotating a later connect.
+func ingestInto(t *testing.T, telemetryService *telemetry.Service, raw []byte) *telemetry.Record {
 	t.Helper()
 
-	record, err := events.DecodeSample(sample)
+	record, err := events.DecodeSample(raw)
 	if err != nil {
-		t.Fatalf("decode exec sample: %v", err)
+		t.Fatalf("decode sample: %v", err)
 	}
-	ingested, err := telemetry.NewService(10, 10, nil, nil, nil).Ingest(record)
+	ingested, err := telemetryService.Ingest(record)
 	if err != nil {
-		t.Fatalf("ingest exec sample: %v", err)
+		t.Fatalf("ingest sample: %v", err)
 	}
 	return ingested
 }
 
-func connectRecord(t *testing.T, sample []byte) *telemetry.Record {
+func execRecord(t *testing.T, sample []byte) *telemetry.Record {
 	t.Helper()
+	return ingestRecord(t, sample)
+}
 
-	record, err := events.DecodeSample(sample)
-	if err != nil {
-		t.Fatalf("decode connect sample: %v", err)
-	}
-	ingested, err := telemetry.NewService(10, 10, nil, nil, nil).Ingest(record)
-	if err != nil {
-		t.Fatalf("ingest connect sample: %v", err)
-	}
-	return ingested
+func connectRecord(t *testing.T, sample []byte) *telemetry.Record {
+	t.Helper()
+	return ingestRecord(t, sample)
 }
 
 func TestPolicyService_CreatePromoteDeleteLifecycle(t *testing.T) {
@@ -198,6 +208,100 @@ func TestPolicyService_EvaluateExecAllowRuleReturnsAllowWithoutAlert(t *testing.
 	}
 }
 
+func TestPolicyService_EvaluateExecRuleMatchesFilelessBinary(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "fileless exec",
+			Description: "binary run from memfd",
+			Severity:    "critical",
+			Action:      policy.ActionAlert,
+			Type:        policy.RuleTypeExec,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				Fileless: true,
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	onDisk := helpers.RawExecSample(5160, 5000, 88, "payload", "bash", "/tmp/payload", "payload", false)
+	if decision := service.Evaluate(execRecord(t, onDisk)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected on-disk binary to be ignored, got %s", decision.Type)
+	}
+
+	deleted := helpers.RawExecFlagsSample(5161, 5000, 88, "payload", "bash", "payload", "payload", events.ExecFlagDeleted, false)
+	if decision := service.Evaluate(execRecord(t, deleted)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected deleted binary to be ignored by fileless rule, got %s", decision.Type)
+	}
+
+	memfd := helpers.RawExecFlagsSample(5162, 5000, 88, "3", "python3", "memfd:x", "/proc/self/fd/3", events.ExecFlagFileless, false)
+	decision := service.Evaluate(execRecord(t, memfd))
+	if decision.Type != policy.DecisionAlert || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "fileless exec" {
+		t.Fatalf("expected fileless alert, got %+v", decision)
+	}
+}
+
+func TestPolicyService_EvaluateRulesScopedByNamespace(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "host shell",
+			Description: "shell outside containers",
+			Severity:    "warning",
+			Action:      policy.ActionAlert,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				ProcessName:     "bash",
+				ProcessNameType: policy.MatchTypeExact,
+				HostOnly:        true,
+			},
+		},
+		{
+			Name:        "netns curl",
+			Description: "curl in the build network",
+			Severity:    "warning",
+			Action:      policy.ActionAlert,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				ProcessName:     "curl",
+				ProcessNameType: policy.MatchTypeExact,
+				NetNS:           4026532999,
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	host := events.HostNamespaces()
+	container := events.Namespaces{PID: 4026532101, Mnt: 4026532102, Net: 4026532999, User: host.User, UTS: 4026532104}
+
+	shell := helpers.RawExecSample(5170, 5000, 90, "bash", "sshd", "/bin/bash", "bash", false)
+	if decision := service.Evaluate(execRecord(t, helpers.WithNamespaces(shell, host))); decision.Type != policy.DecisionAlert {
+		t.Fatalf("expected host shell alert, got %s", decision.Type)
+	}
+	shell = helpers.RawExecSample(5171, 5000, 90, "bash", "containerd-shim", "/bin/bash", "bash", false)
+	if decision := service.Evaluate(execRecord(t, helpers.WithNamespaces(shell, container))); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected container shell to be ignored, got %s", decision.Type)
+	}
+	shell = helpers.RawExecSample(5172, 5000, 90, "bash", "sshd", "/bin/bash", "bash", false)
+	if decision := service.Evaluate(execRecord(t, shell)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected shell without namespaces to be ignored, got %s", decision.Type)
+	}
+
+	curl := helpers.RawExecSample(5173, 5000, 90, "curl", "sh", "/usr/bin/curl", "curl", false)
+	if decision := service.Evaluate(execRecord(t, helpers.WithNamespaces(curl, container))); decision.Type != policy.DecisionAlert {
+		t.Fatalf("expected netns alert, got %s", decision.Type)
+	}
+	curl = helpers.RawExecSample(5174, 5000, 90, "curl", "sh", "/usr/bin/curl", "curl", false)
+	if decision := service.Evaluate(execRecord(t, helpers.WithNamespaces(curl, host))); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected curl in another netns to be ignored, got %s", decision.Type)
+	}
+}
+
 func TestPolicyService_EvaluateExecBlockedWithoutRuleReturnsSyntheticCriticalAlert(t *testing.T) {
 	service := policy.NewService(fakes.NewRuleRepository(nil), &fakes.KernelSync{}, 60, 10)
 	if err := service.Bootstrap(nil); err != nil {
@@ -263,6 +367,53 @@ func TestPolicyService_EvaluateFileAndConnectRulesCoverAlertAndBlockBranches(t *
 	}
 }
 
+func TestPolicyService_MonitorOnlyDowngradesBlockRulesToAlerts(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "block 4444",
+			Description: "block 4444",
+			Severity:    "critical",
+			Action:      policy.ActionBlock,
+			Type:        policy.RuleTypeConnect,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				DestPort: 4444,
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	service.SetMonitorOnly(true)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+	if service.LastSync().Warning == "" {
+		t.Fatal("expected the sync report to warn that block rules only alert")
+	}
+
+	decision := service.Evaluate(connectRecord(t, helpers.RawConnectSample(88, 7, "curl", "192.168.1.10", 2, 4444, false)))
+	if decision.Type != policy.DecisionAlert {
+		t.Fatalf("expected block rule to be downgraded to alert, got %s", decision.Type)
+	}
+	if len(decision.Alerts) != 1 || decision.Alerts[0].Action != string(policy.ActionAlert) {
+		t.Fatalf("unexpected alert payload: %+v", decision.Alerts)
+	}
+	if !strings.Contains(decision.Alerts[0].Description, "not blocked") {
+		t.Fatalf("expected the alert to say it was not blocked, got %q", decision.Alerts[0].Description)
+	}
+
+	service.SetMonitorOnly(false)
+	if err := service.Reload(); err != nil {
+		t.Fatalf("reload rules: %v", err)
+	}
+	if warning := service.LastSync().Warning; warning != "" {
+		t.Fatalf("expected no warning once enforcing, got %q", warning)
+	}
+	decision = service.Evaluate(connectRecord(t, helpers.RawConnectSample(88, 7, "curl", "192.168.1.10", 2, 4444, false)))
+	if decision.Type != policy.DecisionBlock {
+		t.Fatalf("expected block decision once enforcing, got %s", decision.Type)
+	}
+}
+
 func TestPolicyService_TestingRulesAndThresholdUpdatesReuseExistingHits(t *testing.T) {
 	repo := fakes.NewRuleRepository([]policy.Rule{
 		{
@@ -361,3 +512,698 @@ func TestPolicyService_UpdatePreservesLifecycleFieldsWhenOmitted(t *testing.T) {
 		t.Fatalf("expected rule state to be preserved, got %s", updated.State)
 	}
 }
+
+func TestPolicyService_LastSyncReportsKernelMapChanges(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		helpers.ActiveFileRule("existing", "/tmp/one", policy.ActionBlock),
+	})
+	syncer := &fakes.KernelSync{Report: policy.SyncReport{
+		Maps: []policy.MapSyncStats{{Map: "monitored_files", Added: 1, Total: 1}},
+	}}
+	service := policy.NewService(repo, syncer, 60, 10)
+
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	report := service.LastSync()
+	if !report.Changed() || report.Timestamp.IsZero() {
+		t.Fatalf("expected a timestamped report with changes, got %+v", report)
+	}
+	if len(report.Maps) != 1 || report.Maps[0].Added != 1 {
+		t.Fatalf("unexpected map stats: %+v", report.Maps)
+	}
+
+	syncer.Report = policy.SyncReport{Maps: []policy.MapSyncStats{{Map: "monitored_files", Total: 1}}}
+	if err := service.Reload(); err != nil {
+		t.Fatalf("reload rules: %v", err)
+	}
+	if service.LastSync().Changed() {
+		t.Fatal("expected an unchanged reload to report no changes")
+	}
+}
+
+func TestPolicyService_EvaluatePrivilegeRuleAlertsOnRootTransition(t *testing.T) {
+	fromUID, toUID := uint32(1000), uint32(0)
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "user to root",
+			Description: "unprivileged user became root",
+			Severity:    "high",
+			Action:      policy.ActionAlert,
+			Type:        policy.RuleTypePrivilege,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				ProcessName: "sudo",
+				Operation:   "setuid",
+				FromUID:     &fromUID,
+				ToUID:       &toUID,
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	ingested := ingestRecord(t, helpers.RawPrivilegeSample(77, 7, "sudo", events.PrivilegeOpSetuid, 1000, 0))
+	if ingested.Event.Credentials == nil || ingested.Event.Credentials.Operation != "setuid" {
+		t.Fatalf("expected credential change on event, got %+v", ingested.Event)
+	}
+
+	decision := service.Evaluate(ingested)
+	if decision.Type != policy.DecisionAlert || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "user to root" {
+		t.Fatalf("expected privilege alert, got %+v", decision)
+	}
+
+	drop := ingestRecord(t, helpers.RawPrivilegeSample(77, 7, "sudo", events.PrivilegeOpSetuid, 0, 1000))
+	if decision := service.Evaluate(drop); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected privilege drop to be ignored, got %s", decision.Type)
+	}
+
+	// The rule's process_name must not turn it into an exec rule.
+	if decision := service.Evaluate(execRecord(t, helpers.RawExecSample(77, 1, 7, "sudo", "bash", "/usr/bin/sudo", "sudo id", false))); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected exec of sudo not to match privilege rule, got %s", decision.Type)
+	}
+}
+
+func TestPolicyService_EvaluateKernelLoadRulesHonourAllowlist(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:   "trusted module",
+			Action: policy.ActionAllow,
+			Type:   policy.RuleTypeKernelLoad,
+			State:  policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				Operation: "module",
+				Filename:  "/lib/modules/trusted.ko",
+			},
+		},
+		{
+			Name:        "module load",
+			Description: "kernel module loaded",
+			Severity:    "high",
+			Action:      policy.ActionBlock,
+			Type:        policy.RuleTypeKernelLoad,
+			State:       policy.RuleStateProduction,
+			Match:       policy.MatchCondition{Operation: "module"},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	evaluate := func(raw []byte) policy.Decision {
+		t.Helper()
+		return service.Evaluate(ingestRecord(t, raw))
+	}
+
+	if decision := evaluate(helpers.RawKernelLoadSample(90, 9, "insmod", events.LoadKindModule, "/lib/modules/trusted.ko", 0, false)); decision.Type != policy.DecisionAllow {
+		t.Fatalf("expected allowlisted module to be allowed, got %s", decision.Type)
+	}
+
+	decision := evaluate(helpers.RawKernelLoadSample(91, 9, "insmod", events.LoadKindModule, "/tmp/rootkit.ko", 0, true))
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "module load" {
+		t.Fatalf("expected module load block, got %+v", decision)
+	}
+
+	if decision := evaluate(helpers.RawKernelLoadSample(92, 9, "loader", events.LoadKindBPF, "probe", 2, false)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected BPF load to ignore module rules, got %s", decision.Type)
+	}
+
+	if decision := evaluate(helpers.RawFileSample(93, 9, "cat", "/lib/modules/trusted.ko", 0, 1, 1, false)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected file open to ignore kernel_load rules, got %s", decision.Type)
+	}
+}
+
+func TestPolicyService_EvaluatePtraceRuleMatchesTargetAndTracer(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:   "strace allowed",
+			Action: policy.ActionAllow,
+			Type:   policy.RuleTypePtrace,
+			State:  policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				ProcessName:     "strace",
+				ProcessNameType: policy.MatchTypeExact,
+				TargetName:      "sshd",
+				TargetNameType:  policy.MatchTypeExact,
+			},
+		},
+		{
+			Name:        "protect sshd",
+			Description: "debugger attached to sshd",
+			Severity:    "critical",
+			Action:      policy.ActionBlock,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				TargetName:     "sshd",
+				TargetNameType: policy.MatchTypeExact,
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	evaluate := func(raw []byte) (*telemetry.Record, policy.Decision) {
+		t.Helper()
+		record := ingestRecord(t, raw)
+		return record, service.Evaluate(record)
+	}
+
+	attach := events.PtraceModeAttach | events.PtraceModeRealCreds
+	record, decision := evaluate(helpers.RawPtraceSample(40, 4, "gdb", 22, "sshd", attach, true))
+	if record.Event.Ptrace == nil || record.Event.Ptrace.TargetPID != 22 || record.Event.Ptrace.Mode != "attach" {
+		t.Fatalf("expected ptrace target on event, got %+v", record.Event)
+	}
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "protect sshd" {
+		t.Fatalf("expected sshd ptrace block, got %+v", decision)
+	}
+
+	if _, decision := evaluate(helpers.RawPtraceSample(41, 4, "strace", 22, "sshd", attach, false)); decision.Type != policy.DecisionAllow {
+		t.Fatalf("expected allowlisted tracer to be allowed, got %s", decision.Type)
+	}
+
+	if _, decision := evaluate(helpers.RawPtraceSample(42, 4, "gdb", 23, "bash", attach, false)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected unrelated target to be ignored, got %s", decision.Type)
+	}
+}
+
+func TestPolicyService_EvaluateFileRuleMatchesOperation(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "protect passwd",
+			Description: "write to /etc/passwd",
+			Severity:    "critical",
+			Action:      policy.ActionBlock,
+			Type:        policy.RuleTypeFile,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				Filename:  "/etc/passwd",
+				Operation: "write",
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	readSample := helpers.RawFileOpSample(50, 5, "cat", "/etc/passwd", events.FileOpRead, false)
+	if decision := service.Evaluate(fileRecord(t, readSample, "/etc/passwd", false)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected read to be ignored, got %s", decision.Type)
+	}
+
+	writeSample := helpers.RawFileOpSample(51, 5, "cat", "/etc/passwd", events.FileOpWrite, true)
+	decision := service.Evaluate(fileRecord(t, writeSample, "/etc/passwd", true))
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "protect passwd" {
+		t.Fatalf("expected write block, got %+v", decision)
+	}
+
+	ingested := ingestRecord(t, helpers.RawFileOpSample(52, 5, "mv", "/etc/passwd", events.FileOpRename, false))
+	if ingested.Event.Operation != "rename" {
+		t.Fatalf("expected rename operation on event, got %q", ingested.Event.Operation)
+	}
+	if decision := service.Evaluate(ingested); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected rename to be ignored by write rule, got %s", decision.Type)
+	}
+}
+
+func TestPolicyService_EvaluateFileRuleMatchesAccessMode(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "shadow writes",
+			Description: "write open of /etc/shadow",
+			Severity:    "critical",
+			Action:      policy.ActionBlock,
+			Type:        policy.RuleTypeFile,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				Filename: "/etc/shadow",
+				Access:   "write",
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	readSample := helpers.RawFileSample(60, 6, "cat", "/etc/shadow", syscall.O_RDONLY, 0, 0, false)
+	if decision := service.Evaluate(fileRecord(t, readSample, "/etc/shadow", false)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected read-only open to be ignored, got %s", decision.Type)
+	}
+
+	writeSample := helpers.RawFileSample(61, 6, "tee", "/etc/shadow", syscall.O_WRONLY|syscall.O_TRUNC, 0, 0, true)
+	decision := service.Evaluate(fileRecord(t, writeSample, "/etc/shadow", true))
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "shadow writes" {
+		t.Fatalf("expected write open block, got %+v", decision)
+	}
+}
+
+func TestPolicyService_EvaluateConnectRuleMatchesResolvedDomain(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "no pastebin",
+			Description: "connection to pastebin",
+			Severity:    "high",
+			Action:      policy.ActionBlock,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				DestDomain: "*.pastebin.com",
+			},
+		},
+	})
+	kernelSync := &fakes.KernelSync{}
+	service := policy.NewService(repo, kernelSync, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+	telemetryService := telemetry.NewService(10, 10, nil, nil, nil)
+
+	evaluate := func(raw []byte) (*telemetry.Record, policy.Decision) {
+		t.Helper()
+		record := ingestInto(t, telemetryService, raw)
+		return record, service.Evaluate(record)
+	}
+
+	reply := helpers.DNSMessage("api.pastebin.com", "104.20.1.1")
+	if _, decision := evaluate(helpers.RawDNSSample(70, 7, "curl", "10.0.0.53", true, reply)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected dns reply to produce no decision, got %s", decision.Type)
+	}
+	if len(kernelSync.DomainAddrs) != 1 || len(kernelSync.DomainAddrs[0]) != 1 || kernelSync.DomainAddrs[0][0].String() != "104.20.1.1" {
+		t.Fatalf("expected resolved address to be synced, got %v", kernelSync.DomainAddrs)
+	}
+	if len(kernelSync.DomainRules[0]) != 1 || kernelSync.DomainRules[0][0].Name != "no pastebin" {
+		t.Fatalf("expected matched domain rule to be synced, got %+v", kernelSync.DomainRules)
+	}
+
+	record, decision := evaluate(helpers.RawConnectSample(70, 7, "curl", "104.20.1.1", 2, 443, true))
+	if record.Event.Domain != "api.pastebin.com" {
+		t.Fatalf("expected connect to be annotated with domain, got %q", record.Event.Domain)
+	}
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "no pastebin" {
+		t.Fatalf("expected domain block, got %+v", decision)
+	}
+
+	if _, decision := evaluate(helpers.RawConnectSample(71, 7, "curl", "104.20.1.1", 2, 443, false)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected connect from a process without the lookup to be ignored, got %s", decision.Type)
+	}
+}
+
+func TestPolicyService_RuleChangeResyncsResolvedDomains(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "no pastebin",
+			Description: "connection to pastebin",
+			Severity:    "high",
+			Action:      policy.ActionBlock,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				DestDomain: "*.pastebin.com",
+			},
+		},
+	})
+	kernelSync := &fakes.KernelSync{}
+	service := policy.NewService(repo, kernelSync, 60, 10)
+	service.SetDomainResolver(&fakes.DomainResolver{Domains: map[string][]net.IP{
+		"api.pastebin.com": {net.ParseIP("104.20.1.1")},
+		"example.com":      {net.ParseIP("93.184.216.34")},
+	}})
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	if len(kernelSync.Resynced) != 1 || len(kernelSync.Resynced[0]) != 1 {
+		t.Fatalf("expected one matched domain to be resynced, got %+v", kernelSync.Resynced)
+	}
+	resolved := kernelSync.Resynced[0][0]
+	if len(resolved.Rules) != 1 || resolved.Rules[0].Name != "no pastebin" {
+		t.Fatalf("expected the pastebin rule, got %+v", resolved.Rules)
+	}
+	if len(resolved.Addrs) != 1 || resolved.Addrs[0].String() != "104.20.1.1" {
+		t.Fatalf("expected the cached pastebin address, got %v", resolved.Addrs)
+	}
+
+	if err := service.Delete("no pastebin"); err != nil {
+		t.Fatalf("delete rule: %v", err)
+	}
+	if len(kernelSync.Resynced) != 2 || len(kernelSync.Resynced[1]) != 0 {
+		t.Fatalf("expected deleting the rule to resync no domains, got %+v", kernelSync.Resynced)
+	}
+}
+
+func TestPolicyService_RefreshWatchedDirsResyncsMovedDirectory(t *testing.T) {
+	watched := filepath.Join(t.TempDir(), "watched")
+	if err := os.Mkdir(watched, 0o700); err != nil {
+		t.Fatalf("create watched directory: %v", err)
+	}
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		helpers.ActiveFileRule("watched tree", watched+"/*", policy.ActionBlock),
+	})
+	kernelSync := &fakes.KernelSync{}
+	service := policy.NewService(repo, kernelSync, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+	dirKey := func() (policy.InodeKey, bool) {
+		t.Helper()
+		synced := kernelSync.Rules[len(kernelSync.Rules)-1]
+		return synced[0].Match.DirInodeKey()
+	}
+	original, ok := dirKey()
+	if !ok {
+		t.Fatal("expected the watched directory to be resolved on load")
+	}
+
+	if changed, err := service.RefreshWatchedDirs(); err != nil || changed {
+		t.Fatalf("expected no change for an untouched directory, got %v, %v", changed, err)
+	}
+	if kernelSync.SyncCall != 1 {
+		t.Fatalf("expected no resync for an untouched directory, got %d syncs", kernelSync.SyncCall)
+	}
+
+	if err := os.Rename(watched, watched+".old"); err != nil {
+		t.Fatalf("move watched directory: %v", err)
+	}
+	if err := os.Mkdir(watched, 0o700); err != nil {
+		t.Fatalf("recreate watched directory: %v", err)
+	}
+	if changed, err := service.RefreshWatchedDirs(); err != nil || !changed {
+		t.Fatalf("expected the recreated directory to be picked up, got %v, %v", changed, err)
+	}
+	recreated, ok := dirKey()
+	if !ok || recreated == original {
+		t.Fatalf("expected the recreated directory's inode to be synced, got %+v (was %+v)", recreated, original)
+	}
+
+	if err := os.Remove(watched); err != nil {
+		t.Fatalf("remove watched directory: %v", err)
+	}
+	if changed, err := service.RefreshWatchedDirs(); err != nil || !changed {
+		t.Fatalf("expected the removed directory to be dropped, got %v, %v", changed, err)
+	}
+	if _, ok := dirKey(); ok {
+		t.Fatal("expected no directory inode once the directory is gone")
+	}
+	if kernelSync.SyncCall != 3 {
+		t.Fatalf("expected a resync per change, got %d syncs", kernelSync.SyncCall)
+	}
+}
+
+func TestPolicyService_EvaluateConnectRuleMatchesUnixSocket(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "docker socket",
+			Description: "docker api access",
+			Severity:    "high",
+			Action:      policy.ActionBlock,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				DestSocket: "/var/run/docker.sock",
+			},
+		},
+		{
+			Name:        "containerd sockets",
+			Description: "containerd api access",
+			Severity:    "warning",
+			Action:      policy.ActionAlert,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				DestSocket:     "/run/containerd/",
+				DestSocketType: policy.MatchTypePrefix,
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	evaluate := func(raw []byte) (*telemetry.Record, policy.Decision) {
+		t.Helper()
+		record := ingestRecord(t, raw)
+		return record, service.Evaluate(record)
+	}
+
+	record, decision := evaluate(helpers.RawUnixConnectSample(80, 8, "docker", "/var/run/docker.sock", true))
+	if record.Event.Address != "/var/run/docker.sock" {
+		t.Fatalf("expected socket path as address, got %q", record.Event.Address)
+	}
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "docker socket" {
+		t.Fatalf("expected exact socket block, got %+v", decision)
+	}
+
+	if _, decision := evaluate(helpers.RawUnixConnectSample(81, 8, "ctr", "/run/containerd/containerd.sock", false)); decision.Type != policy.DecisionAlert {
+		t.Fatalf("expected prefix socket alert, got %s", decision.Type)
+	}
+	if _, decision := evaluate(helpers.RawUnixConnectSample(82, 8, "docker", "/var/run/docker.sock.bak", false)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected exact rule to ignore longer path, got %s", decision.Type)
+	}
+	record, decision = evaluate(helpers.RawUnixConnectSample(83, 8, "dbus", "@/tmp/dbus-1", false))
+	if record.Event.Address != "@/tmp/dbus-1" || decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected abstract socket without a match, got %q %s", record.Event.Address, decision.Type)
+	}
+}
+
+func TestPolicyService_EvaluateListenRuleHonoursProcessAllowlist(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:   "sshd on 22",
+			Action: policy.ActionAllow,
+			Type:   policy.RuleTypeListen,
+			State:  policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				ProcessName:     "sshd",
+				ProcessNameType: policy.MatchTypeExact,
+				LocalPort:       22,
+			},
+		},
+		{
+			Name:        "unexpected ssh listener",
+			Description: "unexpected listener on port 22",
+			Severity:    "critical",
+			Action:      policy.ActionBlock,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				LocalPort: 22,
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	evaluate := func(raw []byte) (*telemetry.Record, policy.Decision) {
+		t.Helper()
+		record := ingestRecord(t, raw)
+		return record, service.Evaluate(record)
+	}
+
+	if _, decision := evaluate(helpers.RawListenSample(80, 8, "sshd", "0.0.0.0", 22, 6, events.ListenOpListen, 1, false)); decision.Type != policy.DecisionAllow {
+		t.Fatalf("expected allowlisted listener, got %s", decision.Type)
+	}
+	sshd := helpers.RawExecSample(80, 1, 8, "sshd", "systemd", "/usr/sbin/sshd", "sshd -D", false)
+	if decision := service.Evaluate(execRecord(t, sshd)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected listen rule to ignore exec of sshd, got %s", decision.Type)
+	}
+
+	record, decision := evaluate(helpers.RawListenSample(81, 8, "python3", "0.0.0.0", 22, 6, events.ListenOpListen, 2, true))
+	if record.Event.Type != telemetry.EventTypeListen || record.Event.Protocol != "tcp" || record.Event.Address != "0.0.0.0:22" {
+		t.Fatalf("unexpected listen event: %+v", record.Event)
+	}
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "unexpected ssh listener" {
+		t.Fatalf("expected listener block, got %+v", decision)
+	}
+
+	if _, decision := evaluate(helpers.RawListenSample(82, 8, "python3", "0.0.0.0", 8000, 6, events.ListenOpListen, 3, false)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected other port to be ignored, got %s", decision.Type)
+	}
+	if _, decision := evaluate(helpers.RawListenSample(81, 8, "python3", "0.0.0.0", 22, 6, events.ListenOpClose, 2, false)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected close to be ignored, got %s", decision.Type)
+	}
+}
+
+func TestPolicyService_EvaluateEscapeRules(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "container joins host",
+			Description: "container entered a host namespace",
+			Severity:    "critical",
+			Action:      policy.ActionAlert,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				Operation:     "setns",
+				IntoHost:      true,
+				ContainerOnly: true,
+			},
+		},
+		{
+			Name:        "block device mount",
+			Description: "container mounted a block device",
+			Severity:    "critical",
+			Action:      policy.ActionAlert,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				Operation:     "mount",
+				MountSource:   "/dev/*",
+				ContainerOnly: true,
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	evaluate := func(raw []byte) policy.Decision {
+		t.Helper()
+		return service.Evaluate(ingestRecord(t, raw))
+	}
+
+	host := events.HostNamespaces()
+	container := events.Namespaces{PID: 4026532301, Mnt: 4026532302, Net: 4026532303, User: 4026532304, UTS: 4026532305}
+	joinedHost := container
+	joinedHost.Mnt = host.Mnt
+
+	raw := helpers.RawNamespaceSample(90, 9, "nsenter", events.NamespaceOpSetns, events.CloneNewNS, joinedHost)
+	decision := evaluate(helpers.WithNamespaces(raw, container))
+	if decision.Type != policy.DecisionAlert || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "container joins host" {
+		t.Fatalf("expected host namespace alert, got %+v", decision)
+	}
+
+	otherContainer := container
+	otherContainer.Mnt = 4026532399
+	raw = helpers.RawNamespaceSample(91, 9, "nsenter", events.NamespaceOpSetns, events.CloneNewNS, otherContainer)
+	if decision := evaluate(helpers.WithNamespaces(raw, container)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected setns between containers to be ignored, got %s", decision.Type)
+	}
+	raw = helpers.RawNamespaceSample(92, 1, "nsenter", events.NamespaceOpSetns, events.CloneNewNS, host)
+	if decision := evaluate(helpers.WithNamespaces(raw, host)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected setns on the host to be ignored, got %s", decision.Type)
+	}
+
+	raw = helpers.RawMountSample(93, 9, "mount", "/dev/sda1", "ext4", "/mnt/host", 0)
+	decision = evaluate(helpers.WithNamespaces(raw, container))
+	if decision.Type != policy.DecisionAlert || len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "block device mount" {
+		t.Fatalf("expected block device mount alert, got %+v", decision)
+	}
+	raw = helpers.RawMountSample(94, 9, "mount", "tmpfs", "tmpfs", "/tmp", 0)
+	if decision := evaluate(helpers.WithNamespaces(raw, container)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected tmpfs mount to be ignored, got %s", decision.Type)
+	}
+	raw = helpers.RawMountSample(95, 1, "mount", "/dev/sdb1", "ext4", "/data", 0)
+	if decision := evaluate(helpers.WithNamespaces(raw, host)); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected host mount to be ignored, got %s", decision.Type)
+	}
+
+	shell := helpers.RawExecSample(96, 1, 9, "sh", "runc", "/bin/sh", "sh", false)
+	if decision := service.Evaluate(execRecord(t, helpers.WithNamespaces(shell, container))); decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected escape rules to ignore exec, got %s", decision.Type)
+	}
+}
+
+func TestPolicyService_EvaluateKillRulesReportKernelResponse(t *testing.T) {
+	repo := fakes.NewRuleRepository([]policy.Rule{
+		{
+			Name:        "kill miner",
+			Description: "kill cryptominers on exec",
+			Severity:    "critical",
+			Action:      policy.ActionKill,
+			Type:        policy.RuleTypeExec,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				ProcessName:     "xmrig",
+				ProcessNameType: policy.MatchTypeExact,
+			},
+		},
+		{
+			Name:        "kill reverse shell tree",
+			Description: "kill the tree behind a reverse shell",
+			Severity:    "critical",
+			Action:      policy.ActionKillTree,
+			Type:        policy.RuleTypeConnect,
+			State:       policy.RuleStateProduction,
+			Match: policy.MatchCondition{
+				DestPort: 4444,
+			},
+		},
+	})
+	service := policy.NewService(repo, &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	raw := helpers.RawExecSample(200, 1, 7, "xmrig", "bash", "/tmp/xmrig", "xmrig -o pool", true)
+	decision := service.Evaluate(execRecord(t, helpers.WithResponse(raw, events.ResponseKill)))
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 {
+		t.Fatalf("expected one block decision alert, got %+v", decision)
+	}
+	if got := decision.Alerts[0]; got.Action != string(policy.ActionKill) || !got.Blocked {
+		t.Fatalf("expected kill to be reported, got %+v", got)
+	}
+
+	raw = helpers.RawConnectSample(201, 7, "bash", "10.0.0.5", 2, 4444, true)
+	decision = service.Evaluate(connectRecord(t, helpers.WithResponse(raw, events.ResponseKillTree)))
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 || decision.Alerts[0].Action != string(policy.ActionKillTree) {
+		t.Fatalf("expected kill_tree to be reported, got %+v", decision)
+	}
+
+	// A kill rule the kernel could not enforce still blocks, but reports
+	// only what the rule asked for.
+	decision = service.Evaluate(execRecord(t, helpers.RawExecSample(202, 1, 7, "xmrig", "bash", "/tmp/xmrig", "xmrig", false)))
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 {
+		t.Fatalf("expected block decision from the kill rule, got %+v", decision)
+	}
+	if got := decision.Alerts[0]; got.Action != string(policy.ActionKill) || got.Blocked {
+		t.Fatalf("unexpected unenforced kill alert: %+v", got)
+	}
+
+	raw = helpers.RawExecSample(203, 1, 7, "nc", "bash", "/usr/bin/nc", "nc -e sh", true)
+	decision = service.Evaluate(execRecord(t, helpers.WithResponse(raw, events.ResponseKill)))
+	if len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "Kernel Blocked Execution" || decision.Alerts[0].Action != "kill" {
+		t.Fatalf("expected synthetic kernel kill alert, got %+v", decision.Alerts)
+	}
+}
+
+func TestPolicyService_EvaluateSelfProtectionAlerts(t *testing.T) {
+	service := policy.NewService(fakes.NewRuleRepository(nil), &fakes.KernelSync{}, 60, 10)
+	if err := service.Load(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+
+	evaluate := func(raw []byte) (*telemetry.Record, policy.Decision) {
+		t.Helper()
+		record := ingestRecord(t, raw)
+		return record, service.Evaluate(record)
+	}
+
+	record, decision := evaluate(helpers.RawSignalSample(60, 6, "bash", 61, "nginx", 15))
+	if sent := record.Event.SignalSent; sent == nil || sent.TargetPID != 61 || sent.TargetName != "nginx" || sent.Name != "SIGTERM" {
+		t.Fatalf("expected signal target on event, got %+v", record.Event)
+	}
+	if decision.Type != policy.DecisionNoMatch {
+		t.Fatalf("expected ordinary signal to be ignored, got %s", decision.Type)
+	}
+
+	record, decision = evaluate(helpers.WithSelfProtect(helpers.RawSignalSample(62, 6, "bash", 1000, "aegis", 9)))
+	if !record.Event.Blocked || !record.Event.SelfProtect {
+		t.Fatalf("expected blocked self-protect signal, got %+v", record.Event)
+	}
+	if decision.Type != policy.DecisionBlock || len(decision.Alerts) != 1 {
+		t.Fatalf("expected self-protection block, got %+v", decision)
+	}
+	if got := decision.Alerts[0]; got.Severity != "critical" || got.RuleName != "Aegis Self-Protection" || !strings.Contains(got.Description, "SIGKILL") {
+		t.Fatalf("unexpected self-protection alert: %+v", got)
+	}
+
+	attach := events.PtraceModeAttach | events.PtraceModeRealCreds
+	_, decision = evaluate(helpers.WithSelfProtect(helpers.RawPtraceSample(63, 6, "gdb", 1000, "aegis", attach, true)))
+	if len(decision.Alerts) != 1 || decision.Alerts[0].RuleName != "Aegis Self-Protection" || !strings.Contains(decision.Alerts[0].Description, "Ptrace attach") {
+		t.Fatalf("expected ptrace self-protection alert, got %+v", decision.Alerts)
+	}
+}
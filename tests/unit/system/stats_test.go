@@ -27,3 +27,41 @@ func TestStats_AddAlertDeduplicatesWithinWindow(t *testing.T) {
 		t.Fatalf("expected total alert count to remain deduplicated, got %d", got)
 	}
 }
+
+func TestStats_UpdateRingbufAlertsOnDrops(t *testing.T) {
+	stats := system.NewStats(10, time.Minute)
+
+	sample := system.RingbufStats{Dropped: 40, DroppedByType: map[string]uint64{"file": 40}, BufferSize: 4096}
+	if _, ok := stats.UpdateRingbuf(sample, 100); ok {
+		t.Fatal("expected no alert below the threshold")
+	}
+	if got := stats.Ringbuf(); got.Dropped != 40 || got.DroppedByType["file"] != 40 {
+		t.Fatalf("expected the sample to be stored, got %+v", got)
+	}
+
+	// Only the drops since the previous sample count toward the threshold.
+	sample.Dropped = 120
+	if _, ok := stats.UpdateRingbuf(sample, 100); ok {
+		t.Fatal("expected no alert for 80 new drops")
+	}
+	sample.Dropped = 220
+	alert, ok := stats.UpdateRingbuf(sample, 100)
+	if !ok || alert.Severity != "high" || alert.Blocked {
+		t.Fatalf("expected a high alert for 100 new drops, got %+v (ok=%t)", alert, ok)
+	}
+
+	// A single lost block decision is always reported.
+	sample.Dropped, sample.DroppedBlocked = 221, 1
+	alert, ok = stats.UpdateRingbuf(sample, 100)
+	if !ok || alert.Severity != "critical" || !alert.Blocked {
+		t.Fatalf("expected a critical alert for a dropped block, got %+v (ok=%t)", alert, ok)
+	}
+	if _, ok := stats.UpdateRingbuf(sample, 100); ok {
+		t.Fatal("expected no alert when nothing new was dropped")
+	}
+
+	// Counters restart when the probes are reloaded.
+	if _, ok := stats.UpdateRingbuf(system.RingbufStats{Dropped: 5, DroppedBlocked: 1}, 100); !ok {
+		t.Fatal("expected an alert for a dropped block after a reload")
+	}
+}
@@ -1,6 +1,8 @@
 package telemetry_test
 
 import (
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -96,3 +98,345 @@ func TestTelemetryService_IngestAndQueryUpdateProcessWorkloadAndProfileProjectio
 		t.Fatalf("unexpected latest event order: %+v", latest)
 	}
 }
+
+func TestTelemetryService_ExitMarksProcessDeadAndFinalizesProfile(t *testing.T) {
+	processTree := proc.NewProcessTree(time.Minute, 1000, 16)
+	profiles := proc.NewProfileRegistry()
+	service := telemetry.NewService(100, 100, processTree, nil, profiles)
+
+	execRecord, err := events.DecodeSample(helpers.RawExecSample(5300, 5200, 77, "curl", "bash", "/usr/bin/curl", "curl example.com", false))
+	if err != nil {
+		t.Fatalf("decode exec: %v", err)
+	}
+	if _, err := service.Ingest(execRecord); err != nil {
+		t.Fatalf("ingest exec: %v", err)
+	}
+
+	exitRecord, err := events.DecodeSample(helpers.RawExitSample(5300, 5200, 77, "curl", 0, 9, 1500*time.Millisecond))
+	if err != nil {
+		t.Fatalf("decode exit: %v", err)
+	}
+	exitResult, err := service.Ingest(exitRecord)
+	if err != nil {
+		t.Fatalf("ingest exit: %v", err)
+	}
+
+	exitEvent := exitResult.Event
+	if exitEvent.Type != telemetry.EventTypeExit || exitEvent.PPID != 5200 || exitEvent.Signal != 9 || exitEvent.RuntimeMs != 1500 {
+		t.Fatalf("unexpected exit event: %+v", exitEvent)
+	}
+
+	info, ok := processTree.GetProcess(5300)
+	if !ok || !info.Exited() || info.Signal != 9 {
+		t.Fatalf("expected process to be marked exited, got %+v", info)
+	}
+
+	profile, ok := profiles.GetProfile(5300)
+	if !ok || !profile.Exited() || profile.Static.ExitSignal != 9 {
+		t.Fatalf("expected finalized profile, got %+v", profile)
+	}
+
+	// A later exec on the recycled PID starts a fresh profile.
+	if _, err := service.Ingest(execRecord); err != nil {
+		t.Fatalf("ingest reused pid exec: %v", err)
+	}
+	reused, ok := profiles.GetProfile(5300)
+	if !ok || reused.Exited() || reused.Dynamic.ExecCount != 1 {
+		t.Fatalf("expected fresh profile for reused pid, got %+v", reused)
+	}
+	if info, _ := processTree.GetProcess(5300); info.Exited() {
+		t.Fatal("expected reused pid to be alive in the process tree")
+	}
+
+	result := service.Query(telemetry.Query{Filter: telemetry.Filter{Types: []telemetry.EventType{telemetry.EventTypeExit}}})
+	if result.Total != 1 || result.TypeCounts.Exit != 1 {
+		t.Fatalf("expected one exit event in query, got %+v", result)
+	}
+}
+
+func TestTelemetryService_DNSRepliesAnnotateConnectsUntilExit(t *testing.T) {
+	service := telemetry.NewService(100, 100, nil, nil, nil)
+	ingest := func(raw []byte) telemetry.Event {
+		t.Helper()
+		record, err := events.DecodeSample(raw)
+		if err != nil {
+			t.Fatalf("decode sample: %v", err)
+		}
+		result, err := service.Ingest(record)
+		if err != nil {
+			t.Fatalf("ingest sample: %v", err)
+		}
+		return result.Event
+	}
+
+	query := ingest(helpers.RawDNSSample(6100, 77, "curl", "10.0.0.53", false, helpers.DNSMessage("example.com")))
+	if query.Type != telemetry.EventTypeDNS || query.Operation != "query" || query.Domain != "example.com" || query.Address != "10.0.0.53:53" {
+		t.Fatalf("unexpected dns query event: %+v", query)
+	}
+	if query.DNS == nil || query.DNS.QueryType != "A" || query.DNS.Transport != "udp" {
+		t.Fatalf("unexpected dns details: %+v", query.DNS)
+	}
+
+	reply := ingest(helpers.RawDNSSample(6100, 77, "curl", "10.0.0.53", true, helpers.DNSMessage("example.com", "93.184.216.34")))
+	if reply.Operation != "response" || len(reply.DNS.Answers) != 1 || reply.DNS.Answers[0] != "93.184.216.34" {
+		t.Fatalf("unexpected dns response event: %+v", reply)
+	}
+
+	if connect := ingest(helpers.RawConnectSample(6100, 77, "curl", "93.184.216.34", 2, 443, false)); connect.Domain != "example.com" {
+		t.Fatalf("expected connect to carry resolved domain, got %q", connect.Domain)
+	}
+	if other := ingest(helpers.RawConnectSample(6101, 77, "wget", "93.184.216.34", 2, 443, false)); other.Domain != "" {
+		t.Fatalf("expected domain cache to be per process, got %q", other.Domain)
+	}
+
+	ingest(helpers.RawExitSample(6100, 1, 77, "curl", 0, 0, time.Second))
+	if connect := ingest(helpers.RawConnectSample(6100, 77, "curl", "93.184.216.34", 2, 443, false)); connect.Domain != "" {
+		t.Fatalf("expected domain cache to be dropped on exit, got %q", connect.Domain)
+	}
+
+	result := service.Query(telemetry.Query{Filter: telemetry.Filter{Types: []telemetry.EventType{telemetry.EventTypeDNS}}})
+	if result.Total != 2 || result.TypeCounts.DNS != 2 {
+		t.Fatalf("expected two dns events in query, got %+v", result)
+	}
+}
+
+func TestTelemetryService_ListenerInventoryTracksOpenSockets(t *testing.T) {
+	service := telemetry.NewService(100, 100, nil, nil, nil)
+	ingest := func(raw []byte) {
+		t.Helper()
+		record, err := events.DecodeSample(raw)
+		if err != nil {
+			t.Fatalf("decode sample: %v", err)
+		}
+		if _, err := service.Ingest(record); err != nil {
+			t.Fatalf("ingest sample: %v", err)
+		}
+	}
+
+	ingest(helpers.RawListenSample(7100, 77, "nginx", "0.0.0.0", 80, 6, events.ListenOpListen, 11, false))
+	ingest(helpers.RawListenSample(7200, 77, "named", "127.0.0.1", 53, 17, events.ListenOpBind, 12, false))
+	ingest(helpers.RawListenSample(7300, 77, "nc", "0.0.0.0", 4444, 6, events.ListenOpListen, 13, true))
+
+	listeners := service.Listeners()
+	if len(listeners) != 2 {
+		t.Fatalf("expected two open listeners (blocked one excluded), got %+v", listeners)
+	}
+	if listeners[0].Port != 53 || listeners[0].Protocol != "udp" || listeners[0].ProcessName != "named" {
+		t.Fatalf("expected udp/53 first, got %+v", listeners[0])
+	}
+	if listeners[1].Port != 80 || listeners[1].Protocol != "tcp" || listeners[1].Address != "0.0.0.0:80" {
+		t.Fatalf("expected tcp/80 second, got %+v", listeners[1])
+	}
+
+	ingest(helpers.RawListenSample(7100, 77, "nginx", "0.0.0.0", 80, 6, events.ListenOpClose, 11, false))
+	if listeners := service.Listeners(); len(listeners) != 1 || listeners[0].Port != 53 {
+		t.Fatalf("expected closed listener to be dropped, got %+v", listeners)
+	}
+
+	result := service.Query(telemetry.Query{Filter: telemetry.Filter{Types: []telemetry.EventType{telemetry.EventTypeListen}}})
+	if result.Total != 4 || result.TypeCounts.Listen != 4 {
+		t.Fatalf("expected four listen events in query, got %+v", result)
+	}
+}
+
+func TestTelemetryService_EventsCarryNamespacesAndMarkHostWorkloads(t *testing.T) {
+	workloads := workload.NewRegistry(10)
+	service := telemetry.NewService(10, 10, nil, workloads, nil)
+	host := events.HostNamespaces()
+	container := events.Namespaces{PID: 4026532201, Mnt: 4026532202, Net: 4026532203, User: 4026532204, UTS: 4026532205}
+
+	ingest := func(raw []byte) telemetry.Event {
+		t.Helper()
+		record, err := events.DecodeSample(raw)
+		if err != nil {
+			t.Fatalf("decode sample: %v", err)
+		}
+		result, err := service.Ingest(record)
+		if err != nil {
+			t.Fatalf("ingest sample: %v", err)
+		}
+		return result.Event
+	}
+
+	event := ingest(helpers.WithNamespaces(helpers.RawExecSample(4300, 1, 10, "nginx", "containerd-shim", "/usr/sbin/nginx", "nginx", false), container))
+	if event.Namespaces == nil || event.Namespaces.Net != container.Net || event.Namespaces.PID != container.PID || event.Namespaces.Host {
+		t.Fatalf("expected container namespaces, got %+v", event.Namespaces)
+	}
+	if meta := workloads.Get(10); meta == nil || meta.Host || meta.Namespaces != container {
+		t.Fatalf("expected container workload, got %+v", meta)
+	}
+
+	event = ingest(helpers.WithNamespaces(helpers.RawConnectSample(4301, 11, "sshd", "10.0.0.9", 2, 22, false), host))
+	if event.Namespaces == nil || !event.Namespaces.Host {
+		t.Fatalf("expected host namespaces, got %+v", event.Namespaces)
+	}
+	if meta := workloads.Get(11); meta == nil || !meta.Host {
+		t.Fatalf("expected host workload, got %+v", meta)
+	}
+
+	if event := ingest(helpers.RawConnectSample(4302, 12, "sshd", "10.0.0.9", 2, 22, false)); event.Namespaces != nil {
+		t.Fatalf("expected no namespaces for a header without them, got %+v", event.Namespaces)
+	}
+}
+
+func TestTelemetryService_IngestsNamespaceChangesAndMounts(t *testing.T) {
+	service := telemetry.NewService(10, 10, nil, nil, nil)
+	host := events.HostNamespaces()
+	container := events.Namespaces{PID: 4026532401, Mnt: 4026532402, Net: 4026532403, User: 4026532404, UTS: 4026532405}
+	joined := container
+	joined.Mnt = host.Mnt
+	joined.Net = host.Net
+
+	ingest := func(raw []byte) telemetry.Event {
+		t.Helper()
+		record, err := events.DecodeSample(raw)
+		if err != nil {
+			t.Fatalf("decode sample: %v", err)
+		}
+		result, err := service.Ingest(record)
+		if err != nil {
+			t.Fatalf("ingest sample: %v", err)
+		}
+		return result.Event
+	}
+
+	raw := helpers.RawNamespaceSample(4400, 20, "nsenter", events.NamespaceOpSetns, events.CloneNewNS|events.CloneNewNet, joined)
+	event := ingest(helpers.WithNamespaces(raw, container))
+	if event.Type != telemetry.EventTypeNamespace || event.Operation != "setns" || event.NSChange == nil {
+		t.Fatalf("unexpected setns event: %+v", event)
+	}
+	if got := event.NSChange.Changed; len(got) != 2 || got[0] != "mnt" || got[1] != "net" {
+		t.Fatalf("expected mnt and net to change, got %v", got)
+	}
+	if !event.NSChange.IntoHost || event.NSChange.New == nil || event.NSChange.New.Mnt != host.Mnt {
+		t.Fatalf("expected setns into the host, got %+v", event.NSChange)
+	}
+	if event.Namespaces == nil || event.Namespaces.Mnt != container.Mnt {
+		t.Fatalf("expected namespaces from before the call, got %+v", event.Namespaces)
+	}
+
+	event = ingest(helpers.RawMountSample(4401, 20, "mount", "cgroup", "cgroup", "/tmp/cg", 0x1))
+	if event.Operation != "mount" || event.Filename != "/tmp/cg" || event.Flags != 0x1 {
+		t.Fatalf("unexpected mount event: %+v", event)
+	}
+	if event.NSChange == nil || event.NSChange.FSType != "cgroup" || event.NSChange.Source != "cgroup" || event.NSChange.IntoHost {
+		t.Fatalf("unexpected mount details: %+v", event.NSChange)
+	}
+
+	result := service.Query(telemetry.Query{Filter: telemetry.Filter{Types: []telemetry.EventType{telemetry.EventTypeNamespace}}})
+	if result.Total != 2 || result.TypeCounts.Namespace != 2 {
+		t.Fatalf("expected two namespace events in query, got %+v", result)
+	}
+}
+
+func TestTelemetryService_KillResponsesAndDescendants(t *testing.T) {
+	// PIDs above pid_max keep the /proc seed out of the way.
+	processTree := proc.NewProcessTree(time.Minute, 1000, 16)
+	service := telemetry.NewService(100, 100, processTree, nil, nil)
+
+	for _, sample := range [][]byte{
+		helpers.RawExecSample(9000001, 1, 7, "bash", "sshd", "/bin/bash", "bash", false),
+		helpers.RawExecSample(9000002, 9000001, 7, "sh", "bash", "/bin/sh", "sh", false),
+		helpers.RawExecSample(9000003, 9000002, 7, "nc", "sh", "/usr/bin/nc", "nc", false),
+		helpers.RawExecSample(9000004, 9000001, 7, "sleep", "bash", "/bin/sleep", "sleep 1", false),
+	} {
+		record, err := events.DecodeSample(sample)
+		if err != nil {
+			t.Fatalf("decode exec: %v", err)
+		}
+		if _, err := service.Ingest(record); err != nil {
+			t.Fatalf("ingest exec: %v", err)
+		}
+	}
+	processTree.MarkExited(9000004, time.Now(), 0, 0)
+
+	got := processTree.Descendants(9000001)
+	if len(got) != 2 || got[0] != 9000002 || got[1] != 9000003 {
+		t.Fatalf("expected live descendants parent first, got %v", got)
+	}
+
+	raw := helpers.WithResponse(helpers.RawConnectSample(9000003, 7, "nc", "10.0.0.5", 2, 4444, true), events.ResponseKillTree)
+	record, err := events.DecodeSample(raw)
+	if err != nil {
+		t.Fatalf("decode connect: %v", err)
+	}
+	result, err := service.Ingest(record)
+	if err != nil {
+		t.Fatalf("ingest connect: %v", err)
+	}
+	if !result.Event.Blocked || result.Event.Response != "kill_tree" {
+		t.Fatalf("expected kill_tree response, got %+v", result.Event)
+	}
+
+	record, err = events.DecodeSample(helpers.RawConnectSample(9000003, 7, "nc", "10.0.0.5", 2, 4444, true))
+	if err != nil {
+		t.Fatalf("decode connect: %v", err)
+	}
+	if result, _ := service.Ingest(record); result.Event.Response != "block" {
+		t.Fatalf("expected plain block response, got %q", result.Event.Response)
+	}
+}
+
+func TestDescendantsOf_FollowsForkedChildrenAndSkipsUnconfirmedEdges(t *testing.T) {
+	// 11 was forked by the killed 10 without exec'ing and has been
+	// reparented; 13 reused the pid of an exited child of 12 and now belongs
+	// to someone else.
+	parents := map[uint32]uint32{11: 10, 12: 10, 13: 12, 14: 11, 20: 1}
+	stale := map[uint32]bool{13: true}
+
+	got := proc.DescendantsOf(10, parents, func(child, _ uint32) bool { return !stale[child] })
+	if !slices.Equal(got, []uint32{11, 12, 14}) {
+		t.Fatalf("expected descendants [11 12 14] parents first, got %v", got)
+	}
+	if got := proc.DescendantsOf(10, parents, nil); len(got) != 4 {
+		t.Fatalf("expected every recorded descendant without a filter, got %v", got)
+	}
+}
+
+func TestTelemetryService_ReassemblesExecArgs(t *testing.T) {
+	service := telemetry.NewService(100, 100, nil, nil, nil)
+	ingest := func(raw []byte) *telemetry.Record {
+		t.Helper()
+		record, err := events.DecodeSample(raw)
+		if err != nil {
+			t.Fatalf("decode sample: %v", err)
+		}
+		result, err := service.Ingest(record)
+		if err != nil {
+			t.Fatalf("ingest sample: %v", err)
+		}
+		return result
+	}
+
+	payload := "import os;" + strings.Repeat("x", 3000) + ";os.system('id')"
+	argv := []string{"python3", "-c", payload}
+	env := []string{"PATH=/usr/bin", "HOME=/root"}
+	for _, chunk := range helpers.RawExecArgsSamples(700, 7, "python3", 41, argv, env, events.ArgsChunkLen) {
+		if result := ingest(chunk); result != nil {
+			t.Fatalf("expected argument chunk to be held, got %+v", result.Event)
+		}
+	}
+	exec := helpers.WithArgsSeq(helpers.RawExecSample(700, 1, 7, "python3", "bash", "/usr/bin/python3", "/usr/bin/python3", false), 41)
+	event := ingest(exec).Event
+	if !slices.Equal(event.Args, argv) || !slices.Equal(event.Env, env) || event.ArgsCut {
+		t.Fatalf("expected full argv and env, got args=%d env=%v truncated=%t", len(event.Args), event.Env, event.ArgsCut)
+	}
+	if event.CommandLine != strings.Join(argv, " ") {
+		t.Fatalf("expected command line from argv, got %q", event.CommandLine)
+	}
+
+	// A lost chunk leaves what arrived, marked truncated.
+	chunks := helpers.RawExecArgsSamples(701, 7, "python3", 42, argv, nil, events.ArgsChunkLen)
+	ingest(chunks[0])
+	ingest(chunks[2])
+	exec = helpers.WithArgsSeq(helpers.RawExecSample(701, 1, 7, "python3", "bash", "/usr/bin/python3", "/usr/bin/python3", false), 42)
+	if event := ingest(exec).Event; !event.ArgsCut || len(event.Args) == 0 || event.Args[0] != "python3" {
+		t.Fatalf("expected truncated argv, got %+v", event)
+	}
+
+	// Without argument records the kernel's command line is kept.
+	exec = helpers.RawExecSample(702, 1, 7, "ls", "bash", "/bin/ls", "/bin/ls", false)
+	if event := ingest(exec).Event; event.CommandLine != "/bin/ls" || event.Args != nil || event.ArgsCut {
+		t.Fatalf("expected fallback command line, got %+v", event)
+	}
+}